@@ -112,5 +112,17 @@ func StartConsoleFlags() []cli.Flag {
 			EnvVars: []string{"RE_ENABLE_CERTIFICATES_AUTH"},
 			Value:   false,
 		},
+		&cli.BoolFlag{
+			Name:    "offline",
+			Usage:   "if the console has no internet access, the software catalogue and releases are imported from signed bundles",
+			EnvVars: []string{"OFFLINE_MODE"},
+			Value:   false,
+		},
+		&cli.StringFlag{
+			Name:    "bundle-public-key",
+			Value:   "certificates/bundle.pub",
+			Usage:   "the path to the ed25519 public key in PEM format used to verify offline catalogue bundles",
+			EnvVars: []string{"BUNDLE_PUBLIC_KEY_FILENAME"},
+		},
 	}
 }
@@ -0,0 +1,83 @@
+package commands
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/scncore/scnorion-console/internal/common"
+	"github.com/scncore/utils"
+	"github.com/urfave/cli/v2"
+)
+
+func ImportBundle() *cli.Command {
+	return &cli.Command{
+		Name:   "import-bundle",
+		Usage:  "Import a signed bundle with the software catalogue and releases for consoles without internet access",
+		Action: importBundle,
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  "file",
+				Usage: "the path to the bundle file",
+			},
+			&cli.StringFlag{
+				Name:    "bundle-public-key",
+				Value:   "certificates/bundle.pub",
+				Usage:   "the path to the ed25519 public key in PEM format used to verify the bundle",
+				EnvVars: []string{"BUNDLE_PUBLIC_KEY_FILENAME"},
+			},
+			&cli.BoolFlag{
+				Name:  "rollback",
+				Usage: "restore the catalogue replaced by the last import",
+				Value: false,
+			},
+		},
+	}
+}
+
+func importBundle(cCtx *cli.Context) error {
+	worker := common.NewWorker("")
+	worker.BundlePublicKeyPath = cCtx.String("bundle-public-key")
+
+	// Use the same folders that the start command uses
+	cwd, err := utils.GetWd()
+	if err != nil {
+		log.Fatal("[FATAL]: could not get working directory")
+	}
+
+	tmpDir := filepath.Join(cwd, "tmp")
+	if strings.HasSuffix(cwd, "tmp") {
+		tmpDir = cwd
+	}
+	worker.WinGetDBFolder = filepath.Join(tmpDir, "winget")
+	worker.FlatpakDBFolder = filepath.Join(tmpDir, "flatpak")
+	worker.BrewDBFolder = filepath.Join(tmpDir, "brew")
+	worker.CommonSoftwareDBFolder = filepath.Join(tmpDir, "commondb")
+	worker.ServerReleasesFolder = filepath.Join(tmpDir, "server-releases")
+
+	for _, create := range []func() error{worker.CreateWingetDBDir, worker.CreateFlatpakDBDir, worker.CreateBrewDBDir, worker.CreateCommonSoftwareDBDir, worker.CreateServerReleasesDir} {
+		if err := create(); err != nil {
+			log.Fatalf("[FATAL]: could not create catalogue dir: %v", err)
+		}
+	}
+
+	if cCtx.Bool("rollback") {
+		if err := worker.RollbackCatalogBundle(); err != nil {
+			log.Fatalf("[FATAL]: could not roll back the catalogue: %v", err)
+		}
+		log.Println("[INFO]: the previous catalogue has been restored")
+		return nil
+	}
+
+	if cCtx.String("file") == "" {
+		log.Fatal("[FATAL]: the bundle file is required")
+	}
+
+	manifest, err := worker.ImportCatalogBundle(cCtx.String("file"))
+	if err != nil {
+		log.Fatalf("[FATAL]: could not import the bundle: %v", err)
+	}
+	log.Printf("[INFO]: the bundle created on %s has been imported", manifest.Created.Format("2006-01-02 15:04"))
+
+	return nil
+}
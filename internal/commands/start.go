@@ -91,6 +91,24 @@ func startConsole(cCtx *cli.Context) error {
 		log.Fatalf("[FATAL]: could not create commondb temp dir: %v", err)
 	}
 
+	// Create vulnerabilities directory for NVD and OSV feeds
+	worker.VulnerabilityFeedFolder = filepath.Join(cwd, "tmp", "vulnerabilities")
+	if strings.HasSuffix(cwd, "tmp") {
+		worker.VulnerabilityFeedFolder = filepath.Join(cwd, "vulnerabilities")
+	}
+	if err := worker.CreateVulnerabilityFeedDir(); err != nil {
+		log.Fatalf("[FATAL]: could not create vulnerabilities temp dir: %v", err)
+	}
+
+	// Create private packages directory for uploaded installers, it's not a temp dir
+	worker.PrivatePackagesFolder = filepath.Join(cwd, "packages")
+	if strings.HasSuffix(cwd, "tmp") {
+		worker.PrivatePackagesFolder = filepath.Join(filepath.Dir(cwd), "packages")
+	}
+	if err := worker.CreatePrivatePackagesDir(); err != nil {
+		log.Fatalf("[FATAL]: could not create private packages dir: %v", err)
+	}
+
 	// Save pid to PIDFILE
 	if err := os.WriteFile("PIDFILE", []byte(strconv.Itoa(os.Getpid())), 0666); err != nil {
 		return err
@@ -0,0 +1,25 @@
+package common
+
+import (
+	models "github.com/scncore/scnorion-console/internal/models/winget"
+)
+
+func (w *Worker) catalogFolders() models.CatalogFolders {
+	return models.CatalogFolders{
+		Common:   w.CommonSoftwareDBFolder,
+		Winget:   w.WinGetDBFolder,
+		Flatpak:  w.FlatpakDBFolder,
+		Brew:     w.BrewDBFolder,
+		Releases: w.ServerReleasesFolder,
+	}
+}
+
+// ImportCatalogBundle imports an offline catalogue bundle and refreshes the common software database
+func (w *Worker) ImportCatalogBundle(bundlePath string) (*models.BundleManifest, error) {
+	return models.ImportCatalogBundleFile(bundlePath, w.BundlePublicKeyPath, w.catalogFolders())
+}
+
+// RollbackCatalogBundle restores the catalogue replaced by the last bundle import
+func (w *Worker) RollbackCatalogBundle() error {
+	return models.RollbackCatalog(w.catalogFolders())
+}
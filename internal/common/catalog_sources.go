@@ -0,0 +1,124 @@
+package common
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	models "github.com/scncore/scnorion-console/internal/models/winget"
+)
+
+// catalogSourceRetryInterval is how long we wait to download a source index again if it fails,
+// unless the source asks for a shorter interval. Disabled sources are checked with the same interval
+const catalogSourceRetryInterval = 30 * time.Minute
+
+// StartCatalogSourceJobs downloads the index of every catalogue source and schedules
+// a job to refresh it using the interval that the source needs
+func (w *Worker) StartCatalogSourceJobs() error {
+	// In offline mode the catalogue is imported from bundles
+	if w.OfflineMode {
+		log.Println("[INFO]: offline mode, catalogue sources won't be downloaded")
+		return nil
+	}
+
+	for _, s := range models.CatalogSources(w.catalogFolders()) {
+		if err := os.MkdirAll(w.catalogFolders().SourceFolder(s.Name()), 0770); err != nil {
+			log.Printf("[ERROR]: could not create the %s catalogue source folder, reason: %v", s.Name(), err)
+			continue
+		}
+
+		// Try to download at start
+		duration := s.RefreshInterval()
+		if !w.catalogSourceInUse(s) {
+			log.Printf("[INFO]: %s catalogue source is not enabled, it won't be downloaded", s.Name())
+			duration = catalogSourceRetryInterval
+		} else if err := w.refreshCatalogSource(s); err != nil {
+			log.Printf("[ERROR]: could not get the %s catalogue source, reason: %v", s.Name(), err)
+			duration = catalogSourceRetryInterval
+		} else {
+			log.Printf("[INFO]: %s catalogue source has been downloaded", s.Name())
+		}
+
+		if err := w.startCatalogSourceJob(s, duration); err != nil {
+			log.Printf("[ERROR]: could not start the %s catalogue source job: %v", s.Name(), err)
+			return err
+		}
+		log.Printf("[INFO]: download %s catalogue source job has been scheduled every %s", s.Name(), duration.String())
+	}
+
+	return nil
+}
+
+func (w *Worker) startCatalogSourceJob(s models.CatalogSource, duration time.Duration) error {
+	var job gocron.Job
+	var err error
+
+	job, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(duration),
+		gocron.NewTask(
+			func() {
+				jobDuration := s.RefreshInterval()
+				if !w.catalogSourceInUse(s) {
+					jobDuration = catalogSourceRetryInterval
+				} else if err := w.refreshCatalogSource(s); err != nil {
+					log.Printf("[ERROR]: could not get the %s catalogue source, reason: %v", s.Name(), err)
+					jobDuration = catalogSourceRetryInterval
+					if r, ok := s.(models.CatalogRetrySource); ok {
+						jobDuration = r.RetryInterval()
+					}
+				} else if err := w.refreshCommonSoftwareSource(s); err != nil {
+					log.Printf("[ERROR]: could not refresh %s packages in the common software database, reason: %v", s.Name(), err)
+				}
+
+				if jobDuration.String() == duration.String() {
+					return
+				}
+
+				w.TaskScheduler.RemoveJob(job.ID())
+				if err := w.startCatalogSourceJob(s, jobDuration); err == nil {
+					log.Printf("[INFO]: download %s catalogue source job has been re-scheduled every %s", s.Name(), jobDuration.String())
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[ERROR]: could not schedule %s catalogue source job, reason: %v", s.Name(), err)
+		return err
+	}
+	return nil
+}
+
+// catalogSourceInUse tells if the index of a source must be downloaded, the sources that
+// have no general setting are only downloaded once they're enabled globally or in a tenant
+func (w *Worker) catalogSourceInUse(s models.CatalogSource) bool {
+	if models.IsBuiltinCatalogSource(s.Name()) {
+		return true
+	}
+
+	inUse, err := w.Model.IsCatalogSourceInUse(s.Name())
+	if err != nil {
+		log.Printf("[ERROR]: could not check if the %s catalogue source is enabled, reason: %v", s.Name(), err)
+		return false
+	}
+	return inUse
+}
+
+func (w *Worker) refreshCatalogSource(s models.CatalogSource) error {
+	// If we're in development don't download
+	if os.Getenv("DEVEL") == "true" {
+		return nil
+	}
+
+	return s.Refresh()
+}
+
+func (w *Worker) refreshCommonSoftwareSource(s models.CatalogSource) error {
+	commonDB, err := models.OpenCommonDB(w.CommonSoftwareDBFolder)
+	if err != nil {
+		return err
+	}
+	defer commonDB.Close()
+
+	return models.RefreshCommonSoftwareSource(commonDB, s)
+}
@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"os"
+	"path/filepath"
 	"time"
 
 	"github.com/go-co-op/gocron/v2"
@@ -45,7 +47,7 @@ func (w *Worker) CheckAgentLatestReleases(channel string) error {
 	// Check agent release against our API
 	url := fmt.Sprintf("https://releases.scnorion.eu/api?action=latestAgentRelease&channel=%s", channel)
 
-	body, err := utils.QueryReleasesEndpoint(url)
+	body, err := w.getReleaseInfo(url, "latest-agent.json")
 	if err != nil {
 		return err
 	}
@@ -66,7 +68,7 @@ func (w *Worker) CheckServerLatestReleases(channel string) error {
 	// Check server release against our API
 	url := fmt.Sprintf("https://releases.scnorion.eu/api?action=latestServerRelease&channel=%s", channel)
 
-	body, err := utils.QueryReleasesEndpoint(url)
+	body, err := w.getReleaseInfo(url, "latest.json")
 	if err != nil {
 		return err
 	}
@@ -83,6 +85,14 @@ func (w *Worker) CheckServerLatestReleases(channel string) error {
 	return nil
 }
 
+// getReleaseInfo queries our releases API or, in offline mode, reads the file imported from a catalogue bundle
+func (w *Worker) getReleaseInfo(url, fileName string) ([]byte, error) {
+	if w.OfflineMode {
+		return os.ReadFile(filepath.Join(w.ServerReleasesFolder, fileName))
+	}
+	return utils.QueryReleasesEndpoint(url)
+}
+
 func (w *Worker) StartDownloadLatestAgentReleaseJob(channel string) error {
 	var err error
 	var jobDuration time.Duration
@@ -158,6 +158,21 @@ func (w *Worker) GenerateConsoleConfig() error {
 		}
 	}
 
+	// Offline mode settings are optional, the catalogue is downloaded from internet by default
+	key, err = cfg.Section("Console").GetKey("offline")
+	if err == nil {
+		w.OfflineMode, err = key.Bool()
+		if err != nil {
+			return err
+		}
+	}
+
+	w.BundlePublicKeyPath = "certificates/bundle.pub"
+	key, err = cfg.Section("Certificates").GetKey("BundlePublicKey")
+	if err == nil {
+		w.BundlePublicKeyPath = key.String()
+	}
+
 	key, err = cfg.Section("Server").GetKey("Version")
 	if err != nil {
 		return err
@@ -75,6 +75,41 @@ func (w *Worker) StartDBConnectJob() error {
 		if err := w.StartCheckLatestReleasesJob(channel); err != nil {
 			log.Printf("[ERROR]: could not start check latest releases job, reason: %s", err.Error())
 		}
+
+		// Start a job to keep the history of logical disks usage
+		if err := w.StartLogicalDiskSnapshotJob(); err != nil {
+			log.Printf("[ERROR]: could not start logical disk snapshot job, reason: %s", err.Error())
+		}
+
+		// Start a job to place agents in sites using the assignment rules
+		if err := w.StartSiteRulesJob(); err != nil {
+			log.Printf("[ERROR]: could not start site rules job, reason: %s", err.Error())
+		}
+
+		// Start a job to keep the peripherals register up to date
+		if err := w.StartPeripheralAssetsJob(); err != nil {
+			log.Printf("[ERROR]: could not start peripherals register job, reason: %s", err.Error())
+		}
+
+		// Start a job to keep the history of logged on users
+		if err := w.StartLoggedOnUsersJob(); err != nil {
+			log.Printf("[ERROR]: could not start logged on users job, reason: %s", err.Error())
+		}
+
+		// Start a job to import vulnerability feeds and match them with installed software
+		if err := w.StartVulnerabilityFeedJob(); err != nil {
+			log.Printf("[ERROR]: could not start vulnerability feed job, reason: %s", err.Error())
+		}
+
+		// Start a job to normalize software names and publishers
+		if err := w.StartSoftwareNormalizationJob(); err != nil {
+			log.Printf("[ERROR]: could not start software normalization job, reason: %s", err.Error())
+		}
+
+		// Start a job to look for software policy violations
+		if err := w.StartSoftwarePoliciesJob(); err != nil {
+			log.Printf("[ERROR]: could not start software policies job, reason: %s", err.Error())
+		}
 		return nil
 	}
 	log.Printf("[ERROR]: could not connect with database %v", err)
@@ -150,6 +185,48 @@ func (w *Worker) StartDBConnectJob() error {
 					log.Printf("[ERROR]: could not start check latest releases job, reason: %s", err.Error())
 					return
 				}
+
+				// Start a job to keep the history of logical disks usage
+				if err := w.StartLogicalDiskSnapshotJob(); err != nil {
+					log.Printf("[ERROR]: could not start logical disk snapshot job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to place agents in sites using the assignment rules
+				if err := w.StartSiteRulesJob(); err != nil {
+					log.Printf("[ERROR]: could not start site rules job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to keep the peripherals register up to date
+				if err := w.StartPeripheralAssetsJob(); err != nil {
+					log.Printf("[ERROR]: could not start peripherals register job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to keep the history of logged on users
+				if err := w.StartLoggedOnUsersJob(); err != nil {
+					log.Printf("[ERROR]: could not start logged on users job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to import vulnerability feeds and match them with installed software
+				if err := w.StartVulnerabilityFeedJob(); err != nil {
+					log.Printf("[ERROR]: could not start vulnerability feed job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to normalize software names and publishers
+				if err := w.StartSoftwareNormalizationJob(); err != nil {
+					log.Printf("[ERROR]: could not start software normalization job, reason: %s", err.Error())
+					return
+				}
+
+				// Start a job to look for software policy violations
+				if err := w.StartSoftwarePoliciesJob(); err != nil {
+					log.Printf("[ERROR]: could not start software policies job, reason: %s", err.Error())
+					return
+				}
 			},
 		),
 	)
@@ -189,7 +266,7 @@ func (w *Worker) StartConsoleService() {
 	w.SessionManager = sessions.New(w.DBUrl, sessionLifetimeInMinutes)
 
 	// HTTPS web server
-	w.WebServer = webserver.New(w.Model, w.NATSServers, w.SessionManager, w.TaskScheduler, w.JWTKey, w.ConsoleCertPath, w.ConsolePrivateKeyPath, w.SFTPPrivateKeyPath, w.CACertPath, serverName, consolePort, authPort, w.DownloadDir, w.Domain, w.OrgName, w.OrgProvince, w.OrgLocality, w.OrgAddress, w.Country, w.ReverseProxyAuthPort, w.ReverseProxyServer, w.ServerReleasesFolder, w.WinGetDBFolder, w.FlatpakDBFolder, w.BrewDBFolder, w.CommonSoftwareDBFolder, w.Version, w.ReenableCertAuth)
+	w.WebServer = webserver.New(w.Model, w.NATSServers, w.SessionManager, w.TaskScheduler, w.JWTKey, w.ConsoleCertPath, w.ConsolePrivateKeyPath, w.SFTPPrivateKeyPath, w.CACertPath, serverName, consolePort, authPort, w.DownloadDir, w.Domain, w.OrgName, w.OrgProvince, w.OrgLocality, w.OrgAddress, w.Country, w.ReverseProxyAuthPort, w.ReverseProxyServer, w.ServerReleasesFolder, w.WinGetDBFolder, w.FlatpakDBFolder, w.BrewDBFolder, w.CommonSoftwareDBFolder, w.PrivatePackagesFolder, w.BundlePublicKeyPath, w.VulnerabilityFeedFolder, w.Version, w.ReenableCertAuth, w.OfflineMode)
 	go func() {
 		if err := w.WebServer.Serve(":"+consolePort, w.ConsoleCertPath, w.ConsolePrivateKeyPath); err != http.ErrServerClosed {
 			log.Printf("[ERROR]: the server has stopped, reason: %v", err.Error())
@@ -198,7 +275,7 @@ func (w *Worker) StartConsoleService() {
 	log.Println("[INFO]: console is running")
 
 	// HTTPS auth server
-	w.AuthServer = authserver.New(w.Model, w.SessionManager, w.CACertPath, serverName, consolePort, authPort, w.ReverseProxyAuthPort)
+	w.AuthServer = authserver.New(w.Model, w.SessionManager, w.CACertPath, serverName, consolePort, authPort, w.ReverseProxyAuthPort, w.PrivatePackagesFolder)
 	go func() {
 		if err := w.AuthServer.Serve(":"+authPort, w.ConsoleCertPath, w.ConsolePrivateKeyPath); err != http.ErrServerClosed {
 			log.Printf("[ERROR]: the server has stopped, reason: %v", err.Error())
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+// Days of logical disks history kept in the database
+const LOGICAL_DISK_HISTORY_DAYS = 90
+
+func (w *Worker) StartLogicalDiskSnapshotJob() error {
+	var err error
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(1*time.Hour),
+		),
+		gocron.NewTask(
+			func() {
+				if err := w.Model.SaveLogicalDiskSnapshots(); err != nil {
+					log.Printf("[ERROR]: could not save logical disks snapshots, reason: %v", err)
+					return
+				}
+
+				if _, err := w.Model.DeleteOldLogicalDiskSnapshots(LOGICAL_DISK_HISTORY_DAYS); err != nil {
+					log.Printf("[ERROR]: could not delete old logical disks snapshots, reason: %v", err)
+				}
+			},
+		),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the logical disk snapshot job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: logical disk snapshot job has been scheduled every hour")
+	return nil
+}
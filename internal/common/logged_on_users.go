@@ -0,0 +1,33 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartLoggedOnUsersJob() error {
+	var err error
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(10*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				if err := w.Model.SaveLoggedOnUsers(); err != nil {
+					log.Printf("[ERROR]: could not save logged on users, reason: %v", err)
+				}
+			},
+		),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the logged on users job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: logged on users job has been scheduled every 10 minutes")
+	return nil
+}
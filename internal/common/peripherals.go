@@ -0,0 +1,33 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartPeripheralAssetsJob() error {
+	var err error
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(1*time.Hour),
+		),
+		gocron.NewTask(
+			func() {
+				if err := w.Model.SyncPeripheralAssets(); err != nil {
+					log.Printf("[ERROR]: could not update the peripherals register, reason: %v", err)
+				}
+			},
+		),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the peripherals register job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: peripherals register job has been scheduled every hour")
+	return nil
+}
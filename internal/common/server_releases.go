@@ -47,6 +47,12 @@ func (w *Worker) GetServerReleases() error {
 }
 
 func (w *Worker) StartServerReleasesDownloadJob() error {
+	// In offline mode the catalogue is imported from bundles
+	if w.OfflineMode {
+		log.Println("[INFO]: offline mode, server releases won't be downloaded")
+		return nil
+	}
+
 	// Try to download server releases at start
 	if err := w.GetServerReleases(); err != nil {
 		log.Printf("[ERROR]: could not get server releases, reason: %v", err)
@@ -0,0 +1,42 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartSiteRulesJob() error {
+	var err error
+
+	// The first run checks every agent, the next ones only the agents that have reported since the previous run
+	since := time.Time{}
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(1*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				start := time.Now()
+				moved, err := w.Model.ApplySiteRulesToReportedAgents(since)
+				if err != nil {
+					log.Printf("[ERROR]: could not apply site assignment rules, reason: %v", err)
+					return
+				}
+				since = start
+				if moved > 0 {
+					log.Printf("[INFO]: %d agents have been moved to a new site by the site assignment rules", moved)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the site rules job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: site rules job has been scheduled every minute")
+	return nil
+}
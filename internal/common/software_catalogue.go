@@ -0,0 +1,33 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartSoftwareNormalizationJob() error {
+	var err error
+
+	// Create task, agents replace their applications when they report so they're normalized regularly
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(30*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				if _, err := w.Model.NormalizeApps(); err != nil {
+					log.Printf("[ERROR]: could not normalize software names, reason: %v", err)
+				}
+			},
+		),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the software normalization job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: software normalization job has been scheduled every 30 minutes")
+	return nil
+}
@@ -4,6 +4,7 @@ import (
 	"log"
 
 	"github.com/scncore/nats"
+	scnorion_models "github.com/scncore/scnorion-console/internal/models"
 	models "github.com/scncore/scnorion-console/internal/models/winget"
 )
 
@@ -26,84 +27,27 @@ func (w *Worker) StartCommonPackagesDBJob() error {
 		return err
 	}
 
-	flatpakDB, err := models.OpenFlatpakDB(w.FlatpakDBFolder)
-	if err != nil {
-		log.Println("[INFO]: could not get flatpak database")
-	} else {
-		rows, err := flatpakDB.Query(`SELECT DISTINCT id, name FROM apps`)
-		if err != nil {
-			log.Println("[INFO]: could not query flatpak apps")
-		} else {
-			packages := []nats.SoftwarePackage{}
-			defer rows.Close()
-			for rows.Next() {
-				var p nats.SoftwarePackage
-				err := rows.Scan(&p.ID, &p.Name)
-				if err != nil {
-					log.Printf("[ERROR]: could not get flatpak db row, reason: %v", err)
-					break
-				}
-				packages = append(packages, p)
-			}
-
-			if err := models.InsertCommonSoftware(commonDB, packages, "flatpak"); err != nil {
-				log.Printf("[ERROR]: could not insert flatpak apps to common software database, reason: %v", err)
-			}
-		}
-	}
-
-	brewDB, err := models.OpenBrewDB(w.BrewDBFolder)
-	if err != nil {
-		log.Println("[INFO]: could not get brew database")
-	} else {
-		rows, err := brewDB.Query(`SELECT DISTINCT id, name FROM apps`)
-		if err != nil {
-			log.Println("[INFO]: could not query brew apps")
-		} else {
-			packages := []nats.SoftwarePackage{}
-			defer rows.Close()
-			for rows.Next() {
-				var p nats.SoftwarePackage
-				err := rows.Scan(&p.ID, &p.Name)
-				if err != nil {
-					log.Printf("[ERROR]: could not get brew db row, reason: %v", err)
-					break
-				}
-				packages = append(packages, p)
-			}
-
-			if err := models.InsertCommonSoftware(commonDB, packages, "brew"); err != nil {
-				log.Printf("[ERROR]: could not insert brew apps to common software database, reason: %v", err)
-			}
-		}
+	if err := models.RefreshCommonSoftware(commonDB, w.catalogFolders()); err != nil {
+		log.Printf("[ERROR]: %v", err)
+		return err
 	}
 
-	wingetDB, err := models.OpenWingetDB(w.WinGetDBFolder)
-	if err != nil {
-		log.Println("[INFO]: could not get winget database")
-	} else {
-		// Old source.msix database information fix-68
-		// rows, err := wingetDB.Query(`SELECT DISTINCT ids.id as id, names.name AS name FROM manifest LEFT JOIN ids ON manifest.id = ids.rowid LEFT JOIN names ON manifest.name = names.rowid`)
-		rows, err := wingetDB.Query(`SELECT DISTINCT id, name FROM packages`)
-
+	// Packages uploaded to the private repository are stored in the console database
+	if w.Model != nil {
+		privatePackages, err := w.Model.GetPrivatePackages()
 		if err != nil {
-			log.Println("[INFO]: could not query winget apps")
+			log.Printf("[ERROR]: could not get private packages, reason: %v", err)
 		} else {
 			packages := []nats.SoftwarePackage{}
-			defer rows.Close()
-			for rows.Next() {
-				var p nats.SoftwarePackage
-				err := rows.Scan(&p.ID, &p.Name)
-				if err != nil {
-					log.Printf("[ERROR]: could not get winget db row, reason: %v", err)
-					break
-				}
-				packages = append(packages, p)
+			details := map[string]models.CatalogPackageDetails{}
+			for _, p := range privatePackages {
+				id := scnorion_models.PrivatePackageID(p.ID)
+				packages = append(packages, nats.SoftwarePackage{ID: id, Name: p.Name})
+				details[id] = models.CatalogPackageDetails{Publisher: p.Publisher}
 			}
 
-			if err := models.InsertCommonSoftware(commonDB, packages, "winget"); err != nil {
-				log.Printf("[ERROR]: could not insert winget apps to common software database, reason: %v", err)
-				return err
+			if err := models.InsertCommonSoftwareDetails(commonDB, packages, "private", details); err != nil {
+				log.Printf("[ERROR]: could not insert private packages to common software database, reason: %v", err)
 			}
 		}
 	}
@@ -0,0 +1,32 @@
+package common
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartSoftwarePoliciesJob() error {
+	var err error
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(15*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				if err := w.Model.EvaluateAllSoftwarePolicies(); err != nil {
+					log.Printf("[ERROR]: could not evaluate software policies, reason: %v", err)
+				}
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the software policies job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: software policies job has been scheduled every 15 minutes")
+	return nil
+}
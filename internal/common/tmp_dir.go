@@ -63,3 +63,23 @@ func (w *Worker) CreateServerReleasesDir() error {
 
 	return nil
 }
+
+func (w *Worker) CreateVulnerabilityFeedDir() error {
+	if _, err := os.Stat(w.VulnerabilityFeedFolder); os.IsNotExist(err) {
+		if err := os.MkdirAll(w.VulnerabilityFeedFolder, 0770); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Worker) CreatePrivatePackagesDir() error {
+	if _, err := os.Stat(w.PrivatePackagesFolder); os.IsNotExist(err) {
+		if err := os.MkdirAll(w.PrivatePackagesFolder, 0770); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,55 @@
+package common
+
+import (
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+)
+
+func (w *Worker) StartVulnerabilityFeedJob() error {
+	var err error
+
+	// Create task
+	_, err = w.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(60*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				w.ImportVulnerabilityFeeds()
+			},
+		),
+		gocron.WithStartAt(gocron.WithStartImmediately()),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the vulnerability feed job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: vulnerability feed job has been scheduled every 60 minutes")
+	return nil
+}
+
+// ImportVulnerabilityFeeds imports the JSON feeds found in the vulnerabilities folder, renaming them
+// so they're not imported again, and matches the installed software with the known vulnerabilities
+func (w *Worker) ImportVulnerabilityFeeds() {
+	files, err := filepath.Glob(filepath.Join(w.VulnerabilityFeedFolder, "*.json"))
+	if err != nil {
+		log.Printf("[ERROR]: could not read vulnerability feeds folder, reason: %v", err)
+		return
+	}
+
+	for _, file := range files {
+		n, err := w.Model.ImportVulnerabilityFeedFile(file)
+		if err != nil {
+			log.Printf("[ERROR]: could not import vulnerability feed %s, reason: %v", file, err)
+			continue
+		}
+		log.Printf("[INFO]: %d vulnerabilities have been imported from %s", n, file)
+	}
+
+	if _, err := w.Model.MatchVulnerabilities(); err != nil {
+		log.Printf("[ERROR]: could not match vulnerabilities with installed software, reason: %v", err)
+	}
+}
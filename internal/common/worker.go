@@ -37,6 +37,9 @@ type Worker struct {
 	FlatpakDBFolder                   string
 	BrewDBFolder                      string
 	CommonSoftwareDBFolder            string
+	VulnerabilityFeedFolder           string
+	PrivatePackagesFolder             string
+	BundlePublicKeyPath               string
 	OrgName                           string
 	OrgProvince                       string
 	OrgLocality                       string
@@ -45,20 +48,15 @@ type Worker struct {
 	ReverseProxyAuthPort              string
 	ReverseProxyServer                string
 	ServerReleasesFolder              string
-	DownloadWingetDBJob               gocron.Job
-	DownloadWingetJobDuration         time.Duration
 	DownloadServerReleasesJob         gocron.Job
 	DownloadServerReleasesJobDuration time.Duration
 	DownloadLatestReleaseJob          gocron.Job
 	DownloadLatestReleaseJobDuration  time.Duration
-	DownloadFlatpakDBJob              gocron.Job
-	DownloadFlatpakJobDuration        time.Duration
-	DownloadBrewDBJob                 gocron.Job
-	DownloadBrewJobDuration           time.Duration
 	CommonSoftwareDBJob               gocron.Job
 	CommonSoftwareJobDuration         time.Duration
 	Version                           string
 	ReenableCertAuth                  bool
+	OfflineMode                       bool
 }
 
 func NewWorker(logName string) *Worker {
@@ -83,21 +81,9 @@ func (w *Worker) StartWorker() {
 		return
 	}
 
-	// Start a job to download Microsoft Winget database
-	if err := w.StartWinGetDBDownloadJob(); err != nil {
-		log.Printf("[ERROR]: could not start index.db download job, reason: %s", err.Error())
-		return
-	}
-
-	// Start a job to download Flatpak database
-	if err := w.StartFlatpakDBDownloadJob(); err != nil {
-		log.Printf("[ERROR]: could not start flatpak.db download job, reason: %s", err.Error())
-		return
-	}
-
-	// Start a job to download Brew database
-	if err := w.StartBrewDBDownloadJob(); err != nil {
-		log.Printf("[ERROR]: could not start brew.db download job, reason: %s", err.Error())
+	// Start a job for every catalogue source (winget, flatpak, brew...) to download its index
+	if err := w.StartCatalogSourceJobs(); err != nil {
+		log.Printf("[ERROR]: could not start catalogue source jobs, reason: %s", err.Error())
 		return
 	}
 
@@ -17,36 +17,9 @@ import (
 )
 
 func (h *Handler) Auth(c echo.Context) error {
-	var err error
-	var cert *x509.Certificate
-	certs := c.Request().TLS.PeerCertificates
-
-	if len(certs) != 1 {
-		clientEncodedCert := c.Request().Header.Get("Client-Cert")
-		if clientEncodedCert != "" {
-			cleanBase64 := ""
-			if strings.Contains(clientEncodedCert, "BEGIN CERTIFICATE") {
-				// NGINX
-				cleanBase64 = strings.TrimPrefix(clientEncodedCert, ":-----BEGIN CERTIFICATE----- ")
-				cleanBase64 = strings.TrimSuffix(cleanBase64, " -----END CERTIFICATE-----:")
-				cleanBase64 = strings.ReplaceAll(cleanBase64, " ", "")
-			} else {
-				// Caddy
-				cleanBase64 = strings.Trim(clientEncodedCert, ":")
-			}
-			decoded, err := base64.StdEncoding.DecodeString(cleanBase64)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "The certificate could not be decoded")
-			}
-			cert, err = x509.ParseCertificate(decoded)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusInternalServerError, "Could not parse client certificate")
-			}
-		} else {
-			return echo.NewHTTPError(http.StatusUnauthorized, "Please provide valid credentials")
-		}
-	} else {
-		cert = certs[0]
+	cert, err := getClientCertificate(c)
+	if err != nil {
+		return err
 	}
 
 	caCert := h.CACert
@@ -56,58 +29,8 @@ func (h *Handler) Auth(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Wrong certificate")
 	}
 
-	if len(cert.OCSPServer) == 0 {
-		return echo.NewHTTPError(http.StatusUnauthorized, "No OCSP responders found in certificate")
-	}
-	ocspServer := cert.OCSPServer[0]
-
-	// Verify cert
-	ocspURL, err := url.Parse(ocspServer)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not parse OCSP Responder URL")
-	}
-
-	issuer, err := getIssuerFromCert(cert, caCert)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Certificate did not pass verification")
-	}
-
-	ocspRequest, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create OCSP Request")
-	}
-
-	httpRequest, err := http.NewRequest(http.MethodPost, ocspServer, bytes.NewBuffer(ocspRequest))
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create request to OCSP Responder")
-	}
-
-	httpRequest.Header.Add("Content-Type", "application/ocsp-request")
-	httpRequest.Header.Add("Accept", "application/ocsp-response")
-	httpRequest.Header.Add("host", ocspURL.Host)
-
-	httpClient := &http.Client{}
-	httpResponse, err := httpClient.Do(httpRequest)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not send request to OCSP Responder")
-	}
-	defer httpResponse.Body.Close()
-	output, err := io.ReadAll(httpResponse.Body)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not read response from OCSP Responder")
-	}
-
-	ocspResponse, err := ocsp.ParseResponse(output, issuer)
-	if err != nil {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not parse OCSP Response")
-	}
-
-	if ocspResponse.Status == 2 {
-		return echo.NewHTTPError(http.StatusInternalServerError, "Could not check OCSP status, try again later")
-	}
-
-	if ocspResponse.Status == 1 {
-		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized. Your certificate has been revoked")
+	if err := verifyClientCertificate(cert, caCert); err != nil {
+		return err
 	}
 
 	// Check if uid exists in database
@@ -167,6 +90,66 @@ func (h *Handler) Auth(c echo.Context) error {
 	}
 }
 
+// verifyClientCertificate checks that the certificate has been issued by our CA
+// and asks the OCSP responder if it has been revoked
+func verifyClientCertificate(cert, caCert *x509.Certificate) error {
+	if len(cert.OCSPServer) == 0 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "No OCSP responders found in certificate")
+	}
+	ocspServer := cert.OCSPServer[0]
+
+	// Verify cert
+	ocspURL, err := url.Parse(ocspServer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not parse OCSP Responder URL")
+	}
+
+	issuer, err := getIssuerFromCert(cert, caCert)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Certificate did not pass verification")
+	}
+
+	ocspRequest, err := ocsp.CreateRequest(cert, issuer, &ocsp.RequestOptions{Hash: crypto.SHA256})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create OCSP Request")
+	}
+
+	httpRequest, err := http.NewRequest(http.MethodPost, ocspServer, bytes.NewBuffer(ocspRequest))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not create request to OCSP Responder")
+	}
+
+	httpRequest.Header.Add("Content-Type", "application/ocsp-request")
+	httpRequest.Header.Add("Accept", "application/ocsp-response")
+	httpRequest.Header.Add("host", ocspURL.Host)
+
+	httpClient := &http.Client{}
+	httpResponse, err := httpClient.Do(httpRequest)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not send request to OCSP Responder")
+	}
+	defer httpResponse.Body.Close()
+	output, err := io.ReadAll(httpResponse.Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not read response from OCSP Responder")
+	}
+
+	ocspResponse, err := ocsp.ParseResponse(output, issuer)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not parse OCSP Response")
+	}
+
+	if ocspResponse.Status == 2 {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not check OCSP status, try again later")
+	}
+
+	if ocspResponse.Status == 1 {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Unauthorized. Your certificate has been revoked")
+	}
+
+	return nil
+}
+
 func getIssuerFromCert(cert, caCert *x509.Certificate) (*x509.Certificate, error) {
 
 	// Check if current certificate is valid for client auth and is issued by our CA
@@ -184,3 +167,39 @@ func getIssuerFromCert(cert, caCert *x509.Certificate) (*x509.Certificate, error
 		return chains[0][1], err
 	}
 }
+
+// getClientCertificate reads the certificate sent by the client or forwarded by the reverse proxy
+func getClientCertificate(c echo.Context) (*x509.Certificate, error) {
+	var cert *x509.Certificate
+	certs := c.Request().TLS.PeerCertificates
+
+	if len(certs) != 1 {
+		clientEncodedCert := c.Request().Header.Get("Client-Cert")
+		if clientEncodedCert != "" {
+			cleanBase64 := ""
+			if strings.Contains(clientEncodedCert, "BEGIN CERTIFICATE") {
+				// NGINX
+				cleanBase64 = strings.TrimPrefix(clientEncodedCert, ":-----BEGIN CERTIFICATE----- ")
+				cleanBase64 = strings.TrimSuffix(cleanBase64, " -----END CERTIFICATE-----:")
+				cleanBase64 = strings.ReplaceAll(cleanBase64, " ", "")
+			} else {
+				// Caddy
+				cleanBase64 = strings.Trim(clientEncodedCert, ":")
+			}
+			decoded, err := base64.StdEncoding.DecodeString(cleanBase64)
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusInternalServerError, "The certificate could not be decoded")
+			}
+			cert, err = x509.ParseCertificate(decoded)
+			if err != nil {
+				return nil, echo.NewHTTPError(http.StatusInternalServerError, "Could not parse client certificate")
+			}
+		} else {
+			return nil, echo.NewHTTPError(http.StatusUnauthorized, "Please provide valid credentials")
+		}
+	} else {
+		cert = certs[0]
+	}
+
+	return cert, nil
+}
@@ -14,9 +14,10 @@ type Handler struct {
 	ServerName           string
 	ConsolePort          string
 	ReverseProxyAuthPort string
+	PackagesFolder       string
 }
 
-func NewHandler(model *models.Model, sm *sessions.SessionManager, cert *x509.Certificate, server, consolePort, reverseProxyAuthPort string) *Handler {
+func NewHandler(model *models.Model, sm *sessions.SessionManager, cert *x509.Certificate, server, consolePort, reverseProxyAuthPort, packagesFolder string) *Handler {
 	return &Handler{
 		Model:                model,
 		SessionManager:       sm,
@@ -24,5 +25,6 @@ func NewHandler(model *models.Model, sm *sessions.SessionManager, cert *x509.Cer
 		ServerName:           server,
 		ConsolePort:          consolePort,
 		ReverseProxyAuthPort: reverseProxyAuthPort,
+		PackagesFolder:       packagesFolder,
 	}
 }
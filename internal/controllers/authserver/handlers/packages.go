@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/ent"
+	"github.com/scncore/scnorion-console/internal/models"
+)
+
+// DownloadPrivatePackage sends an installer from the private repository to agents
+// presenting a client certificate issued by our CA that hasn't been revoked
+func (h *Handler) DownloadPrivatePackage(c echo.Context) error {
+	cert, err := getClientCertificate(c)
+	if err != nil {
+		return err
+	}
+
+	if cert.Subject.CommonName == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Wrong certificate")
+	}
+
+	if err := verifyClientCertificate(cert, h.CACert); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wrong package id")
+	}
+
+	p, err := h.Model.GetPrivatePackage(id)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return echo.NewHTTPError(http.StatusNotFound, "Package not found")
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not get package")
+	}
+
+	path := filepath.Join(h.PackagesFolder, models.PrivatePackageFile(p))
+	if _, err := os.Stat(path); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Package file not found")
+	}
+
+	c.Response().Header().Set("X-Checksum-SHA256", p.Checksum)
+	return c.Attachment(path, p.FileName)
+}
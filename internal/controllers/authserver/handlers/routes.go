@@ -6,4 +6,6 @@ import (
 
 func (h *Handler) Register(e *echo.Echo) {
 	e.GET("/auth", h.Auth)
+	e.GET("/packages/:id", h.DownloadPrivatePackage)
+	e.POST("/usage", h.ReportAppUsage)
 }
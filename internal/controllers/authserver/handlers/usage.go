@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+)
+
+// ReportAppUsage receives the applications run in a computer from agents presenting
+// a client certificate issued by our CA that hasn't been revoked, an agent can only report
+// its own usage which is added to the one we already know
+func (h *Handler) ReportAppUsage(c echo.Context) error {
+	cert, err := getClientCertificate(c)
+	if err != nil {
+		return err
+	}
+
+	if cert.Subject.CommonName == "" {
+		return echo.NewHTTPError(http.StatusUnauthorized, "Certificate did not pass verification")
+	}
+
+	if err := verifyClientCertificate(cert, h.CACert); err != nil {
+		return err
+	}
+
+	report := models.AppUsageReport{}
+	if err := c.Bind(&report); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Wrong usage report")
+	}
+
+	if report.AgentID == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "Agent id is required")
+	}
+
+	if report.AgentID != cert.Subject.CommonName {
+		return echo.NewHTTPError(http.StatusForbidden, "Agents can only report their own usage")
+	}
+
+	if err := h.Model.SaveAppUsage(report); err != nil {
+		log.Printf("[ERROR]: could not save usage report from agent %s, reason: %v", report.AgentID, err)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Could not save usage report")
+	}
+
+	return c.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,260 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/agents_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) PendingAgentCommands(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "created"
+		p.SortOrder = "desc"
+	}
+
+	commands, err := h.Model.GetPendingAgentCommands(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agent_commands.could_not_get", err.Error()), false))
+	}
+
+	p.NItems, err = h.Model.CountPendingAgentCommands(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agent_commands.could_not_get", err.Error()), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, agents_views.AgentsIndex("| Agents", agents_views.PendingAgentCommands(c, p, commands, refreshTime, commonInfo), commonInfo))
+}
+
+// PublishAgentCommand sends a command through the agents stream so it waits for the agent until
+// it's acknowledged or its TTL expires, the command is saved to show it while it's pending
+func (h *Handler) PublishAgentCommand(agentId, kind, subject, description string, data []byte) error {
+	ttl := models.GetAgentCommandTTL(kind)
+
+	sequence, err := h.publishToAgentStream(subject, data, ttl, "")
+	if err != nil {
+		return err
+	}
+
+	if err := h.Model.SaveAgentCommand(agentId, kind, subject, description, sequence, ttl); err != nil {
+		log.Printf("[ERROR]: could not save the %s command sent to agent %s, reason: %v", kind, agentId, err)
+	}
+
+	return nil
+}
+
+// PublishAgentsCommand sends a command addressed to every agent through the agents stream,
+// it isn't saved as a pending command as it doesn't wait for a specific agent
+func (h *Handler) PublishAgentsCommand(kind, subject string, data []byte) error {
+	_, err := h.publishToAgentStream(subject, data, models.GetAgentCommandTTL(kind), "")
+	return err
+}
+
+// publishToAgentStream publishes a command with its TTL, if a message id is passed the stream
+// discards the command if a message with the same id was published within its duplicates window
+func (h *Handler) publishToAgentStream(subject string, data []byte, ttl time.Duration, msgID string) (uint64, error) {
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() || h.JetStream == nil {
+		return 0, errors.New("NATS is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.NATSTimeout)*time.Second)
+	defer cancel()
+
+	// Servers without per message TTL keep the command until CheckAgentCommands deletes it
+	opts := []jetstream.PublishOpt{}
+	if h.supportsMsgTTL() {
+		opts = append(opts, jetstream.WithMsgTTL(ttl))
+	}
+	if msgID != "" {
+		opts = append(opts, jetstream.WithMsgID(msgID))
+	}
+
+	ack, err := h.JetStream.Publish(ctx, subject, data, opts...)
+	if err != nil {
+		return 0, err
+	}
+
+	return ack.Sequence, nil
+}
+
+// supportsMsgTTL reports if the NATS server we're connected to accepts a TTL
+// per message, it was added in NATS 2.11
+func (h *Handler) supportsMsgTTL() bool {
+	if h.NATSConnection == nil {
+		return false
+	}
+	version := h.NATSConnection.ConnectedServerVersion()
+	return version != "" && models.CompareVersions(version, "2.11.0") >= 0
+}
+
+// RequestOrQueueAgentCommand asks the agent to run the action right away, if no agent is listening
+// because it's off the action is queued in the agents stream until the agent comes back. If the request
+// times out the agent got it so it's not queued again, an agent powering off may not answer
+func (h *Handler) RequestOrQueueAgentCommand(agentId, kind string, data []byte) (*nats.Msg, bool, error) {
+	msg, err := h.NATSConnection.Request("agent."+kind+"."+agentId, data, time.Duration(h.NATSTimeout)*time.Second)
+	if err == nil {
+		return msg, false, nil
+	}
+
+	if !errors.Is(err, nats.ErrNoResponders) {
+		return nil, false, err
+	}
+
+	if err := h.PublishAgentCommand(agentId, kind, "agent.queued."+kind+"."+agentId, "", data); err != nil {
+		return nil, false, err
+	}
+
+	return nil, true, nil
+}
+
+func (h *Handler) StartAgentCommandsJob() error {
+	var err error
+
+	// Create task
+	_, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(5*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				h.CheckAgentCommands()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the agent commands job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: agent commands job has been scheduled every 5 minutes")
+	return nil
+}
+
+// CheckAgentCommands looks for the pending commands in the agents stream. The stream keeps a command
+// until its TTL expires, it's delivered once a consumer of the agent acknowledges it
+func (h *Handler) CheckAgentCommands() {
+	// Delivered commands are only useful while they're recent
+	if err := h.Model.DeleteOldAgentCommands(time.Now().AddDate(0, 0, -30)); err != nil {
+		log.Printf("[ERROR]: could not delete old agent commands, reason: %v", err)
+	}
+
+	if h.AgentStream != nil {
+		h.checkAgentStreamCommands()
+	}
+
+	if err := h.Model.ExpireAgentCommands(); err != nil {
+		log.Printf("[ERROR]: could not expire agent commands, reason: %v", err)
+	}
+}
+
+func (h *Handler) checkAgentStreamCommands() {
+	commands, err := h.Model.GetAllPendingAgentCommands()
+	if err != nil {
+		log.Printf("[ERROR]: could not get pending agent commands, reason: %v", err)
+		return
+	}
+
+	if len(commands) == 0 {
+		return
+	}
+
+	consumers, err := h.agentStreamConsumers()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the consumers of the agents stream, reason: %v", err)
+		return
+	}
+
+	for _, cmd := range commands {
+		acknowledged := agentCommandAcknowledged(cmd.Subject, cmd.Sequence, consumers)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.NATSTimeout)*time.Second)
+		_, err := h.AgentStream.GetMsg(ctx, cmd.Sequence)
+		cancel()
+
+		if err == nil {
+			// Acknowledged commands are removed so an agent that creates its consumer again doesn't run them twice,
+			// without per message TTL expired commands must be removed from the stream too
+			remove := acknowledged || (!h.supportsMsgTTL() && !time.Now().Before(cmd.Expires))
+			if remove {
+				ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.NATSTimeout)*time.Second)
+				err := h.AgentStream.DeleteMsg(ctx, cmd.Sequence)
+				cancel()
+				if err != nil && !errors.Is(err, jetstream.ErrMsgNotFound) {
+					log.Printf("[ERROR]: could not delete agent command %d, reason: %v", cmd.ID, err)
+				}
+			}
+
+			if acknowledged {
+				if err := h.Model.SetAgentCommandDelivered(cmd, true); err != nil {
+					log.Printf("[ERROR]: could not update agent command %d, reason: %v", cmd.ID, err)
+				}
+			}
+			continue
+		}
+
+		if !errors.Is(err, jetstream.ErrMsgNotFound) {
+			log.Printf("[ERROR]: could not check agent command %d, reason: %v", cmd.ID, err)
+			return
+		}
+
+		if err := h.Model.SetAgentCommandDelivered(cmd, acknowledged); err != nil {
+			log.Printf("[ERROR]: could not update agent command %d, reason: %v", cmd.ID, err)
+		}
+	}
+}
+
+func (h *Handler) agentStreamConsumers() ([]*jetstream.ConsumerInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(h.NATSTimeout)*time.Second)
+	defer cancel()
+
+	consumers := []*jetstream.ConsumerInfo{}
+	lister := h.AgentStream.ListConsumers(ctx)
+	for info := range lister.Info() {
+		consumers = append(consumers, info)
+	}
+	return consumers, lister.Err()
+}
+
+// agentCommandAcknowledged reports if a consumer interested in the subject of a command
+// has acknowledged every message up to the sequence of the command
+func agentCommandAcknowledged(subject string, sequence uint64, consumers []*jetstream.ConsumerInfo) bool {
+	for _, c := range consumers {
+		filters := append([]string{}, c.Config.FilterSubjects...)
+		if c.Config.FilterSubject != "" {
+			filters = append(filters, c.Config.FilterSubject)
+		}
+		if len(filters) == 0 {
+			filters = []string{">"}
+		}
+
+		for _, filter := range filters {
+			if models.AgentSubjectMatches(filter, subject) && c.AckFloor.Stream >= sequence {
+				return true
+			}
+		}
+	}
+	return false
+}
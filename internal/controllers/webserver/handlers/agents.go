@@ -691,8 +691,13 @@ func (h *Handler) AgentForceRestart(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.restart."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.no_responder"), false))
+		_, queued, err := h.RequestOrQueueAgentCommand(agentId, "restart", nil)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), false))
+		}
+
+		if queued {
+			return h.ListAgents(c, i18n.T(c.Request().Context(), "agent_commands.restart_queued"), "", false)
 		}
 	}
 
@@ -914,7 +919,7 @@ func (h *Handler) AgentSettings(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 		}
 
-		err = h.NATSConnection.Publish("agent.settings."+agentId, data)
+		err = h.PublishAgentCommand(agentId, "settings", "agent.settings."+agentId, "", data)
 		if err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_nats_error", err.Error()), true))
 		}
@@ -935,7 +940,7 @@ func (h *Handler) AgentSettings(c echo.Context) error {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_data_error"), true))
 			}
 
-			err = h.NATSConnection.Publish("agent.settings."+agentId, data)
+			err = h.PublishAgentCommand(agentId, "settings", "agent.settings."+agentId, "", data)
 			if err != nil {
 				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.settings_nats_error", err.Error()), true))
 			}
@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/scncore/scnorion-console/internal/views/software_views"
+)
+
+func (h *Handler) AppUsage(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "unused"
+		p.SortOrder = "desc"
+	}
+
+	search := c.FormValue("filterByAppName")
+
+	days, err := getUnusedAppDays(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.invalid_days"), false))
+	}
+
+	unused, total, err := h.Model.GetUnusedApps(p, search, days, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.could_not_get", err.Error()), false))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, software_views.SoftwareIndex(" | Software", software_views.AppUsage(c, p, search, days, unused, successMessage, refreshTime, commonInfo), commonInfo))
+}
+
+// ReclaimUnusedApp sends an uninstall action to every computer where the application hasn't been run
+// for the days selected, the package to be removed is the one found in the catalogue for the computer's operating system
+func (h *Handler) ReclaimUnusedApp(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	appName := c.FormValue("appName")
+	if appName == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.app_required"), false))
+	}
+
+	days, err := getUnusedAppDays(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.invalid_days"), false))
+	}
+
+	installs, err := h.Model.GetUnusedAppInstalls(appName, days, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.could_not_get", err.Error()), false))
+	}
+	if len(installs) == 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.app_not_found", appName), false))
+	}
+
+	if err := h.checkDirectDeployment(c, commonInfo, "uninstall "+appName); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	_, packages, err := h.getCatalogVersions(appName, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.could_not_get", err.Error()), false))
+	}
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
+	}
+
+	reclaimed := 0
+	skipped := 0
+	for _, i := range installs {
+		pkg, ok := catalogPackageForOS(packages[strings.ToLower(appName)], i.OS)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		action := scnorion_nats.DeployAction{}
+		action.AgentId = i.AgentID
+		action.PackageId = pkg.ID
+		action.PackageName = pkg.Name
+		action.Action = "uninstall"
+
+		data, err := json.Marshal(models.CatalogDeployAction{DeployAction: action, Source: pkg.Source})
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		if err := h.PublishAgentCommand(i.AgentID, "uninstall", "agent.uninstallpackage."+i.AgentID, pkg.Name, data); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		deploymentFailed, err := h.Model.DeploymentFailed(i.AgentID, pkg.ID, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		if err := h.Model.SaveDeployInfo(&action, deploymentFailed, commonInfo); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		reclaimed++
+	}
+
+	c.Request().Method = "GET"
+	return h.AppUsage(c, i18n.T(c.Request().Context(), "app_usage.reclaim_sent", reclaimed, skipped))
+}
+
+func getUnusedAppDays(c echo.Context) (int, error) {
+	value := c.FormValue("filterByDays")
+	if value == "" {
+		return models.DefaultUnusedAppDays, nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, err
+	}
+	if days < 1 {
+		return 0, fmt.Errorf("the number of days must be greater than 0")
+	}
+	return days, nil
+}
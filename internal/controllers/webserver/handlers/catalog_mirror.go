@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) CatalogMirror(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	folders := h.catalogFolders()
+
+	bundle, err := winget.GetCatalogBundle(folders)
+	if err != nil {
+		log.Printf("[ERROR]: could not read the imported bundle information, reason: %v", err)
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	data := admin_views.CatalogMirrorData{
+		OfflineMode:     h.OfflineMode,
+		Updated:         winget.CatalogUpdated(folders),
+		Bundle:          bundle,
+		PreviousCatalog: winget.HasPreviousCatalog(folders),
+	}
+
+	return RenderView(c, admin_views.CatalogMirrorIndex(" | Offline mirror", admin_views.CatalogMirror(c, data, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+func (h *Handler) UploadCatalogBundle(c echo.Context) error {
+	manifest, err := h.ImportCatalogBundle(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "catalog_mirror.could_not_import", err.Error()), false))
+	}
+
+	return h.CatalogMirror(c, i18n.T(c.Request().Context(), "catalog_mirror.imported", manifest.Created.Local().Format("2006-01-02 15:04")))
+}
+
+// ImportCatalogBundle imports the uploaded bundle, its signature and checksums are verified before
+// replacing the winget, flatpak and brew databases and the release information
+func (h *Handler) ImportCatalogBundle(c echo.Context) (*winget.BundleManifest, error) {
+	file, err := c.FormFile("bundleFile")
+	if err != nil {
+		return nil, errors.New(i18n.T(c.Request().Context(), "catalog_mirror.no_file"))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(h.DownloadDir, "bundle-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		tmp.Close()
+		if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR]: could not remove temp bundle file, reason: %v", err)
+		}
+	}()
+
+	if _, err := io.Copy(tmp, src); err != nil {
+		return nil, err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return nil, err
+	}
+
+	return winget.ImportCatalogBundleFile(tmp.Name(), h.BundlePublicKeyPath, h.catalogFolders())
+}
+
+func (h *Handler) RollbackCatalogBundle(c echo.Context) error {
+	if err := winget.RollbackCatalog(h.catalogFolders()); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "catalog_mirror.could_not_rollback", err.Error()), false))
+	}
+
+	return h.CatalogMirror(c, i18n.T(c.Request().Context(), "catalog_mirror.rolled_back"))
+}
+
+func (h *Handler) catalogFolders() winget.CatalogFolders {
+	return winget.CatalogFolders{
+		Common:   h.CommonFolder,
+		Winget:   h.WingetFolder,
+		Flatpak:  h.FlatpakFolder,
+		Brew:     h.BrewFolder,
+		Releases: h.ServerReleasesFolder,
+	}
+}
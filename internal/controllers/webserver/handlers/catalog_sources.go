@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+)
+
+// enabledCatalogSources returns the catalogue sources whose packages can be deployed in a tenant
+func (h *Handler) enabledCatalogSources(tenantID string) ([]winget.CatalogSource, error) {
+	sources := []winget.CatalogSource{}
+	for _, s := range winget.CatalogSources(h.catalogFolders()) {
+		enabled, err := h.Model.IsCatalogSourceEnabled(tenantID, s.Name())
+		if err != nil {
+			return nil, err
+		}
+		if enabled {
+			sources = append(sources, s)
+		}
+	}
+	return sources, nil
+}
+
+// getCatalogSourceSettings returns the sources that are enabled in the settings page, winget,
+// flatpak and brew have their own settings
+func (h *Handler) getCatalogSourceSettings(tenantID string) ([]admin_views.CatalogSourceSetting, error) {
+	settings := []admin_views.CatalogSourceSetting{}
+	for _, s := range winget.CatalogSources(h.catalogFolders()) {
+		if winget.IsBuiltinCatalogSource(s.Name()) {
+			continue
+		}
+
+		enabled, err := h.Model.IsCatalogSourceEnabled(tenantID, s.Name())
+		if err != nil {
+			return nil, err
+		}
+		settings = append(settings, admin_views.CatalogSourceSetting{Name: s.Name(), Enabled: enabled})
+	}
+	return settings, nil
+}
+
+// marshalDeployAction adds the catalogue source of the package to the deploy action, so agents
+// know which package manager installs it. The source is left empty if the package isn't found
+func (h *Handler) marshalDeployAction(action scnorion_nats.DeployAction) ([]byte, error) {
+	source := ""
+	packages, err := winget.FindCatalogPackagesByID([]string{action.PackageId}, h.catalogFolders())
+	if err != nil {
+		log.Printf("[ERROR]: could not find the source of package %s, reason: %v", action.PackageId, err)
+	} else {
+		source = packages[action.PackageId].Source
+	}
+
+	return json.Marshal(models.CatalogDeployAction{DeployAction: action, Source: source})
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) ChangeControl(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	if c.Request().Method == "POST" {
+		if c.FormValue("change-control-approval") != "" {
+			required := c.FormValue("change-control-approval") == "on"
+			if err := h.Model.SetDeploymentApprovalRequired(tenantID, required); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_save_approval", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "change_control.approval_saved")
+		} else {
+			r, err := getChangeFreezeRequest(c)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.invalid_freeze"), true))
+			}
+
+			if err := h.Model.AddChangeFreeze(tenantID, r); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_add", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "change_control.added")
+		}
+	}
+
+	if c.Request().Method == "DELETE" {
+		freezeID, err := strconv.Atoi(c.FormValue("freezeId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.invalid_freeze"), true))
+		}
+
+		if err := h.Model.DeleteChangeFreeze(tenantID, freezeID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_delete", err.Error()), true))
+		}
+		successMessage = i18n.T(c.Request().Context(), "change_control.deleted")
+	}
+
+	freezes, err := h.Model.GetChangeFreezes(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_get", err.Error()), true))
+	}
+
+	// Loaded after the changes so the freeze in effect and the approval setting are up to date
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_get", err.Error()), true))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.SitesIndex(" | Change Control", admin_views.ChangeControl(c, freezes, commonInfo.DeploymentApproval, successMessage, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
+}
+
+// getChangeFreezeRequest reads the freeze form, its dates are sent by datetime-local inputs
+func getChangeFreezeRequest(c echo.Context) (models.ChangeFreezeRequest, error) {
+	var err error
+
+	r := models.ChangeFreezeRequest{
+		Name:   c.FormValue("change-freeze-name"),
+		Reason: c.FormValue("change-freeze-reason"),
+	}
+
+	if r.Start, err = time.ParseInLocation("2006-01-02T15:04", c.FormValue("change-freeze-start"), time.Local); err != nil {
+		return r, err
+	}
+	if r.End, err = time.ParseInLocation("2006-01-02T15:04", c.FormValue("change-freeze-end"), time.Local); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// GetChangeControlInfo loads the change freeze in effect and whether the tenant approves its deployments,
+// only the handlers that check them or show the change control fields need them
+func (h *Handler) GetChangeControlInfo(commonInfo *partials.CommonInfo) error {
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return err
+	}
+
+	commonInfo.ChangeFreeze, err = h.Model.GetActiveChangeFreeze(tenantID, time.Now())
+	if err != nil {
+		return err
+	}
+
+	commonInfo.DeploymentApproval, err = h.Model.GetDeploymentApprovalRequired(commonInfo.TenantID)
+	return err
+}
+
+// checkChangeFreeze blocks a change while a freeze is in effect unless the user gives a reason to override it,
+// the override is recorded with the freeze. The error is meant to be shown to the user
+func (h *Handler) checkChangeFreeze(c echo.Context, commonInfo *partials.CommonInfo, action string) error {
+	freeze := commonInfo.ChangeFreeze
+	if freeze == nil {
+		return nil
+	}
+
+	reason := strings.TrimSpace(c.FormValue("changeFreezeReason"))
+	if reason == "" {
+		return errors.New(i18n.T(c.Request().Context(), "change_control.frozen", freeze.Name, commonInfo.Translator.FmtDateMedium(freeze.End.Local())+" "+commonInfo.Translator.FmtTimeShort(freeze.End.Local())))
+	}
+
+	user := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	if err := h.Model.AddChangeFreezeOverride(freeze.ID, user, action, reason); err != nil {
+		return errors.New(i18n.T(c.Request().Context(), "change_control.could_not_override", err.Error()))
+	}
+
+	return nil
+}
+
+// checkDirectDeployment refuses the deployments that are sent right away if the tenant approves its
+// deployments, these must be created from the Deploy section with a change reference
+func (h *Handler) checkDirectDeployment(c echo.Context, commonInfo *partials.CommonInfo, action string) error {
+	if commonInfo.DeploymentApproval {
+		return errors.New(i18n.T(c.Request().Context(), "change_control.use_deployment_jobs"))
+	}
+	return h.checkChangeFreeze(c, commonInfo, action)
+}
+
+// computerDeploymentJob sends a deployment of the computer page as a job for that computer, so it waits for approval
+func (h *Handler) computerDeploymentJob(c echo.Context, agentId, action, packageId, packageName, packageVersion string, commonInfo *partials.CommonInfo) error {
+	r := models.DeploymentJobRequest{
+		PackageID:      packageId,
+		PackageName:    packageName,
+		PackageVersion: packageVersion,
+		Action:         action,
+		Selection:      "selected",
+		Agents:         []string{agentId},
+	}
+
+	if _, err := h.createDeploymentJob(c, r, commonInfo); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_create", err.Error()), true))
+	}
+
+	c.Request().Method = "GET"
+	return h.ComputerDeploy(c, i18n.T(c.Request().Context(), "change_control.waiting_approval"))
+}
@@ -23,6 +23,7 @@ import (
 	scnorion_ent "github.com/scncore/ent"
 	scnorion_nats "github.com/scncore/nats"
 	models "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/charts"
 	"github.com/scncore/scnorion-console/internal/views/computers_views"
 	"github.com/scncore/scnorion-console/internal/views/filters"
 	"github.com/scncore/scnorion-console/internal/views/partials"
@@ -184,9 +185,14 @@ func (h *Handler) OperatingSystem(c echo.Context) error {
 
 	confirmDelete := c.QueryParam("delete") != ""
 
+	loggedOnUsers, err := h.Model.GetAgentLoggedOnUsers(agentId)
+	if err != nil {
+		return RenderView(c, computers_views.InventoryIndex(" | Inventory", partials.Error(c, err.Error(), "Computers", partials.GetNavigationUrl(commonInfo, "/computers"), commonInfo), commonInfo))
+	}
+
 	p := partials.PaginationAndSort{}
 
-	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.OperatingSystem(c, p, agent, confirmDelete, commonInfo), commonInfo))
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.OperatingSystem(c, p, agent, loggedOnUsers, confirmDelete, commonInfo), commonInfo))
 }
 
 func (h *Handler) NetworkAdapters(c echo.Context) error {
@@ -265,10 +271,20 @@ func (h *Handler) LogicalDisks(c echo.Context) error {
 		return RenderView(c, computers_views.InventoryIndex(" | Inventory", partials.Error(c, err.Error(), "Computers", partials.GetNavigationUrl(commonInfo, "/computers"), commonInfo), commonInfo))
 	}
 
+	history, forecasts, err := h.Model.GetLogicalDiskForecasts(agentId)
+	if err != nil {
+		return RenderView(c, computers_views.InventoryIndex(" | Inventory", partials.Error(c, err.Error(), "Computers", partials.GetNavigationUrl(commonInfo, "/computers"), commonInfo), commonInfo))
+	}
+
+	usageThreshold, freeSpaceThreshold, err := h.Model.GetDefaultDiskThresholds(commonInfo.TenantID)
+	if err != nil {
+		log.Printf("[ERROR]: could not get disk thresholds, reason: %v", err)
+	}
+
 	confirmDelete := c.QueryParam("delete") != ""
 	p := partials.PaginationAndSort{}
 
-	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.LogicalDisks(c, p, agent, confirmDelete, commonInfo), commonInfo))
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.LogicalDisks(c, p, agent, confirmDelete, forecasts, usageThreshold, freeSpaceThreshold, charts.DiskFreeSpaceHistory(c.Request().Context(), history), len(history) > 0, commonInfo), commonInfo))
 }
 
 func (h *Handler) PhysicalDisks(c echo.Context) error {
@@ -393,9 +409,15 @@ func (h *Handler) Apps(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
+	violations, err := h.Model.GetAgentPolicyViolations(agentId)
+	if err != nil {
+		log.Printf("[ERROR]: an error occurred querying policy violations for agent: %v", err)
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
 	confirmDelete := c.QueryParam("delete") != ""
 
-	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.Apps(c, p, *f, a, apps, confirmDelete, commonInfo), commonInfo))
+	return RenderView(c, computers_views.InventoryIndex(" | Inventory", computers_views.Apps(c, p, *f, a, apps, violations, confirmDelete, commonInfo), commonInfo))
 }
 
 func (h *Handler) RemoteAssistance(c echo.Context) error {
@@ -488,6 +510,15 @@ func (h *Handler) ComputersList(c echo.Context, successMessage string, comesFrom
 		f.Username = c.FormValue("filterByUsername")
 	}
 
+	if comesFromDialog {
+		u, err := url.Parse(c.Request().Header.Get("Hx-Current-Url"))
+		if err == nil {
+			f.LoggedOnUser = u.Query().Get("filterByLoggedOnUser")
+		}
+	} else {
+		f.LoggedOnUser = c.FormValue("filterByLoggedOnUser")
+	}
+
 	availableOSes, err := h.Model.GetAgentsUsedOSes(commonInfo)
 	if err != nil {
 		return err
@@ -691,6 +722,10 @@ func (h *Handler) ComputerDeploy(c echo.Context, successMessage string) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 
 	if agentId == "" {
@@ -721,13 +756,18 @@ func (h *Handler) ComputerDeploy(c echo.Context, successMessage string) error {
 		return RenderView(c, computers_views.DeploymentsTable(c, p, agentId, deployments, commonInfo))
 	}
 
+	attempts, err := h.Model.GetDeploymentAttemptsForAgent(agentId, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_retries.could_not_get", err.Error()), false))
+	}
+
 	refreshTime, err := h.Model.GetDefaultRefreshTime()
 	if err != nil {
 		log.Println("[ERROR]: could not get refresh time from database")
 		refreshTime = 5
 	}
 
-	return RenderView(c, computers_views.InventoryIndex(" | Deploy SW", computers_views.ComputerDeploy(c, p, agent, deployments, successMessage, confirmDelete, refreshTime, commonInfo), commonInfo))
+	return RenderView(c, computers_views.InventoryIndex(" | Deploy SW", computers_views.ComputerDeploy(c, p, agent, deployments, attempts, successMessage, confirmDelete, refreshTime, commonInfo), commonInfo))
 }
 
 func (h *Handler) ComputerDeploySearchPackagesInstall(c echo.Context) error {
@@ -758,43 +798,31 @@ func (h *Handler) ComputerDeploySearchPackagesInstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.search_empty_error"), true))
 	}
 
-	// Default sort
+	// Default sort, the most relevant packages come first
 	if p.SortBy == "" {
-		p.SortBy = "name"
+		p.SortBy = "rank"
 		p.SortOrder = "asc"
 	}
 
-	switch agent.Os {
-	case "windows":
-		f = filters.DeployPackageFilter{Sources: []string{"winget"}}
-		useWinget, err := h.Model.GetDefaultUseWinget(commonInfo.TenantID)
-		if err != nil {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.could_not_get_winget_use"), true))
-		}
-
-		if !useWinget {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.use_winget_is_false"), true))
-		}
-	case "macos", "macOS":
-		f = filters.DeployPackageFilter{Sources: []string{"brew"}}
-		useBrew, err := h.Model.GetDefaultUseBrew(commonInfo.TenantID)
-		if err != nil {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.could_not_get_brew_use"), true))
-		}
+	// Search only in the enabled sources whose packages can be installed on the agent
+	sources, err := h.enabledCatalogSources(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.could_not_get_catalog_sources", err.Error()), true))
+	}
 
-		if !useBrew {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.use_brew_is_false"), true))
-		}
-	default:
-		f = filters.DeployPackageFilter{Sources: []string{"flatpak"}}
-		useFlatpak, err := h.Model.GetDefaultUseFlatpak(commonInfo.TenantID)
-		if err != nil {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.could_not_get_flatpak_use"), true))
+	f = filters.DeployPackageFilter{}
+	versionedSources := []string{}
+	for _, s := range sources {
+		if models.SupportsOS(s, agent.Os) {
+			f.Sources = append(f.Sources, s.Name())
+			if _, ok := s.(models.CatalogVersionsSource); ok {
+				versionedSources = append(versionedSources, s.Name())
+			}
 		}
+	}
 
-		if !useFlatpak {
-			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.use_flatpak_is_false"), true))
-		}
+	if len(f.Sources) == 0 {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.no_catalog_source_enabled"), true))
 	}
 
 	packages, err = models.SearchPackages(search, p, h.CommonFolder, f)
@@ -807,7 +835,7 @@ func (h *Handler) ComputerDeploySearchPackagesInstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "install.could_not_count_packages", err.Error()), true))
 	}
 
-	return RenderView(c, computers_views.SearchPacketResult(c, agentId, packages, p, commonInfo))
+	return RenderView(c, computers_views.SearchPacketResult(c, agentId, packages, versionedSources, p, commonInfo))
 
 }
 
@@ -817,6 +845,10 @@ func (h *Handler) ComputerDeployInstall(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 	if agentId == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), false))
@@ -838,6 +870,13 @@ func (h *Handler) ComputerDeployInstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.already_deployed"), true))
 	}
 
+	if commonInfo.DeploymentApproval {
+		return h.computerDeploymentJob(c, agentId, "install", packageId, packageName, c.FormValue("filterByPackageVersion"), commonInfo)
+	}
+	if err := h.checkChangeFreeze(c, commonInfo, "install "+packageName); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
 	deploymentFailed, err := h.Model.DeploymentFailed(agentId, packageId, commonInfo)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
@@ -847,10 +886,11 @@ func (h *Handler) ComputerDeployInstall(c echo.Context) error {
 	action.AgentId = agentId
 	action.PackageId = packageId
 	action.PackageName = packageName
+	action.PackageVersion = c.FormValue("filterByPackageVersion")
 	// action.Repository = "winget"
 	action.Action = "install"
 
-	data, err := json.Marshal(action)
+	data, err := h.marshalDeployAction(action)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -859,7 +899,7 @@ func (h *Handler) ComputerDeployInstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.installpackage."+agentId, data)
+	err = h.PublishAgentCommand(agentId, "install", "agent.installpackage."+agentId, packageName, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -878,6 +918,10 @@ func (h *Handler) ComputerDeployUpdate(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 	if agentId == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), false))
@@ -890,6 +934,13 @@ func (h *Handler) ComputerDeployUpdate(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.deploy_empty_values"), true))
 	}
 
+	if commonInfo.DeploymentApproval {
+		return h.computerDeploymentJob(c, agentId, "update", packageId, packageName, "", commonInfo)
+	}
+	if err := h.checkChangeFreeze(c, commonInfo, "update "+packageName); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
 	action := scnorion_nats.DeployAction{}
 	action.AgentId = agentId
 	action.PackageId = packageId
@@ -897,7 +948,7 @@ func (h *Handler) ComputerDeployUpdate(c echo.Context) error {
 	// action.Repository = "winget"
 	action.Action = "update"
 
-	data, err := json.Marshal(action)
+	data, err := h.marshalDeployAction(action)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -906,7 +957,7 @@ func (h *Handler) ComputerDeployUpdate(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.updatepackage."+agentId, data)
+	err = h.PublishAgentCommand(agentId, "update", "agent.updatepackage."+agentId, packageName, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -930,6 +981,10 @@ func (h *Handler) ComputerDeployUninstall(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	agentId := c.Param("uuid")
 	if agentId == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), false))
@@ -952,6 +1007,13 @@ func (h *Handler) ComputerDeployUninstall(c echo.Context) error {
 		return h.ComputerDeploy(c, i18n.T(c.Request().Context(), "agents.deployment_removed"))
 	}
 
+	if commonInfo.DeploymentApproval {
+		return h.computerDeploymentJob(c, agentId, "uninstall", packageId, packageName, "", commonInfo)
+	}
+	if err := h.checkChangeFreeze(c, commonInfo, "uninstall "+packageName); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
 	action := scnorion_nats.DeployAction{}
 	action.AgentId = agentId
 	action.PackageId = packageId
@@ -959,7 +1021,7 @@ func (h *Handler) ComputerDeployUninstall(c echo.Context) error {
 	// action.Repository = "winget"
 	action.Action = "uninstall"
 
-	data, err := json.Marshal(action)
+	data, err := h.marshalDeployAction(action)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -968,7 +1030,7 @@ func (h *Handler) ComputerDeployUninstall(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	err = h.NATSConnection.Publish("agent.uninstallpackage."+agentId, data)
+	err = h.PublishAgentCommand(agentId, "uninstall", "agent.uninstallpackage."+agentId, packageName, data)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
@@ -1060,10 +1122,25 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.poweroff_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.poweroff."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if c.FormValue("respectWindow") == "on" {
+			held, next, err := h.HoldUntilMaintenanceWindow(agentId, "poweroff", "agent.queued.poweroff."+agentId, "", data)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.could_not_hold", err.Error()), true))
+			}
+			if held {
+				return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "maintenance_windows.held_until", commonInfo.Translator.FmtDateMedium(next.Local())+" "+commonInfo.Translator.FmtTimeShort(next.Local()))))
+			}
+		}
+
+		_, queued, err := h.RequestOrQueueAgentCommand(agentId, "poweroff", data)
+		if err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 		}
 
+		if queued {
+			return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agent_commands.poweroff_queued")))
+		}
+
 		return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agents.poweroff_success")))
 	case "reboot":
 		if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
@@ -1091,10 +1168,25 @@ func (h *Handler) PowerManagement(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.reboot_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.reboot."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		if c.FormValue("respectWindow") == "on" {
+			held, next, err := h.HoldUntilMaintenanceWindow(agentId, "reboot", "agent.queued.reboot."+agentId, "", data)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.could_not_hold", err.Error()), true))
+			}
+			if held {
+				return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "maintenance_windows.held_until", commonInfo.Translator.FmtDateMedium(next.Local())+" "+commonInfo.Translator.FmtTimeShort(next.Local()))))
+			}
+		}
+
+		_, queued, err := h.RequestOrQueueAgentCommand(agentId, "reboot", data)
+		if err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 		}
 
+		if queued {
+			return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agent_commands.reboot_queued")))
+		}
+
 		return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agents.reboot_success")))
 	default:
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_allowed_power_action"), false))
@@ -1289,7 +1381,8 @@ func (h *Handler) ComputerStartVNC(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.vnc_could_not_marshal"), false))
 		}
 
-		if _, err := h.NATSConnection.Request("agent.startvnc."+agentId, data, time.Duration(h.NATSTimeout)*time.Second); err != nil {
+		// If the agent is not reachable the session starts when it connects again, as long as the operator is still waiting
+		if _, _, err := h.RequestOrQueueAgentCommand(agentId, "startvnc", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), true))
 		}
 
@@ -1356,8 +1449,8 @@ func (h *Handler) ComputerStopVNC(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
 	}
 
-	if _, err := h.NATSConnection.Request("agent.stopvnc."+agentId, nil, time.Duration(h.NATSTimeout)*time.Second); err != nil {
-		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.no_responder"), false))
+	if _, _, err := h.RequestOrQueueAgentCommand(agentId, "stopvnc", nil); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), false))
 	}
 
 	return RenderView(c, computers_views.InventoryIndex("| Computers", computers_views.VNC(c, agent, domain, false, false, "", commonInfo), commonInfo))
@@ -1440,11 +1533,16 @@ func (h *Handler) SetDefaultPrinter(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.defaultprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
+	msg, queued, err := h.RequestOrQueueAgentCommand(agentId, "defaultprinter", []byte(printerName))
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 	}
 
+	// The printers are updated with the next report of the agent
+	if queued {
+		return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agent_commands.defaultprinter_queued")))
+	}
+
 	if string(msg.Data) != "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.printer_could_not_set_as_default", string(msg.Data)), false))
 	}
@@ -1494,11 +1592,16 @@ func (h *Handler) RemovePrinter(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.could_not_get_agent"), false))
 	}
 
-	msg, err := h.NATSConnection.Request("agent.removeprinter."+agentId, []byte(printerName), time.Duration(h.NATSTimeout)*time.Second)
+	msg, queued, err := h.RequestOrQueueAgentCommand(agentId, "removeprinter", []byte(printerName))
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.request_error", err.Error()), true))
 	}
 
+	// The printers are updated with the next report of the agent
+	if queued {
+		return RenderSuccess(c, partials.SuccessMessage(i18n.T(c.Request().Context(), "agent_commands.removeprinter_queued")))
+	}
+
 	if string(msg.Data) != "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.printer_could_not_be_removed", string(msg.Data)), false))
 	}
@@ -7,9 +7,11 @@ import (
 	"time"
 
 	"github.com/labstack/echo/v4"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
 	"github.com/scncore/scnorion-console/internal/views/charts"
 	"github.com/scncore/scnorion-console/internal/views/dashboard_views"
 	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
 )
 
 func (h *Handler) Dashboard(c echo.Context) error {
@@ -145,6 +147,19 @@ func (h *Handler) Dashboard(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "severity", SortOrder: "desc"}
+	data.MostExposedComputers, _, err = h.Model.GetExposedComputers(p, commonInfo)
+	if err != nil {
+		log.Printf("[ERROR]: could not get the most exposed computers, reason: %v", err)
+	}
+
+	// The software catalogue may be imported from bundles in offline mode, warn admins if it gets too old
+	data.OfflineMode = h.OfflineMode
+	data.CatalogAgeDays = -1
+	if updated := winget.CatalogUpdated(h.catalogFolders()); !updated.IsZero() {
+		data.CatalogAgeDays = int(time.Since(updated).Hours() / 24)
+	}
+
 	h.CheckNATSComponentStatus(&data)
 
 	return RenderView(c, dashboard_views.DashboardIndex("| Dashboard", dashboard_views.Dashboard(c, data, commonInfo), commonInfo))
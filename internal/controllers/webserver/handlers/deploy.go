@@ -1,15 +1,16 @@
 package handlers
 
 import (
-	"encoding/json"
 	"fmt"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
 
 	"github.com/invopop/ctxi18n/i18n"
 	"github.com/labstack/echo/v4"
-	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/ent/deploymentjob"
+	scnorion_models "github.com/scncore/scnorion-console/internal/models"
 	models "github.com/scncore/scnorion-console/internal/models/winget"
 	"github.com/scncore/scnorion-console/internal/views/deploy_views"
 	"github.com/scncore/scnorion-console/internal/views/filters"
@@ -49,31 +50,22 @@ func (h *Handler) SearchPackagesAction(c echo.Context, install bool) error {
 
 	allSources := []string{}
 
-	useWinget, err := h.Model.GetDefaultUseWinget(commonInfo.TenantID)
+	sources, err := h.enabledCatalogSources(commonInfo.TenantID)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
-	if useWinget {
-		allSources = append(allSources, "winget")
+	for _, s := range sources {
+		allSources = append(allSources, s.Name())
 	}
 
-	useFlatpak, err := h.Model.GetDefaultUseFlatpak(commonInfo.TenantID)
+	privatePackages, err := h.Model.CountPrivatePackages()
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
-	if useFlatpak {
-		allSources = append(allSources, "flatpak")
-	}
-
-	useBrew, err := h.Model.GetDefaultUseBrew(commonInfo.TenantID)
-	if err != nil {
-		return RenderError(c, partials.ErrorMessage(err.Error(), true))
-	}
-
-	if useBrew {
-		allSources = append(allSources, "brew")
+	if privatePackages > 0 {
+		allSources = append(allSources, "private")
 	}
 
 	filteredSources := []string{}
@@ -85,15 +77,7 @@ func (h *Handler) SearchPackagesAction(c echo.Context, install bool) error {
 	}
 
 	if len(filteredSources) == 0 {
-		if useWinget {
-			filteredSources = append(allSources, "winget")
-		}
-		if useFlatpak {
-			filteredSources = append(allSources, "flatpak")
-		}
-		if useBrew {
-			filteredSources = append(allSources, "brew")
-		}
+		filteredSources = allSources
 	}
 
 	f := filters.DeployPackageFilter{}
@@ -102,9 +86,9 @@ func (h *Handler) SearchPackagesAction(c echo.Context, install bool) error {
 	p := partials.NewPaginationAndSort()
 	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
 
-	// Default sort
+	// Default sort, the most relevant packages come first
 	if p.SortBy == "" {
-		p.SortBy = "name"
+		p.SortBy = "rank"
 		p.SortOrder = "asc"
 	}
 
@@ -127,6 +111,10 @@ func (h *Handler) SelectPackageDeployment(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	packageId := c.FormValue("filterByPackageId")
 	packageName := c.FormValue("filterByPackageName")
 	installParam := c.FormValue("filterByInstallationType")
@@ -144,13 +132,23 @@ func (h *Handler) SelectPackageDeployment(c echo.Context) error {
 		f.SelectedItems = 0
 	}
 
-	switch source {
-	case "winget":
-		f.AgentOSVersions = []string{"windows"}
-	case "flatpak":
-		f.AgentOSVersions = []string{"ubuntu", "neon", "debian", "opensuse-leap", "linuxmint", "fedora", "manjaro", "arch", "almalinux", "rocky"}
-	case "brew":
-		f.AgentOSVersions = []string{"macOS"}
+	// Packages can only be deployed to the platforms supported by their source,
+	// private installers to the platform they were built for. The versions of
+	// a package are downloaded when the operator opens the version selector
+	versionsUrl := ""
+	if id, ok := scnorion_models.ParsePrivatePackageID(packageId); ok {
+		p, err := h.Model.GetPrivatePackage(id)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		f.AgentOSVersions = models.PlatformOSVersions(scnorion_models.PrivatePackagePlatform(p.FileType.String()))
+	} else if s, ok := models.GetCatalogSource(source, h.catalogFolders()); ok {
+		for _, platform := range s.Platforms() {
+			f.AgentOSVersions = append(f.AgentOSVersions, models.PlatformOSVersions(platform)...)
+		}
+		if _, ok := s.(models.CatalogVersionsSource); ok {
+			versionsUrl = partials.GetNavigationUrl(commonInfo, "/deploy/versions")
+		}
 	}
 
 	tmpAllAgents := []string{}
@@ -182,13 +180,23 @@ func (h *Handler) SelectPackageDeployment(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	plans, err := h.Model.GetRolloutPlans(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_get", err.Error()), true))
+	}
+
 	refreshTime, err := h.Model.GetDefaultRefreshTime()
 	if err != nil {
 		log.Println("[ERROR]: could not get refresh time from database")
 		refreshTime = 5
 	}
 
-	return RenderView(c, deploy_views.DeployIndex("", deploy_views.SelectPackageDeployment(c, p, f, packageId, packageName, agents, install, refreshTime, commonInfo), commonInfo))
+	return RenderView(c, deploy_views.DeployIndex("", deploy_views.SelectPackageDeployment(c, p, f, packageId, packageName, source, versionsUrl, plans, agents, install, refreshTime, commonInfo), commonInfo))
 }
 
 func (h *Handler) DeployPackageToSelectedAgents(c echo.Context) error {
@@ -199,9 +207,14 @@ func (h *Handler) DeployPackageToSelectedAgents(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	checkedItems := c.FormValue("selectedAgents")
 	packageId := c.FormValue("filterByPackageId")
 	packageName := c.FormValue("filterByPackageName")
+	packageVersion := c.FormValue("filterByPackageVersion")
 	installParam := c.FormValue("filterByInstallationType")
 
 	agents := strings.Split(checkedItems, ",")
@@ -214,60 +227,88 @@ func (h *Handler) DeployPackageToSelectedAgents(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), true))
 	}
 
-	for _, agent := range agents {
-		action := scnorion_nats.DeployAction{
-			AgentId:     agent,
-			PackageId:   packageId,
-			PackageName: packageName,
-			// Repository:  "winget",
-		}
+	// The deployment is sent once the operator has seen what it would do
+	if c.FormValue("confirmDeployment") != "true" {
+		return h.previewDeployment(c, packageId, packageName, agents, install, commonInfo)
+	}
 
-		if install {
-			action.Action = "install"
-		} else {
-			action.Action = "uninstall"
-		}
+	start, err := getDeploymentStart(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_start"), true))
+	}
 
-		actionBytes, err := json.Marshal(action)
-		if err != nil {
-			return RenderError(c, partials.ErrorMessage(err.Error(), true))
-		}
+	planID, err := getRolloutPlan(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_plan"), true))
+	}
 
-		deploymentFailed, err := h.Model.DeploymentFailed(agent, packageId, commonInfo)
+	r := scnorion_models.DeploymentJobRequest{
+		PackageID:     packageId,
+		PackageName:   packageName,
+		Action:        "uninstall",
+		Selection:     "selected",
+		Start:         start,
+		Agents:        agents,
+		PlanID:        planID,
+		RespectWindow: c.FormValue("respectWindow") == "on",
+	}
+	if planID != 0 {
+		r.Selection = "rollout"
+	}
+	if err := getRetryPolicy(c, &r); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_retries.invalid_policy"), true))
+	}
+	if install {
+		r.Action = "install"
+		r.PackageVersion = packageVersion
+	}
+
+	// Private installers are described by the console, they can't have catalogue packages installed first
+	if id, ok := scnorion_models.ParsePrivatePackageID(packageId); ok {
+		p, err := h.Model.GetPrivatePackage(id)
 		if err != nil {
 			return RenderError(c, partials.ErrorMessage(err.Error(), true))
 		}
-
+		r.PackageName = p.Name
 		if install {
-			if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
-				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
-			}
-
-			if err := h.NATSConnection.Publish("agent.installpackage."+agent, actionBytes); err != nil {
-				return RenderError(c, partials.ErrorMessage(err.Error(), true))
-			}
-
-			if err := h.Model.SaveDeployInfo(&action, deploymentFailed, commonInfo); err != nil {
-				return RenderError(c, partials.ErrorMessage(err.Error(), true))
-			}
-		} else {
-			if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
-				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
-			}
-
-			if err := h.NATSConnection.Publish("agent.uninstallpackage."+agent, actionBytes); err != nil {
-				return RenderError(c, partials.ErrorMessage(err.Error(), true))
-			}
-
-			if err := h.Model.SaveDeployInfo(&action, deploymentFailed, commonInfo); err != nil {
-				return RenderError(c, partials.ErrorMessage(err.Error(), true))
-			}
+			r.PackageVersion = p.Version
+		}
+	} else if install {
+		if err := h.getDeploymentChain(c, &r); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_chains.invalid_chain", err.Error()), true))
 		}
 	}
 
+	job, err := h.createDeploymentJob(c, r, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_create", err.Error()), true))
+	}
+
+	c.Response().Header().Set("HX-Push-Url", partials.GetNavigationUrl(commonInfo, "/deploy/jobs/"+strconv.Itoa(job.ID)))
+	if job.Status == deploymentjob.StatusPending {
+		return h.showDeploymentJob(c, job.ID, i18n.T(c.Request().Context(), "change_control.waiting_approval"))
+	}
 	if install {
-		return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.Deploy(c, true, i18n.T(c.Request().Context(), "install.requested"), commonInfo), commonInfo))
-	} else {
-		return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.Deploy(c, false, i18n.T(c.Request().Context(), "uninstall.requested"), commonInfo), commonInfo))
+		return h.showDeploymentJob(c, job.ID, i18n.T(c.Request().Context(), "install.requested"))
 	}
+	return h.showDeploymentJob(c, job.ID, i18n.T(c.Request().Context(), "uninstall.requested"))
+}
+
+// PackageVersions renders the versions of a package that can be selected before it's deployed
+func (h *Handler) PackageVersions(c echo.Context) error {
+	s, ok := models.GetCatalogSource(c.FormValue("filterBySource"), h.catalogFolders())
+	if !ok {
+		return RenderView(c, partials.PackageVersionOptions([]string{}))
+	}
+
+	return RenderView(c, partials.PackageVersionOptions(h.packageVersions(s, c.FormValue("filterByPackageId"))))
+}
+
+// packageVersions returns the versions of a package, the newest first
+func (h *Handler) packageVersions(s models.CatalogSource, packageId string) []string {
+	versions := models.PackageVersions(s, packageId, h.OfflineMode)
+	slices.SortFunc(versions, func(a, b string) int {
+		return scnorion_models.CompareVersions(b, a)
+	})
+	return slices.Compact(versions)
 }
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/ent"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/deploy_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) DeploymentAssignments(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	if c.Request().Method == "POST" {
+		if c.FormValue("assignmentId") != "" {
+			assignmentID, err := strconv.Atoi(c.FormValue("assignmentId"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.invalid_assignment"), true))
+			}
+
+			enabled := c.FormValue("enabled") == "true"
+
+			// Resuming an assignment deploys its package again, so it's a change like a new assignment
+			if enabled {
+				a, err := h.Model.GetDeploymentAssignment(tenantID, assignmentID)
+				if err != nil {
+					return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_get", err.Error()), true))
+				}
+
+				if err := h.checkChangeFreeze(c, commonInfo, "resume assignment "+a.Name); err != nil {
+					return RenderError(c, partials.ErrorMessage(err.Error(), true))
+				}
+
+				if commonInfo.DeploymentApproval {
+					user := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+					if err := h.Model.SetDeploymentAssignmentChangeReference(tenantID, a.ID, c.FormValue("changeReference"), user); err != nil {
+						return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_update", err.Error()), true))
+					}
+				}
+			}
+
+			if err := h.Model.SetDeploymentAssignmentEnabled(tenantID, assignmentID, enabled); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_update", err.Error()), true))
+			}
+			if enabled && commonInfo.DeploymentApproval {
+				successMessage = i18n.T(c.Request().Context(), "deployment_assignments.enabled_waiting_approval")
+			} else if enabled {
+				successMessage = i18n.T(c.Request().Context(), "deployment_assignments.enabled")
+			} else {
+				successMessage = i18n.T(c.Request().Context(), "deployment_assignments.disabled")
+			}
+		} else {
+			r, err := getDeploymentAssignmentRequest(c)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.invalid_assignment"), true))
+			}
+			r.CreatedBy = h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+			r.ChangeReference = c.FormValue("changeReference")
+			if commonInfo.SiteID != "-1" {
+				if r.ScopeSiteID, err = strconv.Atoi(commonInfo.SiteID); err != nil {
+					return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.could_not_convert_to_int", err.Error()), true))
+				}
+			}
+
+			if err := h.checkChangeFreeze(c, commonInfo, "assign "+r.PackageName); err != nil {
+				return RenderError(c, partials.ErrorMessage(err.Error(), true))
+			}
+
+			a, err := h.Model.AddDeploymentAssignment(tenantID, r)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_add", err.Error()), true))
+			}
+
+			// The computers already in the group don't wait for the scheduler, unless their job waits for approval
+			a, err = h.Model.GetDeploymentAssignment(tenantID, a.ID)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_get", err.Error()), true))
+			}
+			if err := h.Model.EvaluateDeploymentAssignment(a); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_evaluate", err.Error()), true))
+			}
+			h.SendDueDeploymentTargets()
+
+			successMessage = i18n.T(c.Request().Context(), "deployment_assignments.added")
+			if commonInfo.DeploymentApproval {
+				successMessage = i18n.T(c.Request().Context(), "deployment_assignments.added_waiting_approval")
+			}
+		}
+	}
+
+	if c.Request().Method == "DELETE" {
+		assignmentID, err := strconv.Atoi(c.FormValue("assignmentId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.invalid_assignment"), true))
+		}
+
+		if err := h.Model.DeleteDeploymentAssignment(tenantID, assignmentID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_delete", err.Error()), true))
+		}
+		successMessage = i18n.T(c.Request().Context(), "deployment_assignments.deleted")
+	}
+
+	assignments, err := h.Model.GetDeploymentAssignments(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_assignments.could_not_get", err.Error()), true))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	tags, err := h.Model.GetAllTags(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	// Assignments are evaluated against the computers of the site the user is working in, or the whole tenant
+	oses, err := h.Model.GetAgentsUsedOSes(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	if commonInfo.SiteID != "-1" {
+		siteSites := []*ent.Site{}
+		for _, s := range sites {
+			if strconv.Itoa(s.ID) == commonInfo.SiteID {
+				siteSites = append(siteSites, s)
+			}
+		}
+		sites = siteSites
+	}
+
+	// The package comes from the install page, without it the assignments are only listed
+	packageId := c.QueryParam("packageId")
+	packageName := c.QueryParam("packageName")
+
+	return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.DeploymentAssignments(c, assignments, sites, tags, oses, packageId, packageName, successMessage, commonInfo), commonInfo))
+}
+
+// getDeploymentAssignmentRequest reads the assignment form, the group is read from the fields of the selected kind
+func getDeploymentAssignmentRequest(c echo.Context) (models.DeploymentAssignmentRequest, error) {
+	var err error
+
+	r := models.DeploymentAssignmentRequest{
+		Name:             c.FormValue("assignment-name"),
+		PackageID:        c.FormValue("assignment-package-id"),
+		PackageName:      c.FormValue("assignment-package-name"),
+		PackageVersion:   c.FormValue("assignment-package-version"),
+		Kind:             c.FormValue("assignment-kind"),
+		UninstallOnLeave: c.FormValue("assignment-uninstall-on-leave") == "on",
+	}
+
+	switch r.Kind {
+	case "tag":
+		if r.TagID, err = strconv.Atoi(c.FormValue("assignment-tag")); err != nil {
+			return r, err
+		}
+	case "site":
+		if r.SiteID, err = strconv.Atoi(c.FormValue("assignment-site")); err != nil {
+			return r, err
+		}
+	case "search":
+		params, err := c.FormParams()
+		if err != nil {
+			return r, err
+		}
+
+		r.Search.Nickname = c.FormValue("assignment-search-nickname")
+		r.Search.AgentOSVersions = params["assignment-search-os"]
+		for _, value := range params["assignment-search-tags"] {
+			id, err := strconv.Atoi(value)
+			if err != nil {
+				return r, err
+			}
+			r.Search.Tags = append(r.Search.Tags, id)
+		}
+		if remote := c.FormValue("assignment-search-remote"); remote != "" {
+			r.Search.IsRemote = []string{remote}
+		}
+	}
+
+	return r, nil
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	ent "github.com/scncore/ent"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/deploy_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) DeploymentJobs(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "start"
+		p.SortOrder = "desc"
+	}
+
+	jobs, err := h.Model.GetDeploymentJobs(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_get", err.Error()), false))
+	}
+
+	p.NItems, err = h.Model.CountDeploymentJobs(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_get", err.Error()), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.DeploymentJobs(c, p, jobs, successMessage, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) DeploymentJob(c echo.Context) error {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_job"), false))
+	}
+
+	return h.showDeploymentJob(c, id, "")
+}
+
+func (h *Handler) showDeploymentJob(c echo.Context, id int, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	job, err := h.Model.GetDeploymentJob(id, commonInfo)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.not_found"), false))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_get", err.Error()), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	rings := []models.RolloutRingProgress{}
+	if job.Edges.Plan != nil {
+		rings = models.GetRolloutRingsProgress(job, time.Now())
+	}
+
+	return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.DeploymentJob(c, job, models.GetDeploymentJobProgress(job.Edges.Targets), rings, successMessage, refreshTime, commonInfo), commonInfo))
+}
+
+// DeploymentJobAction approves, rejects, promotes, pauses, resumes or aborts a deployment job
+func (h *Handler) DeploymentJobAction(c echo.Context, action string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_job"), false))
+	}
+
+	successMessage := ""
+	user := h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+
+	switch action {
+	case "approve":
+		// Approving a deployment during a change freeze sends it, so it needs an override too
+		if err := h.checkChangeFreeze(c, commonInfo, "approve deployment "+strconv.Itoa(id)); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		err = h.Model.ApproveDeploymentJob(id, user, commonInfo)
+		successMessage = "change_control.approved"
+	case "reject":
+		err = h.Model.RejectDeploymentJob(id, user, commonInfo)
+		successMessage = "change_control.rejected"
+	case "promote":
+		err = h.Model.PromoteDeploymentJob(id, commonInfo)
+		successMessage = "rollouts.promoted"
+	case "pause":
+		err = h.Model.PauseDeploymentJob(id, commonInfo)
+		successMessage = "rollouts.paused"
+	case "resume":
+		err = h.Model.ResumeDeploymentJob(id, commonInfo)
+		successMessage = "rollouts.resumed"
+	case "abort":
+		err = h.Model.AbortDeploymentJob(id, commonInfo)
+		successMessage = "rollouts.aborted"
+	}
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.not_found"), false))
+		}
+		if action == "approve" || action == "reject" {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "change_control.could_not_"+action, err.Error()), false))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_"+action, err.Error()), false))
+	}
+
+	// The next ring is sent right away
+	if action == "promote" || action == "resume" || action == "approve" {
+		h.SendDueDeploymentTargets()
+	}
+
+	return h.showDeploymentJob(c, id, i18n.T(c.Request().Context(), successMessage))
+}
+
+// createDeploymentJob stores the job and sends it right away unless it has been scheduled or waits for approval
+func (h *Handler) createDeploymentJob(c echo.Context, r models.DeploymentJobRequest, commonInfo *partials.CommonInfo) (*ent.DeploymentJob, error) {
+	if err := h.checkChangeFreeze(c, commonInfo, r.Action+" "+r.PackageName); err != nil {
+		return nil, err
+	}
+
+	r.CreatedBy = h.SessionManager.Manager.GetString(c.Request().Context(), "uid")
+	r.ChangeReference = c.FormValue("changeReference")
+
+	job, err := h.Model.CreateDeploymentJob(r, commonInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	if !job.Start.After(time.Now()) {
+		h.SendDueDeploymentTargets()
+	}
+
+	return job, nil
+}
+
+// getRolloutPlan reads the optional rollout plan selected for a deployment
+func getRolloutPlan(c echo.Context) (int, error) {
+	value := c.FormValue("rolloutPlan")
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// getRetryPolicy reads the retry policy of a deployment, without it the deployment is sent only once
+func getRetryPolicy(c echo.Context, r *models.DeploymentJobRequest) error {
+	var err error
+
+	if c.FormValue("retryMaxAttempts") == "" {
+		return nil
+	}
+
+	if r.RetryMaxAttempts, err = strconv.Atoi(c.FormValue("retryMaxAttempts")); err != nil {
+		return err
+	}
+	if r.RetryBackoff, err = strconv.Atoi(c.FormValue("retryBackoff")); err != nil {
+		return err
+	}
+
+	params, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+	r.RetryOn = params["retryOn"]
+
+	return nil
+}
+
+// getDeploymentChain reads the packages that are installed before the package of a deployment,
+// their names are taken from the catalogue so they must be known
+func (h *Handler) getDeploymentChain(c echo.Context, r *models.DeploymentJobRequest) error {
+	params, err := c.FormParams()
+	if err != nil {
+		return err
+	}
+
+	ids := params["chainPackageId"]
+	versions := params["chainPackageVersion"]
+
+	chain := []models.DeploymentChainItem{}
+	for i, id := range ids {
+		if id = strings.TrimSpace(id); id == "" {
+			continue
+		}
+		item := models.DeploymentChainItem{PackageID: id}
+		if i < len(versions) {
+			item.PackageVersion = strings.TrimSpace(versions[i])
+		}
+		chain = append(chain, item)
+	}
+
+	if len(chain) == 0 {
+		return nil
+	}
+
+	ids = []string{}
+	for _, item := range chain {
+		ids = append(ids, item.PackageID)
+	}
+
+	packages, err := winget.FindCatalogPackagesByID(ids, h.catalogFolders())
+	if err != nil {
+		return err
+	}
+
+	for i, item := range chain {
+		p, ok := packages[item.PackageID]
+		if !ok {
+			return fmt.Errorf("the package %s is not in the catalogue", item.PackageID)
+		}
+		chain[i].PackageName = p.Name
+	}
+
+	r.Chain = chain
+	return nil
+}
+
+// getDeploymentStart reads the optional start time of a deployment, it's sent by a datetime-local input
+func getDeploymentStart(c echo.Context) (time.Time, error) {
+	value := c.FormValue("deploymentStart")
+	if value == "" {
+		return time.Now(), nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04", value, time.Local)
+}
+
+func (h *Handler) StartDeploymentJobsJob() error {
+	var err error
+
+	// Create task
+	_, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(1*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				if err := h.Model.UpdateDeploymentTargets(); err != nil {
+					log.Printf("[ERROR]: could not update the deployment targets, reason: %v", err)
+				}
+				if err := h.Model.EvaluateDeploymentAssignments(); err != nil {
+					log.Printf("[ERROR]: could not evaluate the deployment assignments, reason: %v", err)
+				}
+				if err := h.Model.EvaluateRollouts(); err != nil {
+					log.Printf("[ERROR]: could not evaluate the rollouts, reason: %v", err)
+				}
+				if err := h.Model.RetryDeploymentTargets(); err != nil {
+					log.Printf("[ERROR]: could not queue the deployment retries, reason: %v", err)
+				}
+				h.SendDueDeploymentTargets()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the deployment jobs job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: deployment jobs job has been scheduled every minute")
+	return nil
+}
+
+// deploymentTargetsLock prevents the scheduler and a new job from sending the same targets twice
+var deploymentTargetsLock sync.Mutex
+
+// SendDueDeploymentTargets sends the action to every queued computer whose job start time has arrived.
+// Targets stay queued if NATS is not connected so they're sent in the next run
+func (h *Handler) SendDueDeploymentTargets() {
+	deploymentTargetsLock.Lock()
+	defer deploymentTargetsLock.Unlock()
+
+	targets, err := h.Model.GetDueDeploymentTargets()
+	if err != nil {
+		log.Printf("[ERROR]: could not get the queued deployment targets, reason: %v", err)
+		return
+	}
+
+	if len(targets) == 0 {
+		return
+	}
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		log.Println("[ERROR]: could not send the queued deployment targets, NATS is not connected")
+		return
+	}
+
+	for _, t := range targets {
+		// The target is sent before its command is published so a quick report of the agent is matched
+		if err := h.Model.SetDeploymentTargetSent(t.ID); err != nil {
+			log.Printf("[ERROR]: could not set deployment target %d as sent, reason: %v", t.ID, err)
+			continue
+		}
+
+		if err := h.sendDeploymentTarget(t); err != nil {
+			log.Printf("[ERROR]: could not send deployment job %d to agent, reason: %v", t.Edges.Job.ID, err)
+			if err := h.Model.SetDeploymentTargetFailed(t.ID, err.Error()); err != nil {
+				log.Printf("[ERROR]: could not set deployment target %d as failed, reason: %v", t.ID, err)
+			}
+		}
+	}
+}
+
+// sendDeploymentTarget saves the deployment info that the agent will update and publishes
+// the command of a target, nothing is published if the info can't be saved
+func (h *Handler) sendDeploymentTarget(t *ent.DeploymentJobTarget) error {
+	job := t.Edges.Job
+	if job.Edges.Tenant == nil {
+		return fmt.Errorf("deployment job %d has no tenant", job.ID)
+	}
+	if t.Edges.Agent == nil {
+		return fmt.Errorf("deployment target %d has no agent", t.ID)
+	}
+
+	// The deployment info is scoped to the tenant of the job
+	commonInfo := &partials.CommonInfo{TenantID: strconv.Itoa(job.Edges.Tenant.ID), SiteID: "-1"}
+
+	if job.Action == "agentupdate" {
+		return h.sendAgentUpdate(t.Edges.Agent.ID, job.PackageVersion, job.PackageName, commonInfo)
+	}
+
+	if job.Action == "rollback" {
+		return h.sendDeploymentRollback(t, commonInfo)
+	}
+
+	// Targets of a chain install the packages that go first before the package of the job
+	p := models.GetDeploymentChainPackage(t)
+
+	if id, ok := models.ParsePrivatePackageID(p.PackageID); ok {
+		return h.sendPrivatePackageTarget(t.Edges.Agent.ID, id, p.Action, commonInfo)
+	}
+
+	action := scnorion_nats.DeployAction{
+		AgentId:        t.Edges.Agent.ID,
+		PackageId:      p.PackageID,
+		PackageName:    p.PackageName,
+		PackageVersion: p.PackageVersion,
+		Action:         p.Action,
+	}
+
+	data, err := h.marshalDeployAction(action)
+	if err != nil {
+		return err
+	}
+
+	deploymentFailed, err := h.Model.DeploymentFailed(action.AgentId, action.PackageId, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Model.SaveDeployInfo(&action, deploymentFailed, commonInfo); err != nil {
+		return err
+	}
+
+	return h.PublishAgentCommand(action.AgentId, p.Action, "agent."+p.Action+"package."+action.AgentId, p.PackageName, data)
+}
+
+// sendPrivatePackageTarget sends the download URL, checksum and installer arguments of a private package,
+// agents can't find these installers in a public catalogue
+func (h *Handler) sendPrivatePackageTarget(agentID string, id int, actionName string, commonInfo *partials.CommonInfo) error {
+	if actionName != "install" && actionName != "uninstall" {
+		return fmt.Errorf("private packages can't be sent with the %s action", actionName)
+	}
+
+	p, err := h.Model.GetPrivatePackage(id)
+	if err != nil {
+		return err
+	}
+
+	action := models.NewPrivatePackageAction(agentID, actionName, h.privatePackageURL(p.ID), p)
+
+	data, err := json.Marshal(action)
+	if err != nil {
+		return err
+	}
+
+	deploymentFailed, err := h.Model.DeploymentFailed(agentID, action.PackageId, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Model.SaveDeployInfo(&action.DeployAction, deploymentFailed, commonInfo); err != nil {
+		return err
+	}
+
+	return h.PublishAgentCommand(agentID, actionName, "agent."+actionName+"privatepackage."+agentID, p.Name, data)
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/deploy_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// previewDeployment shows what an install or uninstall would do on the selected computers, the deployment
+// is sent once the preview is confirmed. Clients that accept JSON get the preview as JSON
+func (h *Handler) previewDeployment(c echo.Context, packageId, packageName string, agents []string, install bool, commonInfo *partials.CommonInfo) error {
+	r := models.DeploymentPreviewRequest{
+		PackageID:   packageId,
+		PackageName: packageName,
+		Install:     install,
+		Agents:      agents,
+	}
+
+	if id, ok := models.ParsePrivatePackageID(packageId); ok {
+		p, err := h.Model.GetPrivatePackage(id)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_preview.could_not_preview", err.Error()), true))
+		}
+
+		platform := models.PrivatePackagePlatform(p.FileType.String())
+		r.Compatible = func(os string) bool { return winget.AgentPlatform(os) == platform }
+		r.InstallerSize = p.Size
+	} else if source, ok := h.deploymentPreviewSource(c.FormValue("filterBySource"), packageId); ok {
+		// Catalogue sources don't publish the size of their installers, the preview shows the download as unknown
+		r.Compatible = func(os string) bool { return winget.SupportsOS(source, os) }
+	}
+
+	preview, err := h.Model.GetDeploymentPreview(r, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_preview.could_not_preview", err.Error()), true))
+	}
+
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMEApplicationJSON) {
+		return c.JSON(http.StatusOK, preview)
+	}
+
+	params, err := c.FormParams()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	// The preview is a step of the deployment form, it has no address of its own
+	c.Response().Header().Set("HX-Push-Url", "false")
+	return RenderView(c, deploy_views.DeployIndex("", deploy_views.DeploymentPreview(c, preview, packageId, packageName, install, params, commonInfo), commonInfo))
+}
+
+// deploymentPreviewSource returns the catalogue source of the package, it's looked up in the catalogue
+// if the deployment form didn't send it
+func (h *Handler) deploymentPreviewSource(name, packageId string) (winget.CatalogSource, bool) {
+	if name == "" {
+		packages, err := winget.FindCatalogPackagesByID([]string{packageId}, h.catalogFolders())
+		if err != nil {
+			return nil, false
+		}
+		p, ok := packages[packageId]
+		if !ok {
+			return nil, false
+		}
+		name = p.Source
+	}
+	return winget.GetCatalogSource(name, h.catalogFolders())
+}
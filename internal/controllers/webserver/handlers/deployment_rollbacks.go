@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// ComputerDeployRollback installs again the version that the computer had before the package was updated
+func (h *Handler) ComputerDeployRollback(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	agentId := c.Param("uuid")
+	if agentId == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), false))
+	}
+
+	packageId := c.FormValue("filterByPackageId")
+	packageName := c.FormValue("filterByPackageName")
+
+	if packageId == "" || packageName == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.deploy_empty_values"), true))
+	}
+
+	if err := h.checkDeploymentRollback(packageId); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_rollback.not_supported", err.Error()), true))
+	}
+
+	r := models.DeploymentJobRequest{PackageID: packageId, PackageName: packageName, Action: "rollback", Selection: "selected", Agents: []string{agentId}}
+	if _, err := h.createDeploymentJob(c, r, commonInfo); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_rollback.could_not_rollback", err.Error()), true))
+	}
+
+	c.Request().Method = "GET"
+	return h.ComputerDeploy(c, i18n.T(c.Request().Context(), "deployment_rollback.requested"))
+}
+
+// DeploymentJobRollback rolls back the computers that an update job updated successfully
+func (h *Handler) DeploymentJobRollback(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_job"), false))
+	}
+
+	job, err := h.Model.GetDeploymentJob(id, commonInfo)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.not_found"), false))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_get", err.Error()), false))
+	}
+
+	if job.Action != "update" || job.Status != deploymentjob.StatusCompleted {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_rollback.only_completed_updates"), false))
+	}
+
+	if err := h.checkDeploymentRollback(job.PackageID); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_rollback.not_supported", err.Error()), false))
+	}
+
+	agents := []string{}
+	for _, t := range job.Edges.Targets {
+		if t.Status == deploymentjobtarget.StatusSucceeded && t.Edges.Agent != nil {
+			agents = append(agents, t.Edges.Agent.ID)
+		}
+	}
+
+	r := models.DeploymentJobRequest{PackageID: job.PackageID, PackageName: job.PackageName, Action: "rollback", Selection: "selected", Agents: agents}
+	rollback, err := h.createDeploymentJob(c, r, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_rollback.could_not_rollback", err.Error()), false))
+	}
+
+	if rollback.Status == deploymentjob.StatusPending {
+		return h.showDeploymentJob(c, rollback.ID, i18n.T(c.Request().Context(), "change_control.waiting_approval"))
+	}
+	return h.showDeploymentJob(c, rollback.ID, i18n.T(c.Request().Context(), "deployment_rollback.requested"))
+}
+
+// checkDeploymentRollback tells if the source of the package can install a pinned version
+func (h *Handler) checkDeploymentRollback(packageId string) error {
+	if _, ok := models.ParsePrivatePackageID(packageId); ok {
+		return errors.New("private packages have a single version")
+	}
+
+	packages, err := winget.FindCatalogPackagesByID([]string{packageId}, h.catalogFolders())
+	if err != nil {
+		return err
+	}
+
+	p, ok := packages[packageId]
+	if !ok {
+		return errors.New("the package is not in the catalogue")
+	}
+
+	source, ok := winget.GetCatalogSource(p.Source, h.catalogFolders())
+	if !ok {
+		return errors.New("the source of the package is not enabled")
+	}
+
+	if _, ok := source.(winget.CatalogVersionsSource); !ok {
+		return errors.New("the source of the package can't install a specific version")
+	}
+
+	return nil
+}
+
+// sendDeploymentRollback sends a pinned install of the version that the computer had before the update
+func (h *Handler) sendDeploymentRollback(t *ent.DeploymentJobTarget, commonInfo *partials.CommonInfo) error {
+	job := t.Edges.Job
+	agentId := t.Edges.Agent.ID
+
+	version, err := h.Model.GetDeploymentRollbackVersion(agentId, job.PackageID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	action := scnorion_nats.DeployAction{
+		AgentId:        agentId,
+		PackageId:      job.PackageID,
+		PackageName:    job.PackageName,
+		PackageVersion: version,
+		Action:         "install",
+	}
+
+	data, err := h.marshalDeployAction(action)
+	if err != nil {
+		return err
+	}
+
+	if err := h.Model.SaveDeploymentRollback(agentId, job.PackageID, version, commonInfo); err != nil {
+		return err
+	}
+
+	return h.PublishAgentCommand(agentId, "install", "agent.installpackage."+agentId, job.PackageName, data)
+}
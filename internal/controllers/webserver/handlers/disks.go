@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/go-co-op/gocron/v2"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/views/disks_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) DisksNearlyFull(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Request().Method == "POST" && c.FormValue("disk-usage-threshold") != "" {
+		usageThreshold, err := strconv.Atoi(c.FormValue("disk-usage-threshold"))
+		if err != nil || usageThreshold < 0 || usageThreshold > 100 {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.invalid_usage_threshold"), true))
+		}
+
+		freeSpaceThreshold, err := strconv.Atoi(c.FormValue("disk-free-space-threshold"))
+		if err != nil || freeSpaceThreshold < 0 {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.invalid_free_space_threshold"), true))
+		}
+
+		settings, err := h.Model.GetGeneralSettings(commonInfo.TenantID)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.could_not_get_thresholds", err.Error()), true))
+		}
+
+		if err := h.Model.UpdateDiskThresholds(settings.ID, usageThreshold, freeSpaceThreshold); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.could_not_save_thresholds", err.Error()), true))
+		}
+
+		if err := h.Model.UpdateDiskAlertEmail(settings.ID, c.FormValue("disk-alert-email")); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.invalid_alert_email"), true))
+		}
+
+		successMessage = i18n.T(c.Request().Context(), "disks.thresholds_saved")
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "usage"
+		p.SortOrder = "desc"
+	}
+
+	usageThreshold, freeSpaceThreshold, err := h.Model.GetDefaultDiskThresholds(commonInfo.TenantID)
+	if err != nil {
+		return RenderView(c, disks_views.DisksIndex(" | Disks", partials.Error(c, err.Error(), "Disks", partials.GetNavigationUrl(commonInfo, "/disks"), commonInfo), commonInfo))
+	}
+
+	alertEmail, err := h.Model.GetDiskAlertEmail(commonInfo.TenantID)
+	if err != nil {
+		return RenderView(c, disks_views.DisksIndex(" | Disks", partials.Error(c, err.Error(), "Disks", partials.GetNavigationUrl(commonInfo, "/disks"), commonInfo), commonInfo))
+	}
+
+	disks, total, err := h.Model.GetDisksNearlyFull(p, usageThreshold, freeSpaceThreshold, commonInfo)
+	if err != nil {
+		return RenderView(c, disks_views.DisksIndex(" | Disks", partials.Error(c, err.Error(), "Disks", partials.GetNavigationUrl(commonInfo, "/disks"), commonInfo), commonInfo))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, disks_views.DisksIndex(" | Disks", disks_views.Disks(c, p, disks, usageThreshold, freeSpaceThreshold, alertEmail, refreshTime, successMessage, commonInfo), commonInfo))
+}
+
+func (h *Handler) StartDiskAlertsJob() error {
+	var err error
+
+	// Create task
+	_, err = h.TaskScheduler.NewJob(
+		gocron.DailyJob(1, gocron.NewAtTimes(gocron.NewAtTime(8, 0, 0))),
+		gocron.NewTask(
+			func() {
+				h.SendDiskAlerts()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the disk alerts job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: disk alerts job has been scheduled every day at 08:00")
+	return nil
+}
+
+// SendDiskAlerts emails every tenant that has an alert email a digest of the volumes that exceed its thresholds
+func (h *Handler) SendDiskAlerts() {
+	tenants, err := h.Model.GetTenants()
+	if err != nil {
+		log.Printf("[ERROR]: could not get tenants to send disk alerts, reason: %v", err)
+		return
+	}
+
+	for _, t := range tenants {
+		email, disks, err := h.Model.GetDiskAlerts(t.ID)
+		if err != nil {
+			log.Printf("[ERROR]: could not get the disks nearly full of tenant %d, reason: %v", t.ID, err)
+			continue
+		}
+
+		if email == "" || len(disks) == 0 {
+			continue
+		}
+
+		if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+			log.Println("[ERROR]: could not send disk alerts, NATS is not connected")
+			return
+		}
+
+		lines := []string{}
+		for _, d := range disks {
+			lines = append(lines, fmt.Sprintf("%s %s: %d%% used, %s free of %s", d.Nickname, d.Label, d.Usage, d.RemainingSpace, d.Size))
+		}
+
+		notification := scnorion_nats.Notification{
+			To:               email,
+			Subject:          "scnorion | Disks nearly full",
+			MessageTitle:     "scnorion | Disks nearly full",
+			MessageText:      fmt.Sprintf("The following volumes in %s exceed the disk thresholds: %s", t.Description, strings.Join(lines, "; ")),
+			MessageGreeting:  "Hi",
+			MessageAction:    "Show disks",
+			MessageActionURL: fmt.Sprintf("https://%s:%s/tenant/%d/disks", h.ServerName, h.ConsolePort, t.ID),
+		}
+
+		data, err := json.Marshal(notification)
+		if err != nil {
+			log.Printf("[ERROR]: could not marshal disk alert notification, reason: %v", err)
+			continue
+		}
+
+		if err := h.NATSConnection.Publish("notification.disk_alert", data); err != nil {
+			log.Printf("[ERROR]: could not send disk alert notification, reason: %v", err)
+		}
+	}
+}
@@ -17,45 +17,49 @@ import (
 type Handler struct {
 	Model *models.Model
 
-	SessionManager       *sessions.SessionManager
-	JWTKey               string
-	CertPath             string
-	KeyPath              string
-	SFTPKeyPath          string
-	CACertPath           string
-	DownloadDir          string
-	ServerName           string
-	AuthPort             string
-	ConsolePort          string
-	Domain               string
-	TaskScheduler        gocron.Scheduler
-	NATSServers          string
-	NATSTimeout          int
-	NATSConnection       *nats.Conn
-	NATSConnectJob       gocron.Job
-	JetStream            jetstream.JetStream
-	JetStreamCancelFunc  context.CancelFunc
-	AgentStream          jetstream.Stream
-	ServerStream         jetstream.Stream
-	OrgName              string
-	OrgProvince          string
-	OrgLocality          string
-	OrgAddress           string
-	Country              string
-	ReverseProxyAuthPort string
-	ReverseProxyServer   string
-	LatestServerRelease  scnorion_nats.scnorionRelease
-	Replicas             int
-	ServerReleasesFolder string
-	WingetFolder         string
-	FlatpakFolder        string
-	BrewFolder           string
-	CommonFolder         string
-	Version              string
-	ReenableCertAuth     bool
+	SessionManager        *sessions.SessionManager
+	JWTKey                string
+	CertPath              string
+	KeyPath               string
+	SFTPKeyPath           string
+	CACertPath            string
+	DownloadDir           string
+	ServerName            string
+	AuthPort              string
+	ConsolePort           string
+	Domain                string
+	TaskScheduler         gocron.Scheduler
+	NATSServers           string
+	NATSTimeout           int
+	NATSConnection        *nats.Conn
+	NATSConnectJob        gocron.Job
+	JetStream             jetstream.JetStream
+	JetStreamCancelFunc   context.CancelFunc
+	AgentStream           jetstream.Stream
+	ServerStream          jetstream.Stream
+	OrgName               string
+	OrgProvince           string
+	OrgLocality           string
+	OrgAddress            string
+	Country               string
+	ReverseProxyAuthPort  string
+	ReverseProxyServer    string
+	LatestServerRelease   scnorion_nats.scnorionRelease
+	Replicas              int
+	ServerReleasesFolder  string
+	WingetFolder          string
+	FlatpakFolder         string
+	BrewFolder            string
+	CommonFolder          string
+	PackagesFolder        string
+	BundlePublicKeyPath   string
+	VulnerabilitiesFolder string
+	Version               string
+	ReenableCertAuth      bool
+	OfflineMode           bool
 }
 
-func NewHandler(model *models.Model, natsServers string, s *sessions.SessionManager, ts gocron.Scheduler, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, version string, reEnableCertAuth bool) *Handler {
+func NewHandler(model *models.Model, natsServers string, s *sessions.SessionManager, ts gocron.Scheduler, jwtKey, certPath, keyPath, sftpKeyPath, caCertPath, server, consolePort, authPort, tmpDownloadDir, domain, orgName, orgProvince, orgLocality, orgAddress, country, reverseProxyAuthPort, reverseProxyServer, serverReleasesFolder, wingetFolder, flatpakFolder, brewFolder, commonFolder, packagesFolder, bundlePublicKeyPath, vulnerabilitiesFolder, version string, reEnableCertAuth, offlineMode bool) *Handler {
 
 	// Get NATS request timeout seconds
 	timeout, err := model.GetNATSTimeout()
@@ -68,36 +72,40 @@ func NewHandler(model *models.Model, natsServers string, s *sessions.SessionMana
 	replicas := strings.Split(natsServers, ",")
 
 	h := Handler{
-		Model:                model,
-		SessionManager:       s,
-		JWTKey:               jwtKey,
-		CertPath:             certPath,
-		KeyPath:              keyPath,
-		SFTPKeyPath:          sftpKeyPath,
-		CACertPath:           caCertPath,
-		DownloadDir:          tmpDownloadDir,
-		ServerName:           server,
-		ConsolePort:          consolePort,
-		AuthPort:             authPort,
-		Domain:               domain,
-		NATSTimeout:          timeout,
-		NATSServers:          natsServers,
-		TaskScheduler:        ts,
-		OrgName:              orgName,
-		OrgProvince:          orgProvince,
-		OrgLocality:          orgLocality,
-		OrgAddress:           orgAddress,
-		Country:              country,
-		ReverseProxyAuthPort: reverseProxyAuthPort,
-		ReverseProxyServer:   reverseProxyServer,
-		Replicas:             len(replicas),
-		ServerReleasesFolder: serverReleasesFolder,
-		WingetFolder:         wingetFolder,
-		FlatpakFolder:        flatpakFolder,
-		BrewFolder:           brewFolder,
-		CommonFolder:         commonFolder,
-		Version:              version,
-		ReenableCertAuth:     reEnableCertAuth,
+		Model:                 model,
+		SessionManager:        s,
+		JWTKey:                jwtKey,
+		CertPath:              certPath,
+		KeyPath:               keyPath,
+		SFTPKeyPath:           sftpKeyPath,
+		CACertPath:            caCertPath,
+		DownloadDir:           tmpDownloadDir,
+		ServerName:            server,
+		ConsolePort:           consolePort,
+		AuthPort:              authPort,
+		Domain:                domain,
+		NATSTimeout:           timeout,
+		NATSServers:           natsServers,
+		TaskScheduler:         ts,
+		OrgName:               orgName,
+		OrgProvince:           orgProvince,
+		OrgLocality:           orgLocality,
+		OrgAddress:            orgAddress,
+		Country:               country,
+		ReverseProxyAuthPort:  reverseProxyAuthPort,
+		ReverseProxyServer:    reverseProxyServer,
+		Replicas:              len(replicas),
+		ServerReleasesFolder:  serverReleasesFolder,
+		WingetFolder:          wingetFolder,
+		FlatpakFolder:         flatpakFolder,
+		BrewFolder:            brewFolder,
+		CommonFolder:          commonFolder,
+		PackagesFolder:        packagesFolder,
+		BundlePublicKeyPath:   bundlePublicKeyPath,
+		VulnerabilitiesFolder: vulnerabilitiesFolder,
+		Version:               version,
+		ReenableCertAuth:      reEnableCertAuth,
+		OfflineMode:           offlineMode,
 	}
 
 	// Try to create the NATS Connection and start a job if it can't be possible to connect
@@ -105,9 +113,58 @@ func NewHandler(model *models.Model, natsServers string, s *sessions.SessionMana
 		log.Fatalf("[FATAL]: could not start NATS Connect job")
 	}
 
+	// Start a job to notify and remediate software policy violations
+	if err := h.StartPolicyViolationsJob(); err != nil {
+		log.Printf("[ERROR]: could not start policy violations job, reason: %s", err.Error())
+	}
+
+	// Start a job to follow the commands that are waiting for offline agents
+	if err := h.StartAgentCommandsJob(); err != nil {
+		log.Printf("[ERROR]: could not start agent commands job, reason: %s", err.Error())
+	}
+
+	// Start a job to send the scheduled deployments and follow their progress
+	if err := h.StartDeploymentJobsJob(); err != nil {
+		log.Printf("[ERROR]: could not start deployment jobs job, reason: %s", err.Error())
+	}
+
+	// Start a job to release the commands held until a maintenance window opens
+	if err := h.StartMaintenanceWindowsJob(); err != nil {
+		log.Printf("[ERROR]: could not start maintenance windows job, reason: %s", err.Error())
+	}
+
+	// Start a job to warn about the volumes that exceed the disk thresholds
+	if err := h.StartDiskAlertsJob(); err != nil {
+		log.Printf("[ERROR]: could not start disk alerts job, reason: %s", err.Error())
+	}
+
 	return &h
 }
 
+// agentStreamConfig returns the stream where every command sent to the agents is kept until it expires,
+// an agent that is off has no consumer running so the stream can't wait for its interest. Commands carry
+// their own TTL so offline agents don't get stale actions. NATS servers older than 2.11 can't expire
+// messages, CheckAgentCommands removes the expired commands instead and MaxAge bounds the rest
+func (h *Handler) agentStreamConfig() jetstream.StreamConfig {
+	agentStreamConfig := jetstream.StreamConfig{
+		Name: "AGENTS_STREAM",
+		Subjects: []string{"agent.certificate.>", "agent.enable.>", "agent.disable.>", "agent.report.>", "agent.update.>", "agent.uninstall.>",
+			"agent.installpackage.>", "agent.updatepackage.>", "agent.uninstallpackage.>", "agent.installprivatepackage.>", "agent.uninstallprivatepackage.>",
+			"agent.settings.>", "agent.queued.>", "agent.newconfig"},
+		Retention:   jetstream.LimitsPolicy,
+		MaxAge:      models.MaxAgentCommandTTL(),
+		AllowMsgTTL: h.supportsMsgTTL(),
+		// Held commands released again within this time are discarded by their message id
+		Duplicates: time.Hour,
+	}
+
+	if h.Replicas > 1 {
+		agentStreamConfig.Replicas = h.Replicas
+	}
+
+	return agentStreamConfig
+}
+
 func (h *Handler) StartNATSConnectJob() error {
 	var err error
 	var ctx context.Context
@@ -118,17 +175,7 @@ func (h *Handler) StartNATSConnectJob() error {
 		if err == nil {
 			ctx, h.JetStreamCancelFunc = context.WithTimeout(context.Background(), 60*time.Minute)
 
-			agentStreamConfig := jetstream.StreamConfig{
-				Name:      "AGENTS_STREAM",
-				Subjects:  []string{"agent.certificate.>", "agent.enable.>", "agent.disable.>", "agent.report.>", "agent.update.>", "agent.uninstall.>"},
-				Retention: jetstream.InterestPolicy,
-			}
-
-			if h.Replicas > 1 {
-				agentStreamConfig.Replicas = h.Replicas
-			}
-
-			h.AgentStream, err = h.JetStream.CreateOrUpdateStream(ctx, agentStreamConfig)
+			h.AgentStream, err = h.JetStream.CreateOrUpdateStream(ctx, h.agentStreamConfig())
 			if err == nil {
 				log.Println("[INFO]: agent stream could be instantiated")
 
@@ -187,17 +234,7 @@ func (h *Handler) StartNATSConnectJob() error {
 
 				ctx, h.JetStreamCancelFunc = context.WithTimeout(context.Background(), 60*time.Minute)
 
-				agentStreamConfig := jetstream.StreamConfig{
-					Name:      "AGENTS_STREAM",
-					Subjects:  []string{"agent.certificate.>", "agent.enable.>", "agent.disable.>", "agent.report.>", "agent.update.>", "agent.uninstall.>"},
-					Retention: jetstream.InterestPolicy,
-				}
-
-				if h.Replicas > 1 {
-					agentStreamConfig.Replicas = h.Replicas
-				}
-
-				h.AgentStream, err = h.JetStream.CreateOrUpdateStream(ctx, agentStreamConfig)
+				h.AgentStream, err = h.JetStream.CreateOrUpdateStream(ctx, h.agentStreamConfig())
 				if err != nil {
 					log.Printf("[ERROR]: Agent Stream could not be created or updated, reason: %v", err)
 					return
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/views/licenses_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) Licenses(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	if c.Request().Method == "POST" {
+		if err := h.Model.AddLicenseProduct(tenantID, c.FormValue("license-name"), c.FormValue("license-name-pattern"), c.FormValue("license-vendor-pattern"), c.FormValue("license-version-pattern")); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_add_product", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "licenses.product_added")
+	}
+
+	if c.Request().Method == "DELETE" {
+		productID, err := strconv.Atoi(c.FormValue("productId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_product"), false))
+		}
+
+		if err := h.Model.DeleteLicenseProduct(tenantID, productID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_delete_product", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "licenses.product_deleted")
+	}
+
+	compliance, err := h.Model.GetLicenseCompliance(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_get_compliance", err.Error()), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, licenses_views.LicensesIndex(" | Licenses", licenses_views.Licenses(c, compliance, successMessage, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) LicenseProduct(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	productID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_product"), false))
+	}
+
+	if c.Request().Method == "POST" {
+		seats, err := strconv.Atoi(c.FormValue("entitlement-seats"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_seats"), false))
+		}
+
+		cost := 0.0
+		if c.FormValue("entitlement-cost") != "" {
+			cost, err = strconv.ParseFloat(c.FormValue("entitlement-cost"), 64)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_cost"), false))
+			}
+		}
+
+		var expiry *time.Time
+		if c.FormValue("entitlement-expiry") != "" {
+			date, err := time.ParseInLocation("2006-01-02", c.FormValue("entitlement-expiry"), time.Local)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_expiry"), false))
+			}
+			// Entitlements are valid until the end of the expiry date
+			date = date.AddDate(0, 0, 1)
+			expiry = &date
+		}
+
+		if err := h.Model.AddLicenseEntitlement(tenantID, productID, seats, c.FormValue("entitlement-type"), expiry, cost, c.FormValue("entitlement-contract")); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_add_entitlement", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "licenses.entitlement_added")
+	}
+
+	if c.Request().Method == "DELETE" {
+		entitlementID, err := strconv.Atoi(c.FormValue("entitlementId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.invalid_entitlement"), false))
+		}
+
+		if err := h.Model.DeleteLicenseEntitlement(tenantID, entitlementID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_delete_entitlement", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "licenses.entitlement_deleted")
+	}
+
+	product, compliance, computers, err := h.Model.GetLicenseProductCompliance(productID, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "licenses.could_not_get_compliance", err.Error()), false))
+	}
+
+	return RenderView(c, licenses_views.LicensesIndex(" | Licenses", licenses_views.LicenseProduct(c, product, compliance, computers, successMessage, commonInfo), commonInfo))
+}
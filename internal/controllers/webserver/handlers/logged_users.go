@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"log"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/logged_users_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) LoggedOnUsers(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "username"
+		p.SortOrder = "asc"
+	}
+
+	search := strings.TrimSpace(c.FormValue("search"))
+
+	users, total, err := h.Model.GetLoggedOnUsers(p, search, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, logged_users_views.LoggedUsersIndex(" | Users", logged_users_views.LoggedUsers(c, p, search, users, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) Search(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	search := strings.TrimSpace(c.FormValue("q"))
+
+	p := partials.NewPaginationAndSort()
+	p.SortBy = "nickname"
+	p.SortOrder = "asc"
+
+	computers := []models.Computer{}
+	users := []models.UserDevices{}
+	if search != "" {
+		computers, err = h.Model.GetComputersByPage(p, filters.AgentFilter{Nickname: search}, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		p.SortBy = "username"
+		users, _, err = h.Model.GetLoggedOnUsers(p, search, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+	}
+
+	return RenderView(c, logged_users_views.LoggedUsersIndex(" | Search", logged_users_views.SearchResults(c, search, computers, users, commonInfo), commonInfo))
+}
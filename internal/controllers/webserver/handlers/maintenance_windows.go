@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) MaintenanceWindows(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	if c.Request().Method == "POST" {
+		r, err := getMaintenanceWindowRequest(c)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.invalid_window"), true))
+		}
+
+		if err := h.Model.AddMaintenanceWindow(tenantID, r); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.could_not_add", err.Error()), true))
+		}
+		successMessage = i18n.T(c.Request().Context(), "maintenance_windows.added")
+	}
+
+	if c.Request().Method == "DELETE" {
+		windowID, err := strconv.Atoi(c.FormValue("windowId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.invalid_window"), true))
+		}
+
+		if err := h.Model.DeleteMaintenanceWindow(tenantID, windowID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.could_not_delete", err.Error()), true))
+		}
+		successMessage = i18n.T(c.Request().Context(), "maintenance_windows.deleted")
+	}
+
+	windows, err := h.Model.GetMaintenanceWindows(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "maintenance_windows.could_not_get", err.Error()), true))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	tags, err := h.Model.GetAllTags(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.SitesIndex(" | Maintenance Windows", admin_views.MaintenanceWindows(c, windows, sites, tags, successMessage, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
+}
+
+// getMaintenanceWindowRequest reads the window form, the site and tag are only used for their scope
+func getMaintenanceWindowRequest(c echo.Context) (models.MaintenanceWindowRequest, error) {
+	var err error
+
+	r := models.MaintenanceWindowRequest{
+		Name:     c.FormValue("maintenance-window-name"),
+		Scope:    c.FormValue("maintenance-window-scope"),
+		Kind:     c.FormValue("maintenance-window-kind"),
+		Start:    c.FormValue("maintenance-window-start"),
+		Cron:     c.FormValue("maintenance-window-cron"),
+		Timezone: c.FormValue("maintenance-window-timezone"),
+	}
+
+	params, err := c.FormParams()
+	if err != nil {
+		return r, err
+	}
+	r.Days = params["maintenance-window-days"]
+
+	switch r.Scope {
+	case "site":
+		if r.SiteID, err = strconv.Atoi(c.FormValue("maintenance-window-site")); err != nil {
+			return r, err
+		}
+	case "tag":
+		if r.TagID, err = strconv.Atoi(c.FormValue("maintenance-window-tag")); err != nil {
+			return r, err
+		}
+	}
+
+	if r.Duration, err = strconv.Atoi(c.FormValue("maintenance-window-duration")); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
+
+// HoldUntilMaintenanceWindow keeps the command in the console if the maintenance windows of the agent
+// are closed, it returns when the next window opens so the user knows when the command will be sent
+func (h *Handler) HoldUntilMaintenanceWindow(agentId, kind, subject, description string, data []byte) (bool, time.Time, error) {
+	open, next, err := h.Model.AgentMaintenanceWindow(agentId, time.Now())
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if open {
+		return false, next, nil
+	}
+
+	// The command still has its own TTL once the window opens
+	if next.IsZero() {
+		next = time.Now()
+	}
+	if err := h.Model.HoldAgentCommand(agentId, kind, subject, description, data, next.Add(models.GetAgentCommandTTL(kind))); err != nil {
+		return false, time.Time{}, err
+	}
+
+	return true, next, nil
+}
+
+// HoldProfileTasks asks the agents of a profile that respects maintenance windows to apply its tasks,
+// agents with a closed window get the command once their window opens
+func (h *Handler) HoldProfileTasks(profileID int, commonInfo *partials.CommonInfo) error {
+	profile, err := h.Model.GetProfileById(profileID, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	if !profile.RespectWindow {
+		return nil
+	}
+
+	agents, err := h.Model.GetProfileAgents(profile, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(map[string]int{"profile_id": profile.ID})
+	if err != nil {
+		return err
+	}
+
+	for _, a := range agents {
+		subject := "agent.queued.profile." + a.ID
+		held, _, err := h.HoldUntilMaintenanceWindow(a.ID, "profile", subject, profile.Name, data)
+		if err != nil {
+			return err
+		}
+		if held {
+			continue
+		}
+
+		if err := h.PublishAgentCommand(a.ID, "profile", subject, profile.Name, data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (h *Handler) StartMaintenanceWindowsJob() error {
+	var err error
+
+	// Create task
+	_, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(1*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				h.ReleaseHeldAgentCommands()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the maintenance windows job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: maintenance windows job has been scheduled every minute")
+	return nil
+}
+
+// ReleaseHeldAgentCommands publishes the held commands whose agent has a maintenance window open,
+// from then on they're followed like any other command waiting in the agents stream
+func (h *Handler) ReleaseHeldAgentCommands() {
+	commands, err := h.Model.GetHeldAgentCommands()
+	if err != nil {
+		log.Printf("[ERROR]: could not get held agent commands, reason: %v", err)
+		return
+	}
+
+	now := time.Now()
+	windows := map[string]bool{}
+	for _, cmd := range commands {
+		if cmd.Edges.Agent == nil {
+			continue
+		}
+		agentId := cmd.Edges.Agent.ID
+
+		open, ok := windows[agentId]
+		if !ok {
+			open, _, err = h.Model.AgentMaintenanceWindow(agentId, now)
+			if err != nil {
+				log.Printf("[ERROR]: could not check the maintenance windows of agent %s, reason: %v", agentId, err)
+				continue
+			}
+			windows[agentId] = open
+		}
+
+		if !open {
+			continue
+		}
+
+		// The message id makes the stream discard the command if it's released again
+		// because we couldn't save that it had already been released
+		ttl := models.GetAgentCommandTTL(cmd.Kind)
+		sequence, err := h.publishToAgentStream(cmd.Subject, cmd.Data, ttl, "agent-command-"+strconv.Itoa(cmd.ID))
+		if err != nil {
+			log.Printf("[ERROR]: could not release the %s command held for agent %s, reason: %v", cmd.Kind, agentId, err)
+			return
+		}
+
+		if err := h.Model.SetAgentCommandReleased(cmd.ID, sequence, ttl); err != nil {
+			log.Printf("[ERROR]: could not update agent command %d, reason: %v", cmd.ID, err)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/network_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) NetworkSubnets(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "cidr"
+		p.SortOrder = "asc"
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	f := filters.SubnetFilter{}
+	f.CIDR = c.FormValue("filterByCIDR")
+
+	siteOptions := []string{}
+	for _, s := range sites {
+		siteOptions = append(siteOptions, s.Description)
+	}
+	for index := range siteOptions {
+		value := c.FormValue(fmt.Sprintf("filterBySubnetSite%d", index))
+		if value != "" {
+			f.Sites = append(f.Sites, value)
+		}
+	}
+
+	subnets, total, err := h.Model.GetSubnets(p, f, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, network_views.NetworkIndex(" | Network", network_views.Subnets(c, p, f, subnets, siteOptions, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) NetworkSubnet(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	cidr := c.QueryParam("cidr")
+	if cidr == "" {
+		cidr = c.FormValue("cidr")
+	}
+	if _, _, err := net.ParseCIDR(cidr); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "network.invalid_subnet"), false))
+	}
+
+	if c.Request().Method == "POST" && c.FormValue("subnet-site") != "" {
+		siteID, err := strconv.Atoi(c.FormValue("subnet-site"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "network.invalid_site"), true))
+		}
+
+		if err := h.Model.SetSubnetSite(cidr, tenantID, siteID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "network.could_not_set_site", err.Error()), true))
+		}
+
+		successMessage = i18n.T(c.Request().Context(), "network.site_saved")
+	}
+
+	hosts, err := h.Model.GetSubnetHosts(cidr, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	currentSite, err := h.Model.GetSubnetSite(cidr, tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, network_views.NetworkIndex(" | Network", network_views.SubnetDetail(c, cidr, hosts, sites, currentSite, successMessage, commonInfo), commonInfo))
+}
+
+func (h *Handler) NetworkConflicts(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	conflicts, err := h.Model.GetNetworkConflicts(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, network_views.NetworkIndex(" | Network", network_views.Conflicts(c, conflicts, refreshTime, commonInfo), commonInfo))
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"log"
+
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/scncore/scnorion-console/internal/views/peripherals_views"
+)
+
+func (h *Handler) PeripheralMonitors(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := h.getPeripheralsPagination(c)
+
+	p.NItems, err = h.Model.CountMonitorAssets(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	monitors, err := h.Model.GetMonitorAssets(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, peripherals_views.PeripheralsIndex(" | Peripherals", peripherals_views.Monitors(c, p, monitors, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) PeripheralPrinters(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := h.getPeripheralsPagination(c)
+
+	p.NItems, err = h.Model.CountPrinterAssets(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	printers, err := h.Model.GetPrinterAssets(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, peripherals_views.PeripheralsIndex(" | Peripherals", peripherals_views.Printers(c, p, printers, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) PeripheralChanges(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := h.getPeripheralsPagination(c)
+
+	p.NItems, err = h.Model.CountPeripheralChanges(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	changes, err := h.Model.GetPeripheralChanges(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, peripherals_views.PeripheralsIndex(" | Peripherals", peripherals_views.Changes(c, p, changes, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) getPeripheralsPagination(c echo.Context) partials.PaginationAndSort {
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "lastSeen"
+		p.SortOrder = "desc"
+	}
+
+	return p
+}
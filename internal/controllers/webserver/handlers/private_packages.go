@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) PrivatePackages(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Request().Method == "POST" {
+		if err := h.UploadPrivatePackage(c); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "private_packages.could_not_upload", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "private_packages.uploaded")
+	}
+
+	if c.Request().Method == "DELETE" {
+		packageID, err := strconv.Atoi(c.FormValue("packageId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "private_packages.invalid_package"), false))
+		}
+
+		if err := h.RemovePrivatePackage(packageID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "private_packages.could_not_delete", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "private_packages.deleted")
+	}
+
+	packages, err := h.Model.GetPrivatePackages()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "private_packages.could_not_get", err.Error()), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.PrivatePackagesIndex(" | Private packages", admin_views.PrivatePackages(c, packages, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// UploadPrivatePackage stores the installer named by its SHA256 checksum, saves its metadata
+// and adds it to the common software database so it can be found in the deploy search
+func (h *Handler) UploadPrivatePackage(c echo.Context) error {
+	file, err := c.FormFile("packageFile")
+	if err != nil {
+		return fmt.Errorf("%s", i18n.T(c.Request().Context(), "private_packages.no_file"))
+	}
+
+	fileType, err := models.PrivatePackageFileType(file.Filename)
+	if err != nil {
+		return err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp, err := os.CreateTemp(h.PackagesFolder, "upload-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		if err := os.Remove(tmp.Name()); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR]: could not remove temp upload file, reason: %v", err)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, hash), src)
+	if err != nil {
+		return err
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	// An admin can provide the checksum published by the vendor to detect corrupted uploads
+	expected := strings.ToLower(strings.TrimSpace(c.FormValue("package-checksum")))
+	if expected != "" && expected != checksum {
+		return fmt.Errorf("%s", i18n.T(c.Request().Context(), "private_packages.checksum_mismatch", checksum))
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	dst := filepath.Join(h.PackagesFolder, checksum+"."+fileType)
+	_, err = os.Stat(dst)
+	newFile := os.IsNotExist(err)
+	if newFile {
+		if err := os.Rename(tmp.Name(), dst); err != nil {
+			return err
+		}
+	}
+
+	p, err := h.Model.AddPrivatePackage(models.PrivatePackageInfo{
+		Name:          c.FormValue("package-name"),
+		Version:       c.FormValue("package-version"),
+		Publisher:     c.FormValue("package-publisher"),
+		FileName:      file.Filename,
+		Size:          size,
+		Checksum:      checksum,
+		SilentArgs:    c.FormValue("package-silent-args"),
+		DetectionRule: c.FormValue("package-detection-rule"),
+	})
+	if err != nil {
+		if newFile {
+			if err := os.Remove(dst); err != nil {
+				log.Printf("[ERROR]: could not remove private package file, reason: %v", err)
+			}
+		}
+		return err
+	}
+
+	db, err := winget.OpenCommonDB(h.CommonFolder)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	id := models.PrivatePackageID(p.ID)
+	return winget.InsertCommonSoftwareDetails(db, []scnorion_nats.SoftwarePackage{{ID: id, Name: p.Name}}, "private", map[string]winget.CatalogPackageDetails{id: {Publisher: p.Publisher}})
+}
+
+func (h *Handler) RemovePrivatePackage(id int) error {
+	p, unused, err := h.Model.DeletePrivatePackage(id)
+	if err != nil {
+		return err
+	}
+
+	if unused {
+		if err := os.Remove(filepath.Join(h.PackagesFolder, models.PrivatePackageFile(p))); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR]: could not remove private package file, reason: %v", err)
+		}
+	}
+
+	db, err := winget.OpenCommonDB(h.CommonFolder)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return winget.DeleteCommonSoftware(db, models.PrivatePackageID(id))
+}
+
+// privatePackageURL is the auth server endpoint where agents download the installers with their certificates
+func (h *Handler) privatePackageURL(id int) string {
+	if h.ReverseProxyAuthPort != "" {
+		return fmt.Sprintf("https://%s:%s/packages/%d", h.ReverseProxyServer, h.ReverseProxyAuthPort, id)
+	}
+	return fmt.Sprintf("https://%s:%s/packages/%d", h.ServerName, h.AuthPort, id)
+}
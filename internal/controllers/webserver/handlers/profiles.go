@@ -55,6 +55,10 @@ func (h *Handler) NewProfile(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	siteID, err := strconv.Atoi(commonInfo.SiteID)
 	if err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "sites.could_not_convert_site_to_int", commonInfo.SiteID), true))
@@ -89,6 +93,10 @@ func (h *Handler) EditProfile(c echo.Context, method string, id string, successM
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	p := partials.NewPaginationAndSort()
 	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
 
@@ -128,6 +136,12 @@ func (h *Handler) EditProfile(c echo.Context, method string, id string, successM
 		method = c.Request().Method
 	}
 
+	if method == "POST" || method == "DELETE" {
+		if err := h.checkChangeFreeze(c, commonInfo, "edit profile "+profile.Name); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		}
+	}
+
 	if method == "POST" {
 		description := c.FormValue("profile-description")
 		if description == "" {
@@ -135,11 +149,16 @@ func (h *Handler) EditProfile(c echo.Context, method string, id string, successM
 		}
 
 		applyToAll := c.FormValue("profile-assignment")
+		respectWindow := c.FormValue("profile-respect-window") == "on"
 
-		if err := h.Model.UpdateProfile(profileId, description, applyToAll, commonInfo); err != nil {
+		if err := h.Model.UpdateProfile(profileId, description, applyToAll, respectWindow, commonInfo); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "profiles.edit.could_not_save"), true))
 		}
 
+		if err := h.HoldProfileTasks(profileId, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not send the tasks of profile %d to its agents, reason: %v", profileId, err)
+		}
+
 		return h.EditProfile(c, "GET", id, i18n.T(c.Request().Context(), "profiles.edit.saved"))
 	}
 
@@ -164,6 +183,15 @@ func (h *Handler) EditProfile(c echo.Context, method string, id string, successM
 }
 
 func (h *Handler) ProfileTags(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	id := c.Param("uuid")
 	if id == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "profiles.edit.empty_id"), true))
@@ -184,6 +212,10 @@ func (h *Handler) ProfileTags(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "profiles.edit.tag_id_invalid"), true))
 	}
 
+	if err := h.checkChangeFreeze(c, commonInfo, "edit profile "+id); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
 	if c.Request().Method == "POST" {
 		if err := h.Model.AddTagToProfile(profileId, tagId); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "profiles.edit.could_not_add_tag"), true))
@@ -247,6 +279,10 @@ func (h *Handler) ConfirmDeleteTask(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	p := partials.NewPaginationAndSort()
 	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
 
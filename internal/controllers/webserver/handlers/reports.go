@@ -72,6 +72,20 @@ func (h *Handler) GenerateCSVReports(c echo.Context) error {
 		return h.GenerateAntivirusCSVReport(c, w, fileName)
 	case "updates":
 		return h.GenerateUpdatesCSVReport(c, w, fileName)
+	case "disks":
+		return h.GenerateDisksCSVReport(c, w, fileName)
+	case "peripherals":
+		return h.GeneratePeripheralsCSVReport(c, w, fileName)
+	case "licenses":
+		return h.GenerateLicensesCSVReport(c, w, fileName)
+	case "vulnerabilities":
+		return h.GenerateVulnerabilitiesCSVReport(c, w, fileName)
+	case "drift":
+		return h.GenerateVersionDriftCSVReport(c, w, fileName)
+	case "usage":
+		return h.GenerateAppUsageCSVReport(c, w, fileName)
+	case "deploymentjob":
+		return h.GenerateDeploymentJobCSVReport(c, w, fileName)
 	default:
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.invalid_report_selected"), false))
 	}
@@ -160,6 +174,312 @@ func (h *Handler) GenerateComputersCSVReport(c echo.Context, w *csv.Writer, file
 	return c.String(http.StatusOK, "")
 }
 
+func (h *Handler) GenerateDisksCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.PaginationAndSort{}
+	p.GetPaginationAndSortParams("0", "0", c.FormValue("sortBy"), c.FormValue("sortOrder"), "")
+
+	usageThreshold, freeSpaceThreshold, err := h.Model.GetDefaultDiskThresholds(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "disks.could_not_get_thresholds", err.Error()), false))
+	}
+
+	allDisks, _, err := h.Model.GetDisksNearlyFull(p, usageThreshold, freeSpaceThreshold, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_disks"), false))
+	}
+
+	w.Write([]string{"computer", "os", "label", "usage", "remaining_space", "total_size", "days_until_full"})
+
+	for _, disk := range allDisks {
+		daysUntilFull := ""
+		if disk.DaysUntilFull >= 0 {
+			daysUntilFull = strconv.Itoa(disk.DaysUntilFull)
+		}
+
+		record := []string{disk.Nickname, disk.OS, disk.Label, strconv.Itoa(disk.Usage), disk.RemainingSpace, disk.Size, daysUntilFull}
+		if err := w.Write(record); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GeneratePeripheralsCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.PaginationAndSort{}
+	p.GetPaginationAndSortParams("0", "0", c.FormValue("sortBy"), c.FormValue("sortOrder"), "")
+
+	allChanges, err := h.Model.GetPeripheralChanges(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_peripherals"), false))
+	}
+
+	w.Write([]string{"type", "manufacturer", "model", "serial", "name", "status", "previous_computer", "current_computer", "first_seen", "last_seen"})
+
+	for _, a := range allChanges {
+		status := "moved"
+		previous := a.PreviousAgentNickname
+		current := a.AgentNickname
+		if a.Missing {
+			status = "missing"
+			previous = a.AgentNickname
+			current = ""
+		}
+
+		record := []string{a.Type.String(), a.Manufacturer, a.Model, a.Serial, a.Name, status, previous, current, a.FirstSeen.Format("2006-01-02 15:04:05"), a.LastSeen.Format("2006-01-02 15:04:05")}
+		if err := w.Write(record); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GenerateLicensesCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	compliance, err := h.Model.GetLicenseCompliance(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_licenses"), false))
+	}
+
+	w.Write([]string{"product", "device_seats", "user_seats", "computers", "users", "shortfall", "unused", "cost", "status"})
+
+	for _, l := range compliance {
+		record := []string{l.Name, strconv.Itoa(l.DeviceSeats), strconv.Itoa(l.UserSeats), strconv.Itoa(l.Devices), strconv.Itoa(l.Users), strconv.Itoa(l.Shortfall), strconv.Itoa(l.Unused), strconv.FormatFloat(l.Cost, 'f', 2, 64), l.Status}
+		if err := w.Write(record); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GenerateVulnerabilitiesCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	matches, err := h.Model.GetAppVulnerabilities(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_vulnerabilities"), false))
+	}
+
+	w.Write([]string{"computer", "application", "version", "cve", "severity", "score"})
+
+	for _, v := range matches {
+		if v.Edges.Owner == nil || v.Edges.Vulnerability == nil {
+			continue
+		}
+		record := []string{v.Edges.Owner.Nickname, v.AppName, v.AppVersion, v.Edges.Vulnerability.CveID, v.Edges.Vulnerability.Severity, strconv.FormatFloat(v.Edges.Vulnerability.Score, 'f', 1, 64)}
+		if err := w.Write(record); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GenerateVersionDriftCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	search := c.FormValue("filterByAppName")
+
+	catalog, _, err := h.getCatalogVersions(search, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_version_drift"), false))
+	}
+
+	p := partials.PaginationAndSort{}
+	p.GetPaginationAndSortParams("0", "0", c.FormValue("sortBy"), c.FormValue("sortOrder"), "")
+
+	drift, _, err := h.Model.GetVersionDrift(p, search, catalog, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_version_drift"), false))
+	}
+
+	w.Write([]string{"application", "publisher", "version", "computers", "newest", "catalogue", "laggards"})
+
+	for _, d := range drift {
+		for _, v := range d.Versions {
+			record := []string{d.Name, d.Publisher, v.Version, strconv.Itoa(v.Count), d.Newest, d.CatalogSummary(), strconv.Itoa(d.Laggards)}
+			if err := w.Write(record); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GenerateAppUsageCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	days, err := getUnusedAppDays(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "app_usage.invalid_days"), false))
+	}
+
+	p := partials.PaginationAndSort{}
+	p.GetPaginationAndSortParams("0", "0", c.FormValue("sortBy"), c.FormValue("sortOrder"), "")
+
+	unused, _, err := h.Model.GetUnusedApps(p, c.FormValue("filterByAppName"), days, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_app_usage"), false))
+	}
+
+	w.Write([]string{"application", "publisher", "computer", "version", "last used"})
+
+	for _, u := range unused {
+		installs, err := h.Model.GetUnusedAppInstalls(u.Name, days, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_app_usage"), false))
+		}
+
+		for _, i := range installs {
+			lastUsed := ""
+			if !i.LastUsed.IsZero() {
+				lastUsed = i.LastUsed.Format("2006-01-02")
+			}
+
+			record := []string{u.Name, u.Publisher, i.Nickname, i.Version, lastUsed}
+			if err := w.Write(record); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+			}
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func (h *Handler) GenerateDeploymentJobCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	id, err := strconv.Atoi(c.FormValue("filterByJobId"))
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_job"), false))
+	}
+
+	job, err := h.Model.GetDeploymentJob(id, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_deployment_job"), false))
+	}
+
+	w.Write([]string{"package", "version", "action", "created by", "start", "computer", "status", "sent", "updated", "message"})
+
+	for _, t := range job.Edges.Targets {
+		nickname := ""
+		if t.Edges.Agent != nil {
+			nickname = t.Edges.Agent.Nickname
+		}
+
+		sent := ""
+		if !t.Sent.IsZero() {
+			sent = t.Sent.Format("2006-01-02T15:04:05")
+		}
+
+		record := []string{job.PackageName, job.PackageVersion, job.Action, job.CreatedBy, job.Start.Format("2006-01-02T15:04:05"), nickname, string(t.Status), sent, t.Updated.Format("2006-01-02T15:04:05"), t.Message}
+		if err := w.Write(record); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+		}
+	}
+
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_write_to_csv"), false))
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
 func (h *Handler) GenerateSoftwareCSVReport(c echo.Context, w *csv.Writer, fileName string) error {
 	commonInfo, err := h.GetCommonInfo(c)
 	if err != nil {
@@ -592,6 +912,7 @@ func (h *Handler) GetComputerFilters(c echo.Context) (*filters.AgentFilter, erro
 
 	f.Nickname = c.FormValue("filterByNickname")
 	f.Username = c.FormValue("filterByUsername")
+	f.LoggedOnUser = c.FormValue("filterByLoggedOnUser")
 
 	availableOSes, err := h.Model.GetAgentsUsedOSes(commonInfo)
 	if err != nil {
@@ -991,6 +1312,204 @@ func getSoftwareTransactions(apps []models.App) []core.Row {
 	return rows
 }
 
+func (h *Handler) GenerateLicensesReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	fileName := uuid.NewString() + ".pdf"
+	dstPath := filepath.Join(h.DownloadDir, fileName)
+
+	compliance, err := h.Model.GetLicenseCompliance(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_licenses"), false))
+	}
+
+	m, err := GetLicensesReport(c, compliance)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_initiate_report"), false))
+	}
+
+	document, err := m.Generate()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_generate_report"), false))
+	}
+
+	err = document.Save(dstPath)
+	if err != nil {
+		return err
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func GetLicensesReport(c echo.Context, compliance []models.LicenseCompliance) (core.Maroto, error) {
+	cfg := config.NewBuilder().
+		WithPageNumber().
+		WithLeftMargin(10).
+		WithTopMargin(10).
+		WithOrientation(orientation.Horizontal).
+		WithRightMargin(10).
+		Build()
+
+	mrt := maroto.New(cfg)
+	m := maroto.NewMetricsDecorator(mrt)
+
+	tableHeader := []core.Row{
+		getPageHeader(i18n.T(c.Request().Context(), "licenses.title")),
+		row.New(5).Add(
+			text.NewCol(3, i18n.T(c.Request().Context(), "licenses.product"), props.Text{Size: 9, Left: 3, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "licenses.seats"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "licenses.in_use"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "licenses.cost"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(3, i18n.T(c.Request().Context(), "licenses.status"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+		).WithStyle(&props.Cell{BackgroundColor: getDarkGreenColor()}),
+	}
+	if err := m.RegisterHeader(tableHeader...); err != nil {
+		return nil, err
+	}
+
+	m.AddRows(getLicensesTransactions(c, compliance)...)
+
+	return m, nil
+}
+
+func getLicensesTransactions(c echo.Context, compliance []models.LicenseCompliance) []core.Row {
+	var contentsRow []core.Row
+
+	rows := []core.Row{}
+
+	for i, l := range compliance {
+		status := i18n.T(c.Request().Context(), "licenses.statuses."+l.Status)
+		switch l.Status {
+		case models.LICENSE_STATUS_UNDER:
+			status = fmt.Sprintf("%s (%d)", status, l.Shortfall)
+		case models.LICENSE_STATUS_OVER:
+			status = fmt.Sprintf("%s (%d)", status, l.Unused)
+		}
+
+		r := row.New(4).Add(
+			text.NewCol(3, l.Name, props.Text{Size: 8, Left: 3, Align: align.Left}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "licenses.seats_summary", l.DeviceSeats, l.UserSeats), props.Text{Size: 8, Align: align.Left}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "licenses.usage_summary", l.Devices, l.Users), props.Text{Size: 8, Align: align.Left}),
+			text.NewCol(2, strconv.FormatFloat(l.Cost, 'f', 2, 64), props.Text{Size: 8, Align: align.Left}),
+			text.NewCol(3, status, props.Text{Size: 8, Align: align.Left}),
+		)
+		if i%2 == 0 {
+			gray := getLightGreenColor()
+			r.WithStyle(&props.Cell{BackgroundColor: gray})
+		}
+
+		contentsRow = append(contentsRow, r)
+	}
+
+	rows = append(rows, contentsRow...)
+
+	return rows
+}
+
+func (h *Handler) GenerateVulnerabilitiesReport(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	fileName := uuid.NewString() + ".pdf"
+	dstPath := filepath.Join(h.DownloadDir, fileName)
+
+	p := partials.PaginationAndSort{SortBy: "score", SortOrder: "desc"}
+	exposures, _, err := h.Model.GetVulnerabilityExposures(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_get_all_vulnerabilities"), false))
+	}
+
+	m, err := GetVulnerabilitiesReport(c, exposures)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_initiate_report"), false))
+	}
+
+	document, err := m.Generate()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "reports.could_not_generate_report"), false))
+	}
+
+	err = document.Save(dstPath)
+	if err != nil {
+		return err
+	}
+
+	// Redirect to file
+	url := "/download/" + fileName
+	c.Response().Header().Set("HX-Redirect", url)
+
+	return c.String(http.StatusOK, "")
+}
+
+func GetVulnerabilitiesReport(c echo.Context, exposures []models.VulnerabilityExposure) (core.Maroto, error) {
+	cfg := config.NewBuilder().
+		WithPageNumber().
+		WithLeftMargin(10).
+		WithTopMargin(10).
+		WithOrientation(orientation.Horizontal).
+		WithRightMargin(10).
+		Build()
+
+	mrt := maroto.New(cfg)
+	m := maroto.NewMetricsDecorator(mrt)
+
+	tableHeader := []core.Row{
+		getPageHeader(i18n.T(c.Request().Context(), "vulnerabilities.title")),
+		row.New(5).Add(
+			text.NewCol(2, "CVE", props.Text{Size: 9, Left: 3, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "vulnerabilities.severity"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(6, i18n.T(c.Request().Context(), "vulnerabilities.summary"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+			text.NewCol(2, i18n.T(c.Request().Context(), "vulnerabilities.computers"), props.Text{Size: 9, Align: align.Left, Style: fontstyle.Bold, Color: &props.WhiteColor}),
+		).WithStyle(&props.Cell{BackgroundColor: getDarkGreenColor()}),
+	}
+	if err := m.RegisterHeader(tableHeader...); err != nil {
+		return nil, err
+	}
+
+	m.AddRows(getVulnerabilitiesTransactions(c, exposures)...)
+
+	return m, nil
+}
+
+func getVulnerabilitiesTransactions(c echo.Context, exposures []models.VulnerabilityExposure) []core.Row {
+	var contentsRow []core.Row
+
+	rows := []core.Row{}
+
+	for i, e := range exposures {
+		summary := e.Summary
+		if len(summary) > 110 {
+			summary = summary[:107] + "..."
+		}
+
+		r := row.New(4).Add(
+			text.NewCol(2, e.CVE, props.Text{Size: 8, Left: 3, Align: align.Left}),
+			text.NewCol(2, fmt.Sprintf("%s (%.1f)", i18n.T(c.Request().Context(), "vulnerabilities.severities."+e.Severity), e.Score), props.Text{Size: 8, Align: align.Left}),
+			text.NewCol(6, summary, props.Text{Size: 8, Align: align.Left}),
+			text.NewCol(2, strconv.Itoa(e.Computers), props.Text{Size: 8, Align: align.Left}),
+		)
+		if i%2 == 0 {
+			gray := getLightGreenColor()
+			r.WithStyle(&props.Cell{BackgroundColor: gray})
+		}
+
+		contentsRow = append(contentsRow, r)
+	}
+
+	rows = append(rows, contentsRow...)
+
+	return rows
+}
+
 func getPageHeader(title string) core.Row {
 	cwd, err := utils.GetWd()
 	if err != nil {
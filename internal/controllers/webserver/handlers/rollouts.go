@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/deploy_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) RolloutPlans(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	if c.Request().Method == "POST" {
+		if c.FormValue("planId") != "" {
+			planID, err := strconv.Atoi(c.FormValue("planId"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_plan"), true))
+			}
+
+			r, err := getRolloutRingRequest(c)
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_ring"), true))
+			}
+
+			if err := h.Model.AddRolloutRing(tenantID, planID, r); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_add_ring", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "rollouts.ring_added")
+		} else {
+			if err := h.Model.AddRolloutPlan(tenantID, c.FormValue("rollout-plan-name"), c.FormValue("rollout-plan-description")); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_add_plan", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "rollouts.plan_added")
+		}
+	}
+
+	if c.Request().Method == "DELETE" {
+		if c.FormValue("ringId") != "" {
+			ringID, err := strconv.Atoi(c.FormValue("ringId"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_ring"), true))
+			}
+
+			if err := h.Model.DeleteRolloutRing(tenantID, ringID); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_delete_ring", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "rollouts.ring_deleted")
+		} else {
+			planID, err := strconv.Atoi(c.FormValue("planId"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_plan"), true))
+			}
+
+			if err := h.Model.DeleteRolloutPlan(tenantID, planID); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_delete_plan", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "rollouts.plan_deleted")
+		}
+	}
+
+	plans, err := h.Model.GetRolloutPlans(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_get", err.Error()), true))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	tags, err := h.Model.GetAllTags(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	return RenderView(c, deploy_views.DeployIndex("| Deploy", deploy_views.RolloutPlans(c, plans, sites, tags, successMessage, commonInfo), commonInfo))
+}
+
+// getRolloutRingRequest reads the ring form, the value comes from the field of the selected kind
+func getRolloutRingRequest(c echo.Context) (models.RolloutRingRequest, error) {
+	var err error
+
+	r := models.RolloutRingRequest{
+		Kind:        c.FormValue("rollout-ring-kind"),
+		AutoPromote: c.FormValue("rollout-ring-auto-promote") == "on",
+	}
+
+	value := c.FormValue("rollout-ring-percentage")
+	switch r.Kind {
+	case "tag":
+		value = c.FormValue("rollout-ring-tag")
+	case "site":
+		value = c.FormValue("rollout-ring-site")
+	}
+
+	if r.Value, err = strconv.Atoi(value); err != nil {
+		return r, err
+	}
+	if r.WaitHours, err = strconv.Atoi(c.FormValue("rollout-ring-wait-hours")); err != nil {
+		return r, err
+	}
+	if r.MinSuccessRate, err = strconv.Atoi(c.FormValue("rollout-ring-success-rate")); err != nil {
+		return r, err
+	}
+	if r.MaxFailures, err = strconv.Atoi(c.FormValue("rollout-ring-max-failures")); err != nil {
+		return r, err
+	}
+
+	return r, nil
+}
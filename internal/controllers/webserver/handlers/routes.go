@@ -15,6 +15,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.GET("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.POST("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.DELETE("/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
+	e.GET("/agents/commands", h.PendingAgentCommands, h.IsAuthenticated)
 	e.GET("/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.POST("/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.GET("/agents/enable", h.AgentsEnable, h.IsAuthenticated)
@@ -38,6 +39,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.GET("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.DELETE("/tenant/:tenant/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/agents/commands", h.PendingAgentCommands, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/agents/enable", h.AgentsEnable, h.IsAuthenticated)
@@ -61,6 +63,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.GET("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
 	e.DELETE("/tenant/:tenant/site/:site/agents", func(c echo.Context) error { return h.ListAgents(c, "", "", false) }, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/agents/commands", h.PendingAgentCommands, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/agents/admit", h.AgentsAdmit, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/agents/enable", h.AgentsEnable, h.IsAuthenticated)
@@ -118,6 +121,17 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.GET("/admin/certificates", h.ListCertificates, h.IsAuthenticated)
 	e.POST("/admin/certificates", h.CertificateConfirmRevocation, h.IsAuthenticated)
 	e.DELETE("/admin/certificates", h.RevocateCertificate, h.IsAuthenticated)
+	e.GET("/admin/software-catalogue", h.SoftwareCatalogue, h.IsAuthenticated)
+	e.POST("/admin/software-catalogue", h.SoftwareCatalogue, h.IsAuthenticated)
+	e.DELETE("/admin/software-catalogue", h.SoftwareCatalogue, h.IsAuthenticated)
+	e.GET("/admin/packages", h.PrivatePackages, h.IsAuthenticated)
+	e.POST("/admin/packages", h.PrivatePackages, h.IsAuthenticated)
+	e.DELETE("/admin/packages", h.PrivatePackages, h.IsAuthenticated)
+	e.GET("/admin/mirror", func(c echo.Context) error { return h.CatalogMirror(c, "") }, h.IsAuthenticated)
+	e.POST("/admin/mirror/import", h.UploadCatalogBundle, h.IsAuthenticated)
+	e.POST("/admin/mirror/rollback", h.RollbackCatalogBundle, h.IsAuthenticated)
+	e.GET("/admin/vulnerabilities", h.VulnerabilityFeeds, h.IsAuthenticated)
+	e.POST("/admin/vulnerabilities", h.VulnerabilityFeeds, h.IsAuthenticated)
 	e.GET("/admin/authentication", h.AuthenticationSettings, h.IsAuthenticated)
 	e.POST("/admin/authentication", h.AuthenticationSettings, h.IsAuthenticated)
 	e.GET("/admin/update-servers", h.UpdateServers, h.IsAuthenticated)
@@ -156,6 +170,17 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/admin/sites/:site", h.EditSite, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/admin/sites/:site/confirm-delete", func(c echo.Context) error { return h.ListSites(c, "", "", true) }, h.IsAuthenticated)
 	e.DELETE("/tenant/:tenant/admin/sites/:site", h.DeleteSite, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/admin/site-rules", h.SiteRules, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/admin/site-rules", h.SiteRules, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/admin/site-rules", h.SiteRules, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/admin/site-rules/dry-run", h.SiteRulesDryRun, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/admin/site-rules/apply", h.ApplySiteRules, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/admin/maintenance-windows", h.MaintenanceWindows, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/admin/maintenance-windows", h.MaintenanceWindows, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/admin/maintenance-windows", h.MaintenanceWindows, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/admin/change-control", h.ChangeControl, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/admin/change-control", h.ChangeControl, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/admin/change-control", h.ChangeControl, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/admin/rustdesk/inherit", h.ApplyGlobalRustDeskSettings, h.IsAuthenticated)
 
 	e.GET("/dashboard", h.Dashboard, h.IsAuthenticated)
@@ -171,6 +196,22 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated)
 	e.GET("/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
 	e.POST("/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated)
+	e.POST("/deploy/versions", h.PackageVersions, h.IsAuthenticated)
+	e.GET("/deploy/jobs", func(c echo.Context) error { return h.DeploymentJobs(c, "") }, h.IsAuthenticated)
+	e.GET("/deploy/jobs/:id", h.DeploymentJob, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/approve", func(c echo.Context) error { return h.DeploymentJobAction(c, "approve") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/reject", func(c echo.Context) error { return h.DeploymentJobAction(c, "reject") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/promote", func(c echo.Context) error { return h.DeploymentJobAction(c, "promote") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/pause", func(c echo.Context) error { return h.DeploymentJobAction(c, "pause") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/resume", func(c echo.Context) error { return h.DeploymentJobAction(c, "resume") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/abort", func(c echo.Context) error { return h.DeploymentJobAction(c, "abort") }, h.IsAuthenticated)
+	e.POST("/deploy/jobs/:id/rollback", h.DeploymentJobRollback, h.IsAuthenticated)
+	e.GET("/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.POST("/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.DELETE("/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.GET("/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.POST("/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.DELETE("/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/deploy", h.DeployInstall, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/deploy/install", h.DeployInstall, h.IsAuthenticated)
@@ -181,6 +222,22 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/versions", h.PackageVersions, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/deploy/jobs", func(c echo.Context) error { return h.DeploymentJobs(c, "") }, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/deploy/jobs/:id", h.DeploymentJob, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/approve", func(c echo.Context) error { return h.DeploymentJobAction(c, "approve") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/reject", func(c echo.Context) error { return h.DeploymentJobAction(c, "reject") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/promote", func(c echo.Context) error { return h.DeploymentJobAction(c, "promote") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/pause", func(c echo.Context) error { return h.DeploymentJobAction(c, "pause") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/resume", func(c echo.Context) error { return h.DeploymentJobAction(c, "resume") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/abort", func(c echo.Context) error { return h.DeploymentJobAction(c, "abort") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/jobs/:id/rollback", h.DeploymentJobRollback, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/site/:site/deploy", h.DeployInstall, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/deploy/install", h.DeployInstall, h.IsAuthenticated)
@@ -191,6 +248,22 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/site/:site/deploy/searchuninstall", func(c echo.Context) error { return h.SearchPackagesAction(c, false) }, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.SelectPackageDeployment, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/deploy/selectpackagedeployment", h.DeployPackageToSelectedAgents, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/versions", h.PackageVersions, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/deploy/jobs", func(c echo.Context) error { return h.DeploymentJobs(c, "") }, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/deploy/jobs/:id", h.DeploymentJob, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/approve", func(c echo.Context) error { return h.DeploymentJobAction(c, "approve") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/reject", func(c echo.Context) error { return h.DeploymentJobAction(c, "reject") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/promote", func(c echo.Context) error { return h.DeploymentJobAction(c, "promote") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/pause", func(c echo.Context) error { return h.DeploymentJobAction(c, "pause") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/resume", func(c echo.Context) error { return h.DeploymentJobAction(c, "resume") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/abort", func(c echo.Context) error { return h.DeploymentJobAction(c, "abort") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/jobs/:id/rollback", h.DeploymentJobRollback, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/site/:site/deploy/rollouts", h.RolloutPlans, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/site/:site/deploy/assignments", h.DeploymentAssignments, h.IsAuthenticated)
 
 	e.GET("/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
 	e.POST("/computers", func(c echo.Context) error { return h.ComputersList(c, "", false) }, h.IsAuthenticated)
@@ -231,6 +304,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
 	e.POST("/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
 	e.POST("/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
+	e.POST("/computers/:uuid/deploy/rollback", h.ComputerDeployRollback, h.IsAuthenticated)
 	e.POST("/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
 	e.GET("/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
 	e.POST("/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
@@ -286,6 +360,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/computers/:uuid/deploy/rollback", h.ComputerDeployRollback, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
@@ -338,6 +413,7 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/searchinstall", h.ComputerDeploySearchPackagesInstall, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/install", h.ComputerDeployInstall, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/update", h.ComputerDeployUpdate, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/rollback", h.ComputerDeployRollback, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/computers/:uuid/deploy/uninstall", h.ComputerDeployUninstall, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/computers/:uuid/metadata", h.ComputerMetadata, h.IsAuthenticated)
@@ -397,6 +473,8 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
 	e.POST("/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
 	e.POST("/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
+	e.POST("/reports/licenses", h.GenerateLicensesReport, h.IsAuthenticated)
+	e.POST("/reports/vulnerabilities", h.GenerateVulnerabilitiesReport, h.IsAuthenticated)
 	e.POST("/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
 	e.POST("/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
 	e.POST("/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
@@ -406,6 +484,8 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/reports/licenses", h.GenerateLicensesReport, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/reports/vulnerabilities", h.GenerateVulnerabilitiesReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
@@ -415,6 +495,8 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/site/:site/reports/antivirus", h.GenerateAntivirusReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/reports/updates", h.GenerateUpdatesReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/reports/software", h.GenerateSoftwareReport, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/reports/licenses", h.GenerateLicensesReport, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/reports/vulnerabilities", h.GenerateVulnerabilitiesReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/reports/computer/:uuid", h.GenerateComputerReport, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/reports/:report/csv", h.GenerateCSVReports, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/reports/computer/:uuid/ods", h.GenerateComputerODSReport, h.IsAuthenticated)
@@ -427,6 +509,14 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
 	e.GET("/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
 	e.POST("/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
+	e.GET("/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.POST("/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.DELETE("/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.GET("/security/violations", h.PolicyViolations, h.IsAuthenticated)
+	e.GET("/security/vulnerabilities", h.Vulnerabilities, h.IsAuthenticated)
+	e.GET("/security/vulnerabilities/cve/:cve", h.Vulnerability, h.IsAuthenticated)
+	e.GET("/security/vulnerabilities/computers", h.ExposedComputers, h.IsAuthenticated)
+	e.GET("/security/vulnerabilities/computers/:uuid", h.ComputerVulnerabilities, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/security", h.ListAntivirusStatus, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/security", h.ListAntivirusStatus, h.IsAuthenticated)
@@ -436,6 +526,14 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/violations", h.PolicyViolations, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/vulnerabilities", h.Vulnerabilities, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/vulnerabilities/cve/:cve", h.Vulnerability, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/vulnerabilities/computers", h.ExposedComputers, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/security/vulnerabilities/computers/:uuid", h.ComputerVulnerabilities, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/security", h.ListAntivirusStatus, h.IsAuthenticated)
@@ -445,15 +543,116 @@ func (h *Handler) Register(e *echo.Echo) {
 	e.POST("/tenant/:tenant/site/:site/security/antivirus", h.ListAntivirusStatus, h.IsAuthenticated)
 	e.GET("/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/security/updates", h.ListSecurityUpdatesStatus, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/site/:site/security/policies", h.SoftwarePolicies, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/violations", h.PolicyViolations, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/vulnerabilities", h.Vulnerabilities, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/vulnerabilities/cve/:cve", h.Vulnerability, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/vulnerabilities/computers", h.ExposedComputers, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/security/vulnerabilities/computers/:uuid", h.ComputerVulnerabilities, h.IsAuthenticated)
 
 	e.GET("/software", h.Software, h.IsAuthenticated)
 	e.POST("/software", h.Software, h.IsAuthenticated)
+	e.GET("/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/software/drift/upgrade", h.UpgradeLaggards, h.IsAuthenticated)
+	e.GET("/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/software/usage/reclaim", h.ReclaimUnusedApp, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/software", h.Software, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/software", h.Software, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/software/drift/upgrade", h.UpgradeLaggards, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/software/usage/reclaim", h.ReclaimUnusedApp, h.IsAuthenticated)
 
 	e.GET("/tenant/:tenant/site/:site/software", h.Software, h.IsAuthenticated)
 	e.POST("/tenant/:tenant/site/:site/software", h.Software, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/software/drift", func(c echo.Context) error { return h.VersionDrift(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/software/drift/upgrade", h.UpgradeLaggards, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/software/usage", func(c echo.Context) error { return h.AppUsage(c, "") }, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/software/usage/reclaim", h.ReclaimUnusedApp, h.IsAuthenticated)
+
+	e.GET("/disks", h.DisksNearlyFull, h.IsAuthenticated)
+	e.POST("/disks", h.DisksNearlyFull, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/disks", h.DisksNearlyFull, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/disks", h.DisksNearlyFull, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/site/:site/disks", h.DisksNearlyFull, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/disks", h.DisksNearlyFull, h.IsAuthenticated)
+
+	e.GET("/network", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.POST("/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.POST("/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.GET("/network/conflicts", h.NetworkConflicts, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/network", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/network/conflicts", h.NetworkConflicts, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/site/:site/network", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/network/subnets", h.NetworkSubnets, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/network/subnet", h.NetworkSubnet, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/network/conflicts", h.NetworkConflicts, h.IsAuthenticated)
+
+	e.GET("/peripherals", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/peripherals/monitors", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/peripherals/printers", h.PeripheralPrinters, h.IsAuthenticated)
+	e.GET("/peripherals/changes", h.PeripheralChanges, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/peripherals", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/peripherals/monitors", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/peripherals/printers", h.PeripheralPrinters, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/peripherals/changes", h.PeripheralChanges, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/site/:site/peripherals", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/peripherals/monitors", h.PeripheralMonitors, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/peripherals/printers", h.PeripheralPrinters, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/peripherals/changes", h.PeripheralChanges, h.IsAuthenticated)
+
+	e.GET("/logged-users", h.LoggedOnUsers, h.IsAuthenticated)
+	e.GET("/search", h.Search, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/logged-users", h.LoggedOnUsers, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/search", h.Search, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/site/:site/logged-users", h.LoggedOnUsers, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/search", h.Search, h.IsAuthenticated)
+
+	e.GET("/licenses", h.Licenses, h.IsAuthenticated)
+	e.POST("/licenses", h.Licenses, h.IsAuthenticated)
+	e.DELETE("/licenses", h.Licenses, h.IsAuthenticated)
+	e.GET("/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.POST("/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.DELETE("/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/licenses", h.Licenses, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/licenses", h.Licenses, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/licenses", h.Licenses, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+
+	e.GET("/tenant/:tenant/site/:site/licenses", h.Licenses, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/licenses", h.Licenses, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/site/:site/licenses", h.Licenses, h.IsAuthenticated)
+	e.GET("/tenant/:tenant/site/:site/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.POST("/tenant/:tenant/site/:site/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
+	e.DELETE("/tenant/:tenant/site/:site/licenses/:id", h.LicenseProduct, h.IsAuthenticated)
 
 	e.GET("/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
 	e.POST("/tasks/:profile/new", h.NewTask, h.IsAuthenticated)
@@ -118,6 +118,17 @@ func (h *Handler) GeneralSettings(c echo.Context) error {
 			}
 		}
 
+		if source := c.FormValue("catalog-source"); source != "" {
+			enabled, err := strconv.ParseBool(c.FormValue("catalog-source-enabled"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.use_catalog_source_invalid"), true))
+			}
+
+			if err := h.Model.SetCatalogSourceEnabled(commonInfo.TenantID, source, enabled); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.use_catalog_source_could_not_be_saved"), true))
+			}
+		}
+
 		if settings.WinGetFrequency != 0 {
 			return h.ChangeWingetFrequency(c, settings)
 		}
@@ -165,7 +176,12 @@ func (h *Handler) GeneralSettings(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
-	return RenderView(c, admin_views.GeneralSettingsIndex(" | General Settings", admin_views.GeneralSettings(c, settings, agentsExists, serversExists, allTags, commonInfo, h.GetAdminTenantName(commonInfo), ""), commonInfo))
+	catalogSources, err := h.getCatalogSourceSettings(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.GeneralSettingsIndex(" | General Settings", admin_views.GeneralSettings(c, settings, catalogSources, agentsExists, serversExists, allTags, commonInfo, h.GetAdminTenantName(commonInfo), ""), commonInfo))
 }
 
 func validateGeneralSettings(c echo.Context) (*models.GeneralSettings, error) {
@@ -401,7 +417,7 @@ func (h *Handler) ChangeAgentFrequency(c echo.Context, settings *models.GeneralS
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_error"), true))
 	}
 
@@ -417,7 +433,7 @@ func (h *Handler) ChangeAgentFrequency(c echo.Context, settings *models.GeneralS
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.agent_frequency_could_not_be_saved"), true))
@@ -470,7 +486,7 @@ func (h *Handler) ChangeWingetFrequency(c echo.Context, settings *models.General
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_error"), true))
 	}
 
@@ -486,7 +502,7 @@ func (h *Handler) ChangeWingetFrequency(c echo.Context, settings *models.General
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.winget_configure_frequency_could_not_be_saved"), true))
@@ -539,7 +555,7 @@ func (h *Handler) ChangeSFTPSetting(c echo.Context, settings *models.GeneralSett
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_error"), true))
 	}
 
@@ -555,7 +571,7 @@ func (h *Handler) ChangeSFTPSetting(c echo.Context, settings *models.GeneralSett
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_sftp_could_not_be_saved"), true))
@@ -613,7 +629,7 @@ func (h *Handler) ChangeRemoteAssistanceSetting(c echo.Context, settings *models
 		return err
 	}
 
-	if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+	if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_error"), true))
 	}
 
@@ -629,7 +645,7 @@ func (h *Handler) ChangeRemoteAssistanceSetting(c echo.Context, settings *models
 			return err
 		}
 
-		if err := h.NATSConnection.Publish("agent.newconfig", data); err != nil {
+		if err := h.PublishAgentsCommand("newconfig", "agent.newconfig", data); err != nil {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_error"), true))
 		}
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "settings.disable_remote_assistance_could_not_be_saved"), true))
@@ -684,5 +700,10 @@ func (h *Handler) ApplyGlobalSettings(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(err.Error(), false))
 	}
 
-	return RenderView(c, admin_views.GeneralSettingsIndex(" | General Settings", admin_views.GeneralSettings(c, settings, agentsExists, serversExists, allTags, commonInfo, h.GetAdminTenantName(commonInfo), i18n.T(c.Request().Context(), "settings.saved")), commonInfo))
+	catalogSources, err := h.getCatalogSourceSettings(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.GeneralSettingsIndex(" | General Settings", admin_views.GeneralSettings(c, settings, catalogSources, agentsExists, serversExists, allTags, commonInfo, h.GetAdminTenantName(commonInfo), i18n.T(c.Request().Context(), "settings.saved")), commonInfo))
 }
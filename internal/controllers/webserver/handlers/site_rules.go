@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) SiteRules(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	if c.Request().Method == "POST" {
+		if c.FormValue("ruleId") != "" {
+			ruleID, err := strconv.Atoi(c.FormValue("ruleId"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.invalid_rule"), true))
+			}
+
+			if err := h.Model.MoveSiteRule(tenantID, ruleID, c.FormValue("direction") == "up"); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_move", err.Error()), true))
+			}
+		} else {
+			siteID, err := strconv.Atoi(c.FormValue("site-rule-site"))
+			if err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.invalid_site"), true))
+			}
+
+			ruleType := c.FormValue("site-rule-type")
+			value := c.FormValue("site-rule-value")
+			if ruleType == "tag" {
+				value = c.FormValue("site-rule-tag")
+			}
+
+			if err := h.Model.AddSiteRule(tenantID, siteID, ruleType, value); err != nil {
+				return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_add", err.Error()), true))
+			}
+			successMessage = i18n.T(c.Request().Context(), "site_rules.added")
+		}
+	}
+
+	if c.Request().Method == "DELETE" {
+		ruleID, err := strconv.Atoi(c.FormValue("ruleId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.invalid_rule"), true))
+		}
+
+		if err := h.Model.DeleteSiteRule(tenantID, ruleID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_delete", err.Error()), true))
+		}
+		successMessage = i18n.T(c.Request().Context(), "site_rules.deleted")
+	}
+
+	return h.renderSiteRules(c, commonInfo, tenantID, nil, successMessage)
+}
+
+func (h *Handler) SiteRulesDryRun(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	moves, err := h.Model.EvaluateSiteRules(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_evaluate", err.Error()), true))
+	}
+
+	return h.renderSiteRules(c, commonInfo, tenantID, moves, "")
+}
+
+func (h *Handler) ApplySiteRules(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), true))
+	}
+
+	moved, err := h.Model.ApplySiteRules(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_apply", err.Error()), true))
+	}
+
+	return h.renderSiteRules(c, commonInfo, tenantID, nil, i18n.T(c.Request().Context(), "site_rules.applied", moved))
+}
+
+func (h *Handler) renderSiteRules(c echo.Context, commonInfo *partials.CommonInfo, tenantID int, moves []models.SiteRuleMove, successMessage string) error {
+	rules, err := h.Model.GetSiteRules(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "site_rules.could_not_get", err.Error()), true))
+	}
+
+	sites, err := h.Model.GetSites(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	tags, err := h.Model.GetAllTags(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.SitesIndex(" | Site Rules", admin_views.SiteRules(c, rules, sites, tags, moves, successMessage, agentsExists, serversExists, commonInfo, h.GetAdminTenantName(commonInfo)), commonInfo))
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"strconv"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+func (h *Handler) SoftwareCatalogue(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Request().Method == "POST" {
+		if err := h.Model.AddSoftwareAlias(c.FormValue("alias-kind"), c.FormValue("alias-pattern"), c.FormValue("alias-canonical")); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_catalogue.could_not_add", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "software_catalogue.added")
+	}
+
+	if c.Request().Method == "DELETE" {
+		aliasID, err := strconv.Atoi(c.FormValue("aliasId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_catalogue.invalid_alias"), false))
+		}
+
+		if err := h.Model.DeleteSoftwareAlias(aliasID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_catalogue.could_not_delete", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "software_catalogue.deleted")
+	}
+
+	aliases, err := h.Model.GetSoftwareAliases()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_catalogue.could_not_get", err.Error()), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.SoftwareCatalogueIndex(" | Software catalogue", admin_views.SoftwareCatalogue(c, aliases, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
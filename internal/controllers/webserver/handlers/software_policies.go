@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-co-op/gocron/v2"
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/ent"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/scncore/scnorion-console/internal/views/security_views"
+)
+
+func (h *Handler) SoftwarePolicies(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	if c.Request().Method == "POST" {
+		autoRemediate := c.FormValue("policy-auto-remediate") == "on"
+		packages := models.SoftwarePolicyPackages{Windows: c.FormValue("policy-package-id"), Linux: c.FormValue("policy-flatpak-package-id"), MacOS: c.FormValue("policy-brew-package-id")}
+		if err := h.Model.AddSoftwarePolicy(tenantID, c.FormValue("policy-name"), c.FormValue("policy-mode"), c.FormValue("policy-name-pattern"), c.FormValue("policy-publisher-pattern"), c.FormValue("policy-min-version"), c.FormValue("policy-max-version"), c.FormValue("policy-email"), autoRemediate, packages); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_policies.could_not_add", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "software_policies.added")
+	}
+
+	if c.Request().Method == "DELETE" {
+		policyID, err := strconv.Atoi(c.FormValue("policyId"))
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_policies.invalid_policy"), false))
+		}
+
+		if err := h.Model.DeleteSoftwarePolicy(tenantID, policyID); err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_policies.could_not_delete", err.Error()), false))
+		}
+		successMessage = i18n.T(c.Request().Context(), "software_policies.deleted")
+	}
+
+	policies, err := h.Model.GetSoftwarePolicies(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "software_policies.could_not_get", err.Error()), false))
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.SoftwarePolicies(c, policies, successMessage, commonInfo), commonInfo))
+}
+
+func (h *Handler) PolicyViolations(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "firstSeen"
+		p.SortOrder = "desc"
+	}
+
+	p.NItems, err = h.Model.CountPolicyViolations(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	violations, err := h.Model.GetPolicyViolationsByPage(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.PolicyViolations(c, p, violations, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) StartPolicyViolationsJob() error {
+	var err error
+
+	// Create task
+	_, err = h.TaskScheduler.NewJob(
+		gocron.DurationJob(
+			time.Duration(5*time.Minute),
+		),
+		gocron.NewTask(
+			func() {
+				h.SendPolicyViolations()
+			},
+		),
+	)
+	if err != nil {
+		log.Printf("[FATAL]: could not start the policy violations job: %v", err)
+		return err
+	}
+	log.Println("[INFO]: policy violations job has been scheduled every 5 minutes")
+	return nil
+}
+
+// SendPolicyViolations emails the violations that haven't been notified yet and requests the uninstallation
+// of denied packages when the policy is auto-remediated. What couldn't be sent is retried in the next run
+func (h *Handler) SendPolicyViolations() {
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		log.Println("[ERROR]: could not send software policy violations, NATS is not connected")
+		return
+	}
+
+	tenants, err := h.Model.GetTenants()
+	if err != nil {
+		log.Printf("[ERROR]: could not get tenants to send software policy violations, reason: %v", err)
+		return
+	}
+
+	for _, t := range tenants {
+		notifications, err := h.Model.GetPendingPolicyNotifications(t.ID)
+		if err != nil {
+			log.Printf("[ERROR]: could not get the policy violations to notify for tenant %d, reason: %v", t.ID, err)
+		} else {
+			h.notifyPolicyViolations(t, notifications)
+		}
+
+		remediations, err := h.Model.GetPendingPolicyRemediations(t.ID)
+		if err != nil {
+			log.Printf("[ERROR]: could not get the policy violations to remediate for tenant %d, reason: %v", t.ID, err)
+		} else {
+			h.remediatePolicyViolations(t, remediations)
+		}
+	}
+}
+
+func (h *Handler) notifyPolicyViolations(t *ent.Tenant, notifications []models.PolicyNotification) {
+	for _, n := range notifications {
+		lines := []string{}
+		ids := []int{}
+		for _, v := range n.Violations {
+			policy := "not in the allow list"
+			if v.Edges.Policy != nil {
+				policy = v.Edges.Policy.Name
+			}
+			lines = append(lines, fmt.Sprintf("%s: %s %s (%s)", v.Edges.Owner.Nickname, v.AppName, v.AppVersion, policy))
+			ids = append(ids, v.ID)
+		}
+
+		notification := scnorion_nats.Notification{
+			To:               n.Email,
+			Subject:          "scnorion | Software policy violations",
+			MessageTitle:     "scnorion | Software policy violations",
+			MessageText:      fmt.Sprintf("The following applications are not allowed in %s: %s", t.Description, strings.Join(lines, "; ")),
+			MessageGreeting:  "Hi",
+			MessageAction:    "Show violations",
+			MessageActionURL: fmt.Sprintf("https://%s:%s/tenant/%d/security/violations", h.ServerName, h.ConsolePort, t.ID),
+		}
+
+		data, err := json.Marshal(notification)
+		if err != nil {
+			log.Printf("[ERROR]: could not marshal software policy notification, reason: %v", err)
+			continue
+		}
+
+		if err := h.NATSConnection.Publish("notification.software_policy", data); err != nil {
+			log.Printf("[ERROR]: could not send software policy notification, reason: %v", err)
+			continue
+		}
+
+		if err := h.Model.SetPolicyViolationsNotified(ids); err != nil {
+			log.Printf("[ERROR]: could not mark violations as notified, reason: %v", err)
+		}
+	}
+}
+
+func (h *Handler) remediatePolicyViolations(t *ent.Tenant, remediations []models.PolicyRemediation) {
+	commonInfo := &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: "-1"}
+
+	for _, r := range remediations {
+		action := scnorion_nats.DeployAction{
+			AgentId:     r.AgentID,
+			PackageId:   r.PackageID,
+			PackageName: r.AppName,
+			Action:      "uninstall",
+		}
+
+		actionBytes, err := h.marshalDeployAction(action)
+		if err != nil {
+			log.Printf("[ERROR]: could not marshal uninstall action, reason: %v", err)
+			continue
+		}
+
+		if err := h.PublishAgentCommand(action.AgentId, "uninstall", "agent.uninstallpackage."+action.AgentId, action.PackageName, actionBytes); err != nil {
+			log.Printf("[ERROR]: could not request the uninstallation of %s, reason: %v", action.PackageId, err)
+			continue
+		}
+
+		// The uninstallation has been requested, so it's not requested again even if the rest fails
+		if err := h.Model.SetPolicyViolationRemediated(r.ViolationID); err != nil {
+			log.Printf("[ERROR]: could not mark violation as remediated, reason: %v", err)
+		}
+
+		if err := h.Model.SaveDeployInfo(&action, false, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not save uninstall deployment info, reason: %v", err)
+		}
+	}
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"errors"
 	"fmt"
+	"log"
 	"slices"
 	"strconv"
 	"strings"
@@ -25,6 +26,10 @@ func (h *Handler) NewTask(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	profile := c.Param("profile")
 	if profile == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tasks.new.empty_profile"), true))
@@ -36,6 +41,10 @@ func (h *Handler) NewTask(c echo.Context) error {
 	}
 
 	if c.Request().Method == "POST" {
+		if err := h.checkChangeFreeze(c, commonInfo, "edit profile "+profile); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		}
+
 		t, err := validateTaskForm(c)
 		if err != nil {
 			return RenderError(c, partials.ErrorMessage(fmt.Sprintf("%v", err), true))
@@ -45,6 +54,10 @@ func (h *Handler) NewTask(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(fmt.Sprintf("%s : %v", i18n.T(c.Request().Context(), "tasks.new.could_not_save"), err), true))
 		}
 
+		if err := h.HoldProfileTasks(profileID, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not send the tasks of profile %d to its agents, reason: %v", profileID, err)
+		}
+
 		return h.EditProfile(c, "GET", profile, i18n.T(c.Request().Context(), "tasks.new.saved"))
 	}
 
@@ -59,6 +72,10 @@ func (h *Handler) EditTask(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	id := c.Param("id")
 	if id == "" {
 		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tasks.edit.empty_task"), true))
@@ -78,6 +95,12 @@ func (h *Handler) EditTask(c echo.Context) error {
 		return RenderError(c, partials.ErrorMessage(fmt.Sprintf("%s : %v", i18n.T(c.Request().Context(), "tasks.edit.no_profile"), err), true))
 	}
 
+	if c.Request().Method == "POST" || c.Request().Method == "DELETE" {
+		if err := h.checkChangeFreeze(c, commonInfo, "edit profile "+task.Edges.Profile.Name); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), true))
+		}
+	}
+
 	if c.Request().Method == "POST" {
 		t, err := validateTaskForm(c)
 		if err != nil {
@@ -88,6 +111,10 @@ func (h *Handler) EditTask(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(fmt.Sprintf("%s : %v", i18n.T(c.Request().Context(), "tasks.edit.could_not_save"), err), true))
 		}
 
+		if err := h.HoldProfileTasks(task.Edges.Profile.ID, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not send the tasks of profile %d to its agents, reason: %v", task.Edges.Profile.ID, err)
+		}
+
 		return h.EditProfile(c, "GET", strconv.Itoa(task.Edges.Profile.ID), i18n.T(c.Request().Context(), "tasks.edit.saved"))
 	}
 
@@ -95,6 +122,10 @@ func (h *Handler) EditTask(c echo.Context) error {
 		if err := h.Model.DeleteTask(taskId); err != nil {
 			return RenderError(c, partials.ErrorMessage(fmt.Sprintf("%s : %v", i18n.T(c.Request().Context(), "tasks.edit.could_not_delete"), err), true))
 		}
+
+		if err := h.HoldProfileTasks(task.Edges.Profile.ID, commonInfo); err != nil {
+			log.Printf("[ERROR]: could not send the tasks of profile %d to its agents, reason: %v", task.Edges.Profile.ID, err)
+		}
 		return h.EditProfile(c, "GET", strconv.Itoa(task.Edges.Profile.ID), i18n.T(c.Request().Context(), "tasks.edit.deleted"))
 	}
 
@@ -1,7 +1,6 @@
 package handlers
 
 import (
-	"context"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -14,6 +13,7 @@ import (
 	scnorion_ent "github.com/scncore/ent"
 	"github.com/scncore/ent/release"
 	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
 	"github.com/scncore/scnorion-console/internal/views/admin_views"
 	"github.com/scncore/scnorion-console/internal/views/filters"
 	"github.com/scncore/scnorion-console/internal/views/partials"
@@ -27,6 +27,10 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 		return err
 	}
 
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
 	successMessage := ""
 	errorMessage := ""
 
@@ -53,6 +57,14 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "admin.update.agents.release_cant_be_empty"), false))
 		}
 
+		if c.FormValue("rolloutPlan") != "" || c.FormValue("respectWindow") == "on" {
+			return h.agentUpdateJob(c, strings.Split(agents, ","), sr, commonInfo)
+		}
+
+		if err := h.checkChangeFreeze(c, commonInfo, "update agents to "+sr); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
 		for a := range strings.SplitSeq(agents, ",") {
 
 			agentInfo, err := h.Model.GetAgentById(a, commonInfo)
@@ -60,28 +72,9 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 				return RenderError(c, partials.ErrorMessage(err.Error(), false))
 			}
 
-			arch := ""
-			switch agentInfo.Edges.Computer.ProcessorArch {
-			case "x64", "x86_64":
-				arch = "amd64"
-			case "aarch64":
-				arch = "arm64"
-			}
+			agentOs, arch := agentReleasePlatform(agentInfo)
 
-			switch agentInfo.Os {
-			case "debian", "ubuntu", "opensuse-leap", "linuxmint", "fedora", "manjaro", "arch", "almalinux", "rocky", "neon":
-				agentInfo.Os = "linux"
-			case "macOS":
-				agentInfo.Os = "darwin"
-				macArch := strings.TrimSpace(agentInfo.Edges.Computer.ProcessorArch)
-				if macArch == "x86_64" {
-					arch = "amd64"
-				} else {
-					arch = "arm64"
-				}
-			}
-
-			releaseToBeApplied, err := h.Model.GetAgentsReleaseByType(release.ReleaseTypeAgent, channel, agentInfo.Os, arch, sr)
+			releaseToBeApplied, err := h.Model.GetAgentsReleaseByType(release.ReleaseTypeAgent, channel, agentOs, arch, sr)
 			if err != nil {
 				log.Printf("[ERROR]: could not get release to be applied, reason: %v\n", err)
 				errorMessage = err.Error()
@@ -126,7 +119,7 @@ func (h *Handler) UpdateAgents(c echo.Context) error {
 				continue
 			}
 
-			if _, err := h.JetStream.Publish(context.Background(), "agent.update."+a, data); err != nil {
+			if err := h.PublishAgentCommand(a, "agentupdate", "agent.update."+a, i18n.T(c.Request().Context(), "admin.update.agents.task_update", releaseToBeApplied.Version), data); err != nil {
 				errorMessage = i18n.T(c.Request().Context(), "admin.update.agents.cannot_send_request")
 				if err := h.Model.SaveAgentUpdateInfo(a, "admin.update.agents.task_status_error", "admin.update.agents.cannot_send_request", releaseToBeApplied.Version, commonInfo); err != nil {
 					log.Println("[ERROR]: could not save update task info")
@@ -156,8 +149,129 @@ func (h *Handler) UpdateAgentsConfirm(c echo.Context) error {
 		return err
 	}
 
+	tenantID, err := strconv.Atoi(commonInfo.TenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "tenants.could_not_convert_to_int", err.Error()), false))
+	}
+
+	plans, err := h.Model.GetRolloutPlans(tenantID)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.could_not_get", err.Error()), false))
+	}
+
 	version := c.FormValue("filterBySelectedRelease")
-	return RenderConfirm(c, partials.ConfirmUpdateAgents(c, version, commonInfo))
+	return RenderConfirm(c, partials.ConfirmUpdateAgents(c, version, plans, commonInfo))
+}
+
+// agentReleasePlatform returns the os and arch used by the agent releases for the computer of the agent
+func agentReleasePlatform(agentInfo *scnorion_ent.Agent) (string, string) {
+	agentOs := agentInfo.Os
+
+	arch := ""
+	switch agentInfo.Edges.Computer.ProcessorArch {
+	case "x64", "x86_64":
+		arch = "amd64"
+	case "aarch64":
+		arch = "arm64"
+	}
+
+	switch agentInfo.Os {
+	case "debian", "ubuntu", "opensuse-leap", "linuxmint", "fedora", "manjaro", "arch", "almalinux", "rocky", "neon":
+		agentOs = "linux"
+	case "macOS":
+		agentOs = "darwin"
+		macArch := strings.TrimSpace(agentInfo.Edges.Computer.ProcessorArch)
+		if macArch == "x86_64" {
+			arch = "amd64"
+		} else {
+			arch = "arm64"
+		}
+	}
+
+	return agentOs, arch
+}
+
+// agentUpdateJob creates a deployment job that updates the agents ring by ring following a rollout plan,
+// or as their maintenance windows open when the update respects them
+func (h *Handler) agentUpdateJob(c echo.Context, agents []string, version string, commonInfo *partials.CommonInfo) error {
+	planID, err := getRolloutPlan(c)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "rollouts.invalid_plan"), false))
+	}
+
+	start := time.Now()
+	if c.FormValue("update-agent-date") != "" {
+		start, err = time.ParseInLocation("2006-01-02T15:04", c.FormValue("update-agent-date"), time.Local)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.invalid_start"), false))
+		}
+	}
+
+	r := models.DeploymentJobRequest{
+		PackageID:      models.AgentUpdatePackageID,
+		PackageName:    i18n.T(c.Request().Context(), "admin.update.agents.task_update", version),
+		PackageVersion: version,
+		Action:         "agentupdate",
+		Selection:      "selected",
+		Start:          start,
+		Agents:         agents,
+		PlanID:         planID,
+		RespectWindow:  c.FormValue("respectWindow") == "on",
+	}
+	if planID != 0 {
+		r.Selection = "rollout"
+	}
+
+	job, err := h.createDeploymentJob(c, r, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "deployment_jobs.could_not_create", err.Error()), false))
+	}
+
+	c.Response().Header().Set("HX-Push-Url", partials.GetNavigationUrl(commonInfo, "/deploy/jobs/"+strconv.Itoa(job.ID)))
+	if planID == 0 {
+		return h.showDeploymentJob(c, job.ID, i18n.T(c.Request().Context(), "deployment_jobs.created"))
+	}
+	return h.showDeploymentJob(c, job.ID, i18n.T(c.Request().Context(), "rollouts.created"))
+}
+
+// sendAgentUpdate asks the agent to update to the release of that version for its platform,
+// it's used by the deployment jobs so the update is applied as soon as the agent gets it
+func (h *Handler) sendAgentUpdate(agentId, version, description string, commonInfo *partials.CommonInfo) error {
+	channel, err := h.Model.GetDefaultUpdateChannel()
+	if err != nil {
+		log.Println("[ERROR]: could not get updates channel settings")
+		channel = "stable"
+	}
+
+	agentInfo, err := h.Model.GetAgentById(agentId, commonInfo)
+	if err != nil {
+		return err
+	}
+
+	agentOs, arch := agentReleasePlatform(agentInfo)
+
+	releaseToBeApplied, err := h.Model.GetAgentsReleaseByType(release.ReleaseTypeAgent, channel, agentOs, arch, version)
+	if err != nil {
+		return err
+	}
+
+	updateRequest := scnorion_nats.scnorionUpdateRequest{}
+	updateRequest.DownloadFrom = releaseToBeApplied.FileURL
+	updateRequest.DownloadHash = releaseToBeApplied.Checksum
+	updateRequest.Version = releaseToBeApplied.Version
+	updateRequest.UpdateNow = true
+
+	data, err := json.Marshal(updateRequest)
+	if err != nil {
+		return err
+	}
+
+	// The update is pending before it's published so an earlier result for the same version isn't taken as its report
+	if err := h.Model.SaveAgentUpdateInfo(agentId, "admin.update.agents.task_status_pending", description, releaseToBeApplied.Version, commonInfo); err != nil {
+		return err
+	}
+
+	return h.PublishAgentCommand(agentId, "agentupdate", "agent.update."+agentId, description, data)
 }
 
 func (h *Handler) ShowUpdateAgentList(c echo.Context, r *scnorion_ent.Release, successMessage, errorMessage string) error {
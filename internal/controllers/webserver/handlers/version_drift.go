@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/models"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/scncore/scnorion-console/internal/views/software_views"
+)
+
+func (h *Handler) VersionDrift(c echo.Context, successMessage string) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "laggards"
+		p.SortOrder = "desc"
+	}
+
+	search := c.FormValue("filterByAppName")
+
+	catalog, _, err := h.getCatalogVersions(search, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.could_not_get", err.Error()), false))
+	}
+
+	drift, total, err := h.Model.GetVersionDrift(p, search, catalog, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.could_not_get", err.Error()), false))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, software_views.SoftwareIndex(" | Software", software_views.VersionDrift(c, p, search, drift, successMessage, refreshTime, commonInfo), commonInfo))
+}
+
+// UpgradeLaggards sends an update action to every computer with a version older than the target,
+// the package to be updated is the one found in the catalogue for the computer's operating system
+func (h *Handler) UpgradeLaggards(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if err := h.GetChangeControlInfo(commonInfo); err != nil {
+		return err
+	}
+
+	appName := c.FormValue("appName")
+	if appName == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.app_required"), false))
+	}
+
+	if err := h.checkDirectDeployment(c, commonInfo, "update "+appName); err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	catalog, packages, err := h.getCatalogVersions(appName, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.could_not_get", err.Error()), false))
+	}
+
+	drift, _, err := h.Model.GetVersionDrift(partials.PaginationAndSort{}, appName, catalog, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.could_not_get", err.Error()), false))
+	}
+
+	var found *models.VersionDrift
+	for i := range drift {
+		if strings.EqualFold(drift[i].Name, appName) {
+			found = &drift[i]
+			break
+		}
+	}
+	if found == nil || found.Target() == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.app_not_found", appName), false))
+	}
+
+	laggards, err := h.Model.GetVersionLaggards(*found, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "version_drift.could_not_get", err.Error()), false))
+	}
+
+	if h.NATSConnection == nil || !h.NATSConnection.IsConnected() {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "nats.not_connected"), false))
+	}
+
+	updated := 0
+	skipped := 0
+	for _, l := range laggards {
+		pkg, ok := catalogPackageForOS(packages[strings.ToLower(appName)], l.OS)
+		if !ok {
+			skipped++
+			continue
+		}
+
+		action := scnorion_nats.DeployAction{}
+		action.AgentId = l.AgentID
+		action.PackageId = pkg.ID
+		action.PackageName = pkg.Name
+		action.Action = "update"
+
+		data, err := json.Marshal(models.CatalogDeployAction{DeployAction: action, Source: pkg.Source})
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		if err := h.PublishAgentCommand(l.AgentID, "update", "agent.updatepackage."+l.AgentID, pkg.Name, data); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		deploymentFailed, err := h.Model.DeploymentFailed(l.AgentID, pkg.ID, commonInfo)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+
+		if err := h.Model.SaveDeployInfo(&action, deploymentFailed, commonInfo); err != nil {
+			return RenderError(c, partials.ErrorMessage(err.Error(), false))
+		}
+		updated++
+	}
+
+	c.Request().Method = "GET"
+	return h.VersionDrift(c, i18n.T(c.Request().Context(), "version_drift.upgrade_sent", updated, skipped))
+}
+
+// getCatalogVersions returns the newest catalogue version of each application published by each source
+// and the catalogue packages found, indexed by lowercase name
+func (h *Handler) getCatalogVersions(search string, commonInfo *partials.CommonInfo) (map[string]map[string]string, map[string][]winget.CatalogPackage, error) {
+	names, err := h.Model.GetVersionDriftNames(search, commonInfo)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	packages, err := winget.FindCatalogPackages(names, h.catalogFolders())
+	if err != nil {
+		// The catalogue is optional, the drift is computed with the installed versions
+		log.Printf("[ERROR]: could not search the common software database, reason: %v", err)
+		return map[string]map[string]string{}, map[string][]winget.CatalogPackage{}, nil
+	}
+
+	versions := map[string]map[string]string{}
+	for name, list := range packages {
+		versions[name] = map[string]string{}
+		for _, p := range list {
+			if p.Version != "" && (versions[name][p.Source] == "" || models.CompareVersions(p.Version, versions[name][p.Source]) > 0) {
+				versions[name][p.Source] = p.Version
+			}
+		}
+	}
+
+	return versions, packages, nil
+}
+
+func catalogPackageForOS(packages []winget.CatalogPackage, os string) (winget.CatalogPackage, bool) {
+	source := winget.CatalogSourceForOS(os)
+	for _, p := range packages {
+		if p.Source == source {
+			return p, true
+		}
+	}
+	return winget.CatalogPackage{}, false
+}
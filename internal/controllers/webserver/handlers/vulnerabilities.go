@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/invopop/ctxi18n/i18n"
+	"github.com/labstack/echo/v4"
+	"github.com/scncore/ent"
+	"github.com/scncore/scnorion-console/internal/views/admin_views"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/scncore/scnorion-console/internal/views/security_views"
+)
+
+func (h *Handler) Vulnerabilities(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "score"
+		p.SortOrder = "desc"
+	}
+
+	exposures, total, err := h.Model.GetVulnerabilityExposures(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), false))
+	}
+	p.NItems = total
+
+	known, err := h.Model.CountVulnerabilities()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), false))
+	}
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.Vulnerabilities(c, p, exposures, known, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) Vulnerability(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	cve := strings.ToUpper(c.Param("cve"))
+
+	v, err := h.Model.GetVulnerability(cve)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.not_found", cve), true))
+		}
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), true))
+	}
+
+	affected, err := h.Model.GetVulnerabilityComputers(cve, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), true))
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.Vulnerability(c, v, affected, commonInfo), commonInfo))
+}
+
+func (h *Handler) ExposedComputers(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	p := partials.NewPaginationAndSort()
+	p.GetPaginationAndSortParams(c.FormValue("page"), c.FormValue("pageSize"), c.FormValue("sortBy"), c.FormValue("sortOrder"), c.FormValue("currentSortBy"))
+
+	// Default sort
+	if p.SortBy == "" {
+		p.SortBy = "severity"
+		p.SortOrder = "desc"
+	}
+
+	computers, total, err := h.Model.GetExposedComputers(p, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), false))
+	}
+	p.NItems = total
+
+	refreshTime, err := h.Model.GetDefaultRefreshTime()
+	if err != nil {
+		log.Println("[ERROR]: could not get refresh time from database")
+		refreshTime = 5
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.ExposedComputers(c, p, computers, refreshTime, commonInfo), commonInfo))
+}
+
+func (h *Handler) ComputerVulnerabilities(c echo.Context) error {
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	agentId := c.Param("uuid")
+	if agentId == "" {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "agents.no_empty_id"), true))
+	}
+
+	agent, err := h.Model.GetAgentById(agentId, commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), true))
+	}
+
+	vulnerabilities, err := h.Model.GetAgentVulnerabilities(agentId)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), true))
+	}
+
+	return RenderView(c, security_views.SecurityIndex("| Security", security_views.ComputerVulnerabilities(c, agent, vulnerabilities, commonInfo), commonInfo))
+}
+
+func (h *Handler) VulnerabilityFeeds(c echo.Context) error {
+	successMessage := ""
+
+	commonInfo, err := h.GetCommonInfo(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Request().Method == "POST" {
+		path, err := h.saveVulnerabilityFeed(c)
+		if err != nil {
+			return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_import", err.Error()), false))
+		}
+
+		// Big feeds take a while to import and match so it's done in the background
+		go func() {
+			n, err := h.Model.ImportVulnerabilityFeedFile(path)
+			if err != nil {
+				log.Printf("[ERROR]: could not import vulnerability feed %s, reason: %v", path, err)
+				if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+					log.Printf("[ERROR]: could not remove vulnerability feed %s, reason: %v", path, err)
+				}
+				return
+			}
+			matches, err := h.Model.MatchVulnerabilities()
+			if err != nil {
+				log.Printf("[ERROR]: could not match vulnerabilities, reason: %v", err)
+				return
+			}
+			log.Printf("[INFO]: %d vulnerabilities have been imported, %d vulnerable applications found", n, matches)
+		}()
+
+		successMessage = i18n.T(c.Request().Context(), "vulnerabilities.import_started")
+	}
+
+	known, err := h.Model.CountVulnerabilities()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(i18n.T(c.Request().Context(), "vulnerabilities.could_not_get", err.Error()), false))
+	}
+
+	serversExists, err := h.Model.ServersExists()
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	agentsExists, err := h.Model.AgentsExists(commonInfo)
+	if err != nil {
+		return RenderError(c, partials.ErrorMessage(err.Error(), false))
+	}
+
+	return RenderView(c, admin_views.VulnerabilityFeedsIndex(" | Vulnerability feeds", admin_views.VulnerabilityFeeds(c, known, successMessage, agentsExists, serversExists, commonInfo), commonInfo))
+}
+
+// saveVulnerabilityFeed streams the uploaded feed to the vulnerabilities folder, the
+// extension keeps the hourly job from importing it while it's being written
+func (h *Handler) saveVulnerabilityFeed(c echo.Context) (string, error) {
+	file, err := c.FormFile("feedFile")
+	if err != nil {
+		return "", errors.New(i18n.T(c.Request().Context(), "vulnerabilities.no_file"))
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(h.VulnerabilitiesFolder, 0755); err != nil {
+		return "", err
+	}
+
+	dst, err := os.CreateTemp(h.VulnerabilitiesFolder, "upload-*.upload")
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+
+	if err := dst.Close(); err != nil {
+		_ = os.Remove(dst.Name())
+		return "", err
+	}
+
+	return filepath.Clean(dst.Name()), nil
+}
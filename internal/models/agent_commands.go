@@ -0,0 +1,217 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/agentcommand"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// AgentCommandTTLs is how long every kind of command waits in the stream for an agent
+// that is off, power actions make no sense once the working day is over
+var AgentCommandTTLs = map[string]time.Duration{
+	"install":   7 * 24 * time.Hour,
+	"update":    7 * 24 * time.Hour,
+	"uninstall": 7 * 24 * time.Hour,
+	"settings":  30 * 24 * time.Hour,
+	"poweroff":  12 * time.Hour,
+	"reboot":    12 * time.Hour,
+	"restart":   12 * time.Hour,
+	"newconfig": 30 * 24 * time.Hour,
+	// Printers may have changed and remote assistance is only useful while the operator waits
+	"defaultprinter": 24 * time.Hour,
+	"removeprinter":  24 * time.Hour,
+	"startvnc":       5 * time.Minute,
+	"stopvnc":        5 * time.Minute,
+	"profile":        7 * 24 * time.Hour,
+}
+
+// DefaultAgentCommandTTL is used for the kinds of commands that have no TTL of their own
+const DefaultAgentCommandTTL = 7 * 24 * time.Hour
+
+func GetAgentCommandTTL(kind string) time.Duration {
+	if ttl, ok := AgentCommandTTLs[kind]; ok {
+		return ttl
+	}
+	return DefaultAgentCommandTTL
+}
+
+// MaxAgentCommandTTL is the longest time a command waits for an agent, the agents stream
+// doesn't keep messages for longer
+func MaxAgentCommandTTL() time.Duration {
+	ttl := DefaultAgentCommandTTL
+	for _, t := range AgentCommandTTLs {
+		ttl = max(ttl, t)
+	}
+	return ttl
+}
+
+// SaveAgentCommand keeps track of a command published to the agents stream so we can
+// tell if it's still waiting for the agent, the sequence is the one assigned by the stream
+func (m *Model) SaveAgentCommand(agentID, kind, subject, description string, sequence uint64, ttl time.Duration) error {
+	return m.Client.AgentCommand.Create().
+		SetKind(kind).
+		SetSubject(subject).
+		SetDescription(description).
+		SetSequence(sequence).
+		SetCreated(time.Now()).
+		SetExpires(time.Now().Add(ttl)).
+		SetStatus(agentcommand.StatusPending).
+		SetAgentID(agentID).
+		Exec(context.Background())
+}
+
+// HoldAgentCommand keeps a command in the console until a maintenance window of the agent opens,
+// it has no sequence until it's published to the agents stream. A held command expires if it
+// hasn't been released by the time passed in, and the same command is only held once
+func (m *Model) HoldAgentCommand(agentID, kind, subject, description string, data []byte, expires time.Time) error {
+	exists, err := m.Client.AgentCommand.Query().
+		Where(agentcommand.StatusEQ(agentcommand.StatusHeld), agentcommand.Subject(subject), agentcommand.DataEQ(data), agentcommand.HasAgentWith(agent.ID(agentID))).
+		Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return nil
+	}
+
+	return m.Client.AgentCommand.Create().
+		SetKind(kind).
+		SetSubject(subject).
+		SetDescription(description).
+		SetData(data).
+		SetCreated(time.Now()).
+		SetExpires(expires).
+		SetStatus(agentcommand.StatusHeld).
+		SetAgentID(agentID).
+		Exec(context.Background())
+}
+
+// GetHeldAgentCommands returns the commands of every tenant waiting for a maintenance window
+func (m *Model) GetHeldAgentCommands() ([]*ent.AgentCommand, error) {
+	return m.Client.AgentCommand.Query().Where(agentcommand.StatusEQ(agentcommand.StatusHeld), agentcommand.ExpiresGT(time.Now())).WithAgent().Order(ent.Asc(agentcommand.FieldCreated)).All(context.Background())
+}
+
+// SetAgentCommandReleased is called once a held command has been published to the agents stream
+func (m *Model) SetAgentCommandReleased(id int, sequence uint64, ttl time.Duration) error {
+	return m.Client.AgentCommand.UpdateOneID(id).
+		SetSequence(sequence).
+		SetExpires(time.Now().Add(ttl)).
+		SetStatus(agentcommand.StatusPending).
+		SetUpdated(time.Now()).
+		Exec(context.Background())
+}
+
+func (m *Model) getPendingAgentCommandsQuery(c *partials.CommonInfo) (*ent.AgentCommandQuery, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == -1 {
+		return m.Client.AgentCommand.Query().Where(agentcommand.StatusIn(agentcommand.StatusPending, agentcommand.StatusHeld), agentcommand.HasAgentWith(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))), nil
+	}
+	return m.Client.AgentCommand.Query().Where(agentcommand.StatusIn(agentcommand.StatusPending, agentcommand.StatusHeld), agentcommand.HasAgentWith(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))), nil
+}
+
+func (m *Model) CountPendingAgentCommands(c *partials.CommonInfo) (int, error) {
+	query, err := m.getPendingAgentCommandsQuery(c)
+	if err != nil {
+		return 0, err
+	}
+	return query.Count(context.Background())
+}
+
+func (m *Model) GetPendingAgentCommands(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.AgentCommand, error) {
+	query, err := m.getPendingAgentCommandsQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.WithAgent().Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+
+	switch p.SortBy {
+	case "kind":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(agentcommand.FieldKind))
+		} else {
+			query = query.Order(ent.Desc(agentcommand.FieldKind))
+		}
+	case "expires":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(agentcommand.FieldExpires))
+		} else {
+			query = query.Order(ent.Desc(agentcommand.FieldExpires))
+		}
+	default:
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(agentcommand.FieldCreated))
+		} else {
+			query = query.Order(ent.Desc(agentcommand.FieldCreated))
+		}
+	}
+
+	return query.All(context.Background())
+}
+
+// GetAllPendingAgentCommands returns the pending commands of every tenant
+func (m *Model) GetAllPendingAgentCommands() ([]*ent.AgentCommand, error) {
+	return m.Client.AgentCommand.Query().Where(agentcommand.StatusEQ(agentcommand.StatusPending)).All(context.Background())
+}
+
+// SetAgentCommandDelivered is called once a consumer of the agent acknowledged the command or the
+// command is no longer in the stream. Commands removed before the agent acknowledged them won't
+// reach the agent and are set as expired
+func (m *Model) SetAgentCommandDelivered(cmd *ent.AgentCommand, acknowledged bool) error {
+	status := agentcommand.StatusAcknowledged
+	if !acknowledged {
+		status = agentcommand.StatusExpired
+	}
+	return m.Client.AgentCommand.UpdateOneID(cmd.ID).SetStatus(status).SetUpdated(time.Now()).Exec(context.Background())
+}
+
+// ExpireAgentCommands marks as expired the pending commands whose TTL has passed
+// and the held commands that were not released in time
+func (m *Model) ExpireAgentCommands() error {
+	return m.Client.AgentCommand.Update().
+		Where(agentcommand.StatusIn(agentcommand.StatusPending, agentcommand.StatusHeld), agentcommand.ExpiresLTE(time.Now())).
+		SetStatus(agentcommand.StatusExpired).
+		SetUpdated(time.Now()).
+		Exec(context.Background())
+}
+
+// DeleteOldAgentCommands removes the commands that were delivered or expired before the date passed in
+func (m *Model) DeleteOldAgentCommands(before time.Time) error {
+	_, err := m.Client.AgentCommand.Delete().
+		Where(agentcommand.StatusIn(agentcommand.StatusAcknowledged, agentcommand.StatusExpired), agentcommand.UpdatedLT(before)).
+		Exec(context.Background())
+	return err
+}
+
+// AgentSubjectMatches reports if a subject is selected by the filter subject of a consumer,
+// a * wildcard matches a single token and > matches the remaining tokens
+func AgentSubjectMatches(filter, subject string) bool {
+	filterTokens := strings.Split(filter, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range filterTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+	return len(filterTokens) == len(subjectTokens)
+}
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/agentcommand"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AgentCommandsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *AgentCommandsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	err = client.Agent.Create().
+		SetID("agent1").
+		SetHostname("agent1").
+		SetOs("windows").
+		SetNickname("agent1").
+		SetAgentStatus(agent.AgentStatusEnabled).
+		AddSiteIDs(s.ID).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *AgentCommandsTestSuite) TestGetAgentCommandTTL() {
+	assert.Equal(suite.T(), 12*time.Hour, GetAgentCommandTTL("reboot"))
+	assert.Equal(suite.T(), DefaultAgentCommandTTL, GetAgentCommandTTL("unknown"))
+	assert.Equal(suite.T(), 30*24*time.Hour, MaxAgentCommandTTL(), "the stream keeps commands as long as the longest TTL")
+}
+
+func (suite *AgentCommandsTestSuite) TestPendingAgentCommands() {
+	err := suite.model.SaveAgentCommand("agent1", "install", "agent.installpackage.agent1", "Mozilla.Firefox", 1, time.Hour)
+	assert.NoError(suite.T(), err, "should save command")
+
+	err = suite.model.SaveAgentCommand("agent1", "reboot", "agent.queued.reboot.agent1", "", 2, -1*time.Minute)
+	assert.NoError(suite.T(), err, "should save command")
+
+	err = suite.model.SaveAgentCommand("agent1", "settings", "agent.settings.agent1", "", 3, time.Hour)
+	assert.NoError(suite.T(), err, "should save command")
+
+	count, err := suite.model.CountPendingAgentCommands(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count pending commands")
+	assert.Equal(suite.T(), 3, count)
+
+	err = suite.model.ExpireAgentCommands()
+	assert.NoError(suite.T(), err, "should expire commands")
+
+	commands, err := suite.model.GetPendingAgentCommands(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get pending commands")
+	assert.Equal(suite.T(), 2, len(commands), "the reboot has expired")
+	assert.Equal(suite.T(), "agent1", commands[0].Edges.Agent.ID)
+
+	err = suite.model.SetAgentCommandDelivered(commands[0], true)
+	assert.NoError(suite.T(), err, "should set command as delivered")
+
+	cmd, err := suite.model.Client.AgentCommand.Get(context.Background(), commands[0].ID)
+	assert.NoError(suite.T(), err, "should get command")
+	assert.Equal(suite.T(), agentcommand.StatusAcknowledged, cmd.Status)
+
+	all, err := suite.model.GetAllPendingAgentCommands()
+	assert.NoError(suite.T(), err, "should get all pending commands")
+	assert.Equal(suite.T(), 1, len(all))
+
+	err = suite.model.DeleteOldAgentCommands(time.Now().Add(time.Minute))
+	assert.NoError(suite.T(), err, "should delete old commands")
+
+	total, err := suite.model.Client.AgentCommand.Query().Count(context.Background())
+	assert.NoError(suite.T(), err, "should count commands")
+	assert.Equal(suite.T(), 1, total, "pending commands should be kept")
+}
+
+func (suite *AgentCommandsTestSuite) TestDroppedAgentCommands() {
+	err := suite.model.SaveAgentCommand("agent1", "install", "agent.installpackage.agent1", "Mozilla.Firefox", 1, time.Hour)
+	assert.NoError(suite.T(), err, "should save command")
+
+	all, err := suite.model.GetAllPendingAgentCommands()
+	assert.NoError(suite.T(), err, "should get all pending commands")
+	assert.Equal(suite.T(), 1, len(all))
+
+	err = suite.model.SetAgentCommandDelivered(all[0], false)
+	assert.NoError(suite.T(), err, "should set command as delivered")
+
+	cmd, err := suite.model.Client.AgentCommand.Get(context.Background(), all[0].ID)
+	assert.NoError(suite.T(), err, "should get command")
+	assert.Equal(suite.T(), agentcommand.StatusExpired, cmd.Status, "commands dropped without an ack have not been acknowledged")
+}
+
+func (suite *AgentCommandsTestSuite) TestHeldAgentCommands() {
+	err := suite.model.HoldAgentCommand("agent1", "reboot", "agent.queued.reboot.agent1", "", []byte("{}"), time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should hold command")
+
+	err = suite.model.HoldAgentCommand("agent1", "reboot", "agent.queued.reboot.agent1", "", []byte("{}"), time.Now().Add(time.Hour))
+	assert.NoError(suite.T(), err, "should hold command")
+
+	count, err := suite.model.CountPendingAgentCommands(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count pending commands")
+	assert.Equal(suite.T(), 1, count, "held commands are shown as pending and only held once")
+
+	all, err := suite.model.GetAllPendingAgentCommands()
+	assert.NoError(suite.T(), err, "should get all pending commands")
+	assert.Equal(suite.T(), 0, len(all), "held commands are not in the stream")
+
+	held, err := suite.model.GetHeldAgentCommands()
+	assert.NoError(suite.T(), err, "should get held commands")
+	assert.Equal(suite.T(), 1, len(held))
+	assert.Equal(suite.T(), "agent1", held[0].Edges.Agent.ID)
+	assert.Equal(suite.T(), []byte("{}"), held[0].Data)
+
+	err = suite.model.SetAgentCommandReleased(held[0].ID, 5, time.Hour)
+	assert.NoError(suite.T(), err, "should release command")
+
+	cmd, err := suite.model.Client.AgentCommand.Get(context.Background(), held[0].ID)
+	assert.NoError(suite.T(), err, "should get command")
+	assert.Equal(suite.T(), agentcommand.StatusPending, cmd.Status)
+	assert.Equal(suite.T(), uint64(5), cmd.Sequence)
+}
+
+func (suite *AgentCommandsTestSuite) TestExpireHeldAgentCommands() {
+	err := suite.model.HoldAgentCommand("agent1", "reboot", "agent.queued.reboot.agent1", "", []byte("{}"), time.Now().Add(-time.Minute))
+	assert.NoError(suite.T(), err, "should hold command")
+
+	held, err := suite.model.GetHeldAgentCommands()
+	assert.NoError(suite.T(), err, "should get held commands")
+	assert.Equal(suite.T(), 0, len(held), "expired commands are not released")
+
+	err = suite.model.ExpireAgentCommands()
+	assert.NoError(suite.T(), err, "should expire commands")
+
+	count, err := suite.model.CountPendingAgentCommands(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count pending commands")
+	assert.Equal(suite.T(), 0, count, "held commands expire")
+
+	err = suite.model.DeleteOldAgentCommands(time.Now().Add(time.Minute))
+	assert.NoError(suite.T(), err, "should delete old commands")
+
+	total, err := suite.model.Client.AgentCommand.Query().Count(context.Background())
+	assert.NoError(suite.T(), err, "should count commands")
+	assert.Equal(suite.T(), 0, total, "expired held commands are pruned")
+}
+
+func (suite *AgentCommandsTestSuite) TestAgentSubjectMatches() {
+	assert.True(suite.T(), AgentSubjectMatches("agent.queued.>", "agent.queued.reboot.agent1"))
+	assert.True(suite.T(), AgentSubjectMatches("agent.*.agent1", "agent.installpackage.agent1"))
+	assert.True(suite.T(), AgentSubjectMatches("agent.newconfig", "agent.newconfig"))
+	assert.False(suite.T(), AgentSubjectMatches("agent.queued.>", "agent.queued"), "> needs at least one token")
+	assert.False(suite.T(), AgentSubjectMatches("agent.*.agent1", "agent.installpackage.agent2"))
+	assert.False(suite.T(), AgentSubjectMatches("agent.*", "agent.queued.reboot"))
+}
+
+func TestAgentCommandsTestSuite(t *testing.T) {
+	suite.Run(t, new(AgentCommandsTestSuite))
+}
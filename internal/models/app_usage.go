@@ -0,0 +1,270 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/appusage"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// DefaultUnusedAppDays is how long an application must go without being run to be an uninstall candidate
+const DefaultUnusedAppDays = 90
+
+// AppUsageReport is sent by the agents with the applications that have been run since their last report
+type AppUsageReport struct {
+	AgentID string          `json:"agent_id"`
+	Apps    []AppUsageEntry `json:"apps"`
+}
+
+type AppUsageEntry struct {
+	Name      string    `json:"name"`
+	Publisher string    `json:"publisher"`
+	LastUsed  time.Time `json:"last_used"`
+	Runs      int       `json:"runs"`
+}
+
+type UnusedApp struct {
+	Name      string
+	Publisher string
+	Installed int
+	Unused    int
+	LastUsed  time.Time
+}
+
+type UnusedAppInstall struct {
+	AgentID  string
+	Nickname string
+	OS       string
+	Version  string
+	LastUsed time.Time
+}
+
+// SaveAppUsage aggregates a usage report, runs are added to the ones we already know
+// and the last used date is only moved forward
+func (m *Model) SaveAppUsage(report AppUsageReport) error {
+	exists, err := m.Client.Agent.Query().Where(agent.ID(report.AgentID)).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("agent %s doesn't exist", report.AgentID)
+	}
+
+	for _, e := range report.Apps {
+		name := strings.TrimSpace(e.Name)
+		if name == "" {
+			continue
+		}
+
+		u, err := m.Client.AppUsage.Query().Where(appusage.Name(name), appusage.HasOwnerWith(agent.ID(report.AgentID))).Only(context.Background())
+		if err != nil {
+			if !ent.IsNotFound(err) {
+				return err
+			}
+
+			if err := m.Client.AppUsage.Create().
+				SetName(name).
+				SetPublisher(strings.TrimSpace(e.Publisher)).
+				SetLastUsed(e.LastUsed).
+				SetRuns(max(e.Runs, 0)).
+				SetUpdated(time.Now()).
+				SetOwnerID(report.AgentID).
+				Exec(context.Background()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		update := m.Client.AppUsage.UpdateOneID(u.ID).AddRuns(max(e.Runs, 0)).SetUpdated(time.Now())
+		if e.LastUsed.After(u.LastUsed) {
+			update = update.SetLastUsed(e.LastUsed)
+		}
+		if u.Publisher == "" && e.Publisher != "" {
+			update = update.SetPublisher(strings.TrimSpace(e.Publisher))
+		}
+		if err := update.Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) getScopedAppUsage(c *partials.CommonInfo) ([]*ent.AppUsage, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == -1 {
+		return m.Client.AppUsage.Query().Where(appusage.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).WithOwner().All(context.Background())
+	}
+	return m.Client.AppUsage.Query().Where(appusage.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))).WithOwner().All(context.Background())
+}
+
+// GetUnusedApps returns the applications that haven't been run for the days passed in
+// at least one computer. Only the computers that report usage are taken into account
+func (m *Model) GetUnusedApps(p partials.PaginationAndSort, search string, days int, c *partials.CommonInfo) ([]UnusedApp, int, error) {
+	apps, err := m.getScopedAppsWithOwner(search, c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	usage, err := m.getScopedAppUsage(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	unused := ComputeUnusedApps(apps, usage, time.Now().AddDate(0, 0, -days))
+
+	switch p.SortBy {
+	case "name":
+		sort.SliceStable(unused, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return strings.ToLower(unused[i].Name) < strings.ToLower(unused[j].Name)
+			}
+			return strings.ToLower(unused[i].Name) > strings.ToLower(unused[j].Name)
+		})
+	case "installed":
+		sort.SliceStable(unused, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return unused[i].Installed < unused[j].Installed
+			}
+			return unused[i].Installed > unused[j].Installed
+		})
+	case "lastUsed":
+		sort.SliceStable(unused, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return unused[i].LastUsed.Before(unused[j].LastUsed)
+			}
+			return unused[i].LastUsed.After(unused[j].LastUsed)
+		})
+	default:
+		sort.SliceStable(unused, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return unused[i].Unused < unused[j].Unused
+			}
+			return unused[i].Unused > unused[j].Unused
+		})
+	}
+
+	return paginateSlice(unused, p), len(unused), nil
+}
+
+// GetUnusedAppInstalls returns the computers where the application hasn't been run for the days passed in
+func (m *Model) GetUnusedAppInstalls(name string, days int, c *partials.CommonInfo) ([]UnusedAppInstall, error) {
+	apps, err := m.getScopedAppsWithOwner(name, c)
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := m.getScopedAppUsage(c)
+	if err != nil {
+		return nil, err
+	}
+
+	installs := []UnusedAppInstall{}
+	for _, i := range unusedAppInstalls(apps, usage, time.Now().AddDate(0, 0, -days)) {
+		if strings.EqualFold(appDisplayName(i.app), name) && i.unused {
+			installs = append(installs, UnusedAppInstall{AgentID: i.app.Edges.Owner.ID, Nickname: i.app.Edges.Owner.Nickname, OS: i.app.Edges.Owner.Os, Version: i.app.Version, LastUsed: i.lastUsed})
+		}
+	}
+
+	sort.SliceStable(installs, func(i, j int) bool {
+		return installs[i].Nickname < installs[j].Nickname
+	})
+
+	return installs, nil
+}
+
+// ComputeUnusedApps groups the installed applications by their normalized name and counts the computers
+// where they haven't been run since the date passed in, applications used everywhere are left out
+func ComputeUnusedApps(apps []*ent.App, usage []*ent.AppUsage, since time.Time) []UnusedApp {
+	byName := map[string]*UnusedApp{}
+	names := []string{}
+	for _, i := range unusedAppInstalls(apps, usage, since) {
+		name := appDisplayName(i.app)
+		u, ok := byName[name]
+		if !ok {
+			u = &UnusedApp{Name: name, Publisher: appDisplayPublisher(i.app)}
+			byName[name] = u
+			names = append(names, name)
+		}
+
+		u.Installed++
+		if i.unused {
+			u.Unused++
+		}
+		if i.lastUsed.After(u.LastUsed) {
+			u.LastUsed = i.lastUsed
+		}
+	}
+
+	unused := []UnusedApp{}
+	for _, name := range names {
+		if byName[name].Unused > 0 {
+			unused = append(unused, *byName[name])
+		}
+	}
+	return unused
+}
+
+type appInstallUsage struct {
+	app      *ent.App
+	lastUsed time.Time
+	unused   bool
+}
+
+// unusedAppInstalls matches every installed application with its usage, an application is matched
+// by its name or its normalized name once per computer. Computers that have never reported usage are
+// skipped as we can't tell if their applications are being used
+func unusedAppInstalls(apps []*ent.App, usage []*ent.AppUsage, since time.Time) []appInstallUsage {
+	lastUsed := map[string]time.Time{}
+	reporting := map[string]bool{}
+	for _, u := range usage {
+		if u.Edges.Owner == nil {
+			continue
+		}
+		reporting[u.Edges.Owner.ID] = true
+		key := u.Edges.Owner.ID + "/" + strings.ToLower(u.Name)
+		if u.LastUsed.After(lastUsed[key]) {
+			lastUsed[key] = u.LastUsed
+		}
+	}
+
+	installs := []appInstallUsage{}
+	found := map[string]bool{}
+	for _, a := range apps {
+		if a.Edges.Owner == nil || !reporting[a.Edges.Owner.ID] {
+			continue
+		}
+
+		key := a.Edges.Owner.ID + "/" + strings.ToLower(appDisplayName(a))
+		if found[key] {
+			continue
+		}
+		found[key] = true
+
+		last := lastUsed[a.Edges.Owner.ID+"/"+strings.ToLower(a.Name)]
+		if normalized := lastUsed[key]; normalized.After(last) {
+			last = normalized
+		}
+
+		installs = append(installs, appInstallUsage{app: a, lastUsed: last, unused: last.Before(since)})
+	}
+
+	return installs
+}
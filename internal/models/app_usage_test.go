@@ -0,0 +1,120 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/appusage"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AppUsageTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *AppUsageTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 3; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	apps := []struct{ agent, name string }{
+		{"agent0", "Adobe Acrobat"},
+		{"agent1", "Adobe Acrobat"},
+		{"agent2", "Adobe Acrobat"},
+		{"agent0", "7-Zip"},
+		{"agent1", "7-Zip"},
+	}
+	for _, a := range apps {
+		err := client.App.Create().
+			SetName(a.name).
+			SetVersion("1.0").
+			SetOwnerID(a.agent).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "unused", SortOrder: "desc"}
+}
+
+func (suite *AppUsageTestSuite) TestSaveAppUsage() {
+	lastWeek := time.Now().AddDate(0, 0, -7)
+
+	err := suite.model.SaveAppUsage(AppUsageReport{AgentID: "agent0", Apps: []AppUsageEntry{{Name: "7-Zip", LastUsed: lastWeek, Runs: 2}}})
+	assert.NoError(suite.T(), err, "should save usage")
+
+	err = suite.model.SaveAppUsage(AppUsageReport{AgentID: "agent0", Apps: []AppUsageEntry{{Name: "7-Zip", Publisher: "Igor Pavlov", LastUsed: lastWeek.AddDate(0, 0, -30), Runs: 3}}})
+	assert.NoError(suite.T(), err, "should aggregate usage")
+
+	u, err := suite.model.Client.AppUsage.Query().Where(appusage.Name("7-Zip"), appusage.HasOwnerWith(agent.ID("agent0"))).Only(context.Background())
+	assert.NoError(suite.T(), err, "should get usage")
+	assert.Equal(suite.T(), 5, u.Runs, "runs should be added")
+	assert.Equal(suite.T(), lastWeek.Unix(), u.LastUsed.Unix(), "last used date should not go back")
+	assert.Equal(suite.T(), "Igor Pavlov", u.Publisher, "publisher should be set")
+
+	err = suite.model.SaveAppUsage(AppUsageReport{AgentID: "unknown", Apps: []AppUsageEntry{{Name: "7-Zip", Runs: 1}}})
+	assert.Error(suite.T(), err, "should reject usage from unknown agents")
+}
+
+func (suite *AppUsageTestSuite) TestGetUnusedApps() {
+	lastWeek := time.Now().AddDate(0, 0, -7)
+	lastYear := time.Now().AddDate(-1, 0, 0)
+
+	err := suite.model.SaveAppUsage(AppUsageReport{AgentID: "agent0", Apps: []AppUsageEntry{{Name: "7-Zip", LastUsed: lastWeek, Runs: 1}, {Name: "Adobe Acrobat", LastUsed: lastYear, Runs: 1}}})
+	assert.NoError(suite.T(), err, "should save usage")
+
+	err = suite.model.SaveAppUsage(AppUsageReport{AgentID: "agent1", Apps: []AppUsageEntry{{Name: "7-Zip", LastUsed: lastWeek, Runs: 1}}})
+	assert.NoError(suite.T(), err, "should save usage")
+
+	unused, total, err := suite.model.GetUnusedApps(suite.p, "", DefaultUnusedAppDays, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get unused apps")
+	assert.Equal(suite.T(), 1, total, "apps used everywhere should be left out")
+	assert.Equal(suite.T(), "Adobe Acrobat", unused[0].Name)
+	assert.Equal(suite.T(), 2, unused[0].Installed, "computers without usage reports should be skipped")
+	assert.Equal(suite.T(), 2, unused[0].Unused, "apps never run should be unused")
+	assert.Equal(suite.T(), lastYear.Unix(), unused[0].LastUsed.Unix())
+
+	installs, err := suite.model.GetUnusedAppInstalls("Adobe Acrobat", DefaultUnusedAppDays, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get unused installs")
+	assert.Equal(suite.T(), 2, len(installs))
+	assert.Equal(suite.T(), "agent0", installs[0].AgentID)
+	assert.Equal(suite.T(), "agent1", installs[1].AgentID)
+	assert.True(suite.T(), installs[1].LastUsed.IsZero(), "agent1 has never run the app")
+
+	unused, total, err = suite.model.GetUnusedApps(suite.p, "", 400, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get unused apps")
+	assert.Equal(suite.T(), 1, total)
+	assert.Equal(suite.T(), 1, unused[0].Unused, "apps used in the period should not be unused")
+}
+
+func TestAppUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(AppUsageTestSuite))
+}
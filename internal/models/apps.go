@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"sort"
 	"strconv"
 
 	"entgo.io/ent/dialect/sql"
@@ -72,7 +73,9 @@ func (m *Model) CountAllApps(f filters.ApplicationsFilter, c *partials.CommonInf
 
 	applyAppsFilters(query, f)
 
-	if err := query.GroupBy(app.FieldName).Scan(context.Background(), &apps); err != nil {
+	if err := query.Modify(func(s *sql.Selector) {
+		s.Select(appNameSQL + " AS name").GroupBy(appNameSQL)
+	}).Scan(context.Background(), &apps); err != nil {
 		return 0, err
 	}
 	return len(apps), err
@@ -93,13 +96,30 @@ func (m *Model) GetAgentAppsByPage(agentId string, p partials.PaginationAndSort,
 	if siteID == -1 {
 		// Info from agents waiting for admission won't be shown
 		query = m.Client.App.Query().
-			Where(app.HasOwnerWith(agent.ID(agentId), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+			Where(app.HasOwnerWith(agent.ID(agentId), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
 	} else {
 		query = m.Client.App.Query().
-			Where(app.HasOwnerWith(agent.ID(agentId), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))).Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+			Where(app.HasOwnerWith(agent.ID(agentId), agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
 	}
 	applyAppsFilters(query, f)
 
+	// Versions are not sorted as text, so 1.10 comes after 1.9
+	if p.SortBy == "version" {
+		apps, err := query.All(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(apps, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return CompareVersions(apps[i].Version, apps[j].Version) < 0
+			}
+			return CompareVersions(apps[i].Version, apps[j].Version) > 0
+		})
+		return paginateSlice(apps, p), nil
+	}
+
+	query = query.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+
 	switch p.SortBy {
 	case "name":
 		if p.SortOrder == "asc" {
@@ -107,12 +127,6 @@ func (m *Model) GetAgentAppsByPage(agentId string, p partials.PaginationAndSort,
 		} else {
 			query = query.Order(ent.Desc(app.FieldName))
 		}
-	case "version":
-		if p.SortOrder == "asc" {
-			query = query.Order(ent.Asc(app.FieldVersion))
-		} else {
-			query = query.Order(ent.Desc(app.FieldVersion))
-		}
 	case "publisher":
 		if p.SortOrder == "asc" {
 			query = query.Order(ent.Asc(app.FieldPublisher))
@@ -135,7 +149,7 @@ func (m *Model) GetAgentAppsByPage(agentId string, p partials.PaginationAndSort,
 }
 
 func mainAppsByPageSQL(s *sql.Selector, p partials.PaginationAndSort) {
-	s.Select(app.FieldName, app.FieldPublisher, "count(*) AS count").GroupBy(app.FieldName, app.FieldPublisher)
+	s.Select(appNameSQL+" AS name", appPublisherSQL+" AS publisher", "count(*) AS count").GroupBy(appNameSQL, appPublisherSQL)
 	if p.PageSize != 0 {
 		s.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
 	}
@@ -213,7 +227,7 @@ func (m *Model) GetAppsByPage(p partials.PaginationAndSort, f filters.Applicatio
 func (m *Model) GetTop10InstalledApps() ([]App, error) {
 	var apps []App
 	err := m.Client.App.Query().Modify(func(s *sql.Selector) {
-		s.Select(app.FieldName, sql.As(sql.Count("*"), "count")).GroupBy(app.FieldName).OrderBy(sql.Desc("count")).Limit(10)
+		s.Select(appNameSQL+" AS name", sql.As(sql.Count("*"), "count")).GroupBy(appNameSQL).OrderBy(sql.Desc("count")).Limit(10)
 	}).Scan(context.Background(), &apps)
 	if err != nil {
 		return nil, err
@@ -223,11 +237,11 @@ func (m *Model) GetTop10InstalledApps() ([]App, error) {
 
 func applyAppsFilters(query *ent.AppQuery, f filters.ApplicationsFilter) {
 	if len(f.AppName) > 0 {
-		query.Where(app.NameContainsFold(f.AppName))
+		query.Where(app.Or(app.NameContainsFold(f.AppName), app.NormalizedNameContainsFold(f.AppName)))
 	}
 
 	if len(f.Vendor) > 0 {
-		query.Where(app.PublisherContainsFold(f.Vendor))
+		query.Where(app.Or(app.PublisherContainsFold(f.Vendor), app.NormalizedPublisherContainsFold(f.Vendor)))
 	}
 
 	if len(f.Version) > 0 {
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/catalogsourcesetting"
+	"github.com/scncore/ent/tenant"
+	scnorion_nats "github.com/scncore/nats"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+)
+
+// CatalogDeployAction is sent to the agents to deploy a catalogue package, the source
+// tells the agent which package manager installs it
+type CatalogDeployAction struct {
+	scnorion_nats.DeployAction
+	Source string `json:"source,omitempty"`
+}
+
+// IsCatalogSourceEnabled tells if the packages of a catalogue source can be deployed in a tenant.
+// The winget, flatpak and brew sources use their general settings. Other sources use the tenant
+// setting, then the global setting, and they're disabled until an admin enables them
+func (m *Model) IsCatalogSourceEnabled(tenantID, source string) (bool, error) {
+	switch source {
+	case "winget":
+		return m.GetDefaultUseWinget(tenantID)
+	case "flatpak":
+		return m.GetDefaultUseFlatpak(tenantID)
+	case "brew":
+		return m.GetDefaultUseBrew(tenantID)
+	}
+
+	if tenantID != "-1" {
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			return false, err
+		}
+
+		s, err := m.Client.CatalogSourceSetting.Query().Where(catalogsourcesetting.Source(source), catalogsourcesetting.HasTenantWith(tenant.ID(id))).Only(context.Background())
+		if err == nil {
+			return s.Enabled, nil
+		}
+		if !ent.IsNotFound(err) {
+			return false, err
+		}
+	}
+
+	s, err := m.Client.CatalogSourceSetting.Query().Where(catalogsourcesetting.Source(source), catalogsourcesetting.Not(catalogsourcesetting.HasTenant())).Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return s.Enabled, nil
+}
+
+// GetEnabledCatalogSources filters the sources that are enabled in a tenant
+func (m *Model) GetEnabledCatalogSources(tenantID string, sources []string) ([]string, error) {
+	enabled := []string{}
+	for _, source := range sources {
+		ok, err := m.IsCatalogSourceEnabled(tenantID, source)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			enabled = append(enabled, source)
+		}
+	}
+	return enabled, nil
+}
+
+// IsCatalogSourceInUse reports if a source that has no general setting is enabled globally or in any tenant
+func (m *Model) IsCatalogSourceInUse(source string) (bool, error) {
+	return m.Client.CatalogSourceSetting.Query().Where(catalogsourcesetting.Source(source), catalogsourcesetting.Enabled(true)).Exist(context.Background())
+}
+
+// SetCatalogSourceEnabled enables or disables a registered source that has no general setting of its own
+func (m *Model) SetCatalogSourceEnabled(tenantID, source string, enabled bool) error {
+	if winget.IsBuiltinCatalogSource(source) {
+		return fmt.Errorf("%s is enabled with its own setting", source)
+	}
+
+	if !winget.IsRegisteredCatalogSource(source) {
+		return fmt.Errorf("%s is not a catalogue source", source)
+	}
+
+	query := m.Client.CatalogSourceSetting.Query().Where(catalogsourcesetting.Source(source))
+	create := m.Client.CatalogSourceSetting.Create().SetSource(source).SetEnabled(enabled)
+
+	if tenantID == "-1" {
+		query = query.Where(catalogsourcesetting.Not(catalogsourcesetting.HasTenant()))
+	} else {
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			return err
+		}
+		query = query.Where(catalogsourcesetting.HasTenantWith(tenant.ID(id)))
+		create = create.SetTenantID(id)
+	}
+
+	s, err := query.Only(context.Background())
+	if err != nil {
+		if !ent.IsNotFound(err) {
+			return err
+		}
+		return create.Exec(context.Background())
+	}
+
+	return m.Client.CatalogSourceSetting.UpdateOneID(s.ID).SetEnabled(enabled).Exec(context.Background())
+}
@@ -0,0 +1,94 @@
+package models
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/enttest"
+	scnorion_nats "github.com/scncore/nats"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CatalogSourcesTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID string
+}
+
+type testCatalogSource struct{}
+
+func (s testCatalogSource) Name() string                   { return "chocolatey" }
+func (s testCatalogSource) Platforms() []string            { return []string{"windows"} }
+func (s testCatalogSource) RefreshInterval() time.Duration { return time.Hour }
+func (s testCatalogSource) Refresh() error                 { return nil }
+func (s testCatalogSource) Packages() ([]scnorion_nats.SoftwarePackage, error) {
+	return []scnorion_nats.SoftwarePackage{}, nil
+}
+func (s testCatalogSource) Search(name string) ([]scnorion_nats.SoftwarePackage, error) {
+	return []scnorion_nats.SoftwarePackage{}, nil
+}
+
+func (suite *CatalogSourcesTestSuite) SetupSuite() {
+	if !winget.IsRegisteredCatalogSource("chocolatey") {
+		winget.RegisterCatalogSource(func(folders winget.CatalogFolders) winget.CatalogSource { return testCatalogSource{} })
+	}
+}
+
+func (suite *CatalogSourcesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = strconv.Itoa(t.ID)
+}
+
+func (suite *CatalogSourcesTestSuite) TestCatalogSourceEnablement() {
+	enabled, err := suite.model.IsCatalogSourceEnabled(suite.tenantID, "chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is enabled")
+	assert.False(suite.T(), enabled, "new sources are disabled by default")
+
+	inUse, err := suite.model.IsCatalogSourceInUse("chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is in use")
+	assert.False(suite.T(), inUse, "disabled sources are not downloaded")
+
+	err = suite.model.SetCatalogSourceEnabled("-1", "chocolatey", true)
+	assert.NoError(suite.T(), err, "should enable source globally")
+
+	inUse, err = suite.model.IsCatalogSourceInUse("chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is in use")
+	assert.True(suite.T(), inUse, "enabled sources are downloaded")
+
+	enabled, err = suite.model.IsCatalogSourceEnabled(suite.tenantID, "chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is enabled")
+	assert.True(suite.T(), enabled, "tenants use the global setting")
+
+	err = suite.model.SetCatalogSourceEnabled(suite.tenantID, "chocolatey", false)
+	assert.NoError(suite.T(), err, "should disable source for the tenant")
+
+	enabled, err = suite.model.IsCatalogSourceEnabled(suite.tenantID, "chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is enabled")
+	assert.False(suite.T(), enabled, "the tenant setting overrides the global setting")
+
+	enabled, err = suite.model.IsCatalogSourceEnabled("-1", "chocolatey")
+	assert.NoError(suite.T(), err, "should check if source is enabled")
+	assert.True(suite.T(), enabled, "the global setting is kept")
+
+	sources, err := suite.model.GetEnabledCatalogSources("-1", []string{"chocolatey", "snap"})
+	assert.NoError(suite.T(), err, "should get enabled sources")
+	assert.Equal(suite.T(), []string{"chocolatey"}, sources)
+
+	err = suite.model.SetCatalogSourceEnabled("-1", "snap", true)
+	assert.Error(suite.T(), err, "should not enable a source that isn't registered")
+
+	err = suite.model.SetCatalogSourceEnabled("-1", "winget", false)
+	assert.Error(suite.T(), err, "builtin sources use their general settings")
+}
+
+func TestCatalogSourcesTestSuite(t *testing.T) {
+	suite.Run(t, new(CatalogSourcesTestSuite))
+}
@@ -0,0 +1,197 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/changefreeze"
+	"github.com/scncore/ent/changefreezeoverride"
+	"github.com/scncore/ent/deploymentassignment"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/settings"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+type ChangeFreezeRequest struct {
+	Name   string
+	Start  time.Time
+	End    time.Time
+	Reason string
+}
+
+func (m *Model) GetChangeFreezes(tenantID int) ([]*ent.ChangeFreeze, error) {
+	return m.Client.ChangeFreeze.Query().
+		Where(changefreeze.HasTenantWith(tenant.ID(tenantID))).
+		WithOverrides(func(q *ent.ChangeFreezeOverrideQuery) {
+			q.Order(ent.Desc(changefreezeoverride.FieldCreated))
+		}).
+		Order(ent.Desc(changefreeze.FieldStart)).
+		All(context.Background())
+}
+
+func (m *Model) AddChangeFreeze(tenantID int, r ChangeFreezeRequest) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("the freeze name cannot be empty")
+	}
+
+	if r.Start.IsZero() || r.End.IsZero() {
+		return errors.New("the freeze must have a start and an end")
+	}
+
+	if !r.End.After(r.Start) {
+		return errors.New("the freeze must end after it starts")
+	}
+
+	return m.Client.ChangeFreeze.Create().
+		SetName(r.Name).
+		SetStart(r.Start).
+		SetEnd(r.End).
+		SetReason(strings.TrimSpace(r.Reason)).
+		SetTenantID(tenantID).
+		Exec(context.Background())
+}
+
+func (m *Model) DeleteChangeFreeze(tenantID int, freezeID int) error {
+	n, err := m.Client.ChangeFreeze.Delete().
+		Where(changefreeze.ID(freezeID), changefreeze.HasTenantWith(tenant.ID(tenantID))).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return errors.New("the freeze doesn't belong to this tenant")
+	}
+	return nil
+}
+
+// GetActiveChangeFreeze returns the freeze of the tenant that is in effect, nil if changes are allowed.
+// If freezes overlap the one that ends last is returned
+func (m *Model) GetActiveChangeFreeze(tenantID int, now time.Time) (*ent.ChangeFreeze, error) {
+	freeze, err := m.Client.ChangeFreeze.Query().
+		Where(changefreeze.HasTenantWith(tenant.ID(tenantID)), changefreeze.StartLTE(now), changefreeze.EndGT(now)).
+		Order(ent.Desc(changefreeze.FieldEnd)).
+		First(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return freeze, nil
+}
+
+// AddChangeFreezeOverride records who made a change during a freeze and why
+func (m *Model) AddChangeFreezeOverride(freezeID int, user, action, reason string) error {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return errors.New("a reason is required to override the change freeze")
+	}
+
+	return m.Client.ChangeFreezeOverride.Create().
+		SetUser(user).
+		SetAction(action).
+		SetReason(reason).
+		SetCreated(time.Now()).
+		SetFreezeID(freezeID).
+		Exec(context.Background())
+}
+
+func (m *Model) GetDeploymentApprovalRequired(tenantID string) (bool, error) {
+	id, err := strconv.Atoi(tenantID)
+	if err != nil {
+		return false, err
+	}
+
+	s, err := m.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(id))).Select(settings.FieldRequireDeploymentApproval).Only(context.Background())
+	if err != nil {
+		// A tenant without its own settings doesn't approve its deployments
+		if ent.IsNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return s.RequireDeploymentApproval, nil
+}
+
+func (m *Model) SetDeploymentApprovalRequired(tenantID int, required bool) error {
+	n, err := m.Client.Settings.Update().Where(settings.HasTenantWith(tenant.ID(tenantID))).SetRequireDeploymentApproval(required).Save(context.Background())
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// The tenant has no settings yet, they start as a copy of the global ones
+		if err := m.CloneGlobalSettings(tenantID); err != nil {
+			return err
+		}
+		return m.Client.Settings.Update().Where(settings.HasTenantWith(tenant.ID(tenantID))).SetRequireDeploymentApproval(required).Exec(context.Background())
+	}
+	return nil
+}
+
+// ApproveDeploymentJob lets a job that waits for approval start, it must be approved by someone
+// other than the user who created it
+func (m *Model) ApproveDeploymentJob(id int, user string, c *partials.CommonInfo) error {
+	job, err := m.getPendingDeploymentJob(id, user, c)
+	if err != nil {
+		return err
+	}
+
+	query := m.Client.DeploymentJob.UpdateOneID(job.ID).
+		SetStatus(deploymentjob.StatusRunning).
+		SetStatusMessage("").
+		SetReviewedBy(user).
+		SetReviewed(time.Now())
+
+	// A job approved after its start time starts now, so its rings are timed from the approval
+	if now := time.Now(); job.Start.Before(now) {
+		query.SetStart(now).SetRingStarted(now)
+	}
+
+	return query.Exec(context.Background())
+}
+
+// RejectDeploymentJob aborts a job that waits for approval, its computers are never sent
+func (m *Model) RejectDeploymentJob(id int, user string, c *partials.CommonInfo) error {
+	job, err := m.getPendingDeploymentJob(id, user, c)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Client.DeploymentJob.UpdateOneID(job.ID).
+		SetStatus(deploymentjob.StatusAborted).
+		SetStatusMessage("the deployment has been rejected").
+		SetReviewedBy(user).
+		SetReviewed(time.Now()).
+		Exec(context.Background()); err != nil {
+		return err
+	}
+
+	// A rejected assignment is paused, otherwise it would ask for approval again in the next evaluation
+	return m.Client.DeploymentAssignment.Update().
+		Where(deploymentassignment.HasJobsWith(deploymentjob.ID(job.ID))).
+		SetEnabled(false).
+		Exec(context.Background())
+}
+
+func (m *Model) getPendingDeploymentJob(id int, user string, c *partials.CommonInfo) (*ent.DeploymentJob, error) {
+	job, err := m.GetDeploymentJob(id, c)
+	if err != nil {
+		return nil, err
+	}
+
+	if job.Status != deploymentjob.StatusPending {
+		return nil, errors.New("the deployment is not waiting for approval")
+	}
+
+	if user == "" || user == job.CreatedBy {
+		return nil, errors.New("the deployment must be reviewed by a different user")
+	}
+
+	return job, nil
+}
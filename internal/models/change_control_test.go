@@ -0,0 +1,249 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/settings"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ChangeControlTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	siteID     int
+	tagID      int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *ChangeControlTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	err := client.Settings.Create().SetCountry("FR").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create global settings")
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	tag, err := client.Tag.Create().SetTag("Finance").SetDescription("Finance").SetColor("#00ff00").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+	suite.tagID = tag.ID
+
+	err = client.Agent.Create().SetID("agent0").SetHostname("agent0").SetOs("windows").SetNickname("agent0").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).AddTagIDs(tag.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+}
+
+func (suite *ChangeControlTestSuite) TestChangeFreezes() {
+	now := time.Now()
+
+	err := suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "", Start: now, End: now.Add(time.Hour)})
+	assert.Error(suite.T(), err, "the name is required")
+
+	err = suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "Year end", Start: now, End: now})
+	assert.Error(suite.T(), err, "the freeze must end after it starts")
+
+	err = suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "Next week", Start: now.Add(7 * 24 * time.Hour), End: now.Add(8 * 24 * time.Hour)})
+	assert.NoError(suite.T(), err, "should add change freeze")
+
+	freeze, err := suite.model.GetActiveChangeFreeze(suite.tenantID, now)
+	assert.NoError(suite.T(), err, "should get active change freeze")
+	assert.Nil(suite.T(), freeze, "the freeze has not started yet")
+
+	err = suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "Year end", Start: now.Add(-time.Hour), End: now.Add(time.Hour), Reason: "Accounting close"})
+	assert.NoError(suite.T(), err, "should add change freeze")
+
+	freeze, err = suite.model.GetActiveChangeFreeze(suite.tenantID, now)
+	assert.NoError(suite.T(), err, "should get active change freeze")
+	assert.NotNil(suite.T(), freeze)
+	assert.Equal(suite.T(), "Year end", freeze.Name)
+
+	err = suite.model.AddChangeFreezeOverride(freeze.ID, "admin", "install Firefox", " ")
+	assert.Error(suite.T(), err, "an override needs a reason")
+
+	err = suite.model.AddChangeFreezeOverride(freeze.ID, "admin", "install Firefox", "Security fix")
+	assert.NoError(suite.T(), err, "should add override")
+
+	freezes, err := suite.model.GetChangeFreezes(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get change freezes")
+	assert.Equal(suite.T(), 2, len(freezes))
+	assert.Equal(suite.T(), "Next week", freezes[0].Name)
+	assert.Equal(suite.T(), 1, len(freezes[1].Edges.Overrides))
+	assert.Equal(suite.T(), "Security fix", freezes[1].Edges.Overrides[0].Reason)
+
+	err = suite.model.DeleteChangeFreeze(suite.tenantID+1, freeze.ID)
+	assert.Error(suite.T(), err, "the freeze belongs to another tenant")
+
+	err = suite.model.DeleteChangeFreeze(suite.tenantID, freeze.ID)
+	assert.NoError(suite.T(), err, "should delete change freeze")
+
+	freeze, err = suite.model.GetActiveChangeFreeze(suite.tenantID, now)
+	assert.NoError(suite.T(), err, "should get active change freeze")
+	assert.Nil(suite.T(), freeze)
+}
+
+func (suite *ChangeControlTestSuite) TestDeploymentApproval() {
+	r := DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0"}, CreatedBy: "admin"}
+
+	required, err := suite.model.GetDeploymentApprovalRequired(suite.commonInfo.TenantID)
+	assert.NoError(suite.T(), err, "should get approval setting")
+	assert.False(suite.T(), required)
+
+	err = suite.model.SetDeploymentApprovalRequired(suite.tenantID, true)
+	assert.NoError(suite.T(), err, "should set approval setting")
+
+	s, err := suite.model.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(suite.tenantID))).Only(context.Background())
+	assert.NoError(suite.T(), err, "should get tenant settings")
+	assert.Equal(suite.T(), "FR", s.Country, "the tenant settings should be a copy of the global ones")
+
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "the deployment needs a change reference")
+
+	r.ChangeReference = "CHG-1234"
+	job, err := suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusPending, job.Status)
+	assert.Equal(suite.T(), "CHG-1234", job.ChangeReference)
+
+	err = suite.model.ApproveDeploymentJob(job.ID, "admin", suite.commonInfo)
+	assert.Error(suite.T(), err, "the creator can't approve the deployment")
+
+	err = suite.model.ApproveDeploymentJob(job.ID, "reviewer", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should approve deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusRunning, job.Status)
+	assert.Equal(suite.T(), "reviewer", job.ReviewedBy)
+
+	err = suite.model.RejectDeploymentJob(job.ID, "reviewer", suite.commonInfo)
+	assert.Error(suite.T(), err, "the deployment is not waiting for approval")
+
+	job, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	err = suite.model.RejectDeploymentJob(job.ID, "reviewer", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should reject deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusAborted, job.Status)
+
+	// Agent updates are not approved
+	job, err = suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: AgentUpdatePackageID, PackageName: "Agent update", PackageVersion: "1.0.0", Action: "agentupdate", Agents: []string{"agent0"}, CreatedBy: "admin"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create agent update job")
+	assert.Equal(suite.T(), deploymentjob.StatusRunning, job.Status)
+}
+
+func (suite *ChangeControlTestSuite) TestPrivatePackageApproval() {
+	err := suite.model.SetDeploymentApprovalRequired(suite.tenantID, true)
+	assert.NoError(suite.T(), err, "should set approval setting")
+
+	// Private packages are deployed as jobs so they wait for approval like catalogue packages
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: PrivatePackageID(1), PackageName: "Private", Action: "install", Agents: []string{"agent0"}, CreatedBy: "admin", ChangeReference: "CHG-1234"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusPending, job.Status)
+
+	targets, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Empty(suite.T(), targets, "the job is not sent until it's approved")
+
+	err = suite.model.ApproveDeploymentJob(job.ID, "reviewer", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should approve deployment job")
+
+	targets, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 1, len(targets))
+}
+
+func (suite *ChangeControlTestSuite) TestAssignmentApproval() {
+	err := suite.model.SetDeploymentApprovalRequired(suite.tenantID, true)
+	assert.NoError(suite.T(), err, "should set approval setting")
+
+	r := DeploymentAssignmentRequest{Name: "Finance browser", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID, CreatedBy: "admin"}
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.Error(suite.T(), err, "the assignment needs a change reference")
+
+	r.ChangeReference = "CHG-1"
+	a, err := suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	job, err := suite.model.Client.DeploymentJob.Query().Only(context.Background())
+	assert.NoError(suite.T(), err, "should get assignment job")
+	assert.Equal(suite.T(), deploymentjob.StatusPending, job.Status, "the first job of the assignment waits for approval")
+	assert.Equal(suite.T(), "CHG-1", job.ChangeReference)
+
+	err = suite.model.ApproveDeploymentJob(job.ID, "reviewer", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should approve deployment job")
+
+	// Resuming the assignment asks for approval again
+	err = suite.model.SetDeploymentAssignmentEnabled(suite.tenantID, a.ID, false)
+	assert.NoError(suite.T(), err, "should pause assignment")
+
+	err = suite.model.SetDeploymentAssignmentChangeReference(suite.tenantID, a.ID, "CHG-1", "operator")
+	assert.Error(suite.T(), err, "the assignment needs a new change reference")
+
+	err = suite.model.SetDeploymentAssignmentChangeReference(suite.tenantID, a.ID, "CHG-2", "operator")
+	assert.NoError(suite.T(), err, "should set change reference")
+
+	err = suite.model.SetDeploymentAssignmentEnabled(suite.tenantID, a.ID, true)
+	assert.NoError(suite.T(), err, "should resume assignment")
+
+	err = suite.model.Client.Agent.Create().SetID("agent1").SetHostname("agent1").SetOs("windows").SetNickname("agent1").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(suite.siteID).AddTagIDs(suite.tagID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	job, err = suite.model.Client.DeploymentJob.Query().Where(deploymentjob.ChangeReference("CHG-2")).Only(context.Background())
+	assert.NoError(suite.T(), err, "should get the job of the new change reference")
+	assert.Equal(suite.T(), deploymentjob.StatusPending, job.Status)
+	assert.Equal(suite.T(), "operator", job.CreatedBy, "the user that resumed the assignment can't approve it")
+
+	// Rejecting the job pauses the assignment so it doesn't ask again
+	err = suite.model.RejectDeploymentJob(job.ID, "admin", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should reject deployment job")
+
+	a, err = suite.model.GetDeploymentAssignment(suite.tenantID, a.ID)
+	assert.NoError(suite.T(), err, "should get assignment")
+	assert.False(suite.T(), a.Enabled)
+}
+
+func (suite *ChangeControlTestSuite) TestFreezeDelaysAssignments() {
+	_, err := suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Finance browser", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID})
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	err = suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "Year end", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)})
+	assert.NoError(suite.T(), err, "should add change freeze")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	count, err := suite.model.Client.DeploymentJob.Query().Count(context.Background())
+	assert.NoError(suite.T(), err, "should count jobs")
+	assert.Equal(suite.T(), 0, count, "assignments wait until the freeze ends")
+}
+
+func TestChangeControlTestSuite(t *testing.T) {
+	suite.Run(t, new(ChangeControlTestSuite))
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/scncore/ent/agent"
 	"github.com/scncore/ent/app"
 	"github.com/scncore/ent/computer"
+	"github.com/scncore/ent/loggedonuser"
 	"github.com/scncore/ent/operatingsystem"
 	"github.com/scncore/ent/predicate"
 	"github.com/scncore/ent/printer"
@@ -292,6 +293,10 @@ func applyComputerFilters(query *ent.AgentQuery, f filters.AgentFilter) {
 		query.Where(agent.HasOperatingsystemWith(operatingsystem.UsernameContainsFold(f.Username)))
 	}
 
+	if len(f.LoggedOnUser) > 0 {
+		query.Where(agent.HasLoggedonusersWith(loggedonuser.UsernameContainsFold(f.LoggedOnUser)))
+	}
+
 	if len(f.AgentOSVersions) > 0 {
 		query.Where(agent.OsIn(f.AgentOSVersions...))
 	}
@@ -309,7 +314,7 @@ func applyComputerFilters(query *ent.AgentQuery, f filters.AgentFilter) {
 	}
 
 	if len(f.WithApplication) > 0 {
-		query.Where(agent.HasAppsWith(app.Name(f.WithApplication)))
+		query.Where(agent.HasAppsWith(app.Or(app.Name(f.WithApplication), app.NormalizedName(f.WithApplication))))
 	}
 
 	if len(f.IsRemote) > 0 {
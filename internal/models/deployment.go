@@ -176,49 +176,87 @@ func (m *Model) SaveDeployInfo(data *scnorion_nats.DeployAction, deploymentFaile
 					Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))).
 					Exec(context.Background())
 			}
-		} else {
-			return m.Client.Deployment.Create().
-				SetInstalled(timeZero).
-				SetFailed(false).
-				SetUpdated(timeZero).
-				SetPackageID(data.PackageId).
-				SetName(data.PackageName).
-				SetVersion(data.PackageVersion).
-				SetOwnerID(data.AgentId).
-				Exec(context.Background())
 		}
+
+		// A computer has one row per package, installing it again resets the row until the agent reports
+		n, err := m.Client.Deployment.Update().
+			SetInstalled(timeZero).
+			SetUpdated(timeZero).
+			SetFailed(false).
+			SetName(data.PackageName).
+			SetVersion(data.PackageVersion).
+			Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId)))).
+			Save(context.Background())
+		if err != nil || n > 0 {
+			return err
+		}
+
+		return m.Client.Deployment.Create().
+			SetInstalled(timeZero).
+			SetFailed(false).
+			SetUpdated(timeZero).
+			SetPackageID(data.PackageId).
+			SetName(data.PackageName).
+			SetVersion(data.PackageVersion).
+			SetOwnerID(data.AgentId).
+			Exec(context.Background())
 	}
 
 	if data.Action == "update" {
+		// The version installed before the update is kept so the update can be rolled back
+		previousVersion, err := m.GetDeploymentVersionBeforeUpdate(data.AgentId, data.PackageId, c)
+		if err != nil {
+			return err
+		}
+
 		if siteID == -1 {
 			return m.Client.Deployment.Update().
 				SetUpdated(timeZero).
 				SetFailed(false).
+				SetVersion(data.PackageVersion).
+				SetPreviousVersion(previousVersion).
 				Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))).
 				Exec(context.Background())
 		} else {
 			return m.Client.Deployment.Update().
 				SetUpdated(timeZero).
 				SetFailed(false).
+				SetVersion(data.PackageVersion).
+				SetPreviousVersion(previousVersion).
 				Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))).
 				Exec(context.Background())
 		}
 	}
 
 	if data.Action == "uninstall" {
+		var n int
 		if siteID == -1 {
-			return m.Client.Deployment.Update().
+			n, err = m.Client.Deployment.Update().
 				SetInstalled(timeZero).
 				SetFailed(false).
 				Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))).
-				Exec(context.Background())
+				Save(context.Background())
 		} else {
-			return m.Client.Deployment.Update().
+			n, err = m.Client.Deployment.Update().
 				SetInstalled(timeZero).
 				SetFailed(false).
 				Where(deployment.And(deployment.PackageID(data.PackageId), deployment.HasOwnerWith(agent.ID(data.AgentId), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))).
-				Exec(context.Background())
+				Save(context.Background())
 		}
+		if err != nil || n > 0 {
+			return err
+		}
+
+		// Software installed by other means has no row, one is written so the agent
+		// confirms the uninstall by removing it
+		return m.Client.Deployment.Create().
+			SetInstalled(timeZero).
+			SetFailed(false).
+			SetUpdated(timeZero).
+			SetPackageID(data.PackageId).
+			SetName(data.PackageName).
+			SetOwnerID(data.AgentId).
+			Exec(context.Background())
 	}
 
 	return nil
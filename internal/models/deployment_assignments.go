@@ -0,0 +1,421 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentassignment"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tag"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+var DeploymentAssignmentKinds = []string{"tag", "site", "search"}
+
+type DeploymentAssignmentRequest struct {
+	Name             string
+	PackageID        string
+	PackageName      string
+	PackageVersion   string
+	Kind             string
+	TagID            int
+	SiteID           int
+	Search           filters.AgentFilter
+	UninstallOnLeave bool
+	CreatedBy        string
+	// ScopeSiteID is the site the user was working in, 0 if the user could see every site of the tenant
+	ScopeSiteID int
+	// ChangeReference is required if the tenant approves its deployments
+	ChangeReference string
+}
+
+// DeploymentAssignmentMembers are the computers that must have the package of an assignment,
+// the ones that must get it and the ones that left the group and must have it removed
+type DeploymentAssignmentMembers struct {
+	Members []string
+	Joined  []string
+	Left    []string
+}
+
+func (m *Model) GetDeploymentAssignments(tenantID int) ([]*ent.DeploymentAssignment, error) {
+	return m.Client.DeploymentAssignment.Query().
+		Where(deploymentassignment.HasTenantWith(tenant.ID(tenantID))).
+		WithTag().
+		WithSite().
+		WithJobs(func(q *ent.DeploymentJobQuery) { q.WithTargets().Order(ent.Desc(deploymentjob.FieldCreated)) }).
+		Order(ent.Asc(deploymentassignment.FieldName)).
+		All(context.Background())
+}
+
+func (m *Model) GetDeploymentAssignment(tenantID int, assignmentID int) (*ent.DeploymentAssignment, error) {
+	return m.Client.DeploymentAssignment.Query().
+		Where(deploymentassignment.ID(assignmentID), deploymentassignment.HasTenantWith(tenant.ID(tenantID))).
+		WithTag().
+		WithSite().
+		WithTenant().
+		Only(context.Background())
+}
+
+func (m *Model) AddDeploymentAssignment(tenantID int, r DeploymentAssignmentRequest) (*ent.DeploymentAssignment, error) {
+	r.Name = strings.TrimSpace(r.Name)
+	r.ChangeReference = strings.TrimSpace(r.ChangeReference)
+	if r.Name == "" {
+		return nil, errors.New("the assignment name cannot be empty")
+	}
+
+	if strings.TrimSpace(r.PackageID) == "" {
+		return nil, errors.New("the package ID is required")
+	}
+
+	// Private installers are deployed on demand, assignments only keep catalogue packages installed
+	if _, ok := ParsePrivatePackageID(r.PackageID); ok {
+		return nil, errors.New("private packages can't be assigned")
+	}
+
+	// The first jobs of the assignment wait for approval with this change reference
+	approval, err := m.GetDeploymentApprovalRequired(strconv.Itoa(tenantID))
+	if err != nil {
+		return nil, err
+	}
+	if approval && r.ChangeReference == "" {
+		return nil, errors.New("the assignment needs a change reference")
+	}
+
+	exists, err := m.Client.DeploymentAssignment.Query().Where(deploymentassignment.Name(r.Name), deploymentassignment.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, errors.New("an assignment with that name already exists")
+	}
+
+	// Users working in a site only assign packages to the computers of that site
+	if r.ScopeSiteID != 0 {
+		exists, err := m.Client.Site.Query().Where(site.ID(r.ScopeSiteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("the site doesn't belong to this tenant")
+		}
+		if r.Kind == "site" && r.SiteID != r.ScopeSiteID {
+			return nil, errors.New("the site of the assignment must be the site you're working in")
+		}
+	}
+
+	query := m.Client.DeploymentAssignment.Create().
+		SetName(r.Name).
+		SetPackageID(r.PackageID).
+		SetPackageName(r.PackageName).
+		SetPackageVersion(r.PackageVersion).
+		SetUninstallOnLeave(r.UninstallOnLeave).
+		SetEnabled(true).
+		SetCreatedBy(r.CreatedBy).
+		SetCreated(time.Now()).
+		SetScopeSiteID(r.ScopeSiteID).
+		SetChangeReference(r.ChangeReference).
+		SetChangeRequestedBy(r.CreatedBy).
+		SetTenantID(tenantID)
+
+	switch r.Kind {
+	case "tag":
+		exists, err := m.Client.Tag.Query().Where(tag.ID(r.TagID), tag.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("the tag doesn't belong to this tenant")
+		}
+		query.SetKind(deploymentassignment.KindTag).SetTagID(r.TagID)
+	case "site":
+		exists, err := m.Client.Site.Query().Where(site.ID(r.SiteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			return nil, errors.New("the site doesn't belong to this tenant")
+		}
+		query.SetKind(deploymentassignment.KindSite).SetSiteID(r.SiteID)
+	case "search":
+		if r.Search.Nickname == "" && len(r.Search.AgentOSVersions) == 0 && len(r.Search.Tags) == 0 && len(r.Search.IsRemote) == 0 {
+			return nil, errors.New("at least one search criteria is required")
+		}
+		for _, id := range r.Search.Tags {
+			exists, err := m.Client.Tag.Query().Where(tag.ID(id), tag.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				return nil, errors.New("the tag doesn't belong to this tenant")
+			}
+		}
+		search, err := json.Marshal(r.Search)
+		if err != nil {
+			return nil, err
+		}
+		query.SetKind(deploymentassignment.KindSearch).SetSearch(string(search))
+	default:
+		return nil, errors.New("the assignment type is not valid")
+	}
+
+	return query.Save(context.Background())
+}
+
+func (m *Model) SetDeploymentAssignmentEnabled(tenantID int, assignmentID int, enabled bool) error {
+	a, err := m.GetDeploymentAssignment(tenantID, assignmentID)
+	if err != nil {
+		return err
+	}
+	return m.Client.DeploymentAssignment.UpdateOneID(a.ID).SetEnabled(enabled).Exec(context.Background())
+}
+
+// SetDeploymentAssignmentChangeReference asks for approval again before a paused assignment is resumed,
+// the jobs of the assignment are created again for the new change reference so they wait for a different user
+func (m *Model) SetDeploymentAssignmentChangeReference(tenantID int, assignmentID int, changeReference string, user string) error {
+	a, err := m.GetDeploymentAssignment(tenantID, assignmentID)
+	if err != nil {
+		return err
+	}
+
+	changeReference = strings.TrimSpace(changeReference)
+	if changeReference == "" {
+		return errors.New("the assignment needs a change reference")
+	}
+	if changeReference == a.ChangeReference {
+		return errors.New("the assignment needs a new change reference")
+	}
+
+	return m.Client.DeploymentAssignment.UpdateOneID(a.ID).
+		SetChangeReference(changeReference).
+		SetChangeRequestedBy(user).
+		Exec(context.Background())
+}
+
+// DeleteDeploymentAssignment stops evaluating the assignment, its jobs are kept so
+// the deployments done can still be reviewed
+func (m *Model) DeleteDeploymentAssignment(tenantID int, assignmentID int) error {
+	a, err := m.GetDeploymentAssignment(tenantID, assignmentID)
+	if err != nil {
+		return err
+	}
+	return m.Client.DeploymentAssignment.DeleteOneID(a.ID).Exec(context.Background())
+}
+
+// GetDeploymentAssignmentSearch returns the criteria saved with a search assignment
+func GetDeploymentAssignmentSearch(a *ent.DeploymentAssignment) (filters.AgentFilter, error) {
+	f := filters.AgentFilter{}
+	if a.Search == "" {
+		return f, nil
+	}
+	err := json.Unmarshal([]byte(a.Search), &f)
+	return f, err
+}
+
+// GetDeploymentAssignmentMembers compares the enabled computers of the tenant, or of the site the assignment was
+// created in, that are in the group of the assignment with the ones that got the package from it. A computer got
+// the package if the last target of the assignment jobs for it is an install, targets of aborted jobs don't count
+// so their computers are sent again. Disabled computers neither join nor leave the group
+func (m *Model) GetDeploymentAssignmentMembers(a *ent.DeploymentAssignment, tenantID int) (*DeploymentAssignmentMembers, error) {
+	query := m.Client.Agent.Query().Where(agent.AgentStatusEQ(agent.AgentStatusEnabled), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	if a.ScopeSiteID != 0 {
+		query.Where(agent.HasSiteWith(site.ID(a.ScopeSiteID)))
+	}
+
+	switch a.Kind {
+	case deploymentassignment.KindTag:
+		if a.Edges.Tag == nil {
+			return nil, errors.New("the tag of the assignment has been removed")
+		}
+		query.Where(agent.HasTagsWith(tag.ID(a.Edges.Tag.ID)))
+	case deploymentassignment.KindSite:
+		if a.Edges.Site == nil {
+			return nil, errors.New("the site of the assignment has been removed")
+		}
+		query.Where(agent.HasSiteWith(site.ID(a.Edges.Site.ID)))
+	case deploymentassignment.KindSearch:
+		f, err := GetDeploymentAssignmentSearch(a)
+		if err != nil {
+			return nil, err
+		}
+		// The status is always enabled whatever was saved
+		f.AgentStatusOptions = nil
+		applyAgentFilters(query, f)
+	}
+
+	members, err := query.IDs(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := m.Client.DeploymentJobTarget.Query().
+		Where(deploymentjobtarget.HasJobWith(
+			deploymentjob.HasAssignmentWith(deploymentassignment.ID(a.ID)),
+			deploymentjob.StatusNEQ(deploymentjob.StatusAborted),
+		)).
+		WithAgent().
+		WithJob().
+		Order(ent.Asc(deploymentjobtarget.FieldID)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	last := map[string]*ent.DeploymentJobTarget{}
+	for _, t := range targets {
+		if t.Edges.Agent != nil && t.Edges.Job != nil {
+			last[t.Edges.Agent.ID] = t
+		}
+	}
+
+	result := DeploymentAssignmentMembers{Members: members}
+	for _, id := range members {
+		if t, ok := last[id]; !ok || t.Edges.Job.Action != "install" {
+			result.Joined = append(result.Joined, id)
+		}
+	}
+
+	for id, t := range last {
+		if t.Edges.Job.Action != "install" || t.Edges.Agent.AgentStatus != agent.AgentStatusEnabled || slices.Contains(members, id) {
+			continue
+		}
+		// Only installs that reached the computer leave something to remove, queued ones haven't been
+		// sent and failed or timed out ones may have installed nothing
+		if !slices.Contains([]deploymentjobtarget.Status{deploymentjobtarget.StatusSent, deploymentjobtarget.StatusRunning, deploymentjobtarget.StatusSucceeded}, t.Status) {
+			continue
+		}
+		result.Left = append(result.Left, id)
+	}
+	slices.Sort(result.Left)
+
+	return &result, nil
+}
+
+// EvaluateDeploymentAssignments sends the package of the enabled assignments to the computers that joined
+// their group, and removes it from the ones that left if the assignment asks for it
+func (m *Model) EvaluateDeploymentAssignments() error {
+	assignments, err := m.Client.DeploymentAssignment.Query().
+		Where(deploymentassignment.Enabled(true)).
+		WithTag().
+		WithSite().
+		WithTenant().
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, a := range assignments {
+		// Computers that join or leave the group during a change freeze wait until it ends
+		if a.Edges.Tenant != nil {
+			freeze, err := m.GetActiveChangeFreeze(a.Edges.Tenant.ID, time.Now())
+			if err != nil {
+				log.Printf("[ERROR]: could not get the change freeze of tenant %d, reason: %v", a.Edges.Tenant.ID, err)
+				continue
+			}
+			if freeze != nil {
+				continue
+			}
+		}
+
+		if err := m.EvaluateDeploymentAssignment(a); err != nil {
+			log.Printf("[ERROR]: could not evaluate deployment assignment %d, reason: %v", a.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// EvaluateDeploymentAssignment adds the computers that joined or left the group of the assignment
+// to its install or uninstall jobs, the jobs are created the first time they're needed
+func (m *Model) EvaluateDeploymentAssignment(a *ent.DeploymentAssignment) error {
+	if a.Edges.Tenant == nil {
+		return errors.New("the assignment has no tenant")
+	}
+	tenantID := a.Edges.Tenant.ID
+
+	members, err := m.GetDeploymentAssignmentMembers(a, tenantID)
+	if err != nil {
+		return err
+	}
+
+	if len(members.Joined) > 0 {
+		if err := m.addDeploymentAssignmentTargets(a, tenantID, "install", members.Joined); err != nil {
+			return err
+		}
+	}
+
+	if a.UninstallOnLeave && len(members.Left) > 0 {
+		if err := m.addDeploymentAssignmentTargets(a, tenantID, "uninstall", members.Left); err != nil {
+			return err
+		}
+	}
+
+	return m.Client.DeploymentAssignment.UpdateOneID(a.ID).SetLastEvaluated(time.Now()).Exec(context.Background())
+}
+
+// addDeploymentAssignmentTargets queues the computers in the open job of the assignment for that action,
+// finished jobs are running again with the new computers so one job reports the whole assignment. A job
+// belongs to a change reference, once it's approved the computers that join later are sent without approval
+func (m *Model) addDeploymentAssignmentTargets(a *ent.DeploymentAssignment, tenantID int, action string, agents []string) error {
+	job, err := m.Client.DeploymentJob.Query().
+		Where(
+			deploymentjob.HasAssignmentWith(deploymentassignment.ID(a.ID)),
+			deploymentjob.Action(action),
+			deploymentjob.StatusNEQ(deploymentjob.StatusAborted),
+			deploymentjob.ChangeReference(a.ChangeReference),
+		).
+		Order(ent.Desc(deploymentjob.FieldCreated)).
+		First(context.Background())
+	if err != nil {
+		if !ent.IsNotFound(err) {
+			return err
+		}
+
+		r := DeploymentJobRequest{
+			PackageID:       a.PackageID,
+			PackageName:     a.PackageName,
+			Action:          action,
+			Selection:       "assignment",
+			CreatedBy:       a.CreatedBy,
+			Agents:          agents,
+			AssignmentID:    a.ID,
+			ChangeReference: a.ChangeReference,
+		}
+		if a.ChangeRequestedBy != "" {
+			r.CreatedBy = a.ChangeRequestedBy
+		}
+		if action == "install" {
+			r.PackageVersion = a.PackageVersion
+		}
+		_, err := m.CreateDeploymentJob(r, &partials.CommonInfo{TenantID: strconv.Itoa(tenantID), SiteID: "-1"})
+		return err
+	}
+
+	targets := []*ent.DeploymentJobTargetCreate{}
+	for _, id := range agents {
+		targets = append(targets, m.Client.DeploymentJobTarget.Create().
+			SetStatus(deploymentjobtarget.StatusQueued).
+			SetUpdated(time.Now()).
+			SetJobID(job.ID).
+			SetAgentID(id))
+	}
+	if err := m.Client.DeploymentJobTarget.CreateBulk(targets...).Exec(context.Background()); err != nil {
+		return err
+	}
+
+	if job.Status == deploymentjob.StatusCompleted {
+		return m.Client.DeploymentJob.UpdateOneID(job.ID).SetStatus(deploymentjob.StatusRunning).SetStatusMessage("").Exec(context.Background())
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package models
+
+import (
+	"context"
+	"testing"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentAssignmentsTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	siteID   int
+	tagID    int
+}
+
+func (suite *DeploymentAssignmentsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	finance, err := client.Tag.Create().SetTag("Finance").SetDescription("Finance").SetColor("#00ff00").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+	suite.tagID = finance.ID
+
+	err = client.Agent.Create().SetID("agent0").SetHostname("agent0").SetOs("windows").SetNickname("fin-agent0").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	err = client.Agent.Create().SetID("agent1").SetHostname("agent1").SetOs("windows").SetNickname("agent1").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).AddTagIDs(finance.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	err = client.Agent.Create().SetID("agent2").SetHostname("agent2").SetOs("windows").SetNickname("fin-agent2").SetAgentStatus(agent.AgentStatusDisabled).AddSiteIDs(s.ID).AddTagIDs(finance.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+}
+
+func (suite *DeploymentAssignmentsTestSuite) TestAddDeploymentAssignment() {
+	r := DeploymentAssignmentRequest{Name: "", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID}
+	_, err := suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.Error(suite.T(), err, "the name is required")
+
+	r.Name = "Finance browser"
+	r.PackageID = PrivatePackageID(1)
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.Error(suite.T(), err, "private packages can't be assigned")
+
+	r.PackageID = "Mozilla.Firefox"
+	r.TagID = 9999
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.Error(suite.T(), err, "the tag must belong to the tenant")
+
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Search", PackageID: "Mozilla.Firefox", Kind: "search"})
+	assert.Error(suite.T(), err, "a search needs criteria")
+
+	r.TagID = suite.tagID
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, r)
+	assert.Error(suite.T(), err, "the name must be unique")
+
+	assignments, err := suite.model.GetDeploymentAssignments(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get assignments")
+	assert.Equal(suite.T(), 1, len(assignments))
+	assert.Equal(suite.T(), suite.tagID, assignments[0].Edges.Tag.ID)
+	assert.True(suite.T(), assignments[0].Enabled)
+
+	err = suite.model.DeleteDeploymentAssignment(suite.tenantID, assignments[0].ID)
+	assert.NoError(suite.T(), err, "should delete assignment")
+}
+
+func (suite *DeploymentAssignmentsTestSuite) TestEvaluateTagAssignment() {
+	a, err := suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Finance browser", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID, UninstallOnLeave: true})
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	jobs, err := suite.model.Client.DeploymentJob.Query().WithTargets(func(q *ent.DeploymentJobTargetQuery) { q.WithAgent() }).All(context.Background())
+	assert.NoError(suite.T(), err, "should get jobs")
+	assert.Equal(suite.T(), 1, len(jobs))
+	assert.Equal(suite.T(), "install", jobs[0].Action)
+	assert.Equal(suite.T(), "assignment", jobs[0].Selection)
+	assert.Equal(suite.T(), 1, len(jobs[0].Edges.Targets), "disabled agents are not members")
+	assert.Equal(suite.T(), "agent1", jobs[0].Edges.Targets[0].Edges.Agent.ID)
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	count, err := suite.model.Client.DeploymentJobTarget.Query().Count(context.Background())
+	assert.NoError(suite.T(), err, "should count targets")
+	assert.Equal(suite.T(), 1, count, "members are sent only once")
+
+	// The job finished, a new member runs it again
+	err = suite.model.Client.DeploymentJob.UpdateOneID(jobs[0].ID).SetStatus(deploymentjob.StatusCompleted).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should complete job")
+	err = suite.model.Client.DeploymentJobTarget.Update().SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set targets as succeeded")
+
+	err = suite.model.Client.Agent.UpdateOneID("agent0").AddTagIDs(suite.tagID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should tag agent")
+	err = suite.model.Client.Agent.UpdateOneID("agent1").ClearTags().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should untag agent")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	job, err := suite.model.Client.DeploymentJob.Query().Where(deploymentjob.Action("install")).WithTargets().Only(context.Background())
+	assert.NoError(suite.T(), err, "should get install job")
+	assert.Equal(suite.T(), deploymentjob.StatusRunning, job.Status)
+	assert.Equal(suite.T(), 2, len(job.Edges.Targets))
+
+	uninstall, err := suite.model.Client.DeploymentJob.Query().Where(deploymentjob.Action("uninstall")).WithTargets(func(q *ent.DeploymentJobTargetQuery) { q.WithAgent() }).Only(context.Background())
+	assert.NoError(suite.T(), err, "should get uninstall job")
+	assert.Equal(suite.T(), 1, len(uninstall.Edges.Targets))
+	assert.Equal(suite.T(), "agent1", uninstall.Edges.Targets[0].Edges.Agent.ID)
+
+	a, err = suite.model.GetDeploymentAssignment(suite.tenantID, a.ID)
+	assert.NoError(suite.T(), err, "should get assignment")
+	members, err := suite.model.GetDeploymentAssignmentMembers(a, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get members")
+	assert.Equal(suite.T(), []string{"agent0"}, members.Members)
+	assert.Empty(suite.T(), members.Joined)
+	assert.Empty(suite.T(), members.Left)
+}
+
+func (suite *DeploymentAssignmentsTestSuite) TestEvaluateSearchAssignment() {
+	a, err := suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Finance by name", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "search", Search: filters.AgentFilter{Nickname: "fin-"}})
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	err = suite.model.SetDeploymentAssignmentEnabled(suite.tenantID, a.ID, false)
+	assert.NoError(suite.T(), err, "should pause assignment")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	count, err := suite.model.Client.DeploymentJob.Query().Count(context.Background())
+	assert.NoError(suite.T(), err, "should count jobs")
+	assert.Equal(suite.T(), 0, count, "paused assignments are not evaluated")
+
+	err = suite.model.SetDeploymentAssignmentEnabled(suite.tenantID, a.ID, true)
+	assert.NoError(suite.T(), err, "should resume assignment")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	targets, err := suite.model.Client.DeploymentJobTarget.Query().WithAgent().All(context.Background())
+	assert.NoError(suite.T(), err, "should get targets")
+	assert.Equal(suite.T(), 1, len(targets))
+	assert.Equal(suite.T(), "agent0", targets[0].Edges.Agent.ID)
+
+	// Computers are not uninstalled unless the assignment asks for it
+	err = suite.model.Client.Agent.UpdateOneID("agent0").SetNickname("agent0").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should rename agent")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	count, err = suite.model.Client.DeploymentJob.Query().Where(deploymentjob.Action("uninstall")).Count(context.Background())
+	assert.NoError(suite.T(), err, "should count jobs")
+	assert.Equal(suite.T(), 0, count)
+}
+
+func (suite *DeploymentAssignmentsTestSuite) TestDeploymentAssignmentLeft() {
+	a, err := suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Finance browser", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID, UninstallOnLeave: true})
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	err = suite.model.Client.Agent.UpdateOneID("agent0").AddTagIDs(suite.tagID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should tag agent")
+
+	err = suite.model.EvaluateDeploymentAssignments()
+	assert.NoError(suite.T(), err, "should evaluate assignments")
+
+	// agent0 is still queued and agent1 got the package before being disabled
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.HasAgentWith(agent.ID("agent1"))).SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set target as succeeded")
+	err = suite.model.Client.Agent.UpdateOneID("agent1").SetAgentStatus(agent.AgentStatusDisabled).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should disable agent")
+	err = suite.model.Client.Agent.UpdateOneID("agent0").ClearTags().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should untag agent")
+
+	a, err = suite.model.GetDeploymentAssignment(suite.tenantID, a.ID)
+	assert.NoError(suite.T(), err, "should get assignment")
+	members, err := suite.model.GetDeploymentAssignmentMembers(a, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get members")
+	assert.Empty(suite.T(), members.Members)
+	assert.Empty(suite.T(), members.Joined)
+	assert.Empty(suite.T(), members.Left, "disabled computers and queued installs don't leave the group")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.HasAgentWith(agent.ID("agent0"))).SetStatus(deploymentjobtarget.StatusTimedOut).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set target as timed out")
+
+	members, err = suite.model.GetDeploymentAssignmentMembers(a, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get members")
+	assert.Empty(suite.T(), members.Left, "timed out installs don't leave the group")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.HasAgentWith(agent.ID("agent0"))).SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set target as succeeded")
+
+	members, err = suite.model.GetDeploymentAssignmentMembers(a, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get members")
+	assert.Equal(suite.T(), []string{"agent0"}, members.Left)
+}
+
+func (suite *DeploymentAssignmentsTestSuite) TestDeploymentAssignmentSiteScope() {
+	other, err := suite.model.Client.Site.Create().SetDescription("Other").SetTenantID(suite.tenantID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site")
+
+	err = suite.model.Client.Agent.Create().SetID("agent3").SetHostname("agent3").SetOs("windows").SetNickname("agent3").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(other.ID).AddTagIDs(suite.tagID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	_, err = suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Other site", PackageID: "Mozilla.Firefox", Kind: "site", SiteID: other.ID, ScopeSiteID: suite.siteID})
+	assert.Error(suite.T(), err, "users working in a site can't assign packages to another site")
+
+	a, err := suite.model.AddDeploymentAssignment(suite.tenantID, DeploymentAssignmentRequest{Name: "Finance browser", PackageID: "Mozilla.Firefox", PackageName: "Firefox", Kind: "tag", TagID: suite.tagID, ScopeSiteID: other.ID})
+	assert.NoError(suite.T(), err, "should add assignment")
+
+	a, err = suite.model.GetDeploymentAssignment(suite.tenantID, a.ID)
+	assert.NoError(suite.T(), err, "should get assignment")
+	members, err := suite.model.GetDeploymentAssignmentMembers(a, suite.tenantID)
+	assert.NoError(suite.T(), err, "should get members")
+	assert.Equal(suite.T(), []string{"agent3"}, members.Members, "only the computers of the site are members")
+}
+
+func TestDeploymentAssignmentsTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentAssignmentsTestSuite))
+}
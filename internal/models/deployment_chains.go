@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/deploymentjobattempt"
+	"github.com/scncore/ent/deploymentjobstep"
+	"github.com/scncore/ent/deploymentjobtarget"
+)
+
+// MaxDeploymentChainLength limits the packages that are installed before the package of a job
+const MaxDeploymentChainLength = 5
+
+// DeploymentChainItem is a package that must be installed before the package of the job
+type DeploymentChainItem struct {
+	PackageID      string
+	PackageName    string
+	PackageVersion string
+}
+
+// DeploymentChainPackage is the package a target is installing, the job's package once the chain is done
+type DeploymentChainPackage struct {
+	PackageID      string
+	PackageName    string
+	PackageVersion string
+	Action         string
+}
+
+func validateDeploymentChain(r DeploymentJobRequest) error {
+	if len(r.Chain) == 0 {
+		return nil
+	}
+
+	if r.Action != "install" && r.Action != "update" {
+		return errors.New("only installs and updates can have packages installed first")
+	}
+
+	if len(r.Chain) > MaxDeploymentChainLength {
+		return errors.New("no more than " + strconv.Itoa(MaxDeploymentChainLength) + " packages can be installed first")
+	}
+
+	ids := []string{r.PackageID}
+	for _, item := range r.Chain {
+		if strings.TrimSpace(item.PackageID) == "" {
+			return errors.New("the package ID is required")
+		}
+		if _, ok := ParsePrivatePackageID(item.PackageID); ok {
+			return errors.New("private packages can't be installed first")
+		}
+		if slices.Contains(ids, item.PackageID) {
+			return errors.New("a package can only be once in the chain")
+		}
+		ids = append(ids, item.PackageID)
+	}
+
+	return nil
+}
+
+func (m *Model) createDeploymentJobSteps(jobID int, chain []DeploymentChainItem) error {
+	steps := []*ent.DeploymentJobStepCreate{}
+	for i, item := range chain {
+		steps = append(steps, m.Client.DeploymentJobStep.Create().
+			SetPosition(i).
+			SetPackageID(item.PackageID).
+			SetPackageName(item.PackageName).
+			SetPackageVersion(item.PackageVersion).
+			SetJobID(jobID))
+	}
+	return m.Client.DeploymentJobStep.CreateBulk(steps...).Exec(context.Background())
+}
+
+// withDeploymentJobSteps loads the chain of a job in the order it's installed
+func withDeploymentJobSteps(q *ent.DeploymentJobStepQuery) {
+	q.Order(ent.Asc(deploymentjobstep.FieldPosition))
+}
+
+// GetDeploymentChainPackage returns the package that the target installs in its current step,
+// the job must be loaded with its steps
+func GetDeploymentChainPackage(t *ent.DeploymentJobTarget) DeploymentChainPackage {
+	job := t.Edges.Job
+	if t.Step < len(job.Edges.Steps) {
+		s := job.Edges.Steps[t.Step]
+		return DeploymentChainPackage{PackageID: s.PackageID, PackageName: s.PackageName, PackageVersion: s.PackageVersion, Action: "install"}
+	}
+	return DeploymentChainPackage{PackageID: job.PackageID, PackageName: job.PackageName, PackageVersion: job.PackageVersion, Action: job.Action}
+}
+
+// inDeploymentChain tells if the target is still installing the packages that go before the job's package
+func inDeploymentChain(t *ent.DeploymentJobTarget) bool {
+	return t.Edges.Job != nil && t.Step < len(t.Edges.Job.Edges.Steps)
+}
+
+// advanceDeploymentTarget saves the successful attempt of a package of the chain and queues
+// the target again with the next package, attempts are counted again for every package
+func (m *Model) advanceDeploymentTarget(t *ent.DeploymentJobTarget, now time.Time) error {
+	p := GetDeploymentChainPackage(t)
+
+	err := m.Client.DeploymentJobAttempt.Create().
+		SetAttempt(t.Attempts).
+		SetStatus(deploymentjobattempt.StatusSucceeded).
+		SetMessage(p.PackageName + " has been installed").
+		SetSent(t.Sent).
+		SetFinished(now).
+		SetTargetID(t.ID).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return m.Client.DeploymentJobTarget.UpdateOneID(t.ID).
+		SetStatus(deploymentjobtarget.StatusQueued).
+		SetStep(t.Step + 1).
+		SetAttempts(0).
+		SetMessage("").
+		SetErrorClass("").
+		SetRetryAt(time.Time{}).
+		SetUpdated(now).
+		Exec(context.Background())
+}
@@ -0,0 +1,178 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentChainsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DeploymentChainsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 2; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *DeploymentChainsTestSuite) chain() []DeploymentChainItem {
+	return []DeploymentChainItem{
+		{PackageID: "Microsoft.VCRedist.2015+.x64", PackageName: "VC++ Redistributable"},
+		{PackageID: "Microsoft.DotNet.DesktopRuntime.8", PackageName: ".NET Desktop Runtime", PackageVersion: "8.0.10"},
+	}
+}
+
+// installed makes the agent report that the package was installed after the target was sent
+func (suite *DeploymentChainsTestSuite) installed(agentId, packageId string, failed bool) {
+	err := suite.model.Client.Deployment.Create().
+		SetName(packageId).
+		SetPackageID(packageId).
+		SetOwnerID(agentId).
+		SetInstalled(time.Now().Add(time.Minute)).
+		SetFailed(failed).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create deployment")
+}
+
+func (suite *DeploymentChainsTestSuite) sendDueTargets() {
+	due, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	for _, t := range due {
+		err := suite.model.SetDeploymentTargetSent(t.ID)
+		assert.NoError(suite.T(), err, "should set target as sent")
+	}
+}
+
+func (suite *DeploymentChainsTestSuite) TestDeploymentChainValidation() {
+	r := DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "uninstall", Agents: []string{"agent0"}, Chain: suite.chain()}
+	_, err := suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "uninstalls can't have a chain")
+
+	r.Action = "install"
+	r.Chain = append(suite.chain(), DeploymentChainItem{PackageID: "Mozilla.Firefox"})
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "the package of the job can't be in its chain")
+
+	r.Chain = append(suite.chain(), DeploymentChainItem{PackageID: PrivatePackageID(1)})
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "private packages can't be in a chain")
+
+	r.Chain = []DeploymentChainItem{}
+	for i := 0; i <= MaxDeploymentChainLength; i++ {
+		r.Chain = append(r.Chain, DeploymentChainItem{PackageID: "Package" + strconv.Itoa(i)})
+	}
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "the chain is too long")
+
+	r.Chain = suite.chain()
+	job, err := suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), 2, len(job.Edges.Steps))
+	assert.Equal(suite.T(), "Microsoft.VCRedist.2015+.x64", job.Edges.Steps[0].PackageID)
+}
+
+func (suite *DeploymentChainsTestSuite) TestDeploymentChain() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0", "agent1"}, Chain: suite.chain()}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	due, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 2, len(due))
+	for _, t := range due {
+		p := GetDeploymentChainPackage(t)
+		assert.Equal(suite.T(), "Microsoft.VCRedist.2015+.x64", p.PackageID, "the chain starts with its first package")
+		assert.Equal(suite.T(), "install", p.Action)
+	}
+	suite.sendDueTargets()
+
+	suite.installed("agent0", "Microsoft.VCRedist.2015+.x64", false)
+	suite.installed("agent1", "Microsoft.VCRedist.2015+.x64", true)
+
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	targets, err := suite.model.Client.DeploymentJobTarget.Query().WithAgent().All(context.Background())
+	assert.NoError(suite.T(), err, "should get targets")
+	for _, t := range targets {
+		switch t.Edges.Agent.ID {
+		case "agent0":
+			assert.Equal(suite.T(), deploymentjobtarget.StatusQueued, t.Status, "the next package is queued")
+			assert.Equal(suite.T(), 1, t.Step)
+			assert.Equal(suite.T(), 0, t.Attempts, "attempts are counted again for every package")
+		case "agent1":
+			assert.Equal(suite.T(), deploymentjobtarget.StatusFailed, t.Status, "a failure stops the chain")
+			assert.Equal(suite.T(), 0, t.Step)
+			assert.Contains(suite.T(), t.Message, "VC++ Redistributable")
+		}
+	}
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 1, len(due))
+	p := GetDeploymentChainPackage(due[0])
+	assert.Equal(suite.T(), "Microsoft.DotNet.DesktopRuntime.8", p.PackageID)
+	assert.Equal(suite.T(), "8.0.10", p.PackageVersion)
+	suite.sendDueTargets()
+
+	suite.installed("agent0", "Microsoft.DotNet.DesktopRuntime.8", false)
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 1, len(due))
+	assert.Equal(suite.T(), "Mozilla.Firefox", GetDeploymentChainPackage(due[0]).PackageID, "the package of the job goes last")
+	suite.sendDueTargets()
+
+	suite.installed("agent0", "Mozilla.Firefox", false)
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	progress := GetDeploymentJobProgress(job.Edges.Targets)
+	assert.Equal(suite.T(), 1, progress.Succeeded)
+	assert.Equal(suite.T(), 1, progress.Failed)
+
+	attempts, err := suite.model.GetDeploymentAttemptsForAgent("agent0", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment attempts")
+	assert.Equal(suite.T(), 3, len(attempts), "every package of the chain is an attempt")
+}
+
+func TestDeploymentChainsTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentChainsTestSuite))
+}
@@ -0,0 +1,522 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deployment"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/rolloutring"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// DeploymentJobTimeout is how long we wait for an agent to report the result of a deployment
+const DeploymentJobTimeout = 24 * time.Hour
+
+var DeploymentJobActions = []string{"install", "update", "uninstall", "agentupdate", "rollback"}
+
+// AgentUpdatePackageID is the package of the jobs that update the agents, their version is the release
+const AgentUpdatePackageID = "agent"
+
+type DeploymentJobRequest struct {
+	PackageID      string
+	PackageName    string
+	PackageVersion string
+	Action         string
+	Selection      string
+	CreatedBy      string
+	Start          time.Time
+	Agents         []string
+	PlanID         int
+	RespectWindow  bool
+	AssignmentID   int
+	// Retry policy, a job with one attempt is never sent again
+	RetryMaxAttempts int
+	RetryBackoff     int
+	RetryOn          []string
+	// Packages installed in order before the package of the job, a failure stops the chain for that computer
+	Chain []DeploymentChainItem
+	// Change ticket of the deployment, required if the tenant approves its deployments
+	ChangeReference string
+}
+
+type DeploymentJobProgress struct {
+	Total     int
+	Queued    int
+	Sent      int
+	Running   int
+	Succeeded int
+	Failed    int
+	TimedOut  int
+	Retrying  int
+}
+
+// Finished returns the number of targets that won't change their status anymore
+func (p DeploymentJobProgress) Finished() int {
+	return p.Succeeded + p.Failed + p.TimedOut
+}
+
+// Percent returns the percentage of targets that the number passed in represents
+func (p DeploymentJobProgress) Percent(n int) int {
+	if p.Total == 0 {
+		return 0
+	}
+	return n * 100 / p.Total
+}
+
+func (m *Model) CreateDeploymentJob(r DeploymentJobRequest, c *partials.CommonInfo) (*ent.DeploymentJob, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.TrimSpace(r.PackageID) == "" {
+		return nil, errors.New("the package ID is required")
+	}
+
+	if !slices.Contains(DeploymentJobActions, r.Action) {
+		return nil, errors.New("the deployment action is not valid")
+	}
+
+	// Agents only know how to install and uninstall the installers uploaded to the console
+	if _, ok := ParsePrivatePackageID(r.PackageID); ok && r.Action != "install" && r.Action != "uninstall" {
+		return nil, errors.New("private packages can only be installed or uninstalled")
+	}
+
+	agents := []string{}
+	for _, a := range r.Agents {
+		if a = strings.TrimSpace(a); a != "" {
+			agents = append(agents, a)
+		}
+	}
+	if len(agents) == 0 {
+		return nil, errors.New("at least one computer must be selected")
+	}
+
+	// Only the enabled computers of the tenant, or of the site the user is working in, get a target
+	query := m.Client.Agent.Query().Where(agent.IDIn(agents...), agent.AgentStatusEQ(agent.AgentStatusEnabled), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	if c.SiteID != "-1" {
+		siteID, err := strconv.Atoi(c.SiteID)
+		if err != nil {
+			return nil, err
+		}
+		query.Where(agent.HasSiteWith(site.ID(siteID)))
+	}
+	if agents, err = query.IDs(context.Background()); err != nil {
+		return nil, err
+	}
+	if len(agents) == 0 {
+		return nil, errors.New("none of the selected computers is enabled in this site")
+	}
+
+	// A rollback is only sent to the computers that know the version they had before the update
+	if r.Action == "rollback" {
+		if agents, err = m.getDeploymentRollbackAgents(r.PackageID, agents); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.Start.IsZero() {
+		r.Start = time.Now()
+	}
+
+	if r.RetryMaxAttempts == 0 {
+		r.RetryMaxAttempts = 1
+	}
+	if err := validateRetryPolicy(r); err != nil {
+		return nil, err
+	}
+	if err := validateDeploymentChain(r); err != nil {
+		return nil, err
+	}
+
+	// Package deployments wait for approval if the tenant requires it, assignments only ask for it
+	// when their first job for a change reference is created
+	status := deploymentjob.StatusRunning
+	statusMessage := ""
+	r.ChangeReference = strings.TrimSpace(r.ChangeReference)
+	if r.Action != "agentupdate" {
+		approval, err := m.GetDeploymentApprovalRequired(c.TenantID)
+		if err != nil {
+			return nil, err
+		}
+		if approval {
+			if r.ChangeReference == "" {
+				return nil, errors.New("the deployment needs a change reference")
+			}
+			status = deploymentjob.StatusPending
+			statusMessage = "the deployment is waiting for approval"
+		}
+	}
+
+	// With a rollout plan every computer is assigned to a ring, only the first one is sent at the start
+	rings := map[string]int{}
+	if r.PlanID != 0 {
+		plan, err := m.GetRolloutPlan(tenantID, r.PlanID)
+		if err != nil {
+			return nil, err
+		}
+
+		selected, err := m.Client.Agent.Query().Where(agent.IDIn(agents...)).WithTags().WithSite().All(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		rings = AssignRolloutRings(plan.Edges.Rings, selected)
+	}
+
+	query := m.Client.DeploymentJob.Create().
+		SetPackageID(r.PackageID).
+		SetPackageName(r.PackageName).
+		SetPackageVersion(r.PackageVersion).
+		SetAction(r.Action).
+		SetSelection(r.Selection).
+		SetCreatedBy(r.CreatedBy).
+		SetCreated(time.Now()).
+		SetStart(r.Start).
+		SetStatus(status).
+		SetStatusMessage(statusMessage).
+		SetChangeReference(r.ChangeReference).
+		SetRingStarted(r.Start).
+		SetRespectWindow(r.RespectWindow).
+		SetRetryMaxAttempts(r.RetryMaxAttempts).
+		SetRetryBackoff(r.RetryBackoff).
+		SetRetryOn(r.RetryOn).
+		SetTenantID(tenantID)
+	if r.PlanID != 0 {
+		query.SetPlanID(r.PlanID)
+	}
+	if r.AssignmentID != 0 {
+		query.SetAssignmentID(r.AssignmentID)
+	}
+
+	job, err := query.Save(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(r.Chain) > 0 {
+		if err := m.createDeploymentJobSteps(job.ID, r.Chain); err != nil {
+			if err := m.Client.DeploymentJob.DeleteOneID(job.ID).Exec(context.Background()); err != nil {
+				log.Printf("[ERROR]: could not remove deployment job %d, reason: %v", job.ID, err)
+			}
+			return nil, err
+		}
+	}
+
+	targets := []*ent.DeploymentJobTargetCreate{}
+	for _, a := range agents {
+		targets = append(targets, m.Client.DeploymentJobTarget.Create().
+			SetStatus(deploymentjobtarget.StatusQueued).
+			SetRing(rings[a]).
+			SetUpdated(time.Now()).
+			SetJobID(job.ID).
+			SetAgentID(a))
+	}
+	if err := m.Client.DeploymentJobTarget.CreateBulk(targets...).Exec(context.Background()); err != nil {
+		// A job without targets makes no sense
+		if err := m.Client.DeploymentJob.DeleteOneID(job.ID).Exec(context.Background()); err != nil {
+			log.Printf("[ERROR]: could not remove deployment job %d, reason: %v", job.ID, err)
+		}
+		return nil, err
+	}
+
+	return job, nil
+}
+
+func (m *Model) getDeploymentJobsQuery(c *partials.CommonInfo) (*ent.DeploymentJobQuery, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == -1 {
+		return m.Client.DeploymentJob.Query().Where(deploymentjob.HasTenantWith(tenant.ID(tenantID))), nil
+	}
+	return m.Client.DeploymentJob.Query().Where(deploymentjob.HasTenantWith(tenant.ID(tenantID)), deploymentjob.HasTargetsWith(deploymentjobtarget.HasAgentWith(agent.HasSiteWith(site.ID(siteID))))), nil
+}
+
+func (m *Model) CountDeploymentJobs(c *partials.CommonInfo) (int, error) {
+	query, err := m.getDeploymentJobsQuery(c)
+	if err != nil {
+		return 0, err
+	}
+	return query.Count(context.Background())
+}
+
+func (m *Model) GetDeploymentJobs(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.DeploymentJob, error) {
+	query, err := m.getDeploymentJobsQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	query = query.WithTargets().Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+
+	switch p.SortBy {
+	case "package":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(deploymentjob.FieldPackageName))
+		} else {
+			query = query.Order(ent.Desc(deploymentjob.FieldPackageName))
+		}
+	case "action":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(deploymentjob.FieldAction))
+		} else {
+			query = query.Order(ent.Desc(deploymentjob.FieldAction))
+		}
+	case "createdBy":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(deploymentjob.FieldCreatedBy))
+		} else {
+			query = query.Order(ent.Desc(deploymentjob.FieldCreatedBy))
+		}
+	default:
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(deploymentjob.FieldStart))
+		} else {
+			query = query.Order(ent.Desc(deploymentjob.FieldStart))
+		}
+	}
+
+	return query.All(context.Background())
+}
+
+// GetDeploymentJob returns the job with its targets, when a site is selected only the computers
+// of that site are returned
+func (m *Model) GetDeploymentJob(id int, c *partials.CommonInfo) (*ent.DeploymentJob, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := m.getDeploymentJobsQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Where(deploymentjob.ID(id)).WithTargets(func(q *ent.DeploymentJobTargetQuery) {
+		if siteID != -1 {
+			q.Where(deploymentjobtarget.HasAgentWith(agent.HasSiteWith(site.ID(siteID))))
+		}
+		q.WithAgent().Order(ent.Asc(deploymentjobtarget.FieldRing), ent.Asc(deploymentjobtarget.FieldID))
+	}).WithPlan(func(q *ent.RolloutPlanQuery) {
+		q.WithRings(func(q *ent.RolloutRingQuery) { q.Order(ent.Asc(rolloutring.FieldPosition)) })
+	}).WithAssignment().WithSteps(withDeploymentJobSteps).Only(context.Background())
+}
+
+// GetDeploymentJobProgress counts the targets in every status
+func GetDeploymentJobProgress(targets []*ent.DeploymentJobTarget) DeploymentJobProgress {
+	p := DeploymentJobProgress{Total: len(targets)}
+	for _, t := range targets {
+		switch t.Status {
+		case deploymentjobtarget.StatusQueued:
+			p.Queued++
+		case deploymentjobtarget.StatusSent:
+			p.Sent++
+		case deploymentjobtarget.StatusRunning:
+			p.Running++
+		case deploymentjobtarget.StatusSucceeded:
+			p.Succeeded++
+		case deploymentjobtarget.StatusFailed, deploymentjobtarget.StatusTimedOut:
+			// Targets that will be sent again haven't finished yet
+			switch {
+			case !t.RetryAt.IsZero():
+				p.Retrying++
+			case t.Status == deploymentjobtarget.StatusFailed:
+				p.Failed++
+			default:
+				p.TimedOut++
+			}
+		}
+	}
+	return p
+}
+
+// GetDueDeploymentTargets returns the queued targets of the running jobs whose start time has arrived,
+// for rollouts only the targets of the rings that have been promoted are due
+func (m *Model) GetDueDeploymentTargets() ([]*ent.DeploymentJobTarget, error) {
+	targets, err := m.Client.DeploymentJobTarget.Query().
+		Where(deploymentjobtarget.StatusEQ(deploymentjobtarget.StatusQueued), deploymentjobtarget.HasJobWith(deploymentjob.StatusEQ(deploymentjob.StatusRunning), deploymentjob.StartLTE(time.Now()))).
+		WithJob(func(q *ent.DeploymentJobQuery) { q.WithTenant().WithSteps(withDeploymentJobSteps) }).
+		WithAgent().
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	// Agents with a closed maintenance window keep their targets queued if the job respects windows
+	now := time.Now()
+	windows := map[string]bool{}
+
+	due := []*ent.DeploymentJobTarget{}
+	for _, t := range targets {
+		if t.Ring > t.Edges.Job.CurrentRing {
+			continue
+		}
+
+		if t.Edges.Job.RespectWindow && t.Edges.Agent != nil {
+			open, ok := windows[t.Edges.Agent.ID]
+			if !ok {
+				open, _, err = m.AgentMaintenanceWindow(t.Edges.Agent.ID, now)
+				if err != nil {
+					return nil, err
+				}
+				windows[t.Edges.Agent.ID] = open
+			}
+			if !open {
+				continue
+			}
+		}
+
+		due = append(due, t)
+	}
+	return due, nil
+}
+
+// SetDeploymentTargetSent stamps the target as sent before its command is published,
+// so a report that arrives straight away is matched with the attempt
+func (m *Model) SetDeploymentTargetSent(id int) error {
+	now := time.Now()
+	return m.Client.DeploymentJobTarget.UpdateOneID(id).
+		SetStatus(deploymentjobtarget.StatusSent).
+		SetSent(now).
+		SetUpdated(now).
+		SetMessage("").
+		SetErrorClass("").
+		AddAttempts(1).
+		Exec(context.Background())
+}
+
+// SetDeploymentTargetFailed is called when the console could not send the target, it counts as an attempt.
+// A target stamped as sent before the publish failed already counted it, it's no longer sent
+func (m *Model) SetDeploymentTargetFailed(id int, message string) error {
+	t, err := m.Client.DeploymentJobTarget.Query().Where(deploymentjobtarget.ID(id)).WithJob(func(q *ent.DeploymentJobQuery) { q.WithSteps(withDeploymentJobSteps) }).Only(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if t.Status != deploymentjobtarget.StatusSent {
+		t.Attempts++
+	}
+	t.Sent = now
+	if err := m.Client.DeploymentJobTarget.UpdateOneID(id).SetAttempts(t.Attempts).SetSent(now).Exec(context.Background()); err != nil {
+		return err
+	}
+
+	return m.finishDeploymentTarget(t, deploymentjobtarget.StatusFailed, message, "send", now)
+}
+
+// UpdateDeploymentTargets checks the deployments reported by the agents to know
+// how the targets that have been sent are going
+func (m *Model) UpdateDeploymentTargets() error {
+	targets, err := m.Client.DeploymentJobTarget.Query().
+		Where(deploymentjobtarget.StatusIn(deploymentjobtarget.StatusSent, deploymentjobtarget.StatusRunning)).
+		WithJob(func(q *ent.DeploymentJobQuery) { q.WithSteps(withDeploymentJobSteps) }).
+		WithAgent().
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	for _, t := range targets {
+		if t.Edges.Job == nil || t.Edges.Agent == nil {
+			continue
+		}
+
+		// Agent updates are reported in the agent itself
+		var d *ent.Deployment
+		if t.Edges.Job.Action != "agentupdate" {
+			d, err = m.Client.Deployment.Query().Where(deployment.PackageID(GetDeploymentChainPackage(t).PackageID), deployment.HasOwnerWith(agent.ID(t.Edges.Agent.ID))).Order(ent.Desc(deployment.FieldID)).First(context.Background())
+			if err != nil && !ent.IsNotFound(err) {
+				return err
+			}
+		}
+
+		now := time.Now()
+		status, message := DeploymentTargetStatus(t, d, now)
+		if status == t.Status {
+			continue
+		}
+
+		switch {
+		case status == deploymentjobtarget.StatusSucceeded && inDeploymentChain(t):
+			err = m.advanceDeploymentTarget(t, now)
+		case status == deploymentjobtarget.StatusSucceeded:
+			err = m.finishDeploymentTarget(t, status, message, "", now)
+		case status == deploymentjobtarget.StatusFailed:
+			err = m.finishDeploymentTarget(t, status, message, "agent", now)
+		case status == deploymentjobtarget.StatusTimedOut:
+			err = m.finishDeploymentTarget(t, status, message, "timeout", now)
+		default:
+			err = m.Client.DeploymentJobTarget.UpdateOneID(t.ID).SetStatus(status).SetMessage(message).SetUpdated(now).Exec(context.Background())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeploymentTargetStatus decides the status of a target that has been sent using the deployment
+// row that the agent updates. The row is written before the command is sent, so the deployment
+// is only nil once the agent has removed it after uninstalling the package
+func DeploymentTargetStatus(t *ent.DeploymentJobTarget, d *ent.Deployment, now time.Time) (deploymentjobtarget.Status, string) {
+	if d != nil && d.Failed {
+		return deploymentjobtarget.StatusFailed, "the agent reported that the deployment failed"
+	}
+
+	switch GetDeploymentChainPackage(t).Action {
+	case "install":
+		if d != nil && d.Installed.After(t.Sent) {
+			return deploymentjobtarget.StatusSucceeded, ""
+		}
+	case "update":
+		if d != nil && d.Updated.After(t.Sent) {
+			return deploymentjobtarget.StatusSucceeded, ""
+		}
+	case "rollback":
+		if d != nil && d.Installed.After(t.Sent) {
+			return deploymentjobtarget.StatusSucceeded, ""
+		}
+	case "uninstall":
+		if d == nil {
+			return deploymentjobtarget.StatusSucceeded, ""
+		}
+	case "agentupdate":
+		if a := t.Edges.Agent; a != nil && a.UpdateTaskVersion == t.Edges.Job.PackageVersion {
+			switch a.UpdateTaskStatus {
+			case "admin.update.agents.task_status_success":
+				return deploymentjobtarget.StatusSucceeded, ""
+			case "admin.update.agents.task_status_error":
+				return deploymentjobtarget.StatusFailed, a.UpdateTaskResult
+			}
+		}
+	}
+
+	if now.Sub(t.Sent) > DeploymentJobTimeout {
+		return deploymentjobtarget.StatusTimedOut, "the agent didn't report the result in time"
+	}
+
+	// The agent has contacted us since we sent the action so it should be working on it
+	if t.Edges.Agent != nil && t.Edges.Agent.LastContact.After(t.Sent) {
+		return deploymentjobtarget.StatusRunning, ""
+	}
+
+	return t.Status, t.Message
+}
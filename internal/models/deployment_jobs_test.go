@@ -0,0 +1,221 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deployment"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentJobsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DeploymentJobsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 3; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			SetLastContact(time.Now().Add(-1 * time.Hour)).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *DeploymentJobsTestSuite) TestCreateDeploymentJob() {
+	_, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install"}, suite.commonInfo)
+	assert.Error(suite.T(), err, "should require computers")
+
+	_, err = suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "reboot", Agents: []string{"agent0"}}, suite.commonInfo)
+	assert.Error(suite.T(), err, "should reject unknown actions")
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", CreatedBy: "admin", Agents: []string{"agent0", "agent1", ""}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	count, err := suite.model.CountDeploymentJobs(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count deployment jobs")
+	assert.Equal(suite.T(), 1, count)
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), "admin", job.CreatedBy)
+	assert.Equal(suite.T(), 2, len(job.Edges.Targets), "empty computers should be skipped")
+
+	progress := GetDeploymentJobProgress(job.Edges.Targets)
+	assert.Equal(suite.T(), 2, progress.Queued)
+	assert.Equal(suite.T(), 0, progress.Finished())
+
+	due, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 2, len(due))
+
+	_, err = suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "uninstall", Start: time.Now().Add(time.Hour), Agents: []string{"agent2"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create scheduled deployment job")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 2, len(due), "scheduled jobs should wait for their start time")
+}
+
+func (suite *DeploymentJobsTestSuite) TestUpdateDeploymentTargets() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0", "agent1", "agent2"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	for _, t := range job.Edges.Targets {
+		err := suite.model.SetDeploymentTargetSent(t.ID)
+		assert.NoError(suite.T(), err, "should set target as sent")
+
+		err = suite.model.Client.Deployment.Create().
+			SetName("Firefox").
+			SetPackageID("Mozilla.Firefox").
+			SetOwnerID(t.Edges.Agent.ID).
+			SetInstalled(time.Date(0001, 1, 1, 00, 00, 00, 00, time.UTC)).
+			SetUpdated(time.Date(0001, 1, 1, 00, 00, 00, 00, time.UTC)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create deployment")
+	}
+
+	err = suite.model.Client.Deployment.Update().SetInstalled(time.Now().Add(time.Minute)).Where(deployment.HasOwnerWith(agent.ID("agent0"))).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set deployment as installed")
+
+	err = suite.model.Client.Deployment.Update().SetFailed(true).Where(deployment.HasOwnerWith(agent.ID("agent1"))).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set deployment as failed")
+
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	progress := GetDeploymentJobProgress(job.Edges.Targets)
+	assert.Equal(suite.T(), 1, progress.Succeeded)
+	assert.Equal(suite.T(), 1, progress.Failed)
+	assert.Equal(suite.T(), 1, progress.Sent, "agent2 hasn't contacted us since the action was sent")
+	assert.Equal(suite.T(), 66, progress.Percent(progress.Finished()))
+}
+
+func (suite *DeploymentJobsTestSuite) TestDeploymentTargetStatus() {
+	sent := time.Now().Add(-1 * time.Hour)
+	zero := time.Date(0001, 1, 1, 00, 00, 00, 00, time.UTC)
+
+	target := func(action string, lastContact time.Time) *ent.DeploymentJobTarget {
+		return &ent.DeploymentJobTarget{
+			Status: deploymentjobtarget.StatusSent,
+			Sent:   sent,
+			Edges: ent.DeploymentJobTargetEdges{
+				Job:   &ent.DeploymentJob{Action: action},
+				Agent: &ent.Agent{LastContact: lastContact},
+			},
+		}
+	}
+
+	status, _ := DeploymentTargetStatus(target("install", sent.Add(-1*time.Minute)), &ent.Deployment{Installed: zero}, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusSent, status, "the agent hasn't contacted us yet")
+
+	status, _ = DeploymentTargetStatus(target("install", sent.Add(time.Minute)), &ent.Deployment{Installed: zero}, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusRunning, status, "the agent has contacted us after the action was sent")
+
+	status, _ = DeploymentTargetStatus(target("install", sent), &ent.Deployment{Installed: sent.Add(time.Minute)}, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusSucceeded, status)
+
+	status, message := DeploymentTargetStatus(target("install", sent), &ent.Deployment{Installed: zero, Failed: true}, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusFailed, status)
+	assert.NotEmpty(suite.T(), message, "failures should have a message")
+
+	status, _ = DeploymentTargetStatus(target("update", sent), &ent.Deployment{Installed: sent.Add(-1 * time.Hour), Updated: sent.Add(time.Minute)}, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusSucceeded, status)
+
+	status, _ = DeploymentTargetStatus(target("uninstall", sent), nil, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusSucceeded, status, "the agent removes the deployment when the package is uninstalled")
+
+	status, _ = DeploymentTargetStatus(target("install", sent), &ent.Deployment{Installed: zero}, sent.Add(DeploymentJobTimeout+time.Minute))
+	assert.Equal(suite.T(), deploymentjobtarget.StatusTimedOut, status)
+}
+
+func (suite *DeploymentJobsTestSuite) TestSetDeploymentTargetFailedAfterSent() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	// The target is stamped as sent before publishing, a failed publish doesn't count a second attempt
+	err = suite.model.SetDeploymentTargetSent(job.Edges.Targets[0].ID)
+	assert.NoError(suite.T(), err, "should set target as sent")
+
+	err = suite.model.SetDeploymentTargetFailed(job.Edges.Targets[0].ID, "NATS is not connected")
+	assert.NoError(suite.T(), err, "should set target as failed")
+
+	target, err := suite.model.Client.DeploymentJobTarget.Get(context.Background(), job.Edges.Targets[0].ID)
+	assert.NoError(suite.T(), err, "should get target")
+	assert.Equal(suite.T(), deploymentjobtarget.StatusFailed, target.Status, "the target is no longer sent")
+	assert.Equal(suite.T(), 1, target.Attempts)
+}
+
+func (suite *DeploymentJobsTestSuite) TestPrivatePackageDeploymentJob() {
+	_, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: PrivatePackageID(1), PackageName: "Private", Action: "update", Agents: []string{"agent0"}}, suite.commonInfo)
+	assert.Error(suite.T(), err, "private packages can't be updated")
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: PrivatePackageID(1), PackageName: "Private", Action: "install", Agents: []string{"agent0", "agent1"}, RespectWindow: true}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	targets, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 2, len(targets), "private packages are sent by the scheduler like any other job")
+	for _, t := range targets {
+		assert.Equal(suite.T(), job.ID, t.Edges.Job.ID)
+	}
+}
+
+func (suite *DeploymentJobsTestSuite) TestCreateDeploymentJobSkipsDisabledAgents() {
+	err := suite.model.Client.Agent.UpdateOneID("agent1").SetAgentStatus(agent.AgentStatusDisabled).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should disable agent")
+
+	_, err = suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: []string{"agent1", "unknown"}}, suite.commonInfo)
+	assert.Error(suite.T(), err, "disabled and unknown computers can't be deployed")
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: []string{"agent0", "agent1", "unknown"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), 1, len(job.Edges.Targets), "the disabled computer gets no target")
+	assert.Equal(suite.T(), "agent0", job.Edges.Targets[0].Edges.Agent.ID)
+}
+
+func TestDeploymentJobsTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentJobsTestSuite))
+}
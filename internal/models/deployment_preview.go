@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strconv"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+type DeploymentPreviewRequest struct {
+	PackageID   string
+	PackageName string
+	Install     bool
+	Agents      []string
+	// Compatible tells if the package can be installed on an agent operating system, every agent is compatible if nil
+	Compatible func(os string) bool
+	// InstallerSize is the size in bytes of the installer, zero if its source doesn't publish it
+	InstallerSize int64
+}
+
+type DeploymentPreviewAgent struct {
+	ID           string `json:"id"`
+	Nickname     string `json:"nickname"`
+	Offline      bool   `json:"offline"`
+	Installed    bool   `json:"installed"`
+	Incompatible bool   `json:"incompatible"`
+}
+
+type DeploymentPreviewGroup struct {
+	OS     string                   `json:"os"`
+	Site   string                   `json:"site"`
+	Agents []DeploymentPreviewAgent `json:"agents"`
+}
+
+// DeploymentPreview tells what an install or uninstall would do on the selected computers before it's sent
+type DeploymentPreview struct {
+	Groups       []DeploymentPreviewGroup `json:"groups"`
+	Total        int                      `json:"total"`
+	Offline      int                      `json:"offline"`
+	Installed    int                      `json:"installed"`
+	Incompatible int                      `json:"incompatible"`
+	// Downloads is the number of computers that would download the installer
+	Downloads int `json:"downloads"`
+	// DownloadSize is the estimated download volume in bytes, zero if the size of the installer is unknown
+	DownloadSize int64 `json:"download_size"`
+	// DownloadSizeKnown is false if the source of the package doesn't publish the size of its installer
+	DownloadSizeKnown bool `json:"download_size_known"`
+	// Excluded are the selected computers that are disabled, the deployment is not sent to them
+	Excluded []DeploymentPreviewAgent `json:"excluded"`
+}
+
+// DeploymentOfflineAfter is how long an agent can go without contacting the console before it's considered offline
+const DeploymentOfflineAfter = 24 * time.Hour
+
+// GetDeploymentPreview groups the selected computers by operating system and site and tells which of them
+// are offline, already have the package or can't install it. Disabled computers are listed as excluded
+func (m *Model) GetDeploymentPreview(r DeploymentPreviewRequest, c *partials.CommonInfo) (*DeploymentPreview, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().Where(agent.IDIn(r.Agents...)).WithSite().Order(agent.ByNickname())
+	if siteID == -1 {
+		query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	selected, err := query.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if len(selected) == 0 {
+		return nil, errors.New("none of the selected computers can be found")
+	}
+
+	preview := DeploymentPreview{Excluded: []DeploymentPreviewAgent{}}
+	agents := []*ent.Agent{}
+	for _, a := range selected {
+		if a.AgentStatus != agent.AgentStatusEnabled {
+			preview.Excluded = append(preview.Excluded, DeploymentPreviewAgent{ID: a.ID, Nickname: a.Nickname})
+			continue
+		}
+		agents = append(agents, a)
+	}
+
+	groups := map[[2]string]int{}
+	for _, a := range agents {
+		installed, err := m.DeploymentAlreadyInstalled(a.ID, r.PackageID, c)
+		if err != nil {
+			return nil, err
+		}
+
+		// Software installed by other means is found in the inventory of the computer
+		if !installed && r.PackageName != "" {
+			installed, err = m.Client.App.Query().Where(app.NameEqualFold(r.PackageName), app.HasOwnerWith(agent.ID(a.ID))).Exist(context.Background())
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		item := DeploymentPreviewAgent{
+			ID:           a.ID,
+			Nickname:     a.Nickname,
+			Offline:      time.Since(a.LastContact) > DeploymentOfflineAfter,
+			Installed:    installed,
+			Incompatible: r.Compatible != nil && !r.Compatible(a.Os),
+		}
+
+		preview.Total++
+		if item.Offline {
+			preview.Offline++
+		}
+		if item.Installed {
+			preview.Installed++
+		}
+		if item.Incompatible {
+			preview.Incompatible++
+		}
+		if r.Install && !item.Installed && !item.Incompatible {
+			preview.Downloads++
+		}
+
+		siteName := ""
+		if len(a.Edges.Site) == 1 {
+			siteName = a.Edges.Site[0].Description
+		}
+
+		key := [2]string{a.Os, siteName}
+		index, ok := groups[key]
+		if !ok {
+			index = len(preview.Groups)
+			groups[key] = index
+			preview.Groups = append(preview.Groups, DeploymentPreviewGroup{OS: a.Os, Site: siteName})
+		}
+		preview.Groups[index].Agents = append(preview.Groups[index].Agents, item)
+	}
+
+	sort.Slice(preview.Groups, func(i, j int) bool {
+		if preview.Groups[i].OS != preview.Groups[j].OS {
+			return preview.Groups[i].OS < preview.Groups[j].OS
+		}
+		return preview.Groups[i].Site < preview.Groups[j].Site
+	})
+	for _, g := range preview.Groups {
+		sort.Slice(g.Agents, func(i, j int) bool { return g.Agents[i].Nickname < g.Agents[j].Nickname })
+	}
+
+	preview.DownloadSize = int64(preview.Downloads) * r.InstallerSize
+	preview.DownloadSizeKnown = r.InstallerSize > 0
+
+	return &preview, nil
+}
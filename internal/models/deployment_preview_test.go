@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentPreviewTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DeploymentPreviewTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	computers := []struct {
+		os          string
+		lastContact time.Time
+	}{
+		{"windows", time.Now()},
+		{"windows", time.Now()},
+		{"windows", time.Now().AddDate(0, 0, -3)},
+		{"ubuntu", time.Now()},
+	}
+	for i, computer := range computers {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs(computer.os).
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			SetLastContact(computer.lastContact).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// The first computer got the package from the console, the second one has it in its inventory
+	err = client.Deployment.Create().SetName("Mozilla Firefox").SetPackageID("Mozilla.Firefox").SetOwnerID("agent0").SetInstalled(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create deployment")
+	err = client.App.Create().SetName("mozilla firefox").SetVersion("128.0").SetPublisher("Mozilla").SetOwnerID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create app")
+}
+
+func (suite *DeploymentPreviewTestSuite) TestDeploymentPreview() {
+	r := DeploymentPreviewRequest{
+		PackageID:     "Mozilla.Firefox",
+		PackageName:   "Mozilla Firefox",
+		Install:       true,
+		Agents:        []string{"agent0", "agent1", "agent2", "agent3", "unknown"},
+		Compatible:    func(os string) bool { return os == "windows" },
+		InstallerSize: 1000,
+	}
+
+	preview, err := suite.model.GetDeploymentPreview(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment preview")
+	assert.Equal(suite.T(), 4, preview.Total, "unknown computers are left out")
+	assert.Equal(suite.T(), 1, preview.Offline)
+	assert.Equal(suite.T(), 2, preview.Installed)
+	assert.Equal(suite.T(), 1, preview.Incompatible)
+	assert.Equal(suite.T(), 1, preview.Downloads, "only the offline computer needs the installer")
+	assert.Equal(suite.T(), int64(1000), preview.DownloadSize)
+	assert.True(suite.T(), preview.DownloadSizeKnown)
+	assert.Empty(suite.T(), preview.Excluded)
+
+	assert.Equal(suite.T(), 2, len(preview.Groups))
+	assert.Equal(suite.T(), "ubuntu", preview.Groups[0].OS)
+	assert.True(suite.T(), preview.Groups[0].Agents[0].Incompatible)
+	assert.Equal(suite.T(), "windows", preview.Groups[1].OS)
+	assert.Equal(suite.T(), 3, len(preview.Groups[1].Agents))
+
+	r.Install = false
+	preview, err = suite.model.GetDeploymentPreview(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment preview")
+	assert.Equal(suite.T(), 0, preview.Downloads, "an uninstall downloads nothing")
+
+	_, err = suite.model.GetDeploymentPreview(DeploymentPreviewRequest{PackageID: "Mozilla.Firefox", Agents: []string{"unknown"}}, suite.commonInfo)
+	assert.Error(suite.T(), err, "none of the computers can be found")
+}
+
+func (suite *DeploymentPreviewTestSuite) TestDeploymentPreviewExcluded() {
+	err := suite.model.Client.Agent.UpdateOneID("agent3").SetAgentStatus(agent.AgentStatusDisabled).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should disable agent")
+
+	r := DeploymentPreviewRequest{PackageID: "Mozilla.Firefox", PackageName: "Mozilla Firefox", Install: true, Agents: []string{"agent2", "agent3"}}
+	preview, err := suite.model.GetDeploymentPreview(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment preview")
+	assert.Equal(suite.T(), 1, preview.Total)
+	assert.Equal(suite.T(), 1, len(preview.Excluded), "disabled computers are listed as excluded")
+	assert.Equal(suite.T(), "agent3", preview.Excluded[0].ID)
+	assert.Equal(suite.T(), 1, preview.Downloads)
+	assert.False(suite.T(), preview.DownloadSizeKnown, "catalogue packages don't publish the size of their installer")
+}
+
+func TestDeploymentPreviewTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentPreviewTestSuite))
+}
@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobattempt"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// DeploymentErrorClasses tell why an attempt failed: the console could not send the command,
+// the agent reported an error or the agent didn't report the result in time
+var DeploymentErrorClasses = []string{"send", "agent", "timeout"}
+
+// MaxDeploymentAttempts limits how many times a deployment is sent to a computer
+const MaxDeploymentAttempts = 10
+
+// MaxDeploymentRetryDelay is the longest we wait between two attempts however large the backoff grows
+const MaxDeploymentRetryDelay = 24 * time.Hour
+
+func validateRetryPolicy(r DeploymentJobRequest) error {
+	if r.RetryMaxAttempts < 1 || r.RetryMaxAttempts > MaxDeploymentAttempts {
+		return errors.New("the number of attempts must be between 1 and " + strconv.Itoa(MaxDeploymentAttempts))
+	}
+
+	if r.RetryMaxAttempts > 1 && r.RetryBackoff < 1 {
+		return errors.New("the backoff must be at least one minute")
+	}
+
+	for _, c := range r.RetryOn {
+		if !slices.Contains(DeploymentErrorClasses, c) {
+			return errors.New("the error class is not valid")
+		}
+	}
+
+	if r.RetryMaxAttempts > 1 && len(r.RetryOn) == 0 {
+		return errors.New("at least one error class must be retried")
+	}
+
+	return nil
+}
+
+// DeploymentRetryDelay doubles the backoff after every failed attempt
+func DeploymentRetryDelay(backoff int, attempt int) time.Duration {
+	delay := time.Duration(backoff) * time.Minute
+	for i := 1; i < attempt; i++ {
+		delay *= 2
+		if delay >= MaxDeploymentRetryDelay {
+			return MaxDeploymentRetryDelay
+		}
+	}
+	return min(delay, MaxDeploymentRetryDelay)
+}
+
+// DeploymentRetryAllowed tells if the policy of the job lets a target be sent again after that attempt failed
+func DeploymentRetryAllowed(job *ent.DeploymentJob, attempt int, class string) bool {
+	return attempt < job.RetryMaxAttempts && slices.Contains(job.RetryOn, class)
+}
+
+// finishDeploymentTarget saves the result of the last attempt of a target, if it failed and the
+// retry policy allows it the target is sent again once the backoff has passed
+func (m *Model) finishDeploymentTarget(t *ent.DeploymentJobTarget, status deploymentjobtarget.Status, message, class string, now time.Time) error {
+	if status == deploymentjobtarget.StatusSucceeded {
+		class = ""
+	}
+
+	// A failed package of the chain stops it, the message tells which one
+	if inDeploymentChain(t) {
+		message = GetDeploymentChainPackage(t).PackageName + ": " + message
+	}
+
+	err := m.Client.DeploymentJobAttempt.Create().
+		SetAttempt(t.Attempts).
+		SetStatus(deploymentjobattempt.Status(status.String())).
+		SetMessage(message).
+		SetErrorClass(class).
+		SetSent(t.Sent).
+		SetFinished(now).
+		SetTargetID(t.ID).
+		Exec(context.Background())
+	if err != nil {
+		return err
+	}
+
+	retryAt := time.Time{}
+	if status != deploymentjobtarget.StatusSucceeded && t.Edges.Job != nil && DeploymentRetryAllowed(t.Edges.Job, t.Attempts, class) {
+		retryAt = now.Add(DeploymentRetryDelay(t.Edges.Job.RetryBackoff, t.Attempts))
+	}
+
+	return m.Client.DeploymentJobTarget.UpdateOneID(t.ID).
+		SetStatus(status).
+		SetMessage(message).
+		SetErrorClass(class).
+		SetRetryAt(retryAt).
+		SetUpdated(now).
+		Exec(context.Background())
+}
+
+// RetryDeploymentTargets queues again the failed targets whose backoff has passed
+// so they're sent with the next due targets. Targets of a tenant under a change freeze,
+// or of a job that respects windows while the window of the agent is closed, keep
+// waiting and are retried once the freeze ends or the window opens
+func (m *Model) RetryDeploymentTargets() error {
+	now := time.Now()
+	targets, err := m.Client.DeploymentJobTarget.Query().
+		Where(
+			deploymentjobtarget.StatusIn(deploymentjobtarget.StatusFailed, deploymentjobtarget.StatusTimedOut),
+			deploymentjobtarget.RetryAtNEQ(time.Time{}),
+			deploymentjobtarget.RetryAtLTE(now),
+			deploymentjobtarget.HasJobWith(deploymentjob.StatusEQ(deploymentjob.StatusRunning)),
+		).
+		WithJob(func(q *ent.DeploymentJobQuery) { q.WithTenant() }).
+		WithAgent().
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	frozen := map[int]bool{}
+	windows := map[string]bool{}
+
+	ids := []int{}
+	for _, t := range targets {
+		if t.Edges.Job == nil {
+			continue
+		}
+
+		if t.Edges.Job.Edges.Tenant != nil {
+			tenantID := t.Edges.Job.Edges.Tenant.ID
+			isFrozen, ok := frozen[tenantID]
+			if !ok {
+				freeze, err := m.GetActiveChangeFreeze(tenantID, now)
+				if err != nil {
+					return err
+				}
+				isFrozen = freeze != nil
+				frozen[tenantID] = isFrozen
+			}
+			if isFrozen {
+				continue
+			}
+		}
+
+		if t.Edges.Job.RespectWindow && t.Edges.Agent != nil {
+			open, ok := windows[t.Edges.Agent.ID]
+			if !ok {
+				open, _, err = m.AgentMaintenanceWindow(t.Edges.Agent.ID, now)
+				if err != nil {
+					return err
+				}
+				windows[t.Edges.Agent.ID] = open
+			}
+			if !open {
+				continue
+			}
+		}
+
+		ids = append(ids, t.ID)
+	}
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return m.Client.DeploymentJobTarget.Update().
+		Where(deploymentjobtarget.IDIn(ids...)).
+		SetStatus(deploymentjobtarget.StatusQueued).
+		SetRetryAt(time.Time{}).
+		SetUpdated(now).
+		Exec(context.Background())
+}
+
+// GetDeploymentAttemptsForAgent returns the attempts of the deployment jobs sent to a computer, newest first
+func (m *Model) GetDeploymentAttemptsForAgent(agentId string, c *partials.CommonInfo) ([]*ent.DeploymentJobAttempt, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.DeploymentJobAttempt.Query()
+	if siteID == -1 {
+		query = query.Where(deploymentjobattempt.HasTargetWith(deploymentjobtarget.HasAgentWith(agent.ID(agentId), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))))
+	} else {
+		query = query.Where(deploymentjobattempt.HasTargetWith(deploymentjobtarget.HasAgentWith(agent.ID(agentId), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))))
+	}
+
+	return query.
+		WithTarget(func(q *ent.DeploymentJobTargetQuery) { q.WithJob() }).
+		Order(ent.Desc(deploymentjobattempt.FieldFinished), ent.Desc(deploymentjobattempt.FieldID)).
+		Limit(50).
+		All(context.Background())
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deployment"
+	"github.com/scncore/ent/deploymentjobattempt"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentRetriesTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DeploymentRetriesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 2; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *DeploymentRetriesTestSuite) TestDeploymentRetryDelay() {
+	assert.Equal(suite.T(), 30*time.Minute, DeploymentRetryDelay(30, 1))
+	assert.Equal(suite.T(), 120*time.Minute, DeploymentRetryDelay(30, 3))
+	assert.Equal(suite.T(), MaxDeploymentRetryDelay, DeploymentRetryDelay(600, 5), "the delay should be capped")
+}
+
+func (suite *DeploymentRetriesTestSuite) TestRetryPolicy() {
+	r := DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: []string{"agent0"}}
+
+	r.RetryMaxAttempts = MaxDeploymentAttempts + 1
+	_, err := suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "should limit the attempts")
+
+	r.RetryMaxAttempts = 3
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "should require a backoff")
+
+	r.RetryBackoff = 10
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "should require an error class")
+
+	r.RetryOn = []string{"disk"}
+	_, err = suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.Error(suite.T(), err, "should reject unknown error classes")
+
+	r.RetryOn = []string{"send", "timeout"}
+	job, err := suite.model.CreateDeploymentJob(r, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+	assert.Equal(suite.T(), 3, job.RetryMaxAttempts)
+	assert.True(suite.T(), DeploymentRetryAllowed(job, 1, "timeout"))
+	assert.False(suite.T(), DeploymentRetryAllowed(job, 1, "agent"), "agent errors are not retried")
+	assert.False(suite.T(), DeploymentRetryAllowed(job, 3, "timeout"), "the attempts are used up")
+}
+
+func (suite *DeploymentRetriesTestSuite) TestRetryDeploymentTargets() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0", "agent1"}, RetryMaxAttempts: 2, RetryBackoff: 1, RetryOn: []string{"agent"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	for _, t := range job.Edges.Targets {
+		if t.Edges.Agent.ID == "agent1" {
+			err := suite.model.SetDeploymentTargetFailed(t.ID, "NATS is not connected")
+			assert.NoError(suite.T(), err, "should set target as failed")
+			continue
+		}
+
+		err := suite.model.SetDeploymentTargetSent(t.ID)
+		assert.NoError(suite.T(), err, "should set target as sent")
+	}
+
+	err = suite.model.Client.Deployment.Create().SetName("Firefox").SetPackageID("Mozilla.Firefox").SetOwnerID("agent0").SetFailed(true).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create deployment")
+
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	progress := GetDeploymentJobProgress(job.Edges.Targets)
+	assert.Equal(suite.T(), 1, progress.Retrying, "the agent error of agent0 should be retried")
+	assert.Equal(suite.T(), 1, progress.Failed, "send errors are not retried by this job")
+	assert.Equal(suite.T(), 1, progress.Finished())
+
+	err = suite.model.RetryDeploymentTargets()
+	assert.NoError(suite.T(), err, "should retry deployment targets")
+
+	queued, err := suite.model.Client.DeploymentJobTarget.Query().Where(deploymentjobtarget.StatusEQ(deploymentjobtarget.StatusQueued)).Count(context.Background())
+	assert.NoError(suite.T(), err, "should count queued targets")
+	assert.Equal(suite.T(), 0, queued, "the backoff hasn't passed yet")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.RetryAtNEQ(time.Time{})).SetRetryAt(time.Now().Add(-1 * time.Minute)).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should move the retry time")
+
+	err = suite.model.RetryDeploymentTargets()
+	assert.NoError(suite.T(), err, "should retry deployment targets")
+
+	due, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 1, len(due))
+	assert.Equal(suite.T(), "agent0", due[0].Edges.Agent.ID)
+
+	err = suite.model.SetDeploymentTargetSent(due[0].ID)
+	assert.NoError(suite.T(), err, "should set target as sent")
+
+	err = suite.model.Client.Deployment.Update().SetFailed(true).Where(deployment.HasOwnerWith(agent.ID("agent0"))).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set deployment as failed")
+
+	err = suite.model.UpdateDeploymentTargets()
+	assert.NoError(suite.T(), err, "should update deployment targets")
+
+	target, err := suite.model.Client.DeploymentJobTarget.Get(context.Background(), due[0].ID)
+	assert.NoError(suite.T(), err, "should get target")
+	assert.Equal(suite.T(), deploymentjobtarget.StatusFailed, target.Status)
+	assert.Equal(suite.T(), 2, target.Attempts)
+	assert.True(suite.T(), target.RetryAt.IsZero(), "the attempts are used up")
+
+	attempts, err := suite.model.GetDeploymentAttemptsForAgent("agent0", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment attempts")
+	assert.Equal(suite.T(), 2, len(attempts))
+	assert.Equal(suite.T(), deploymentjobattempt.StatusFailed, attempts[0].Status)
+	assert.Equal(suite.T(), "agent", attempts[0].ErrorClass)
+	assert.Equal(suite.T(), "Firefox", attempts[0].Edges.Target.Edges.Job.PackageName)
+
+	attempts, err = suite.model.GetDeploymentAttemptsForAgent("agent1", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment attempts")
+	assert.Equal(suite.T(), 1, len(attempts))
+	assert.Equal(suite.T(), "send", attempts[0].ErrorClass)
+}
+
+func (suite *DeploymentRetriesTestSuite) TestRetryDeploymentTargetsDuringFreeze() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Firefox", Action: "install", Agents: []string{"agent0"}, RetryMaxAttempts: 2, RetryBackoff: 1, RetryOn: []string{"send"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+
+	err = suite.model.SetDeploymentTargetFailed(job.Edges.Targets[0].ID, "NATS is not connected")
+	assert.NoError(suite.T(), err, "should set target as failed")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.RetryAtNEQ(time.Time{})).SetRetryAt(time.Now().Add(-1 * time.Minute)).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should move the retry time")
+
+	tenantID, err := strconv.Atoi(suite.commonInfo.TenantID)
+	assert.NoError(suite.T(), err, "should get tenant id")
+
+	err = suite.model.AddChangeFreeze(tenantID, ChangeFreezeRequest{Name: "Release", Start: time.Now().Add(-1 * time.Hour), End: time.Now().Add(time.Hour)})
+	assert.NoError(suite.T(), err, "should add change freeze")
+
+	err = suite.model.RetryDeploymentTargets()
+	assert.NoError(suite.T(), err, "should retry deployment targets")
+
+	target, err := suite.model.Client.DeploymentJobTarget.Get(context.Background(), job.Edges.Targets[0].ID)
+	assert.NoError(suite.T(), err, "should get target")
+	assert.Equal(suite.T(), deploymentjobtarget.StatusFailed, target.Status, "retries wait for the freeze to end")
+	assert.False(suite.T(), target.RetryAt.IsZero(), "the target should still be retried later")
+
+	_, err = suite.model.Client.ChangeFreeze.Delete().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should delete change freeze")
+
+	err = suite.model.RetryDeploymentTargets()
+	assert.NoError(suite.T(), err, "should retry deployment targets")
+
+	target, err = suite.model.Client.DeploymentJobTarget.Get(context.Background(), job.Edges.Targets[0].ID)
+	assert.NoError(suite.T(), err, "should get target")
+	assert.Equal(suite.T(), deploymentjobtarget.StatusQueued, target.Status, "should be retried once the freeze ends")
+}
+
+func TestDeploymentRetriesTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentRetriesTestSuite))
+}
@@ -0,0 +1,106 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/deployment"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// CanRollbackDeployment tells if the deployment was updated from a known version that is not the one
+// installed now. A rollback installs the previous version again so it can't be rolled back twice, the
+// previous version is kept so a failed rollback can still be retried by its job
+func CanRollbackDeployment(d *ent.Deployment) bool {
+	return d.PreviousVersion != "" && d.PreviousVersion != d.Version
+}
+
+// GetDeploymentVersionBeforeUpdate returns the version that the computer has before an update is sent,
+// the version that was deployed if it was pinned or the version reported by the software inventory
+func (m *Model) GetDeploymentVersionBeforeUpdate(agentId, packageId string, c *partials.CommonInfo) (string, error) {
+	d, err := m.GetDeployment(agentId, packageId, c)
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if d.Version != "" {
+		return d.Version, nil
+	}
+
+	// The inventory reports names like "Mozilla Firefox (x64 en-US)", their normalized name matches the package
+	n, err := m.NewSoftwareNormalizer()
+	if err != nil {
+		return "", err
+	}
+
+	a, err := m.Client.App.Query().
+		Where(app.Or(app.NameEqualFold(d.Name), app.NormalizedNameEqualFold(n.Name(d.Name))), app.HasOwnerWith(agent.ID(agentId))).
+		First(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	return a.Version, nil
+}
+
+// getDeploymentRollbackAgents keeps the computers that have a version to roll the package back to
+func (m *Model) getDeploymentRollbackAgents(packageId string, agents []string) ([]string, error) {
+	deployments, err := m.Client.Deployment.Query().
+		Where(deployment.PackageID(packageId), deployment.PreviousVersionNEQ(""), deployment.HasOwnerWith(agent.IDIn(agents...))).
+		WithOwner().
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	rollback := []string{}
+	for _, d := range deployments {
+		if CanRollbackDeployment(d) && d.Edges.Owner != nil {
+			rollback = append(rollback, d.Edges.Owner.ID)
+		}
+	}
+
+	if len(rollback) == 0 {
+		return nil, errors.New("none of the selected computers has a previous version of the package")
+	}
+	return rollback, nil
+}
+
+// GetDeploymentRollbackVersion returns the version that a rollback installs on the computer
+func (m *Model) GetDeploymentRollbackVersion(agentId, packageId string, c *partials.CommonInfo) (string, error) {
+	d, err := m.GetDeployment(agentId, packageId, c)
+	if err != nil {
+		return "", err
+	}
+
+	if d.PreviousVersion == "" {
+		return "", errors.New("the computer has no previous version of the package")
+	}
+	return d.PreviousVersion, nil
+}
+
+// SaveDeploymentRollback records that the previous version is being installed again, the rollback
+// is done once the agent reports the installation
+func (m *Model) SaveDeploymentRollback(agentId, packageId, version string, c *partials.CommonInfo) error {
+	d, err := m.GetDeployment(agentId, packageId, c)
+	if err != nil {
+		return err
+	}
+
+	return m.Client.Deployment.UpdateOneID(d.ID).
+		SetInstalled(time.Time{}).
+		SetUpdated(time.Time{}).
+		SetFailed(false).
+		SetVersion(version).
+		SetRolledBack(time.Now()).
+		Exec(context.Background())
+}
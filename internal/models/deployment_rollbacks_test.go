@@ -0,0 +1,138 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	scnorion_nats "github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeploymentRollbacksTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DeploymentRollbacksTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 2; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// The first computer had a pinned version, the second one reports its version in the inventory
+	err = client.Deployment.Create().SetName("Mozilla Firefox").SetPackageID("Mozilla.Firefox").SetVersion("128.0").SetOwnerID("agent0").SetInstalled(time.Now()).SetUpdated(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create deployment")
+	err = client.Deployment.Create().SetName("Mozilla Firefox").SetPackageID("Mozilla.Firefox").SetOwnerID("agent1").SetInstalled(time.Now()).SetUpdated(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create deployment")
+	err = client.App.Create().SetName("mozilla firefox").SetVersion("127.0").SetPublisher("Mozilla").SetOwnerID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create app")
+}
+
+func (suite *DeploymentRollbacksTestSuite) update(agentId string) {
+	action := scnorion_nats.DeployAction{AgentId: agentId, PackageId: "Mozilla.Firefox", PackageName: "Mozilla Firefox", Action: "update"}
+	err := suite.model.SaveDeployInfo(&action, false, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should save deploy info")
+}
+
+func (suite *DeploymentRollbacksTestSuite) TestPreviousVersion() {
+	suite.update("agent0")
+
+	d, err := suite.model.GetDeployment("agent0", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment")
+	assert.Equal(suite.T(), "128.0", d.PreviousVersion, "the pinned version is the previous version")
+	assert.Equal(suite.T(), "", d.Version, "the update installs the latest version")
+	assert.True(suite.T(), CanRollbackDeployment(d))
+
+	// Without a pinned version the inventory tells which version was installed
+	suite.update("agent0")
+	d, err = suite.model.GetDeployment("agent0", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment")
+	assert.Equal(suite.T(), "", d.PreviousVersion, "the inventory of the computer has no version")
+
+	suite.update("agent1")
+	d, err = suite.model.GetDeployment("agent1", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment")
+	assert.Equal(suite.T(), "127.0", d.PreviousVersion)
+}
+
+func (suite *DeploymentRollbacksTestSuite) TestPreviousVersionNormalizedName() {
+	_, err := suite.model.Client.App.Delete().Exec(context.Background())
+	assert.NoError(suite.T(), err, "should delete apps")
+	err = suite.model.Client.App.Create().SetName("Mozilla Firefox (x64 en-US)").SetNormalizedName("Mozilla Firefox").SetVersion("126.0").SetPublisher("Mozilla").SetOwnerID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create app")
+
+	version, err := suite.model.GetDeploymentVersionBeforeUpdate("agent1", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get version before update")
+	assert.Equal(suite.T(), "126.0", version, "should find the app by its normalized name")
+}
+
+func (suite *DeploymentRollbacksTestSuite) TestRollbackJob() {
+	suite.update("agent1")
+
+	_, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Mozilla Firefox", Action: "rollback", Agents: []string{"agent0"}}, suite.commonInfo)
+	assert.Error(suite.T(), err, "the computer has no previous version")
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", PackageName: "Mozilla Firefox", Action: "rollback", Agents: []string{"agent0", "agent1"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create rollback job")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), 1, len(job.Edges.Targets), "only computers with a previous version are rolled back")
+	assert.Equal(suite.T(), "agent1", job.Edges.Targets[0].Edges.Agent.ID)
+
+	version, err := suite.model.GetDeploymentRollbackVersion("agent1", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get rollback version")
+	assert.Equal(suite.T(), "127.0", version)
+
+	sent := time.Now()
+	err = suite.model.SaveDeploymentRollback("agent1", "Mozilla.Firefox", version, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should save rollback")
+
+	d, err := suite.model.GetDeployment("agent1", "Mozilla.Firefox", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment")
+	assert.Equal(suite.T(), "127.0", d.Version)
+	assert.False(suite.T(), d.RolledBack.IsZero())
+	assert.False(suite.T(), CanRollbackDeployment(d), "a rolled back deployment can't be rolled back again")
+
+	target := job.Edges.Targets[0]
+	target.Sent = sent
+	target.Edges.Job = job
+	status, _ := DeploymentTargetStatus(target, d, time.Now())
+	assert.NotEqual(suite.T(), deploymentjobtarget.StatusSucceeded, status, "the agent hasn't reported the installation")
+
+	d, err = suite.model.Client.Deployment.UpdateOneID(d.ID).SetInstalled(time.Now().Add(time.Minute)).Save(context.Background())
+	assert.NoError(suite.T(), err, "should set installed")
+	status, _ = DeploymentTargetStatus(target, d, time.Now())
+	assert.Equal(suite.T(), deploymentjobtarget.StatusSucceeded, status)
+}
+
+func TestDeploymentRollbacksTestSuite(t *testing.T) {
+	suite.Run(t, new(DeploymentRollbacksTestSuite))
+}
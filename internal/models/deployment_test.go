@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deployment"
 	"github.com/scncore/ent/enttest"
 	scnorion_nats "github.com/scncore/nats"
 	"github.com/scncore/scnorion-console/internal/views/partials"
@@ -175,6 +176,38 @@ func (suite *DeploymentTestSuite) TestSaveDeployInfo() {
 	assert.Equal(suite.T(), true, items[3].Installed.IsZero(), "install time should be zero")
 }
 
+func (suite *DeploymentTestSuite) TestSaveDeployInfoOneRowPerPackage() {
+	for _, version := range []string{"1.0", "2.0"} {
+		err := suite.model.SaveDeployInfo(&scnorion_nats.DeployAction{
+			AgentId:        "agent1",
+			Action:         "install",
+			PackageId:      "package0",
+			PackageName:    "Package 0",
+			PackageVersion: version,
+		}, false, suite.commonInfo)
+		assert.NoError(suite.T(), err, "should save deployment info")
+	}
+
+	deployments, err := suite.model.Client.Deployment.Query().Where(deployment.PackageID("package0")).All(context.Background())
+	assert.NoError(suite.T(), err, "should get deployments")
+	assert.Equal(suite.T(), 1, len(deployments), "installing again should reuse the row of the package")
+	assert.Equal(suite.T(), "2.0", deployments[0].Version)
+	assert.True(suite.T(), deployments[0].Installed.IsZero(), "the row waits for the agent report")
+
+	// The agent confirms an uninstall by removing the row, so it's written even if the console didn't deploy the package
+	err = suite.model.SaveDeployInfo(&scnorion_nats.DeployAction{
+		AgentId:     "agent1",
+		Action:      "uninstall",
+		PackageId:   "package9",
+		PackageName: "Package 9",
+	}, false, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should save deployment info")
+
+	exists, err := suite.model.Client.Deployment.Query().Where(deployment.PackageID("package9"), deployment.HasOwnerWith(agent.ID("agent1"))).Exist(context.Background())
+	assert.NoError(suite.T(), err, "should check deployment")
+	assert.True(suite.T(), exists, "the uninstall should write a row")
+}
+
 func TestDeploymentTestSuite(t *testing.T) {
 	suite.Run(t, new(DeploymentTestSuite))
 }
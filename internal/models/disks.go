@@ -0,0 +1,345 @@
+package models
+
+import (
+	"context"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/logicaldisk"
+	"github.com/scncore/ent/logicaldisksnapshot"
+	"github.com/scncore/ent/settings"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+const gigabyte = 1024 * 1024 * 1024
+
+// Number of days of history used to compute the fill-rate forecast
+const DISK_FORECAST_WINDOW_DAYS = 30
+
+type DiskCapacity struct {
+	AgentID               string
+	Nickname              string
+	OS                    string
+	Label                 string
+	Usage                 int
+	RemainingSpace        string
+	Size                  string
+	RemainingSpaceInBytes int64
+	SizeInBytes           int64
+	DaysUntilFull         int
+}
+
+// ParseSizeInUnits converts the human readable sizes reported by the agents (e.g 12.5 GB) to bytes
+func ParseSizeInUnits(size string) int64 {
+	size = strings.TrimSpace(strings.ReplaceAll(size, ",", "."))
+	if size == "" {
+		return 0
+	}
+
+	i := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+
+	number := size
+	unit := ""
+	if i >= 0 {
+		number = strings.TrimSpace(size[:i])
+		unit = strings.ToUpper(strings.TrimSpace(size[i:]))
+	}
+
+	value, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0
+	}
+
+	multiplier := float64(1)
+	switch strings.TrimSuffix(strings.TrimSuffix(unit, "B"), "I") {
+	case "K":
+		multiplier = 1024
+	case "M":
+		multiplier = math.Pow(1024, 2)
+	case "G":
+		multiplier = math.Pow(1024, 3)
+	case "T":
+		multiplier = math.Pow(1024, 4)
+	case "P":
+		multiplier = math.Pow(1024, 5)
+	}
+
+	return int64(value * multiplier)
+}
+
+// ForecastDaysUntilFull fits a line to the used space of a volume over time and returns
+// the number of days until the volume is full, or -1 if the volume is not filling up
+func ForecastDaysUntilFull(snapshots []*ent.LogicalDiskSnapshot) int {
+	if len(snapshots) < 2 {
+		return -1
+	}
+
+	first := snapshots[0].Created
+	last := snapshots[0]
+	for _, s := range snapshots {
+		if s.Created.Before(first) {
+			first = s.Created
+		}
+		if s.Created.After(last.Created) {
+			last = s
+		}
+	}
+
+	if last.Created.Sub(first) < 24*time.Hour {
+		return -1
+	}
+
+	n := float64(len(snapshots))
+	sumX, sumY, sumXY, sumXX := 0.0, 0.0, 0.0, 0.0
+	for _, s := range snapshots {
+		x := s.Created.Sub(first).Hours() / 24
+		y := float64(s.SizeInBytes - s.RemainingSpaceInBytes)
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return -1
+	}
+
+	// slope is the growth of the used space in bytes per day
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope <= 0 {
+		return -1
+	}
+
+	return int(math.Floor(float64(last.RemainingSpaceInBytes) / slope))
+}
+
+func (m *Model) SaveLogicalDiskSnapshots() error {
+	disks, err := m.Client.LogicalDisk.Query().WithOwner().All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	builders := []*ent.LogicalDiskSnapshotCreate{}
+	for _, d := range disks {
+		if d.Edges.Owner == nil {
+			continue
+		}
+
+		builders = append(builders, m.Client.LogicalDiskSnapshot.Create().
+			SetLabel(d.Label).
+			SetUsage(int(d.Usage)).
+			SetSizeInBytes(ParseSizeInUnits(d.SizeInUnits)).
+			SetRemainingSpaceInBytes(ParseSizeInUnits(d.RemainingSpaceInUnits)).
+			SetCreated(now).
+			SetOwnerID(d.Edges.Owner.ID))
+
+		// SQLite has a limit on the number of variables per statement
+		if len(builders) == 100 {
+			if err := m.Client.LogicalDiskSnapshot.CreateBulk(builders...).Exec(context.Background()); err != nil {
+				return err
+			}
+			builders = []*ent.LogicalDiskSnapshotCreate{}
+		}
+	}
+
+	if len(builders) > 0 {
+		return m.Client.LogicalDiskSnapshot.CreateBulk(builders...).Exec(context.Background())
+	}
+
+	return nil
+}
+
+func (m *Model) DeleteOldLogicalDiskSnapshots(days int) (int, error) {
+	return m.Client.LogicalDiskSnapshot.Delete().Where(logicaldisksnapshot.CreatedLT(time.Now().AddDate(0, 0, -1*days))).Exec(context.Background())
+}
+
+// GetLogicalDiskHistory returns the snapshots for the agent's volumes grouped by label and ordered by date
+func (m *Model) GetLogicalDiskHistory(agentId string, days int) (map[string][]*ent.LogicalDiskSnapshot, error) {
+	snapshots, err := m.Client.LogicalDiskSnapshot.Query().
+		Where(logicaldisksnapshot.HasOwnerWith(agent.ID(agentId)), logicaldisksnapshot.CreatedGTE(time.Now().AddDate(0, 0, -1*days))).
+		Order(ent.Asc(logicaldisksnapshot.FieldCreated)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	history := map[string][]*ent.LogicalDiskSnapshot{}
+	for _, s := range snapshots {
+		history[s.Label] = append(history[s.Label], s)
+	}
+
+	return history, nil
+}
+
+// GetLogicalDiskForecasts returns the recent history of the agent's volumes and the days until each volume is full
+func (m *Model) GetLogicalDiskForecasts(agentId string) (map[string][]*ent.LogicalDiskSnapshot, map[string]int, error) {
+	history, err := m.GetLogicalDiskHistory(agentId, DISK_FORECAST_WINDOW_DAYS)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	forecasts := map[string]int{}
+	for label, snapshots := range history {
+		forecasts[label] = ForecastDaysUntilFull(snapshots)
+	}
+
+	return history, forecasts, nil
+}
+
+// IsDiskNearlyFull checks if a volume exceeds the usage percentage or has less free space than the threshold in GB
+func IsDiskNearlyFull(usage int, sizeInBytes int64, remainingSpaceInBytes int64, usageThreshold int, freeSpaceThreshold int) bool {
+	if usageThreshold > 0 && usage >= usageThreshold {
+		return true
+	}
+
+	// sizes that couldn't be parsed are ignored
+	if freeSpaceThreshold > 0 && sizeInBytes > 0 && remainingSpaceInBytes < int64(freeSpaceThreshold)*gigabyte {
+		return true
+	}
+
+	return false
+}
+
+func (m *Model) GetDisksNearlyFull(p partials.PaginationAndSort, usageThreshold int, freeSpaceThreshold int, c *partials.CommonInfo) ([]DiskCapacity, int, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, 0, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := m.Client.LogicalDisk.Query().WithOwner()
+	if siteID == -1 {
+		query.Where(logicaldisk.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query.Where(logicaldisk.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+
+	disks, err := query.All(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	nearlyFull := []DiskCapacity{}
+	agentIds := []string{}
+	for _, d := range disks {
+		if d.Edges.Owner == nil {
+			continue
+		}
+
+		size := ParseSizeInUnits(d.SizeInUnits)
+		remaining := ParseSizeInUnits(d.RemainingSpaceInUnits)
+		if !IsDiskNearlyFull(int(d.Usage), size, remaining, usageThreshold, freeSpaceThreshold) {
+			continue
+		}
+
+		nearlyFull = append(nearlyFull, DiskCapacity{
+			AgentID:               d.Edges.Owner.ID,
+			Nickname:              d.Edges.Owner.Nickname,
+			OS:                    d.Edges.Owner.Os,
+			Label:                 d.Label,
+			Usage:                 int(d.Usage),
+			RemainingSpace:        d.RemainingSpaceInUnits,
+			Size:                  d.SizeInUnits,
+			RemainingSpaceInBytes: remaining,
+			SizeInBytes:           size,
+			DaysUntilFull:         -1,
+		})
+		agentIds = append(agentIds, d.Edges.Owner.ID)
+	}
+
+	if len(nearlyFull) > 0 {
+		snapshots, err := m.Client.LogicalDiskSnapshot.Query().WithOwner().
+			Where(logicaldisksnapshot.HasOwnerWith(agent.IDIn(agentIds...)), logicaldisksnapshot.CreatedGTE(time.Now().AddDate(0, 0, -1*DISK_FORECAST_WINDOW_DAYS))).
+			All(context.Background())
+		if err != nil {
+			return nil, 0, err
+		}
+
+		history := map[string][]*ent.LogicalDiskSnapshot{}
+		for _, s := range snapshots {
+			if s.Edges.Owner != nil {
+				key := s.Edges.Owner.ID + "|" + s.Label
+				history[key] = append(history[key], s)
+			}
+		}
+
+		for i := range nearlyFull {
+			nearlyFull[i].DaysUntilFull = ForecastDaysUntilFull(history[nearlyFull[i].AgentID+"|"+nearlyFull[i].Label])
+		}
+	}
+
+	sortDisks(nearlyFull, p)
+
+	total := len(nearlyFull)
+	if p.PageSize != 0 {
+		start := min((p.CurrentPage-1)*p.PageSize, total)
+		end := min(start+p.PageSize, total)
+		nearlyFull = nearlyFull[start:end]
+	}
+
+	return nearlyFull, total, nil
+}
+
+// GetDiskAlerts returns the email that must be warned and the volumes of the tenant that exceed its thresholds,
+// alerts are disabled while the tenant has no alert email
+func (m *Model) GetDiskAlerts(tenantID int) (string, []DiskCapacity, error) {
+	s, err := m.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+	if err != nil {
+		return "", nil, err
+	}
+
+	if s.DiskAlertEmail == "" {
+		return "", nil, nil
+	}
+
+	p := partials.PaginationAndSort{SortBy: "usage", SortOrder: "desc"}
+	c := &partials.CommonInfo{TenantID: strconv.Itoa(tenantID), SiteID: "-1"}
+	disks, _, err := m.GetDisksNearlyFull(p, s.DiskUsageThreshold, s.DiskFreeSpaceThreshold, c)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return s.DiskAlertEmail, disks, nil
+}
+
+func sortDisks(disks []DiskCapacity, p partials.PaginationAndSort) {
+	less := func(i, j int) bool {
+		switch p.SortBy {
+		case "nickname":
+			return disks[i].Nickname < disks[j].Nickname
+		case "label":
+			return disks[i].Label < disks[j].Label
+		case "remaining":
+			return disks[i].RemainingSpaceInBytes < disks[j].RemainingSpaceInBytes
+		case "forecast":
+			return disks[i].DaysUntilFull < disks[j].DaysUntilFull
+		default:
+			return disks[i].Usage < disks[j].Usage
+		}
+	}
+
+	sort.SliceStable(disks, func(i, j int) bool {
+		// Volumes without forecast go last whatever the order
+		if p.SortBy == "forecast" && (disks[i].DaysUntilFull == -1 || disks[j].DaysUntilFull == -1) {
+			return disks[i].DaysUntilFull != -1 && disks[j].DaysUntilFull == -1
+		}
+		if p.SortOrder == "desc" {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
@@ -0,0 +1,191 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	scnorion_ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DisksTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *DisksTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := range 3 {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// agent0 has a volume with high usage, agent1 has a volume with little free space and agent2 is healthy
+	err = client.LogicalDisk.Create().SetLabel("C:").SetUsage(95).SetSizeInUnits("100 GB").SetRemainingSpaceInUnits("5 GB").SetOwnerID("agent0").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create logical disk")
+
+	err = client.LogicalDisk.Create().SetLabel("D:").SetUsage(70).SetSizeInUnits("10 GB").SetRemainingSpaceInUnits("3 GB").SetOwnerID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create logical disk")
+
+	err = client.LogicalDisk.Create().SetLabel("C:").SetUsage(40).SetSizeInUnits("500 GB").SetRemainingSpaceInUnits("300 GB").SetOwnerID("agent2").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create logical disk")
+
+	// agent0 volume has been growing 1 GB per day during the last 10 days
+	now := time.Now().UTC().Add(-1 * time.Hour)
+	for i := range 10 {
+		err := client.LogicalDiskSnapshot.Create().
+			SetLabel("C:").
+			SetUsage(85 + i).
+			SetSizeInBytes(100 * gigabyte).
+			SetRemainingSpaceInBytes(int64(15-i) * gigabyte).
+			SetCreated(now.AddDate(0, 0, i-9)).
+			SetOwnerID("agent0").
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create logical disk snapshot")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *DisksTestSuite) TestParseSizeInUnits() {
+	assert.Equal(suite.T(), int64(5*gigabyte), ParseSizeInUnits("5 GB"), "5 GB should be parsed")
+	assert.Equal(suite.T(), int64(1536*1024*1024), ParseSizeInUnits("1,5 GiB"), "1,5 GiB should be parsed")
+	assert.Equal(suite.T(), int64(512*1024*1024), ParseSizeInUnits("512MB"), "512MB should be parsed")
+	assert.Equal(suite.T(), int64(0), ParseSizeInUnits(""), "empty size should be 0")
+	assert.Equal(suite.T(), int64(0), ParseSizeInUnits("unknown"), "invalid size should be 0")
+}
+
+func (suite *DisksTestSuite) TestIsDiskNearlyFull() {
+	assert.True(suite.T(), IsDiskNearlyFull(95, 100*gigabyte, 5*gigabyte, 90, 0), "usage above threshold should be nearly full")
+	assert.True(suite.T(), IsDiskNearlyFull(50, 100*gigabyte, 5*gigabyte, 90, 10), "free space below threshold should be nearly full")
+	assert.False(suite.T(), IsDiskNearlyFull(50, 100*gigabyte, 50*gigabyte, 90, 10), "healthy disk should not be nearly full")
+	assert.False(suite.T(), IsDiskNearlyFull(50, 0, 0, 90, 10), "unknown size should not be nearly full")
+	assert.False(suite.T(), IsDiskNearlyFull(95, 100*gigabyte, 5*gigabyte, 0, 0), "disabled thresholds should not raise alerts")
+}
+
+func (suite *DisksTestSuite) TestForecastDaysUntilFull() {
+	history, forecasts, err := suite.model.GetLogicalDiskForecasts("agent0")
+	assert.NoError(suite.T(), err, "should get logical disk forecasts")
+	assert.Equal(suite.T(), 10, len(history["C:"]), "should get 10 snapshots")
+	assert.Equal(suite.T(), 6, forecasts["C:"], "volume should be full in 6 days")
+
+	assert.Equal(suite.T(), -1, ForecastDaysUntilFull([]*scnorion_ent.LogicalDiskSnapshot{}), "no forecast without history")
+
+	stable := []*scnorion_ent.LogicalDiskSnapshot{
+		{SizeInBytes: 100, RemainingSpaceInBytes: 50, Created: time.Now().AddDate(0, 0, -2)},
+		{SizeInBytes: 100, RemainingSpaceInBytes: 50, Created: time.Now()},
+	}
+	assert.Equal(suite.T(), -1, ForecastDaysUntilFull(stable), "no forecast if the volume is not filling up")
+}
+
+func (suite *DisksTestSuite) TestGetDisksNearlyFull() {
+	disks, total, err := suite.model.GetDisksNearlyFull(suite.p, 90, 4, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get disks nearly full")
+	assert.Equal(suite.T(), 2, total, "should get two disks nearly full")
+	assert.Equal(suite.T(), 2, len(disks), "should get two disks nearly full")
+
+	suite.p.SortBy = "usage"
+	suite.p.SortOrder = "desc"
+	disks, _, err = suite.model.GetDisksNearlyFull(suite.p, 90, 4, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get disks nearly full")
+	assert.Equal(suite.T(), "agent0", disks[0].AgentID, "agent0 disk should be first")
+	assert.Equal(suite.T(), 6, disks[0].DaysUntilFull, "agent0 disk should be full in 6 days")
+	assert.Equal(suite.T(), -1, disks[1].DaysUntilFull, "agent1 disk should have no forecast")
+
+	_, total, err = suite.model.GetDisksNearlyFull(suite.p, 0, 0, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get disks nearly full")
+	assert.Equal(suite.T(), 0, total, "no disk should be nearly full with thresholds disabled")
+}
+
+func (suite *DisksTestSuite) TestGetDiskAlerts() {
+	tenantID, err := strconv.Atoi(suite.commonInfo.TenantID)
+	assert.NoError(suite.T(), err, "should parse tenant id")
+
+	s, err := suite.model.Client.Settings.Create().SetTenantID(tenantID).SetDiskUsageThreshold(90).SetDiskFreeSpaceThreshold(4).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tenant settings")
+
+	email, disks, err := suite.model.GetDiskAlerts(tenantID)
+	assert.NoError(suite.T(), err, "should get disk alerts")
+	assert.Equal(suite.T(), "", email, "alerts are disabled without an email")
+	assert.Equal(suite.T(), 0, len(disks), "alerts are disabled without an email")
+
+	err = suite.model.UpdateDiskAlertEmail(s.ID, "not an email")
+	assert.Error(suite.T(), err, "the alert email must be valid")
+
+	err = suite.model.UpdateDiskAlertEmail(s.ID, "admin@example.com")
+	assert.NoError(suite.T(), err, "should update disk alert email")
+
+	email, disks, err = suite.model.GetDiskAlerts(tenantID)
+	assert.NoError(suite.T(), err, "should get disk alerts")
+	assert.Equal(suite.T(), "admin@example.com", email)
+	assert.Equal(suite.T(), 2, len(disks), "should alert about two disks")
+	assert.Equal(suite.T(), "agent0", disks[0].AgentID, "the fullest disk should be first")
+}
+
+func (suite *DisksTestSuite) TestSaveLogicalDiskSnapshots() {
+	err := suite.model.SaveLogicalDiskSnapshots()
+	assert.NoError(suite.T(), err, "should save logical disk snapshots")
+
+	history, err := suite.model.GetLogicalDiskHistory("agent2", 1)
+	assert.NoError(suite.T(), err, "should get logical disk history")
+	assert.Equal(suite.T(), 1, len(history["C:"]), "should get one snapshot for agent2")
+	assert.Equal(suite.T(), int64(300*gigabyte), history["C:"][0].RemainingSpaceInBytes, "remaining space should be 300 GB")
+
+	n, err := suite.model.DeleteOldLogicalDiskSnapshots(5)
+	assert.NoError(suite.T(), err, "should delete old logical disk snapshots")
+	assert.Equal(suite.T(), 5, n, "should delete five old snapshots")
+}
+
+func (suite *DisksTestSuite) TestSortDisksByForecast() {
+	disks := []DiskCapacity{
+		{AgentID: "agent0", DaysUntilFull: -1},
+		{AgentID: "agent1", DaysUntilFull: 3},
+		{AgentID: "agent2", DaysUntilFull: 10},
+		{AgentID: "agent3", DaysUntilFull: -1},
+	}
+
+	sortDisks(disks, partials.PaginationAndSort{SortBy: "forecast", SortOrder: "asc"})
+	assert.Equal(suite.T(), []string{"agent1", "agent2", "agent0", "agent3"}, diskAgentIDs(disks), "volumes without forecast should go last")
+
+	sortDisks(disks, partials.PaginationAndSort{SortBy: "forecast", SortOrder: "desc"})
+	assert.Equal(suite.T(), []string{"agent2", "agent1", "agent0", "agent3"}, diskAgentIDs(disks), "volumes without forecast should go last in descending order")
+}
+
+func diskAgentIDs(disks []DiskCapacity) []string {
+	ids := []string{}
+	for _, d := range disks {
+		ids = append(ids, d.AgentID)
+	}
+	return ids
+}
+
+func TestDisksTestSuite(t *testing.T) {
+	suite.Run(t, new(DisksTestSuite))
+}
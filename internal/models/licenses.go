@@ -0,0 +1,338 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/licenseentitlement"
+	"github.com/scncore/ent/licenseproduct"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+const (
+	LICENSE_STATUS_COMPLIANT = "compliant"
+	LICENSE_STATUS_UNDER     = "under"
+	LICENSE_STATUS_OVER      = "over"
+)
+
+type LicenseCompliance struct {
+	ProductID   int
+	Name        string
+	DeviceSeats int
+	UserSeats   int
+	Devices     int
+	Users       int
+	Shortfall   int
+	Unused      int
+	Cost        float64
+	Status      string
+}
+
+type LicensedComputer struct {
+	AgentID  string
+	Nickname string
+	OS       string
+	Username string
+	Apps     []string
+	Covered  bool
+}
+
+func (m *Model) GetLicenseProducts(tenantID int) ([]*ent.LicenseProduct, error) {
+	return m.Client.LicenseProduct.Query().WithEntitlements().Where(licenseproduct.HasTenantWith(tenant.ID(tenantID))).Order(ent.Asc(licenseproduct.FieldName)).All(context.Background())
+}
+
+func (m *Model) GetLicenseProduct(tenantID int, productID int) (*ent.LicenseProduct, error) {
+	return m.Client.LicenseProduct.Query().
+		WithEntitlements(func(q *ent.LicenseEntitlementQuery) {
+			q.Order(ent.Asc(licenseentitlement.FieldID))
+		}).
+		Where(licenseproduct.ID(productID), licenseproduct.HasTenantWith(tenant.ID(tenantID))).
+		Only(context.Background())
+}
+
+func (m *Model) AddLicenseProduct(tenantID int, name, namePattern, vendorPattern, versionPattern string) error {
+	name = strings.TrimSpace(name)
+	namePattern = strings.TrimSpace(namePattern)
+	if name == "" || namePattern == "" {
+		return errors.New("the product name and the application name pattern are required")
+	}
+
+	for _, pattern := range []string{namePattern, vendorPattern, versionPattern} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	return m.Client.LicenseProduct.Create().
+		SetName(name).
+		SetNamePattern(namePattern).
+		SetVendorPattern(strings.TrimSpace(vendorPattern)).
+		SetVersionPattern(strings.TrimSpace(versionPattern)).
+		SetTenantID(tenantID).
+		Exec(context.Background())
+}
+
+func (m *Model) DeleteLicenseProduct(tenantID int, productID int) error {
+	if _, err := m.Client.LicenseEntitlement.Delete().Where(licenseentitlement.HasProductWith(licenseproduct.ID(productID), licenseproduct.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background()); err != nil {
+		return err
+	}
+
+	_, err := m.Client.LicenseProduct.Delete().Where(licenseproduct.ID(productID), licenseproduct.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
+	return err
+}
+
+func (m *Model) AddLicenseEntitlement(tenantID int, productID int, seats int, licenseType string, expiry *time.Time, cost float64, contract string) error {
+	if seats <= 0 {
+		return errors.New("the number of seats must be greater than zero")
+	}
+
+	if licenseType != licenseentitlement.TypeDevice.String() && licenseType != licenseentitlement.TypeUser.String() {
+		return errors.New("the license type is not valid")
+	}
+
+	if _, err := m.GetLicenseProduct(tenantID, productID); err != nil {
+		return err
+	}
+
+	return m.Client.LicenseEntitlement.Create().
+		SetSeats(seats).
+		SetType(licenseentitlement.Type(licenseType)).
+		SetNillableExpiry(expiry).
+		SetCost(cost).
+		SetContract(strings.TrimSpace(contract)).
+		SetProductID(productID).
+		Exec(context.Background())
+}
+
+func (m *Model) DeleteLicenseEntitlement(tenantID int, entitlementID int) error {
+	_, err := m.Client.LicenseEntitlement.Delete().Where(licenseentitlement.ID(entitlementID), licenseentitlement.HasProductWith(licenseproduct.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background())
+	return err
+}
+
+// MatchLicensePattern matches a value against a case insensitive wildcard pattern, an empty pattern matches everything
+func MatchLicensePattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, err := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+	return err == nil && matched
+}
+
+func MatchLicenseProduct(p *ent.LicenseProduct, a *ent.App) bool {
+	return MatchLicensePattern(p.NamePattern, a.Name) && MatchLicensePattern(p.VendorPattern, a.Publisher) && MatchLicensePattern(p.VersionPattern, a.Version)
+}
+
+// IsEntitlementValid checks that an entitlement hasn't expired
+func IsEntitlementValid(e *ent.LicenseEntitlement, now time.Time) bool {
+	return e.Expiry == nil || e.Expiry.After(now)
+}
+
+// ComputeLicenseCompliance allocates the valid entitlements of a product to the computers where it's installed.
+// User seats are given first to the users with more computers, so all their computers are covered, and the
+// remaining computers need device seats. The product is under-licensed if some computers aren't covered and
+// over-licensed if some seats aren't used
+func ComputeLicenseCompliance(p *ent.LicenseProduct, computers []LicensedComputer, now time.Time) (LicenseCompliance, []LicensedComputer) {
+	compliance := LicenseCompliance{ProductID: p.ID, Name: p.Name, Devices: len(computers)}
+
+	for _, e := range p.Edges.Entitlements {
+		if !IsEntitlementValid(e, now) {
+			continue
+		}
+		if e.Type == licenseentitlement.TypeUser {
+			compliance.UserSeats += e.Seats
+		} else {
+			compliance.DeviceSeats += e.Seats
+		}
+		compliance.Cost += e.Cost
+	}
+
+	byUser := map[string][]int{}
+	for i, c := range computers {
+		if c.Username != "" {
+			byUser[strings.ToLower(c.Username)] = append(byUser[strings.ToLower(c.Username)], i)
+		}
+	}
+	compliance.Users = len(byUser)
+
+	users := []string{}
+	for u := range byUser {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool {
+		if len(byUser[users[i]]) != len(byUser[users[j]]) {
+			return len(byUser[users[i]]) > len(byUser[users[j]])
+		}
+		return users[i] < users[j]
+	})
+
+	result := make([]LicensedComputer, len(computers))
+	copy(result, computers)
+
+	userSeats := compliance.UserSeats
+	for _, u := range users {
+		if userSeats == 0 {
+			break
+		}
+		for _, i := range byUser[u] {
+			result[i].Covered = true
+		}
+		userSeats--
+	}
+
+	deviceSeats := compliance.DeviceSeats
+	for i := range result {
+		if result[i].Covered {
+			continue
+		}
+		if deviceSeats == 0 {
+			compliance.Shortfall++
+			continue
+		}
+		result[i].Covered = true
+		deviceSeats--
+	}
+
+	compliance.Unused = userSeats + deviceSeats
+
+	switch {
+	case compliance.Shortfall > 0:
+		compliance.Status = LICENSE_STATUS_UNDER
+	case compliance.Unused > 0:
+		compliance.Status = LICENSE_STATUS_OVER
+	default:
+		compliance.Status = LICENSE_STATUS_COMPLIANT
+	}
+
+	return compliance, result
+}
+
+// getLicensedComputers returns the computers where a product is installed
+func (m *Model) getLicensedComputers(p *ent.LicenseProduct, apps []*ent.App) []LicensedComputer {
+	computers := []LicensedComputer{}
+	index := map[string]int{}
+
+	for _, a := range apps {
+		if a.Edges.Owner == nil || !MatchLicenseProduct(p, a) {
+			continue
+		}
+
+		owner := a.Edges.Owner
+		i, ok := index[owner.ID]
+		if !ok {
+			i = len(computers)
+			index[owner.ID] = i
+			username := ""
+			if owner.Edges.Operatingsystem != nil {
+				username = owner.Edges.Operatingsystem.Username
+			}
+			computers = append(computers, LicensedComputer{AgentID: owner.ID, Nickname: owner.Nickname, OS: owner.Os, Username: username})
+		}
+		computers[i].Apps = append(computers[i].Apps, strings.TrimSpace(a.Name+" "+a.Version))
+	}
+
+	sort.SliceStable(computers, func(i, j int) bool {
+		return computers[i].Nickname < computers[j].Nickname
+	})
+
+	return computers
+}
+
+func (m *Model) getTenantApps(tenantID int) ([]*ent.App, error) {
+	return m.Client.App.Query().
+		WithOwner(func(q *ent.AgentQuery) {
+			q.WithOperatingsystem()
+		}).
+		Where(app.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		All(context.Background())
+}
+
+// getLicensedApps returns the apps installed in the computers of the tenant, or of the selected site
+func (m *Model) getLicensedApps(c *partials.CommonInfo) ([]*ent.App, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.App.Query().
+		WithOwner(func(q *ent.AgentQuery) {
+			q.WithOperatingsystem()
+		})
+
+	if siteID == -1 {
+		query.Where(app.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query.Where(app.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+
+	return query.All(context.Background())
+}
+
+func (m *Model) GetLicenseCompliance(c *partials.CommonInfo) ([]LicenseCompliance, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	products, err := m.GetLicenseProducts(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	compliance := []LicenseCompliance{}
+	if len(products) == 0 {
+		return compliance, nil
+	}
+
+	apps, err := m.getLicensedApps(c)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for _, p := range products {
+		result, _ := ComputeLicenseCompliance(p, m.getLicensedComputers(p, apps), now)
+		compliance = append(compliance, result)
+	}
+
+	return compliance, nil
+}
+
+func (m *Model) GetLicenseProductCompliance(productID int, c *partials.CommonInfo) (*ent.LicenseProduct, LicenseCompliance, []LicensedComputer, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, LicenseCompliance{}, nil, err
+	}
+
+	p, err := m.GetLicenseProduct(tenantID, productID)
+	if err != nil {
+		return nil, LicenseCompliance{}, nil, err
+	}
+
+	apps, err := m.getLicensedApps(c)
+	if err != nil {
+		return nil, LicenseCompliance{}, nil, err
+	}
+
+	compliance, computers := ComputeLicenseCompliance(p, m.getLicensedComputers(p, apps), time.Now())
+
+	// Computers not covered by a license are shown first
+	sort.SliceStable(computers, func(i, j int) bool {
+		return !computers[i].Covered && computers[j].Covered
+	})
+
+	return p, compliance, computers, nil
+}
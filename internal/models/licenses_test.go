@@ -0,0 +1,162 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LicensesTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *LicensesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	// alice uses agent0 and agent1, bob uses agent2 and agent3 has Office 2016
+	usernames := []string{"alice", "alice", "bob", "carol"}
+	versions := []string{"16.0.1", "16.0.2", "16.0.1", "15.0.1"}
+	for i, username := range usernames {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.OperatingSystem.Create().
+			SetType("windows").
+			SetVersion("10").
+			SetDescription("Windows 10").
+			SetUsername(username).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create operating system")
+
+		err = client.App.Create().
+			SetName("Microsoft Office Professional").
+			SetPublisher("Microsoft Corporation").
+			SetVersion(versions[i]).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	err = suite.model.AddLicenseProduct(t.ID, "Office 2019", "microsoft office*", "Microsoft*", "16.*")
+	assert.NoError(suite.T(), err, "should add license product")
+}
+
+func (suite *LicensesTestSuite) TestAddLicenseProduct() {
+	err := suite.model.AddLicenseProduct(suite.tenantID, "Empty", "", "", "")
+	assert.Error(suite.T(), err, "should not add a product without application name pattern")
+
+	err = suite.model.AddLicenseProduct(suite.tenantID, "Wrong", "[", "", "")
+	assert.Error(suite.T(), err, "should not add a product with a malformed pattern")
+
+	products, err := suite.model.GetLicenseProducts(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get license products")
+	assert.Equal(suite.T(), 1, len(products), "should get one product")
+}
+
+func (suite *LicensesTestSuite) TestMatchLicensePattern() {
+	assert.True(suite.T(), MatchLicensePattern("", "anything"), "empty pattern should match everything")
+	assert.True(suite.T(), MatchLicensePattern("ADOBE*", "Adobe Acrobat"), "should ignore case")
+	assert.False(suite.T(), MatchLicensePattern("16.*", "15.0.1"), "should not match another version")
+}
+
+func (suite *LicensesTestSuite) TestLicenseCompliance() {
+	products, err := suite.model.GetLicenseProducts(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get license products")
+	productID := products[0].ID
+
+	compliance, err := suite.model.GetLicenseCompliance(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), 3, compliance[0].Devices, "should match three computers")
+	assert.Equal(suite.T(), 2, compliance[0].Users, "should match two users")
+	assert.Equal(suite.T(), LICENSE_STATUS_UNDER, compliance[0].Status, "should be under-licensed without entitlements")
+
+	// A user seat for alice covers two computers, bob needs a device seat
+	err = suite.model.AddLicenseEntitlement(suite.tenantID, productID, 1, "user", nil, 100, "CT-1")
+	assert.NoError(suite.T(), err, "should add user entitlement")
+
+	_, productCompliance, computers, err := suite.model.GetLicenseProductCompliance(productID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get product compliance")
+	assert.Equal(suite.T(), 1, productCompliance.Shortfall, "should miss one seat")
+	assert.Equal(suite.T(), "agent2", computers[0].AgentID, "should list the offending computer first")
+	assert.False(suite.T(), computers[0].Covered, "agent2 should not be covered")
+
+	// An expired entitlement is not counted
+	expired := time.Now().AddDate(0, 0, -1)
+	err = suite.model.AddLicenseEntitlement(suite.tenantID, productID, 1, "device", &expired, 50, "CT-2")
+	assert.NoError(suite.T(), err, "should add expired entitlement")
+
+	compliance, err = suite.model.GetLicenseCompliance(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), LICENSE_STATUS_UNDER, compliance[0].Status, "should be under-licensed with expired seats")
+
+	err = suite.model.AddLicenseEntitlement(suite.tenantID, productID, 5, "device", nil, 250, "CT-3")
+	assert.NoError(suite.T(), err, "should add device entitlement")
+
+	compliance, err = suite.model.GetLicenseCompliance(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), LICENSE_STATUS_OVER, compliance[0].Status, "should be over-licensed")
+	assert.Equal(suite.T(), 4, compliance[0].Unused, "should have four unused seats")
+	assert.Equal(suite.T(), float64(350), compliance[0].Cost, "should add the cost of valid entitlements")
+
+	err = suite.model.AddLicenseEntitlement(suite.tenantID, productID, 0, "device", nil, 0, "")
+	assert.Error(suite.T(), err, "should not add an entitlement without seats")
+
+	err = suite.model.DeleteLicenseProduct(suite.tenantID, productID)
+	assert.NoError(suite.T(), err, "should delete license product")
+
+	compliance, err = suite.model.GetLicenseCompliance(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), 0, len(compliance), "should not get products")
+}
+
+func (suite *LicensesTestSuite) TestLicenseComplianceSiteScope() {
+	other, err := suite.model.Client.Site.Create().SetDescription("Other").SetTenantID(suite.tenantID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site")
+
+	err = suite.model.Client.Agent.UpdateOneID("agent2").ClearSite().AddSiteIDs(other.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should move agent")
+
+	compliance, err := suite.model.GetLicenseCompliance(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), 2, compliance[0].Devices, "the computer of the other site should be excluded")
+	assert.Equal(suite.T(), 1, compliance[0].Users, "bob only uses a computer of the other site")
+
+	allSites := &partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: "-1"}
+	compliance, err = suite.model.GetLicenseCompliance(allSites)
+	assert.NoError(suite.T(), err, "should get license compliance")
+	assert.Equal(suite.T(), 3, compliance[0].Devices, "every computer of the tenant should be counted")
+}
+
+func TestLicensesTestSuite(t *testing.T) {
+	suite.Run(t, new(LicensesTestSuite))
+}
@@ -0,0 +1,168 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/loggedonuser"
+	"github.com/scncore/ent/operatingsystem"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+type UserDevice struct {
+	AgentID   string
+	Nickname  string
+	OS        string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+type UserDevices struct {
+	Username string
+	LastSeen time.Time
+	Devices  []UserDevice
+}
+
+// SaveLoggedOnUsers records the user currently logged on every agent, updating
+// the last time it was seen if the user was already known for that agent
+func (m *Model) SaveLoggedOnUsers() error {
+	agents, err := m.Client.Agent.Query().WithOperatingsystem().
+		Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasOperatingsystemWith(operatingsystem.UsernameNEQ(""))).
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, a := range agents {
+		if a.Edges.Operatingsystem == nil {
+			continue
+		}
+		username := strings.TrimSpace(a.Edges.Operatingsystem.Username)
+		if username == "" {
+			continue
+		}
+
+		n, err := m.Client.LoggedOnUser.Update().
+			SetLastSeen(now).
+			Where(loggedonuser.UsernameEqualFold(username), loggedonuser.HasOwnerWith(agent.ID(a.ID))).
+			Save(context.Background())
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+
+		if err := m.Client.LoggedOnUser.Create().
+			SetUsername(username).
+			SetFirstSeen(now).
+			SetLastSeen(now).
+			SetOwnerID(a.ID).
+			Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) GetAgentLoggedOnUsers(agentId string) ([]*ent.LoggedOnUser, error) {
+	return m.Client.LoggedOnUser.Query().Where(loggedonuser.HasOwnerWith(agent.ID(agentId))).Order(ent.Desc(loggedonuser.FieldLastSeen)).All(context.Background())
+}
+
+// GetLoggedOnUsers groups the logged on users history by username, listing the devices used by each user
+func (m *Model) GetLoggedOnUsers(p partials.PaginationAndSort, search string, c *partials.CommonInfo) ([]UserDevices, int, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, 0, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := m.Client.LoggedOnUser.Query().WithOwner()
+	if siteID == -1 {
+		query = query.Where(loggedonuser.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query = query.Where(loggedonuser.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+	if search != "" {
+		query = query.Where(loggedonuser.UsernameContainsFold(search))
+	}
+
+	history, err := query.Order(ent.Desc(loggedonuser.FieldLastSeen)).All(context.Background())
+	if err != nil {
+		return nil, 0, err
+	}
+
+	users := []UserDevices{}
+	index := map[string]int{}
+	for _, h := range history {
+		if h.Edges.Owner == nil {
+			continue
+		}
+
+		key := strings.ToLower(h.Username)
+		i, ok := index[key]
+		if !ok {
+			i = len(users)
+			index[key] = i
+			users = append(users, UserDevices{Username: h.Username, LastSeen: h.LastSeen})
+		}
+
+		users[i].Devices = append(users[i].Devices, UserDevice{
+			AgentID:   h.Edges.Owner.ID,
+			Nickname:  h.Edges.Owner.Nickname,
+			OS:        h.Edges.Owner.Os,
+			FirstSeen: h.FirstSeen,
+			LastSeen:  h.LastSeen,
+		})
+	}
+
+	sortUserDevices(users, p)
+
+	total := len(users)
+	if p.PageSize == 0 {
+		return users, total, nil
+	}
+
+	start := (p.CurrentPage - 1) * p.PageSize
+	if start > total {
+		start = total
+	}
+	end := start + p.PageSize
+	if end > total {
+		end = total
+	}
+
+	return users[start:end], total, nil
+}
+
+func sortUserDevices(users []UserDevices, p partials.PaginationAndSort) {
+	less := func(i, j int) bool {
+		switch p.SortBy {
+		case "devices":
+			return len(users[i].Devices) < len(users[j].Devices)
+		case "lastSeen":
+			return users[i].LastSeen.Before(users[j].LastSeen)
+		default:
+			return strings.ToLower(users[i].Username) < strings.ToLower(users[j].Username)
+		}
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		if p.SortOrder == "desc" {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
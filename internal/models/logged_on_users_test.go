@@ -0,0 +1,124 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/operatingsystem"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggedOnUsersTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *LoggedOnUsersTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	// alice is logged on agent0 and agent1, bob on agent2 and nobody on agent3
+	usernames := []string{"alice", "ALICE", "bob", ""}
+	for i, username := range usernames {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.OperatingSystem.Create().
+			SetType("windows").
+			SetVersion("10").
+			SetDescription("Windows 10").
+			SetUsername(username).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create operating system")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "username", SortOrder: "asc"}
+}
+
+func (suite *LoggedOnUsersTestSuite) TestSaveLoggedOnUsers() {
+	err := suite.model.SaveLoggedOnUsers()
+	assert.NoError(suite.T(), err, "should save logged on users")
+
+	users, err := suite.model.GetAgentLoggedOnUsers("agent0")
+	assert.NoError(suite.T(), err, "should get agent logged on users")
+	assert.Equal(suite.T(), 1, len(users), "should get one user")
+	firstSeen := users[0].FirstSeen
+
+	// bob logs on agent0
+	_, err = suite.model.Client.OperatingSystem.Update().SetUsername("bob").Where(operatingsystem.HasOwnerWith(agent.ID("agent0"))).Save(context.Background())
+	assert.NoError(suite.T(), err, "should update username")
+
+	err = suite.model.SaveLoggedOnUsers()
+	assert.NoError(suite.T(), err, "should save logged on users")
+
+	users, err = suite.model.GetAgentLoggedOnUsers("agent0")
+	assert.NoError(suite.T(), err, "should get agent logged on users")
+	assert.Equal(suite.T(), 2, len(users), "should keep the history of users")
+	assert.Equal(suite.T(), "bob", users[0].Username, "should get the last user first")
+	assert.Equal(suite.T(), firstSeen, users[1].FirstSeen, "should keep the first time a user was seen")
+
+	users, err = suite.model.GetAgentLoggedOnUsers("agent3")
+	assert.NoError(suite.T(), err, "should get agent logged on users")
+	assert.Equal(suite.T(), 0, len(users), "should not save empty usernames")
+}
+
+func (suite *LoggedOnUsersTestSuite) TestGetLoggedOnUsers() {
+	err := suite.model.SaveLoggedOnUsers()
+	assert.NoError(suite.T(), err, "should save logged on users")
+
+	users, total, err := suite.model.GetLoggedOnUsers(suite.p, "", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get logged on users")
+	assert.Equal(suite.T(), 2, total, "should group usernames ignoring case")
+	assert.Equal(suite.T(), 2, len(users[0].Devices), "alice should use two devices")
+	assert.Equal(suite.T(), 1, len(users[1].Devices), "bob should use one device")
+
+	users, total, err = suite.model.GetLoggedOnUsers(suite.p, "BO", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should search logged on users")
+	assert.Equal(suite.T(), 1, total, "should find one user")
+	assert.Equal(suite.T(), "agent2", users[0].Devices[0].AgentID, "bob should use agent2")
+
+	suite.p.PageSize = 1
+	suite.p.CurrentPage = 2
+	users, _, err = suite.model.GetLoggedOnUsers(suite.p, "", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get logged on users by page")
+	assert.Equal(suite.T(), "bob", users[0].Username, "should get bob in the second page")
+}
+
+func (suite *LoggedOnUsersTestSuite) TestFilterComputersByLoggedOnUser() {
+	err := suite.model.SaveLoggedOnUsers()
+	assert.NoError(suite.T(), err, "should save logged on users")
+
+	count, err := suite.model.CountAllComputers(filters.AgentFilter{LoggedOnUser: "alice"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count computers")
+	assert.Equal(suite.T(), 2, count, "alice should have logged on two computers")
+}
+
+func TestLoggedOnUsersTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggedOnUsersTestSuite))
+}
@@ -0,0 +1,244 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/maintenancewindow"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tag"
+	"github.com/scncore/ent/tenant"
+)
+
+var MaintenanceWindowScopes = []string{"tenant", "site", "tag"}
+
+var MaintenanceWindowKinds = []string{"weekly", "cron"}
+
+var MaintenanceWindowDays = []string{"mon", "tue", "wed", "thu", "fri", "sat", "sun"}
+
+type MaintenanceWindowRequest struct {
+	Name     string
+	Scope    string
+	SiteID   int
+	TagID    int
+	Kind     string
+	Days     []string
+	Start    string
+	Cron     string
+	Duration int
+	Timezone string
+}
+
+func (m *Model) GetMaintenanceWindows(tenantID int) ([]*ent.MaintenanceWindow, error) {
+	return m.Client.MaintenanceWindow.Query().
+		Where(maintenancewindow.HasTenantWith(tenant.ID(tenantID))).
+		WithSite().
+		WithTag().
+		Order(ent.Asc(maintenancewindow.FieldName)).
+		All(context.Background())
+}
+
+func (m *Model) AddMaintenanceWindow(tenantID int, r MaintenanceWindowRequest) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return errors.New("the window name cannot be empty")
+	}
+
+	if err := validateMaintenanceWindowSchedule(r); err != nil {
+		return err
+	}
+
+	query := m.Client.MaintenanceWindow.Create().
+		SetName(r.Name).
+		SetKind(maintenancewindow.Kind(r.Kind)).
+		SetDays(r.Days).
+		SetStart(r.Start).
+		SetCron(strings.TrimSpace(r.Cron)).
+		SetDuration(r.Duration).
+		SetTimezone(r.Timezone).
+		SetTenantID(tenantID)
+
+	switch r.Scope {
+	case "tenant":
+		query.SetScope(maintenancewindow.ScopeTenant)
+	case "site":
+		exists, err := m.Client.Site.Query().Where(site.ID(r.SiteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("the site doesn't belong to this tenant")
+		}
+		query.SetScope(maintenancewindow.ScopeSite).SetSiteID(r.SiteID)
+	case "tag":
+		exists, err := m.Client.Tag.Query().Where(tag.ID(r.TagID), tag.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("the tag doesn't belong to this tenant")
+		}
+		query.SetScope(maintenancewindow.ScopeTag).SetTagID(r.TagID)
+	default:
+		return errors.New("the window scope is not valid")
+	}
+
+	return query.Exec(context.Background())
+}
+
+func validateMaintenanceWindowSchedule(r MaintenanceWindowRequest) error {
+	if _, err := time.LoadLocation(r.Timezone); err != nil {
+		return errors.New("the timezone is not valid")
+	}
+
+	if r.Duration < 1 {
+		return errors.New("the window must last at least one minute")
+	}
+
+	switch r.Kind {
+	case "weekly":
+		if len(r.Days) == 0 {
+			return errors.New("at least one day must be selected")
+		}
+		for _, d := range r.Days {
+			if !slices.Contains(MaintenanceWindowDays, d) {
+				return errors.New("the day is not valid")
+			}
+		}
+		if _, err := time.Parse("15:04", r.Start); err != nil {
+			return errors.New("the start time is not valid")
+		}
+	case "cron":
+		if _, err := cron.ParseStandard(strings.TrimSpace(r.Cron)); err != nil {
+			return err
+		}
+	default:
+		return errors.New("the window type is not valid")
+	}
+
+	return nil
+}
+
+func (m *Model) DeleteMaintenanceWindow(tenantID int, windowID int) error {
+	_, err := m.Client.MaintenanceWindow.Delete().Where(maintenancewindow.ID(windowID), maintenancewindow.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
+	return err
+}
+
+// GetAgentMaintenanceWindows returns the windows that apply to an agent, the most specific ones win:
+// the windows of its tags, then the windows of its site and finally the windows of its tenant
+func (m *Model) GetAgentMaintenanceWindows(agentID string) ([]*ent.MaintenanceWindow, error) {
+	a, err := m.Client.Agent.Query().Where(agent.ID(agentID)).WithTags().WithSite(func(q *ent.SiteQuery) { q.WithTenant() }).Only(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	tagIDs := []int{}
+	for _, t := range a.Edges.Tags {
+		tagIDs = append(tagIDs, t.ID)
+	}
+
+	siteIDs := []int{}
+	tenantIDs := []int{}
+	for _, s := range a.Edges.Site {
+		siteIDs = append(siteIDs, s.ID)
+		if s.Edges.Tenant != nil {
+			tenantIDs = append(tenantIDs, s.Edges.Tenant.ID)
+		}
+	}
+
+	if len(tagIDs) > 0 {
+		windows, err := m.Client.MaintenanceWindow.Query().Where(maintenancewindow.ScopeEQ(maintenancewindow.ScopeTag), maintenancewindow.HasTagWith(tag.IDIn(tagIDs...))).All(context.Background())
+		if err != nil || len(windows) > 0 {
+			return windows, err
+		}
+	}
+
+	windows, err := m.Client.MaintenanceWindow.Query().Where(maintenancewindow.ScopeEQ(maintenancewindow.ScopeSite), maintenancewindow.HasSiteWith(site.IDIn(siteIDs...))).All(context.Background())
+	if err != nil || len(windows) > 0 {
+		return windows, err
+	}
+
+	return m.Client.MaintenanceWindow.Query().Where(maintenancewindow.ScopeEQ(maintenancewindow.ScopeTenant), maintenancewindow.HasTenantWith(tenant.IDIn(tenantIDs...))).All(context.Background())
+}
+
+// AgentMaintenanceWindow tells if a maintenance window of the agent is open and, if not, when the next one opens.
+// Agents with no windows are always open
+func (m *Model) AgentMaintenanceWindow(agentID string, now time.Time) (bool, time.Time, error) {
+	windows, err := m.GetAgentMaintenanceWindows(agentID)
+	if err != nil {
+		return false, time.Time{}, err
+	}
+
+	if len(windows) == 0 {
+		return true, now, nil
+	}
+
+	open, next := MaintenanceWindowsOpen(windows, now)
+	return open, next, nil
+}
+
+// MaintenanceWindowsOpen tells if any of the windows is open and the earliest time one of them opens
+func MaintenanceWindowsOpen(windows []*ent.MaintenanceWindow, now time.Time) (bool, time.Time) {
+	next := time.Time{}
+	for _, w := range windows {
+		open, start := MaintenanceWindowOpen(w, now)
+		if open {
+			return true, now
+		}
+		if !start.IsZero() && (next.IsZero() || start.Before(next)) {
+			next = start
+		}
+	}
+	return false, next
+}
+
+// MaintenanceWindowOpen tells if the window is open now, if it's closed it returns when it opens next
+func MaintenanceWindowOpen(w *ent.MaintenanceWindow, now time.Time) (bool, time.Time) {
+	loc, err := time.LoadLocation(w.Timezone)
+	if err != nil {
+		loc = time.Local
+	}
+	now = now.In(loc)
+	duration := time.Duration(w.Duration) * time.Minute
+
+	switch w.Kind {
+	case maintenancewindow.KindCron:
+		schedule, err := cron.ParseStandard(w.Cron)
+		if err != nil {
+			return false, time.Time{}
+		}
+		// The window is open if it started less than its duration ago
+		if start := schedule.Next(now.Add(-duration)); !start.After(now) {
+			return true, start
+		}
+		return false, schedule.Next(now)
+	default:
+		hour, err := time.Parse("15:04", w.Start)
+		if err != nil {
+			return false, time.Time{}
+		}
+
+		// Windows that started yesterday can still be open
+		for i := -1; i <= 7; i++ {
+			day := now.AddDate(0, 0, i)
+			if !slices.Contains(w.Days, strings.ToLower(day.Weekday().String()[:3])) {
+				continue
+			}
+
+			start := time.Date(day.Year(), day.Month(), day.Day(), hour.Hour(), hour.Minute(), 0, 0, loc)
+			if !start.After(now) && now.Before(start.Add(duration)) {
+				return true, start
+			}
+			if start.After(now) {
+				return false, start
+			}
+		}
+		return false, time.Time{}
+	}
+}
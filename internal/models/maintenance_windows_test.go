@@ -0,0 +1,147 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/maintenancewindow"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MaintenanceWindowsTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	siteID   int
+	tagID    int
+}
+
+func (suite *MaintenanceWindowsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	servers, err := client.Tag.Create().SetTag("servers").SetDescription("servers").SetColor("#ff0000").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+	suite.tagID = servers.ID
+
+	err = client.Agent.Create().SetID("agent0").SetHostname("agent0").SetOs("windows").SetNickname("agent0").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	err = client.Agent.Create().SetID("agent1").SetHostname("agent1").SetOs("windows").SetNickname("agent1").SetAgentStatus(agent.AgentStatusEnabled).AddSiteIDs(s.ID).AddTagIDs(servers.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+}
+
+func (suite *MaintenanceWindowsTestSuite) TestAddMaintenanceWindow() {
+	err := suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "", Scope: "tenant", Kind: "weekly", Days: []string{"mon"}, Start: "22:00", Duration: 60, Timezone: "UTC"})
+	assert.Error(suite.T(), err, "the name is required")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "tenant", Kind: "weekly", Days: []string{}, Start: "22:00", Duration: 60, Timezone: "UTC"})
+	assert.Error(suite.T(), err, "at least one day is required")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "tenant", Kind: "weekly", Days: []string{"mon"}, Start: "25:00", Duration: 60, Timezone: "UTC"})
+	assert.Error(suite.T(), err, "the start time is not valid")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "tenant", Kind: "cron", Cron: "not a cron", Duration: 60, Timezone: "UTC"})
+	assert.Error(suite.T(), err, "the cron expression is not valid")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "tenant", Kind: "weekly", Days: []string{"mon"}, Start: "22:00", Duration: 60, Timezone: "Mars/Olympus"})
+	assert.Error(suite.T(), err, "the timezone is not valid")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "tag", TagID: 9999, Kind: "weekly", Days: []string{"mon"}, Start: "22:00", Duration: 60, Timezone: "UTC"})
+	assert.Error(suite.T(), err, "the tag must belong to the tenant")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Nights", Scope: "site", SiteID: suite.siteID, Kind: "weekly", Days: []string{"mon", "tue"}, Start: "22:00", Duration: 60, Timezone: "Europe/Madrid"})
+	assert.NoError(suite.T(), err, "should add maintenance window")
+
+	windows, err := suite.model.GetMaintenanceWindows(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get maintenance windows")
+	assert.Equal(suite.T(), 1, len(windows))
+	assert.Equal(suite.T(), maintenancewindow.ScopeSite, windows[0].Scope)
+	assert.Equal(suite.T(), suite.siteID, windows[0].Edges.Site.ID)
+
+	err = suite.model.DeleteMaintenanceWindow(suite.tenantID, windows[0].ID)
+	assert.NoError(suite.T(), err, "should delete maintenance window")
+
+	windows, err = suite.model.GetMaintenanceWindows(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get maintenance windows")
+	assert.Equal(suite.T(), 0, len(windows))
+}
+
+func (suite *MaintenanceWindowsTestSuite) TestWeeklyMaintenanceWindowOpen() {
+	w := &ent.MaintenanceWindow{Kind: maintenancewindow.KindWeekly, Days: []string{"mon"}, Start: "22:00", Duration: 180, Timezone: "UTC"}
+
+	open, start := MaintenanceWindowOpen(w, time.Date(2026, 10, 19, 23, 0, 0, 0, time.UTC))
+	assert.True(suite.T(), open, "monday at 23:00 should be open")
+	assert.Equal(suite.T(), time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC), start.UTC())
+
+	open, _ = MaintenanceWindowOpen(w, time.Date(2026, 10, 20, 0, 30, 0, 0, time.UTC))
+	assert.True(suite.T(), open, "the window that started yesterday should still be open")
+
+	open, next := MaintenanceWindowOpen(w, time.Date(2026, 10, 20, 1, 0, 0, 0, time.UTC))
+	assert.False(suite.T(), open, "the window should be closed after its duration")
+	assert.Equal(suite.T(), time.Date(2026, 10, 26, 22, 0, 0, 0, time.UTC), next.UTC())
+
+	open, next = MaintenanceWindowOpen(w, time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC))
+	assert.False(suite.T(), open, "monday morning should be closed")
+	assert.Equal(suite.T(), time.Date(2026, 10, 19, 22, 0, 0, 0, time.UTC), next.UTC())
+
+	madrid := &ent.MaintenanceWindow{Kind: maintenancewindow.KindWeekly, Days: []string{"mon"}, Start: "22:00", Duration: 60, Timezone: "Europe/Madrid"}
+	open, _ = MaintenanceWindowOpen(madrid, time.Date(2026, 10, 19, 20, 30, 0, 0, time.UTC))
+	assert.True(suite.T(), open, "the start time should be read in the window's timezone")
+}
+
+func (suite *MaintenanceWindowsTestSuite) TestCronMaintenanceWindowOpen() {
+	w := &ent.MaintenanceWindow{Kind: maintenancewindow.KindCron, Cron: "0 22 * * 1-5", Duration: 60, Timezone: "UTC"}
+
+	open, _ := MaintenanceWindowOpen(w, time.Date(2026, 10, 19, 22, 30, 0, 0, time.UTC))
+	assert.True(suite.T(), open, "monday at 22:30 should be open")
+
+	open, next := MaintenanceWindowOpen(w, time.Date(2026, 10, 24, 10, 0, 0, 0, time.UTC))
+	assert.False(suite.T(), open, "saturday should be closed")
+	assert.Equal(suite.T(), time.Date(2026, 10, 26, 22, 0, 0, 0, time.UTC), next.UTC())
+
+	weekend := &ent.MaintenanceWindow{Kind: maintenancewindow.KindWeekly, Days: []string{"sat"}, Start: "09:00", Duration: 240, Timezone: "UTC"}
+	open, _ = MaintenanceWindowsOpen([]*ent.MaintenanceWindow{w, weekend}, time.Date(2026, 10, 24, 10, 0, 0, 0, time.UTC))
+	assert.True(suite.T(), open, "one open window is enough")
+}
+
+func (suite *MaintenanceWindowsTestSuite) TestAgentMaintenanceWindow() {
+	now := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	open, _, err := suite.model.AgentMaintenanceWindow("agent0", now)
+	assert.NoError(suite.T(), err, "should check agent window")
+	assert.True(suite.T(), open, "agents with no windows are always open")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Mornings", Scope: "tenant", Kind: "cron", Cron: "0 9 * * *", Duration: 120, Timezone: "UTC"})
+	assert.NoError(suite.T(), err, "should add tenant window")
+
+	err = suite.model.AddMaintenanceWindow(suite.tenantID, MaintenanceWindowRequest{Name: "Servers", Scope: "tag", TagID: suite.tagID, Kind: "weekly", Days: []string{"sun"}, Start: "02:00", Duration: 60, Timezone: "UTC"})
+	assert.NoError(suite.T(), err, "should add tag window")
+
+	open, _, err = suite.model.AgentMaintenanceWindow("agent0", now)
+	assert.NoError(suite.T(), err, "should check agent window")
+	assert.True(suite.T(), open, "the tenant window should be open")
+
+	open, next, err := suite.model.AgentMaintenanceWindow("agent1", now)
+	assert.NoError(suite.T(), err, "should check agent window")
+	assert.False(suite.T(), open, "the tag window wins over the tenant window")
+	assert.Equal(suite.T(), time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC), next.UTC())
+}
+
+func TestMaintenanceWindowsTestSuite(t *testing.T) {
+	suite.Run(t, new(MaintenanceWindowsTestSuite))
+}
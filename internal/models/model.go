@@ -54,6 +54,14 @@ func New(dbUrl string, driverName, domain string) (*Model, error) {
 	return &model, nil
 }
 
+// rollback aborts a transaction and returns the error that made it fail
+func rollback(tx *ent.Tx, err error) error {
+	if rerr := tx.Rollback(); rerr != nil {
+		return fmt.Errorf("%w: %v", err, rerr)
+	}
+	return err
+}
+
 func (m *Model) Close() error {
 	return m.Client.Close()
 }
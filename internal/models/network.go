@@ -0,0 +1,398 @@
+package models
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/subnet"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+type NetworkHost struct {
+	AgentID    string
+	Nickname   string
+	OS         string
+	Adapter    string
+	MACAddress string
+	Address    string
+	Gateway    string
+	DHCP       bool
+	CIDR       string
+}
+
+type Subnet struct {
+	CIDR       string
+	Gateways   []string
+	NComputers int
+	NDHCP      int
+	NStatic    int
+	SiteID     int
+	SiteName   string
+}
+
+type NetworkConflict struct {
+	Type  string
+	Value string
+	Hosts []NetworkHost
+}
+
+// ParseAdapterAddresses returns the IPs reported for a network adapter with the subnet they belong to,
+// addresses and masks are comma separated lists, masks can use dotted notation or a prefix length
+func ParseAdapterAddresses(addresses, masks string) []net.IPNet {
+	result := []net.IPNet{}
+
+	maskList := strings.Split(masks, ",")
+	for i, a := range strings.Split(addresses, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+
+		// The address may already contain the prefix
+		if ip, ipNet, err := net.ParseCIDR(a); err == nil {
+			result = append(result, net.IPNet{IP: ip, Mask: ipNet.Mask})
+			continue
+		}
+
+		ip := net.ParseIP(a)
+		if ip == nil {
+			continue
+		}
+
+		mask := ""
+		if i < len(maskList) {
+			mask = maskList[i]
+		} else if len(maskList) == 1 {
+			mask = maskList[0]
+		}
+
+		result = append(result, net.IPNet{IP: ip, Mask: parseMask(ip, mask)})
+	}
+
+	return result
+}
+
+func parseMask(ip net.IP, mask string) net.IPMask {
+	mask = strings.TrimPrefix(strings.TrimSpace(mask), "/")
+	if mask == "" {
+		return nil
+	}
+
+	bits := 128
+	if ip.To4() != nil {
+		bits = 32
+	}
+
+	if prefix, err := strconv.Atoi(mask); err == nil {
+		if prefix < 0 || prefix > bits {
+			return nil
+		}
+		return net.CIDRMask(prefix, bits)
+	}
+
+	if m := net.ParseIP(mask).To4(); m != nil && bits == 32 {
+		ones, size := net.IPMask(m).Size()
+		if size == 0 {
+			return nil
+		}
+		return net.CIDRMask(ones, bits)
+	}
+
+	return nil
+}
+
+// IsRelevantAddress discards addresses that are not useful to build the network view
+func IsRelevantAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsLinkLocalUnicast() && !ip.IsUnspecified() && !ip.IsMulticast()
+}
+
+func (m *Model) getNetworkHosts(c *partials.CommonInfo) ([]NetworkHost, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().WithNetworkadapters().Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission))
+	if siteID == -1 {
+		query.Where(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))
+	} else {
+		query.Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))
+	}
+
+	agents, err := query.All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	hosts := []NetworkHost{}
+	for _, a := range agents {
+		for _, n := range a.Edges.Networkadapters {
+			for _, address := range ParseAdapterAddresses(n.Addresses, n.Subnet) {
+				if !IsRelevantAddress(address.IP) {
+					continue
+				}
+
+				host := NetworkHost{
+					AgentID:    a.ID,
+					Nickname:   a.Nickname,
+					OS:         a.Os,
+					Adapter:    n.Name,
+					MACAddress: strings.ToUpper(n.MACAddress),
+					Address:    address.IP.String(),
+					Gateway:    n.DefaultGateway,
+					DHCP:       n.DhcpEnabled,
+				}
+
+				if address.Mask != nil && address.IP.To4() != nil {
+					network := net.IPNet{IP: address.IP.Mask(address.Mask), Mask: address.Mask}
+					host.CIDR = network.String()
+				}
+
+				hosts = append(hosts, host)
+			}
+		}
+	}
+
+	return hosts, nil
+}
+
+func (m *Model) GetSubnets(p partials.PaginationAndSort, f filters.SubnetFilter, c *partials.CommonInfo) ([]Subnet, int, error) {
+	hosts, err := m.getNetworkHosts(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	assignments, err := m.getSubnetAssignments(c.TenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	subnets := map[string]*Subnet{}
+	computers := map[string][]string{}
+	adapters := map[string][]string{}
+	for _, h := range hosts {
+		if h.CIDR == "" {
+			continue
+		}
+
+		s, ok := subnets[h.CIDR]
+		if !ok {
+			s = &Subnet{CIDR: h.CIDR, SiteID: -1}
+			if assigned, ok := assignments[h.CIDR]; ok && assigned.Edges.Site != nil {
+				s.SiteID = assigned.Edges.Site.ID
+				s.SiteName = assigned.Edges.Site.Description
+			}
+			subnets[h.CIDR] = s
+		}
+
+		if h.Gateway != "" && !slices.Contains(s.Gateways, h.Gateway) {
+			s.Gateways = append(s.Gateways, h.Gateway)
+		}
+
+		if !slices.Contains(computers[h.CIDR], h.AgentID) {
+			computers[h.CIDR] = append(computers[h.CIDR], h.AgentID)
+			s.NComputers++
+		}
+
+		adapter := h.AgentID + "|" + h.Adapter
+		if !slices.Contains(adapters[h.CIDR], adapter) {
+			adapters[h.CIDR] = append(adapters[h.CIDR], adapter)
+			if h.DHCP {
+				s.NDHCP++
+			} else {
+				s.NStatic++
+			}
+		}
+	}
+
+	result := []Subnet{}
+	for _, s := range subnets {
+		if f.CIDR != "" && !strings.Contains(s.CIDR, f.CIDR) {
+			continue
+		}
+		if len(f.Sites) > 0 && !slices.Contains(f.Sites, s.SiteName) {
+			continue
+		}
+		result = append(result, *s)
+	}
+
+	sortSubnets(result, p)
+
+	total := len(result)
+	if p.PageSize != 0 {
+		start := min((p.CurrentPage-1)*p.PageSize, total)
+		end := min(start+p.PageSize, total)
+		result = result[start:end]
+	}
+
+	return result, total, nil
+}
+
+func sortSubnets(subnets []Subnet, p partials.PaginationAndSort) {
+	less := func(i, j int) bool {
+		switch p.SortBy {
+		case "computers":
+			return subnets[i].NComputers < subnets[j].NComputers
+		case "site":
+			return subnets[i].SiteName < subnets[j].SiteName
+		default:
+			return compareCIDR(subnets[i].CIDR, subnets[j].CIDR) < 0
+		}
+	}
+
+	sort.SliceStable(subnets, func(i, j int) bool {
+		if p.SortOrder == "desc" {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+}
+
+func compareCIDR(a, b string) int {
+	ipA, _, errA := net.ParseCIDR(a)
+	ipB, _, errB := net.ParseCIDR(b)
+	if errA != nil || errB != nil {
+		return strings.Compare(a, b)
+	}
+	return bytes.Compare(ipA.To16(), ipB.To16())
+}
+
+func (m *Model) GetSubnetHosts(cidr string, c *partials.CommonInfo) ([]NetworkHost, error) {
+	hosts, err := m.getNetworkHosts(c)
+	if err != nil {
+		return nil, err
+	}
+
+	subnetHosts := []NetworkHost{}
+	for _, h := range hosts {
+		if h.CIDR == cidr {
+			subnetHosts = append(subnetHosts, h)
+		}
+	}
+
+	sort.SliceStable(subnetHosts, func(i, j int) bool {
+		return bytes.Compare(net.ParseIP(subnetHosts[i].Address).To16(), net.ParseIP(subnetHosts[j].Address).To16()) < 0
+	})
+
+	return subnetHosts, nil
+}
+
+// GetNetworkConflicts returns the IP and MAC addresses that are reported by more than one agent
+func (m *Model) GetNetworkConflicts(c *partials.CommonInfo) ([]NetworkConflict, error) {
+	hosts, err := m.getNetworkHosts(c)
+	if err != nil {
+		return nil, err
+	}
+
+	byIP := map[string][]NetworkHost{}
+	byMAC := map[string][]NetworkHost{}
+	for _, h := range hosts {
+		byIP[h.Address] = appendHostIfNewAgent(byIP[h.Address], h)
+		if h.MACAddress != "" && h.MACAddress != "00:00:00:00:00:00" {
+			byMAC[h.MACAddress] = appendHostIfNewAgent(byMAC[h.MACAddress], h)
+		}
+	}
+
+	conflicts := []NetworkConflict{}
+	for ip, h := range byIP {
+		if len(h) > 1 {
+			conflicts = append(conflicts, NetworkConflict{Type: "ip", Value: ip, Hosts: h})
+		}
+	}
+	for mac, h := range byMAC {
+		if len(h) > 1 {
+			conflicts = append(conflicts, NetworkConflict{Type: "mac", Value: mac, Hosts: h})
+		}
+	}
+
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		if conflicts[i].Type != conflicts[j].Type {
+			return conflicts[i].Type < conflicts[j].Type
+		}
+		return conflicts[i].Value < conflicts[j].Value
+	})
+
+	return conflicts, nil
+}
+
+func appendHostIfNewAgent(hosts []NetworkHost, host NetworkHost) []NetworkHost {
+	for _, h := range hosts {
+		if h.AgentID == host.AgentID {
+			return hosts
+		}
+	}
+	return append(hosts, host)
+}
+
+func (m *Model) getSubnetAssignments(tenantID string) (map[string]*ent.Subnet, error) {
+	id, err := strconv.Atoi(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	subnets, err := m.Client.Subnet.Query().WithSite().Where(subnet.HasTenantWith(tenant.ID(id))).All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := map[string]*ent.Subnet{}
+	for _, s := range subnets {
+		assignments[s.Cidr] = s
+	}
+
+	return assignments, nil
+}
+
+func (m *Model) GetSubnetSite(cidr string, tenantID int) (*ent.Site, error) {
+	s, err := m.Client.Subnet.Query().WithSite().Where(subnet.Cidr(cidr), subnet.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+	if err != nil {
+		if ent.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return s.Edges.Site, nil
+}
+
+// SetSubnetSite associates a site to a subnet, a siteID of -1 removes the association
+func (m *Model) SetSubnetSite(cidr string, tenantID int, siteID int) error {
+	if siteID == -1 {
+		_, err := m.Client.Subnet.Delete().Where(subnet.Cidr(cidr), subnet.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
+		return err
+	}
+
+	exists, err := m.Client.Site.Query().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("the site doesn't belong to this tenant")
+	}
+
+	nUpdated, err := m.Client.Subnet.Update().Where(subnet.Cidr(cidr), subnet.HasTenantWith(tenant.ID(tenantID))).ClearSite().SetSiteID(siteID).Save(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if nUpdated == 0 {
+		return m.Client.Subnet.Create().SetCidr(cidr).SetTenantID(tenantID).SetSiteID(siteID).Exec(context.Background())
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type NetworkTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	tenantID   int
+	siteID     int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *NetworkTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := range 4 {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// agent0, agent1 and agent2 are in 192.168.1.0/24, agent2 duplicates the IP of agent1
+	// and agent3 is in 10.0.0.0/8 with the same MAC address as agent0
+	adapters := []struct {
+		owner, mac, addresses, subnet, gateway string
+		dhcp                                   bool
+	}{
+		{"agent0", "aa:bb:cc:dd:ee:00", "192.168.1.10,fe80::1", "255.255.255.0,64", "192.168.1.1", true},
+		{"agent1", "aa:bb:cc:dd:ee:01", "192.168.1.11", "24", "192.168.1.1", true},
+		{"agent2", "aa:bb:cc:dd:ee:02", "192.168.1.11", "255.255.255.0", "192.168.1.254", false},
+		{"agent3", "AA:BB:CC:DD:EE:00", "10.1.2.3", "255.0.0.0", "10.0.0.1", false},
+	}
+	for _, a := range adapters {
+		err := client.NetworkAdapter.Create().
+			SetName("Ethernet").
+			SetMACAddress(a.mac).
+			SetAddresses(a.addresses).
+			SetSubnet(a.subnet).
+			SetDefaultGateway(a.gateway).
+			SetDhcpEnabled(a.dhcp).
+			SetSpeed("1 Gbps").
+			SetOwnerID(a.owner).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create network adapter")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *NetworkTestSuite) TestParseAdapterAddresses() {
+	addresses := ParseAdapterAddresses("192.168.1.10, 10.0.0.5/16", "255.255.255.0")
+	assert.Equal(suite.T(), 2, len(addresses), "should parse two addresses")
+	ones, _ := addresses[0].Mask.Size()
+	assert.Equal(suite.T(), 24, ones, "first address should be a /24")
+	ones, _ = addresses[1].Mask.Size()
+	assert.Equal(suite.T(), 16, ones, "second address should be a /16")
+
+	addresses = ParseAdapterAddresses("192.168.1.10", "")
+	assert.Equal(suite.T(), 1, len(addresses), "should parse an address without mask")
+	assert.Nil(suite.T(), addresses[0].Mask, "mask should be empty")
+
+	addresses = ParseAdapterAddresses("not an ip", "24")
+	assert.Equal(suite.T(), 0, len(addresses), "should ignore invalid addresses")
+}
+
+func (suite *NetworkTestSuite) TestGetSubnets() {
+	subnets, total, err := suite.model.GetSubnets(suite.p, filters.SubnetFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get subnets")
+	assert.Equal(suite.T(), 2, total, "should get two subnets")
+	assert.Equal(suite.T(), "10.0.0.0/8", subnets[0].CIDR, "first subnet should be 10.0.0.0/8")
+	assert.Equal(suite.T(), "192.168.1.0/24", subnets[1].CIDR, "second subnet should be 192.168.1.0/24")
+	assert.Equal(suite.T(), 3, subnets[1].NComputers, "192.168.1.0/24 should have three computers")
+	assert.Equal(suite.T(), 2, subnets[1].NDHCP, "192.168.1.0/24 should have two DHCP adapters")
+	assert.Equal(suite.T(), 1, subnets[1].NStatic, "192.168.1.0/24 should have one static adapter")
+	assert.Equal(suite.T(), 2, len(subnets[1].Gateways), "192.168.1.0/24 should have two gateways")
+
+	_, total, err = suite.model.GetSubnets(suite.p, filters.SubnetFilter{CIDR: "10."}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get subnets")
+	assert.Equal(suite.T(), 1, total, "should get one subnet filtered by CIDR")
+}
+
+func (suite *NetworkTestSuite) TestSetSubnetSite() {
+	err := suite.model.SetSubnetSite("192.168.1.0/24", suite.tenantID, suite.siteID)
+	assert.NoError(suite.T(), err, "should associate site to subnet")
+
+	s, err := suite.model.GetSubnetSite("192.168.1.0/24", suite.tenantID)
+	assert.NoError(suite.T(), err, "should get subnet site")
+	assert.Equal(suite.T(), suite.siteID, s.ID, "subnet should be associated to the default site")
+
+	subnets, total, err := suite.model.GetSubnets(suite.p, filters.SubnetFilter{Sites: []string{"DefaultSite"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get subnets")
+	assert.Equal(suite.T(), 1, total, "should get one subnet filtered by site")
+	assert.Equal(suite.T(), "192.168.1.0/24", subnets[0].CIDR, "subnet should be 192.168.1.0/24")
+
+	err = suite.model.SetSubnetSite("192.168.1.0/24", suite.tenantID, -1)
+	assert.NoError(suite.T(), err, "should remove site from subnet")
+
+	s, err = suite.model.GetSubnetSite("192.168.1.0/24", suite.tenantID)
+	assert.NoError(suite.T(), err, "should get subnet site")
+	assert.Nil(suite.T(), s, "subnet should have no site")
+}
+
+func (suite *NetworkTestSuite) TestGetSubnetHosts() {
+	hosts, err := suite.model.GetSubnetHosts("192.168.1.0/24", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get subnet hosts")
+	assert.Equal(suite.T(), 3, len(hosts), "should get three hosts")
+	assert.Equal(suite.T(), "192.168.1.10", hosts[0].Address, "first host should be 192.168.1.10")
+}
+
+func (suite *NetworkTestSuite) TestGetNetworkConflicts() {
+	conflicts, err := suite.model.GetNetworkConflicts(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get network conflicts")
+	assert.Equal(suite.T(), 2, len(conflicts), "should get two conflicts")
+	assert.Equal(suite.T(), "ip", conflicts[0].Type, "first conflict should be an IP conflict")
+	assert.Equal(suite.T(), "192.168.1.11", conflicts[0].Value, "duplicated IP should be 192.168.1.11")
+	assert.Equal(suite.T(), "mac", conflicts[1].Type, "second conflict should be a MAC conflict")
+	assert.Equal(suite.T(), "AA:BB:CC:DD:EE:00", conflicts[1].Value, "duplicated MAC should be AA:BB:CC:DD:EE:00")
+}
+
+func TestNetworkTestSuite(t *testing.T) {
+	suite.Run(t, new(NetworkTestSuite))
+}
@@ -0,0 +1,306 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/monitor"
+	"github.com/scncore/ent/peripheralasset"
+	"github.com/scncore/ent/predicate"
+	"github.com/scncore/ent/printer"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// SyncPeripheralAssets updates the peripherals register of every tenant with
+// the monitors and printers currently reported by the agents
+func (m *Model) SyncPeripheralAssets() error {
+	tenants, err := m.GetTenants()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, t := range tenants {
+		if err := m.syncMonitorAssets(t.ID, now); err != nil {
+			return err
+		}
+		if err := m.syncPrinterAssets(t.ID, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MonitorAssetKey identifies a monitor using its manufacturer, model and serial number,
+// monitors without a serial number (reported as "0") can't be tracked
+func MonitorAssetKey(manufacturer, model, serial string) string {
+	serial = strings.TrimSpace(serial)
+	if serial == "" || serial == "0" {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(manufacturer)) + "|" + strings.ToLower(strings.TrimSpace(model)) + "|" + serial
+}
+
+func (m *Model) syncMonitorAssets(tenantID int, now time.Time) error {
+	monitors, err := m.Client.Monitor.Query().WithOwner().
+		Where(monitor.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// If the same monitor is reported by several agents, keep the first one by agent ID
+	sort.SliceStable(monitors, func(i, j int) bool {
+		return monitors[i].Edges.Owner != nil && monitors[j].Edges.Owner != nil && monitors[i].Edges.Owner.ID < monitors[j].Edges.Owner.ID
+	})
+
+	seen := map[string]*ent.Monitor{}
+	for _, mon := range monitors {
+		key := MonitorAssetKey(mon.Manufacturer, mon.Model, mon.Serial)
+		if key == "" || mon.Edges.Owner == nil {
+			continue
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = mon
+		}
+	}
+
+	assets, err := m.Client.PeripheralAsset.Query().Where(peripheralasset.TypeEQ(peripheralasset.TypeMonitor), peripheralasset.HasTenantWith(tenant.ID(tenantID))).All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	registered := map[string]*ent.PeripheralAsset{}
+	for _, a := range assets {
+		registered[MonitorAssetKey(a.Manufacturer, a.Model, a.Serial)] = a
+	}
+
+	for key, mon := range seen {
+		owner := mon.Edges.Owner
+		a, ok := registered[key]
+		if !ok {
+			if err := m.Client.PeripheralAsset.Create().
+				SetType(peripheralasset.TypeMonitor).
+				SetManufacturer(mon.Manufacturer).
+				SetModel(mon.Model).
+				SetSerial(mon.Serial).
+				SetAgentID(owner.ID).
+				SetAgentNickname(owner.Nickname).
+				SetFirstSeen(now).
+				SetLastSeen(now).
+				SetTenantID(tenantID).
+				Exec(context.Background()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		query := m.Client.PeripheralAsset.UpdateOneID(a.ID).SetLastSeen(now).SetMissing(false).SetAgentNickname(owner.Nickname)
+		if a.AgentID != owner.ID {
+			query = query.SetPreviousAgentID(a.AgentID).SetPreviousAgentNickname(a.AgentNickname).SetAgentID(owner.ID).SetMoved(now)
+		}
+		if err := query.Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	for key, a := range registered {
+		if _, ok := seen[key]; ok || a.Missing {
+			continue
+		}
+		if err := m.Client.PeripheralAsset.UpdateOneID(a.ID).SetMissing(true).Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) syncPrinterAssets(tenantID int, now time.Time) error {
+	printers, err := m.Client.Printer.Query().WithOwner().
+		Where(printer.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	// Printers are deduplicated by name, counting the computers where they're installed
+	computers := map[string]map[string]bool{}
+	for _, p := range printers {
+		if p.Name == "" || p.Edges.Owner == nil {
+			continue
+		}
+		if computers[p.Name] == nil {
+			computers[p.Name] = map[string]bool{}
+		}
+		computers[p.Name][p.Edges.Owner.ID] = true
+	}
+
+	assets, err := m.Client.PeripheralAsset.Query().Where(peripheralasset.TypeEQ(peripheralasset.TypePrinter), peripheralasset.HasTenantWith(tenant.ID(tenantID))).All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	registered := map[string]*ent.PeripheralAsset{}
+	for _, a := range assets {
+		registered[a.Name] = a
+	}
+
+	for name, agents := range computers {
+		a, ok := registered[name]
+		if !ok {
+			if err := m.Client.PeripheralAsset.Create().
+				SetType(peripheralasset.TypePrinter).
+				SetName(name).
+				SetComputers(len(agents)).
+				SetFirstSeen(now).
+				SetLastSeen(now).
+				SetTenantID(tenantID).
+				Exec(context.Background()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := m.Client.PeripheralAsset.UpdateOneID(a.ID).SetComputers(len(agents)).SetLastSeen(now).SetMissing(false).Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	for name, a := range registered {
+		if _, ok := computers[name]; ok || a.Missing {
+			continue
+		}
+		if err := m.Client.PeripheralAsset.UpdateOneID(a.ID).SetMissing(true).SetComputers(0).Exec(context.Background()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) GetMonitorAssets(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.PeripheralAsset, error) {
+	return m.getPeripheralAssets(p, c, peripheralasset.TypeEQ(peripheralasset.TypeMonitor))
+}
+
+func (m *Model) CountMonitorAssets(c *partials.CommonInfo) (int, error) {
+	return m.countPeripheralAssets(c, peripheralasset.TypeEQ(peripheralasset.TypeMonitor))
+}
+
+func (m *Model) GetPrinterAssets(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.PeripheralAsset, error) {
+	return m.getPeripheralAssets(p, c, peripheralasset.TypeEQ(peripheralasset.TypePrinter))
+}
+
+func (m *Model) CountPrinterAssets(c *partials.CommonInfo) (int, error) {
+	return m.countPeripheralAssets(c, peripheralasset.TypeEQ(peripheralasset.TypePrinter))
+}
+
+// GetPeripheralChanges returns the peripherals that have moved between computers or disappeared
+func (m *Model) GetPeripheralChanges(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.PeripheralAsset, error) {
+	return m.getPeripheralAssets(p, c, peripheralasset.Or(peripheralasset.MovedNotNil(), peripheralasset.Missing(true)))
+}
+
+func (m *Model) CountPeripheralChanges(c *partials.CommonInfo) (int, error) {
+	return m.countPeripheralAssets(c, peripheralasset.Or(peripheralasset.MovedNotNil(), peripheralasset.Missing(true)))
+}
+
+func (m *Model) getPeripheralAssets(p partials.PaginationAndSort, c *partials.CommonInfo, where predicate.PeripheralAsset) ([]*ent.PeripheralAsset, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	scope, err := m.peripheralSiteScope(c)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.PeripheralAsset.Query().Where(where, scope, peripheralasset.HasTenantWith(tenant.ID(tenantID)))
+	if p.PageSize != 0 {
+		query = query.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+	}
+
+	field := ""
+	switch p.SortBy {
+	case "manufacturer":
+		field = peripheralasset.FieldManufacturer
+	case "model":
+		field = peripheralasset.FieldModel
+	case "serial":
+		field = peripheralasset.FieldSerial
+	case "name":
+		field = peripheralasset.FieldName
+	case "computer":
+		field = peripheralasset.FieldAgentNickname
+	case "computers":
+		field = peripheralasset.FieldComputers
+	case "firstSeen":
+		field = peripheralasset.FieldFirstSeen
+	case "lastSeen":
+		field = peripheralasset.FieldLastSeen
+	}
+
+	if field == "" {
+		query = query.Order(ent.Desc(peripheralasset.FieldLastSeen), ent.Asc(peripheralasset.FieldID))
+	} else if p.SortOrder == "asc" {
+		query = query.Order(ent.Asc(field), ent.Asc(peripheralasset.FieldID))
+	} else {
+		query = query.Order(ent.Desc(field), ent.Asc(peripheralasset.FieldID))
+	}
+
+	return query.All(context.Background())
+}
+
+func (m *Model) countPeripheralAssets(c *partials.CommonInfo, where predicate.PeripheralAsset) (int, error) {
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	scope, err := m.peripheralSiteScope(c)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.Client.PeripheralAsset.Query().Where(where, scope, peripheralasset.HasTenantWith(tenant.ID(tenantID))).Count(context.Background())
+}
+
+// peripheralSiteScope limits the register to the peripherals that are or were connected to a computer of the selected site
+func (m *Model) peripheralSiteScope(c *partials.CommonInfo) (predicate.PeripheralAsset, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == -1 {
+		return peripheralasset.HasTenantWith(tenant.ID(tenantID)), nil
+	}
+
+	agentIDs, err := m.Client.Agent.Query().Where(agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).IDs(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	// Printers are registered by name, so they belong to the site if any of its computers has them installed
+	printerNames, err := m.Client.Printer.Query().Where(printer.HasOwnerWith(agent.IDIn(agentIDs...))).Unique(true).Select(printer.FieldName).Strings(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	return peripheralasset.Or(
+		peripheralasset.And(peripheralasset.TypeEQ(peripheralasset.TypeMonitor), peripheralasset.Or(peripheralasset.AgentIDIn(agentIDs...), peripheralasset.PreviousAgentIDIn(agentIDs...))),
+		peripheralasset.And(peripheralasset.TypeEQ(peripheralasset.TypePrinter), peripheralasset.NameIn(printerNames...)),
+	), nil
+}
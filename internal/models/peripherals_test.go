@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/monitor"
+	"github.com/scncore/ent/printer"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PeripheralsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *PeripheralsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := range 3 {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// agent0 has two monitors with serial and agent1 has one without serial
+	monitors := []struct{ owner, model, serial string }{
+		{"agent0", "model0", "SN0"},
+		{"agent0", "model1", "SN1"},
+		{"agent1", "model2", "0"},
+	}
+	for _, mon := range monitors {
+		err := client.Monitor.Create().
+			SetManufacturer("manufacturer").
+			SetModel(mon.model).
+			SetSerial(mon.serial).
+			SetOwnerID(mon.owner).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create monitor")
+	}
+
+	// printer0 is installed in every agent and printer1 only in agent2
+	for i := range 3 {
+		err := client.Printer.Create().SetName("printer0").SetOwnerID(fmt.Sprintf("agent%d", i)).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create printer")
+	}
+	err = client.Printer.Create().SetName("printer1").SetOwnerID("agent2").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create printer")
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *PeripheralsTestSuite) TestMonitorAssetKey() {
+	assert.Equal(suite.T(), "", MonitorAssetKey("Dell", "U2419H", "0"), "should not track monitors without serial")
+	assert.Equal(suite.T(), "", MonitorAssetKey("Dell", "U2419H", " "), "should not track monitors without serial")
+	assert.Equal(suite.T(), MonitorAssetKey("DELL", "u2419h", "ABC"), MonitorAssetKey("Dell", "U2419H", "ABC"), "should ignore case in manufacturer and model")
+}
+
+func (suite *PeripheralsTestSuite) TestSyncPeripheralAssets() {
+	err := suite.model.SyncPeripheralAssets()
+	assert.NoError(suite.T(), err, "should sync peripherals")
+
+	count, err := suite.model.CountMonitorAssets(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count monitors")
+	assert.Equal(suite.T(), 2, count, "should register only monitors with serial")
+
+	suite.p.SortBy = "name"
+	suite.p.SortOrder = "asc"
+	printers, err := suite.model.GetPrinterAssets(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get printers")
+	assert.Equal(suite.T(), 2, len(printers), "should deduplicate printers")
+	assert.Equal(suite.T(), "printer0", printers[0].Name, "should sort printers by name")
+	assert.Equal(suite.T(), 3, printers[0].Computers, "printer0 should be installed in three computers")
+	assert.Equal(suite.T(), 1, printers[1].Computers, "printer1 should be installed in one computer")
+
+	count, err = suite.model.CountPeripheralChanges(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count changes")
+	assert.Equal(suite.T(), 0, count, "should have no changes yet")
+}
+
+func (suite *PeripheralsTestSuite) TestPeripheralsSiteScope() {
+	tenantID, err := strconv.Atoi(suite.commonInfo.TenantID)
+	assert.NoError(suite.T(), err, "should parse tenant id")
+
+	other, err := suite.model.Client.Site.Create().SetDescription("Other").SetTenantID(tenantID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create site")
+
+	// agent2 is moved to the other site with printer1 and a monitor
+	err = suite.model.Client.Agent.UpdateOneID("agent2").ClearSite().AddSiteIDs(other.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should move agent")
+	err = suite.model.Client.Monitor.Create().SetManufacturer("manufacturer").SetModel("model3").SetSerial("SN3").SetOwnerID("agent2").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create monitor")
+
+	err = suite.model.SyncPeripheralAssets()
+	assert.NoError(suite.T(), err, "should sync peripherals")
+
+	count, err := suite.model.CountMonitorAssets(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count monitors")
+	assert.Equal(suite.T(), 2, count, "the monitor of the other site should be excluded")
+
+	count, err = suite.model.CountPrinterAssets(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count printers")
+	assert.Equal(suite.T(), 1, count, "only printer0 is installed in this site")
+
+	otherSite := &partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: strconv.Itoa(other.ID)}
+	count, err = suite.model.CountPrinterAssets(otherSite)
+	assert.NoError(suite.T(), err, "should count printers")
+	assert.Equal(suite.T(), 2, count, "both printers are installed in the other site")
+
+	allSites := &partials.CommonInfo{TenantID: suite.commonInfo.TenantID, SiteID: "-1"}
+	count, err = suite.model.CountMonitorAssets(allSites)
+	assert.NoError(suite.T(), err, "should count monitors")
+	assert.Equal(suite.T(), 3, count, "every monitor of the tenant should be counted")
+}
+
+func (suite *PeripheralsTestSuite) TestMovedAndMissingPeripherals() {
+	err := suite.model.SyncPeripheralAssets()
+	assert.NoError(suite.T(), err, "should sync peripherals")
+
+	// SN0 moves from agent0 to agent2, SN1 and printer1 disappear
+	_, err = suite.model.Client.Monitor.Delete().Where(monitor.HasOwnerWith(agent.ID("agent0"))).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should delete monitors")
+	err = suite.model.Client.Monitor.Create().SetManufacturer("Manufacturer").SetModel("model0").SetSerial("SN0").SetOwnerID("agent2").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create monitor")
+	_, err = suite.model.Client.Printer.Delete().Where(printer.Name("printer1")).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should delete printer")
+
+	err = suite.model.SyncPeripheralAssets()
+	assert.NoError(suite.T(), err, "should sync peripherals")
+
+	suite.p.SortBy = "serial"
+	suite.p.SortOrder = "asc"
+	monitors, err := suite.model.GetMonitorAssets(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get monitors")
+	assert.Equal(suite.T(), 2, len(monitors), "should keep missing monitors in the register")
+	assert.Equal(suite.T(), "agent2", monitors[0].AgentID, "SN0 should be in agent2")
+	assert.Equal(suite.T(), "agent0", monitors[0].PreviousAgentID, "SN0 should have been in agent0")
+	assert.NotNil(suite.T(), monitors[0].Moved, "SN0 should have been moved")
+	assert.True(suite.T(), monitors[1].Missing, "SN1 should be missing")
+
+	changes, err := suite.model.GetPeripheralChanges(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get changes")
+	assert.Equal(suite.T(), 3, len(changes), "should get moved and missing peripherals")
+
+	// printer1 is installed again
+	err = suite.model.Client.Printer.Create().SetName("printer1").SetOwnerID("agent1").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create printer")
+	err = suite.model.SyncPeripheralAssets()
+	assert.NoError(suite.T(), err, "should sync peripherals")
+
+	count, err := suite.model.CountPeripheralChanges(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count changes")
+	assert.Equal(suite.T(), 2, count, "printer1 should not be missing anymore")
+}
+
+func TestPeripheralsTestSuite(t *testing.T) {
+	suite.Run(t, new(PeripheralsTestSuite))
+}
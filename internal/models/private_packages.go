@@ -0,0 +1,173 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/privatepackage"
+	scnorion_nats "github.com/scncore/nats"
+)
+
+var PrivatePackageFileTypes = []string{"msi", "exe", "deb", "rpm", "pkg"}
+
+// Private packages are stored in the common software database with this prefix so they can be told apart from catalogue packages
+const PrivatePackagePrefix = "private-"
+
+type PrivatePackageInfo struct {
+	Name          string
+	Version       string
+	Publisher     string
+	FileName      string
+	Size          int64
+	Checksum      string
+	SilentArgs    string
+	DetectionRule string
+}
+
+// PrivatePackageAction is sent to the agents instead of a catalogue deploy action,
+// the agent downloads the installer from URL and must check its SHA256 checksum before running it
+type PrivatePackageAction struct {
+	scnorion_nats.DeployAction
+	Version       string `json:"version"`
+	FileType      string `json:"file_type"`
+	URL           string `json:"url"`
+	Checksum      string `json:"checksum"`
+	SilentArgs    string `json:"silent_args"`
+	DetectionRule string `json:"detection_rule"`
+}
+
+// PrivatePackageFileType returns the installer type from the file extension
+func PrivatePackageFileType(fileName string) (string, error) {
+	ext := strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), ".")
+	for _, t := range PrivatePackageFileTypes {
+		if ext == t {
+			return t, nil
+		}
+	}
+	return "", fmt.Errorf("%s is not a supported installer, use one of %s", fileName, strings.Join(PrivatePackageFileTypes, ", "))
+}
+
+// PrivatePackagePlatform returns the operating system family that can run an installer type
+func PrivatePackagePlatform(fileType string) string {
+	switch fileType {
+	case "msi", "exe":
+		return "windows"
+	case "deb", "rpm":
+		return "linux"
+	default:
+		return "macOS"
+	}
+}
+
+func PrivatePackageID(id int) string {
+	return PrivatePackagePrefix + strconv.Itoa(id)
+}
+
+// ParsePrivatePackageID returns false if packageID doesn't belong to a private package
+func ParsePrivatePackageID(packageID string) (int, bool) {
+	if !strings.HasPrefix(packageID, PrivatePackagePrefix) {
+		return 0, false
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(packageID, PrivatePackagePrefix))
+	if err != nil {
+		return 0, false
+	}
+	return id, true
+}
+
+func (m *Model) GetPrivatePackages() ([]*ent.PrivatePackage, error) {
+	return m.Client.PrivatePackage.Query().Order(ent.Asc(privatepackage.FieldName), ent.Desc(privatepackage.FieldCreated)).All(context.Background())
+}
+
+func (m *Model) GetPrivatePackage(id int) (*ent.PrivatePackage, error) {
+	return m.Client.PrivatePackage.Get(context.Background(), id)
+}
+
+func (m *Model) CountPrivatePackages() (int, error) {
+	return m.Client.PrivatePackage.Query().Count(context.Background())
+}
+
+func (m *Model) AddPrivatePackage(info PrivatePackageInfo) (*ent.PrivatePackage, error) {
+	info.Name = strings.TrimSpace(info.Name)
+	info.Version = strings.TrimSpace(info.Version)
+
+	if info.Name == "" || info.Version == "" {
+		return nil, errors.New("the name and the version are required")
+	}
+
+	fileType, err := PrivatePackageFileType(info.FileName)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.Checksum) != 64 {
+		return nil, errors.New("a SHA256 checksum is required")
+	}
+
+	exists, err := m.Client.PrivatePackage.Query().Where(privatepackage.NameEqualFold(info.Name), privatepackage.Version(info.Version), privatepackage.FileType(privatepackage.FileType(fileType))).Exist(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, fmt.Errorf("%s %s has already been uploaded", info.Name, info.Version)
+	}
+
+	return m.Client.PrivatePackage.Create().
+		SetName(info.Name).
+		SetVersion(info.Version).
+		SetPublisher(strings.TrimSpace(info.Publisher)).
+		SetFileName(filepath.Base(info.FileName)).
+		SetFileType(privatepackage.FileType(fileType)).
+		SetSize(info.Size).
+		SetChecksum(strings.ToLower(info.Checksum)).
+		SetSilentArgs(strings.TrimSpace(info.SilentArgs)).
+		SetDetectionRule(strings.TrimSpace(info.DetectionRule)).
+		Save(context.Background())
+}
+
+// DeletePrivatePackage returns true if no other package uses the same installer file
+func (m *Model) DeletePrivatePackage(id int) (*ent.PrivatePackage, bool, error) {
+	p, err := m.GetPrivatePackage(id)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := m.Client.PrivatePackage.DeleteOneID(id).Exec(context.Background()); err != nil {
+		return nil, false, err
+	}
+
+	// Installers are stored by checksum and type, see PrivatePackageFile
+	shared, err := m.Client.PrivatePackage.Query().Where(privatepackage.Checksum(p.Checksum), privatepackage.FileTypeEQ(p.FileType)).Exist(context.Background())
+	if err != nil {
+		return p, false, err
+	}
+
+	return p, !shared, nil
+}
+
+// PrivatePackageFile is the name used to store the installer, files are named by their checksum so the same installer is stored once
+func PrivatePackageFile(p *ent.PrivatePackage) string {
+	return p.Checksum + "." + p.FileType.String()
+}
+
+func NewPrivatePackageAction(agentID, action, url string, p *ent.PrivatePackage) PrivatePackageAction {
+	return PrivatePackageAction{
+		DeployAction: scnorion_nats.DeployAction{
+			AgentId:     agentID,
+			PackageId:   PrivatePackageID(p.ID),
+			PackageName: p.Name,
+			Action:      action,
+		},
+		Version:       p.Version,
+		FileType:      p.FileType.String(),
+		URL:           url,
+		Checksum:      p.Checksum,
+		SilentArgs:    p.SilentArgs,
+		DetectionRule: p.DetectionRule,
+	}
+}
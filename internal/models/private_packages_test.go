@@ -0,0 +1,93 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/scncore/ent/enttest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PrivatePackagesTestSuite struct {
+	suite.Suite
+	t     enttest.TestingT
+	model Model
+}
+
+func (suite *PrivatePackagesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+}
+
+func (suite *PrivatePackagesTestSuite) TestPrivatePackageFileType() {
+	fileType, err := PrivatePackageFileType("Setup.MSI")
+	assert.NoError(suite.T(), err, "should accept msi installers")
+	assert.Equal(suite.T(), "msi", fileType)
+	assert.Equal(suite.T(), "windows", PrivatePackagePlatform(fileType))
+	assert.Equal(suite.T(), "linux", PrivatePackagePlatform("rpm"))
+	assert.Equal(suite.T(), "macOS", PrivatePackagePlatform("pkg"))
+
+	_, err = PrivatePackageFileType("setup.zip")
+	assert.Error(suite.T(), err, "should not accept zip files")
+}
+
+func (suite *PrivatePackagesTestSuite) TestPrivatePackageID() {
+	id, ok := ParsePrivatePackageID(PrivatePackageID(7))
+	assert.True(suite.T(), ok, "should parse private package id")
+	assert.Equal(suite.T(), 7, id)
+
+	_, ok = ParsePrivatePackageID("Google.Chrome")
+	assert.False(suite.T(), ok, "catalogue packages are not private")
+}
+
+func (suite *PrivatePackagesTestSuite) TestAddAndDeletePrivatePackages() {
+	checksum := strings.Repeat("a", 64)
+	info := PrivatePackageInfo{Name: "Acme Agent", Version: "1.2.0", FileName: "acme.msi", Size: 1024, Checksum: checksum, SilentArgs: "/qn"}
+
+	p, err := suite.model.AddPrivatePackage(info)
+	assert.NoError(suite.T(), err, "should add private package")
+	assert.Equal(suite.T(), "msi", p.FileType.String())
+	assert.Equal(suite.T(), checksum+".msi", PrivatePackageFile(p))
+
+	_, err = suite.model.AddPrivatePackage(info)
+	assert.Error(suite.T(), err, "should not add the same version twice")
+
+	info.Version = "1.3.0"
+	info.Checksum = ""
+	_, err = suite.model.AddPrivatePackage(info)
+	assert.Error(suite.T(), err, "should require a checksum")
+
+	info.Checksum = checksum
+	other, err := suite.model.AddPrivatePackage(info)
+	assert.NoError(suite.T(), err, "should add a package with the same installer")
+
+	count, err := suite.model.CountPrivatePackages()
+	assert.NoError(suite.T(), err, "should count private packages")
+	assert.Equal(suite.T(), 2, count)
+
+	action := NewPrivatePackageAction("agent1", "install", "https://console:1244/packages/1", p)
+	assert.Equal(suite.T(), PrivatePackageID(p.ID), action.PackageId)
+	assert.Equal(suite.T(), "/qn", action.SilentArgs)
+
+	info.Version = "1.4.0"
+	info.FileName = "acme.exe"
+	exe, err := suite.model.AddPrivatePackage(info)
+	assert.NoError(suite.T(), err, "should add a package with the same checksum and another type")
+
+	_, unused, err := suite.model.DeletePrivatePackage(exe.ID)
+	assert.NoError(suite.T(), err, "should delete private package")
+	assert.True(suite.T(), unused, "the installer is stored in its own file")
+
+	_, unused, err = suite.model.DeletePrivatePackage(p.ID)
+	assert.NoError(suite.T(), err, "should delete private package")
+	assert.False(suite.T(), unused, "the installer is still used by another package")
+
+	_, unused, err = suite.model.DeletePrivatePackage(other.ID)
+	assert.NoError(suite.T(), err, "should delete private package")
+	assert.True(suite.T(), unused, "the installer can be removed")
+}
+
+func TestPrivatePackagesTestSuite(t *testing.T) {
+	suite.Run(t, new(PrivatePackagesTestSuite))
+}
@@ -5,9 +5,11 @@ import (
 	"strconv"
 
 	"github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
 	"github.com/scncore/ent/profile"
 	"github.com/scncore/ent/profileissue"
 	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tag"
 	"github.com/scncore/ent/tenant"
 	"github.com/scncore/scnorion-console/internal/views/partials"
 )
@@ -81,7 +83,7 @@ func (m *Model) AddProfile(siteID int, description string) (*ent.Profile, error)
 	return profile, nil
 }
 
-func (m *Model) UpdateProfile(profileId int, description string, apply string, c *partials.CommonInfo) error {
+func (m *Model) UpdateProfile(profileId int, description string, apply string, respectWindow bool, c *partials.CommonInfo) error {
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
 		return err
@@ -98,11 +100,11 @@ func (m *Model) UpdateProfile(profileId int, description string, apply string, c
 
 	switch apply {
 	case "applyToAll":
-		return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).ClearTags().SetApplyToAll(true).Exec(context.Background())
+		return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).SetRespectWindow(respectWindow).ClearTags().SetApplyToAll(true).Exec(context.Background())
 	case "useTags":
-		return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).SetApplyToAll(false).Exec(context.Background())
+		return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).SetRespectWindow(respectWindow).SetApplyToAll(false).Exec(context.Background())
 	}
-	return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).ClearTags().SetApplyToAll(false).Exec(context.Background())
+	return m.Client.Profile.Update().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).SetName(description).SetRespectWindow(respectWindow).ClearTags().SetApplyToAll(false).Exec(context.Background())
 }
 
 func (m *Model) GetProfileById(profileId int, c *partials.CommonInfo) (*ent.Profile, error) {
@@ -124,6 +126,30 @@ func (m *Model) GetProfileById(profileId int, c *partials.CommonInfo) (*ent.Prof
 	return m.Client.Profile.Query().WithTags().WithTasks().WithIssues().Where(profile.ID(profileId), profile.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))).First(context.Background())
 }
 
+// GetProfileAgents returns the enabled agents of the site the profile is applied to,
+// all of them or the ones with any of the tags of the profile
+func (m *Model) GetProfileAgents(p *ent.Profile, c *partials.CommonInfo) ([]*ent.Agent, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.Agent.Query().Where(agent.AgentStatusEQ(agent.AgentStatusEnabled), agent.HasSiteWith(site.ID(siteID)))
+
+	if !p.ApplyToAll {
+		tags := []int{}
+		for _, t := range p.Edges.Tags {
+			tags = append(tags, t.ID)
+		}
+		if len(tags) == 0 {
+			return []*ent.Agent{}, nil
+		}
+		query = query.Where(agent.HasTagsWith(tag.IDIn(tags...)))
+	}
+
+	return query.All(context.Background())
+}
+
 func (m *Model) DeleteProfile(profileId int, c *partials.CommonInfo) error {
 	siteID, err := strconv.Atoi(c.SiteID)
 	if err != nil {
@@ -0,0 +1,511 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/rolloutplan"
+	"github.com/scncore/ent/rolloutring"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tag"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+var RolloutRingKinds = []string{"tag", "site", "percentage"}
+
+// Decisions taken with the current ring of a rollout
+const (
+	RolloutWait    = "wait"
+	RolloutPromote = "promote"
+	RolloutPause   = "pause"
+)
+
+type RolloutRingRequest struct {
+	Kind           string
+	Value          int
+	WaitHours      int
+	MinSuccessRate int
+	MaxFailures    int
+	AutoPromote    bool
+}
+
+// RolloutRingProgress is the progress of the computers assigned to a ring of a job, Ring is nil
+// for the computers that didn't match any ring of the plan and are deployed last
+type RolloutRingProgress struct {
+	Index    int
+	Ring     *ent.RolloutRing
+	Progress DeploymentJobProgress
+	Decision string
+	Message  string
+}
+
+func (m *Model) GetRolloutPlans(tenantID int) ([]*ent.RolloutPlan, error) {
+	return m.Client.RolloutPlan.Query().
+		Where(rolloutplan.HasTenantWith(tenant.ID(tenantID))).
+		WithRings(func(q *ent.RolloutRingQuery) { q.Order(ent.Asc(rolloutring.FieldPosition)) }).
+		Order(ent.Asc(rolloutplan.FieldName)).
+		All(context.Background())
+}
+
+func (m *Model) GetRolloutPlan(tenantID int, planID int) (*ent.RolloutPlan, error) {
+	return m.Client.RolloutPlan.Query().
+		Where(rolloutplan.ID(planID), rolloutplan.HasTenantWith(tenant.ID(tenantID))).
+		WithRings(func(q *ent.RolloutRingQuery) { q.Order(ent.Asc(rolloutring.FieldPosition)) }).
+		Only(context.Background())
+}
+
+func (m *Model) AddRolloutPlan(tenantID int, name, description string) error {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return errors.New("the plan name cannot be empty")
+	}
+
+	exists, err := m.Client.RolloutPlan.Query().Where(rolloutplan.Name(name), rolloutplan.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if exists {
+		return errors.New("a plan with that name already exists")
+	}
+
+	return m.Client.RolloutPlan.Create().
+		SetName(name).
+		SetDescription(strings.TrimSpace(description)).
+		SetCreated(time.Now()).
+		SetTenantID(tenantID).
+		Exec(context.Background())
+}
+
+// rolloutPlanInUse tells if a job is still deploying with the plan, its rings can't change
+// while that happens as the targets were assigned to them by position
+func (m *Model) rolloutPlanInUse(planID int) (bool, error) {
+	return m.Client.DeploymentJob.Query().
+		Where(deploymentjob.HasPlanWith(rolloutplan.ID(planID)), deploymentjob.StatusIn(deploymentjob.StatusRunning, deploymentjob.StatusPaused)).
+		Exist(context.Background())
+}
+
+func (m *Model) DeleteRolloutPlan(tenantID int, planID int) error {
+	plan, err := m.GetRolloutPlan(tenantID, planID)
+	if err != nil {
+		return err
+	}
+
+	inUse, err := m.rolloutPlanInUse(plan.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.New("the plan is used by a deployment that hasn't finished")
+	}
+
+	if _, err := m.Client.RolloutRing.Delete().Where(rolloutring.HasPlanWith(rolloutplan.ID(plan.ID))).Exec(context.Background()); err != nil {
+		return err
+	}
+
+	return m.Client.RolloutPlan.DeleteOneID(plan.ID).Exec(context.Background())
+}
+
+func (m *Model) AddRolloutRing(tenantID int, planID int, r RolloutRingRequest) error {
+	plan, err := m.GetRolloutPlan(tenantID, planID)
+	if err != nil {
+		return err
+	}
+
+	label, err := m.validateRolloutRing(tenantID, r)
+	if err != nil {
+		return err
+	}
+
+	inUse, err := m.rolloutPlanInUse(plan.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.New("the plan is used by a deployment that hasn't finished")
+	}
+
+	// New rings are deployed last
+	position := 0
+	if len(plan.Edges.Rings) > 0 {
+		position = plan.Edges.Rings[len(plan.Edges.Rings)-1].Position + 1
+	}
+
+	return m.Client.RolloutRing.Create().
+		SetPosition(position).
+		SetKind(rolloutring.Kind(r.Kind)).
+		SetValue(r.Value).
+		SetLabel(label).
+		SetWaitHours(r.WaitHours).
+		SetMinSuccessRate(r.MinSuccessRate).
+		SetMaxFailures(r.MaxFailures).
+		SetAutoPromote(r.AutoPromote).
+		SetPlanID(plan.ID).
+		Exec(context.Background())
+}
+
+// validateRolloutRing checks the ring and returns the label that describes who is in it,
+// the label is kept so the ring can be shown even if the tag or the site are renamed
+func (m *Model) validateRolloutRing(tenantID int, r RolloutRingRequest) (string, error) {
+	label := ""
+
+	switch r.Kind {
+	case "tag":
+		t, err := m.Client.Tag.Query().Where(tag.ID(r.Value), tag.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return "", errors.New("the tag doesn't belong to this tenant")
+			}
+			return "", err
+		}
+		label = t.Tag
+	case "site":
+		s, err := m.Client.Site.Query().Where(site.ID(r.Value), site.HasTenantWith(tenant.ID(tenantID))).Only(context.Background())
+		if err != nil {
+			if ent.IsNotFound(err) {
+				return "", errors.New("the site doesn't belong to this tenant")
+			}
+			return "", err
+		}
+		label = s.Description
+	case "percentage":
+		if r.Value < 1 || r.Value > 100 {
+			return "", errors.New("the percentage must be between 1 and 100")
+		}
+		label = fmt.Sprintf("%d%%", r.Value)
+	default:
+		return "", errors.New("the ring type is not valid")
+	}
+
+	if r.WaitHours < 0 || r.MaxFailures < 0 {
+		return "", errors.New("the wait hours and the maximum failures cannot be negative")
+	}
+
+	if r.MinSuccessRate < 0 || r.MinSuccessRate > 100 {
+		return "", errors.New("the success rate must be between 0 and 100")
+	}
+
+	return label, nil
+}
+
+func (m *Model) DeleteRolloutRing(tenantID int, ringID int) error {
+	ring, err := m.Client.RolloutRing.Query().
+		Where(rolloutring.ID(ringID), rolloutring.HasPlanWith(rolloutplan.HasTenantWith(tenant.ID(tenantID)))).
+		WithPlan().
+		Only(context.Background())
+	if err != nil {
+		return err
+	}
+
+	inUse, err := m.rolloutPlanInUse(ring.Edges.Plan.ID)
+	if err != nil {
+		return err
+	}
+	if inUse {
+		return errors.New("the plan is used by a deployment that hasn't finished")
+	}
+
+	return m.Client.RolloutRing.DeleteOneID(ring.ID).Exec(context.Background())
+}
+
+// AssignRolloutRings returns the index of the ring every agent belongs to. Agents are assigned to the
+// first ring they match, a percentage ring takes that share of the whole selection from the agents
+// that are still unassigned. The agents that match no ring go to an extra ring after the last one
+func AssignRolloutRings(rings []*ent.RolloutRing, agents []*ent.Agent) map[string]int {
+	assigned := map[string]int{}
+
+	sorted := slices.Clone(agents)
+	slices.SortFunc(sorted, func(a, b *ent.Agent) int { return strings.Compare(a.ID, b.ID) })
+
+	for i, r := range rings {
+		switch r.Kind {
+		case rolloutring.KindTag:
+			for _, a := range sorted {
+				if _, ok := assigned[a.ID]; ok {
+					continue
+				}
+				if slices.ContainsFunc(a.Edges.Tags, func(t *ent.Tag) bool { return t.ID == r.Value }) {
+					assigned[a.ID] = i
+				}
+			}
+		case rolloutring.KindSite:
+			for _, a := range sorted {
+				if _, ok := assigned[a.ID]; ok {
+					continue
+				}
+				if slices.ContainsFunc(a.Edges.Site, func(s *ent.Site) bool { return s.ID == r.Value }) {
+					assigned[a.ID] = i
+				}
+			}
+		case rolloutring.KindPercentage:
+			// Round up so a small selection still gets a computer in the ring
+			n := (len(sorted)*r.Value + 99) / 100
+			for _, a := range sorted {
+				if n == 0 {
+					break
+				}
+				if _, ok := assigned[a.ID]; ok {
+					continue
+				}
+				assigned[a.ID] = i
+				n--
+			}
+		}
+	}
+
+	for _, a := range sorted {
+		if _, ok := assigned[a.ID]; !ok {
+			assigned[a.ID] = len(rings)
+		}
+	}
+
+	return assigned
+}
+
+// CheckRolloutRing decides if the ring can be promoted, the deployment must be paused
+// or we have to keep waiting, started is the time when the ring was sent
+func CheckRolloutRing(r *ent.RolloutRing, p DeploymentJobProgress, started time.Time, now time.Time) (string, string) {
+	// There's nothing to wait for in an empty ring
+	if p.Total == 0 {
+		return RolloutPromote, ""
+	}
+
+	failures := p.Failed + p.TimedOut
+	if failures > r.MaxFailures {
+		return RolloutPause, fmt.Sprintf("ring %d has %d failures, the limit is %d", r.Position+1, failures, r.MaxFailures)
+	}
+
+	successRate := p.Percent(p.Succeeded)
+	if p.Finished() == p.Total && successRate < r.MinSuccessRate {
+		return RolloutPause, fmt.Sprintf("ring %d has a success rate of %d%%, at least %d%% is required", r.Position+1, successRate, r.MinSuccessRate)
+	}
+
+	if now.Before(started.Add(time.Duration(r.WaitHours) * time.Hour)) {
+		return RolloutWait, ""
+	}
+
+	if successRate < r.MinSuccessRate {
+		return RolloutWait, ""
+	}
+
+	return RolloutPromote, ""
+}
+
+// GetRolloutRingsProgress splits the targets of the job by ring, the decision is only taken for the current ring
+func GetRolloutRingsProgress(job *ent.DeploymentJob, now time.Time) []RolloutRingProgress {
+	rings := []*ent.RolloutRing{}
+	if job.Edges.Plan != nil {
+		rings = job.Edges.Plan.Edges.Rings
+	}
+
+	last := len(rings) - 1
+	for _, t := range job.Edges.Targets {
+		last = max(last, t.Ring)
+	}
+
+	progress := []RolloutRingProgress{}
+	for i := 0; i <= last; i++ {
+		rp := RolloutRingProgress{Index: i}
+		if i < len(rings) {
+			rp.Ring = rings[i]
+		}
+
+		targets := []*ent.DeploymentJobTarget{}
+		for _, t := range job.Edges.Targets {
+			if t.Ring == i {
+				targets = append(targets, t)
+			}
+		}
+		rp.Progress = GetDeploymentJobProgress(targets)
+
+		if i == job.CurrentRing && rp.Ring != nil && i < last {
+			rp.Decision, rp.Message = CheckRolloutRing(rp.Ring, withoutAcceptedFailures(rp.Progress, job.AcceptedFailures), getRingStarted(job), now)
+		}
+
+		progress = append(progress, rp)
+	}
+
+	return progress
+}
+
+// withoutAcceptedFailures leaves out the failures of the current ring that the operator accepted
+// when the rollout was resumed, so only new failures can pause it again
+func withoutAcceptedFailures(p DeploymentJobProgress, accepted int) DeploymentJobProgress {
+	failed := min(p.Failed, accepted)
+	timedOut := min(p.TimedOut, accepted-failed)
+	p.Failed -= failed
+	p.TimedOut -= timedOut
+	p.Total -= failed + timedOut
+	return p
+}
+
+func getRingStarted(job *ent.DeploymentJob) time.Time {
+	if job.RingStarted.IsZero() {
+		return job.Start
+	}
+	return job.RingStarted
+}
+
+// EvaluateRollouts marks the running jobs as completed once all their computers have finished,
+// and for rollouts decides if the current ring is promoted or the deployment is paused
+func (m *Model) EvaluateRollouts() error {
+	jobs, err := m.Client.DeploymentJob.Query().
+		Where(deploymentjob.StatusEQ(deploymentjob.StatusRunning)).
+		WithTargets().
+		WithPlan(func(q *ent.RolloutPlanQuery) {
+			q.WithRings(func(q *ent.RolloutRingQuery) { q.Order(ent.Asc(rolloutring.FieldPosition)) })
+		}).
+		All(context.Background())
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, job := range jobs {
+		progress := GetDeploymentJobProgress(job.Edges.Targets)
+		if progress.Total > 0 && progress.Finished() == progress.Total {
+			if err := m.Client.DeploymentJob.UpdateOneID(job.ID).SetStatus(deploymentjob.StatusCompleted).SetStatusMessage("").Exec(context.Background()); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if job.Edges.Plan == nil {
+			continue
+		}
+
+		for _, rp := range GetRolloutRingsProgress(job, now) {
+			if rp.Index != job.CurrentRing {
+				continue
+			}
+
+			switch rp.Decision {
+			case RolloutPromote:
+				if rp.Ring.AutoPromote || rp.Progress.Total == 0 {
+					if err := m.promoteDeploymentJob(job.ID, job.CurrentRing); err != nil {
+						return err
+					}
+				}
+			case RolloutPause:
+				if err := m.Client.DeploymentJob.UpdateOneID(job.ID).SetStatus(deploymentjob.StatusPaused).SetStatusMessage(rp.Message).Exec(context.Background()); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Model) promoteDeploymentJob(id int, currentRing int) error {
+	return m.Client.DeploymentJob.UpdateOneID(id).
+		SetCurrentRing(currentRing + 1).
+		SetRingStarted(time.Now()).
+		SetAcceptedFailures(0).
+		SetStatus(deploymentjob.StatusRunning).
+		SetStatusMessage("").
+		Exec(context.Background())
+}
+
+// PromoteDeploymentJob sends the next ring of a rollout without waiting for the criteria of the current one
+func (m *Model) PromoteDeploymentJob(id int, c *partials.CommonInfo) error {
+	job, err := m.GetDeploymentJob(id, c)
+	if err != nil {
+		return err
+	}
+
+	if job.Status != deploymentjob.StatusRunning && job.Status != deploymentjob.StatusPaused {
+		return errors.New("the deployment has already finished")
+	}
+
+	// The targets of the job are filtered by the site selected, the last ring is the one of all its targets
+	targets, err := m.getAllDeploymentJobTargets(job.ID)
+	if err != nil {
+		return err
+	}
+
+	last := 0
+	for _, t := range targets {
+		last = max(last, t.Ring)
+	}
+	if job.CurrentRing >= last {
+		return errors.New("the deployment has no more rings")
+	}
+
+	return m.promoteDeploymentJob(job.ID, job.CurrentRing)
+}
+
+// PauseDeploymentJob stops sending the queued computers of a job, the ones already sent keep going
+func (m *Model) PauseDeploymentJob(id int, c *partials.CommonInfo) error {
+	return m.setDeploymentJobStatus(id, deploymentjob.StatusRunning, deploymentjob.StatusPaused, "the deployment has been paused", c)
+}
+
+// ResumeDeploymentJob sends the queued computers again, the failures of the current ring are accepted
+// so a rollout paused by them is only paused again if there are new failures
+func (m *Model) ResumeDeploymentJob(id int, c *partials.CommonInfo) error {
+	job, err := m.GetDeploymentJob(id, c)
+	if err != nil {
+		return err
+	}
+
+	if job.Status != deploymentjob.StatusPaused {
+		return fmt.Errorf("the deployment is %s", job.Status)
+	}
+
+	targets, err := m.getAllDeploymentJobTargets(job.ID)
+	if err != nil {
+		return err
+	}
+
+	ring := []*ent.DeploymentJobTarget{}
+	for _, t := range targets {
+		if t.Ring == job.CurrentRing {
+			ring = append(ring, t)
+		}
+	}
+	p := GetDeploymentJobProgress(ring)
+
+	return m.Client.DeploymentJob.UpdateOneID(job.ID).
+		SetStatus(deploymentjob.StatusRunning).
+		SetStatusMessage("").
+		SetAcceptedFailures(p.Failed + p.TimedOut).
+		Exec(context.Background())
+}
+
+func (m *Model) getAllDeploymentJobTargets(jobID int) ([]*ent.DeploymentJobTarget, error) {
+	return m.Client.DeploymentJobTarget.Query().Where(deploymentjobtarget.HasJobWith(deploymentjob.ID(jobID))).All(context.Background())
+}
+
+// AbortDeploymentJob cancels a job, its queued computers won't be sent anymore
+func (m *Model) AbortDeploymentJob(id int, c *partials.CommonInfo) error {
+	job, err := m.GetDeploymentJob(id, c)
+	if err != nil {
+		return err
+	}
+
+	if job.Status != deploymentjob.StatusRunning && job.Status != deploymentjob.StatusPaused && job.Status != deploymentjob.StatusPending {
+		return errors.New("the deployment has already finished")
+	}
+
+	return m.Client.DeploymentJob.UpdateOneID(job.ID).SetStatus(deploymentjob.StatusAborted).SetStatusMessage("the deployment has been aborted").Exec(context.Background())
+}
+
+func (m *Model) setDeploymentJobStatus(id int, from, to deploymentjob.Status, message string, c *partials.CommonInfo) error {
+	job, err := m.GetDeploymentJob(id, c)
+	if err != nil {
+		return err
+	}
+
+	if job.Status != from {
+		return fmt.Errorf("the deployment is %s", job.Status)
+	}
+
+	return m.Client.DeploymentJob.UpdateOneID(job.ID).SetStatus(to).SetStatusMessage(message).Exec(context.Background())
+}
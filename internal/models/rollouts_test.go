@@ -0,0 +1,287 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/deploymentjob"
+	"github.com/scncore/ent/deploymentjobtarget"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/rolloutring"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RolloutsTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	tagID      int
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *RolloutsTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	pilot, err := client.Tag.Create().SetTag("pilot").SetDescription("pilot").SetColor("#ff0000").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+	suite.tagID = pilot.ID
+
+	for i := 0; i < 10; i++ {
+		query := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID)
+		if i == 9 {
+			query.AddTagIDs(pilot.ID)
+		}
+		err := query.Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+}
+
+func (suite *RolloutsTestSuite) createPlan() *ent.RolloutPlan {
+	err := suite.model.AddRolloutPlan(suite.tenantID, "Pilot first", "")
+	assert.NoError(suite.T(), err, "should add rollout plan")
+
+	plans, err := suite.model.GetRolloutPlans(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get rollout plans")
+	assert.Equal(suite.T(), 1, len(plans))
+
+	err = suite.model.AddRolloutRing(suite.tenantID, plans[0].ID, RolloutRingRequest{Kind: "tag", Value: suite.tagID, WaitHours: 0, MinSuccessRate: 100, MaxFailures: 0, AutoPromote: true})
+	assert.NoError(suite.T(), err, "should add tag ring")
+
+	err = suite.model.AddRolloutRing(suite.tenantID, plans[0].ID, RolloutRingRequest{Kind: "percentage", Value: 20, WaitHours: 4, MinSuccessRate: 90, MaxFailures: 1})
+	assert.NoError(suite.T(), err, "should add percentage ring")
+
+	plan, err := suite.model.GetRolloutPlan(suite.tenantID, plans[0].ID)
+	assert.NoError(suite.T(), err, "should get rollout plan")
+	return plan
+}
+
+func (suite *RolloutsTestSuite) TestRolloutPlans() {
+	err := suite.model.AddRolloutPlan(suite.tenantID, " ", "")
+	assert.Error(suite.T(), err, "should require a name")
+
+	plan := suite.createPlan()
+	assert.Equal(suite.T(), 2, len(plan.Edges.Rings))
+	assert.Equal(suite.T(), rolloutring.KindTag, plan.Edges.Rings[0].Kind)
+	assert.Equal(suite.T(), 1, plan.Edges.Rings[1].Position)
+
+	err = suite.model.AddRolloutPlan(suite.tenantID, "Pilot first", "")
+	assert.Error(suite.T(), err, "should reject duplicated names")
+
+	err = suite.model.AddRolloutRing(suite.tenantID, plan.ID, RolloutRingRequest{Kind: "percentage", Value: 120})
+	assert.Error(suite.T(), err, "should reject percentages over 100")
+
+	err = suite.model.AddRolloutRing(suite.tenantID, plan.ID, RolloutRingRequest{Kind: "site", Value: 9999})
+	assert.Error(suite.T(), err, "should reject sites from other tenants")
+
+	_, err = suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: []string{"agent0"}, PlanID: plan.ID}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	err = suite.model.DeleteRolloutRing(suite.tenantID, plan.Edges.Rings[0].ID)
+	assert.Error(suite.T(), err, "rings can't be removed while a job uses the plan")
+
+	err = suite.model.Client.DeploymentJob.Update().SetStatus(deploymentjob.StatusCompleted).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should complete jobs")
+
+	err = suite.model.DeleteRolloutPlan(suite.tenantID, plan.ID)
+	assert.NoError(suite.T(), err, "should delete rollout plan")
+}
+
+func (suite *RolloutsTestSuite) TestAssignRolloutRings() {
+	plan := suite.createPlan()
+
+	agents, err := suite.model.Client.Agent.Query().WithTags().WithSite().All(context.Background())
+	assert.NoError(suite.T(), err, "should get agents")
+
+	rings := AssignRolloutRings(plan.Edges.Rings, agents)
+	assert.Equal(suite.T(), 10, len(rings))
+	assert.Equal(suite.T(), 0, rings["agent9"], "tagged agents go to the first ring")
+	assert.Equal(suite.T(), 1, rings["agent0"])
+	assert.Equal(suite.T(), 1, rings["agent1"], "20% of 10 agents are two agents")
+	assert.Equal(suite.T(), 2, rings["agent2"], "the rest go to an extra ring")
+}
+
+func (suite *RolloutsTestSuite) TestCheckRolloutRing() {
+	ring := &ent.RolloutRing{WaitHours: 4, MinSuccessRate: 90, MaxFailures: 1}
+	started := time.Now().Add(-1 * time.Hour)
+
+	decision, _ := CheckRolloutRing(ring, DeploymentJobProgress{}, started, time.Now())
+	assert.Equal(suite.T(), RolloutPromote, decision, "empty rings are skipped")
+
+	decision, _ = CheckRolloutRing(ring, DeploymentJobProgress{Total: 10, Succeeded: 10}, started, time.Now())
+	assert.Equal(suite.T(), RolloutWait, decision, "the ring must wait four hours")
+
+	decision, _ = CheckRolloutRing(ring, DeploymentJobProgress{Total: 10, Succeeded: 10}, started, time.Now().Add(4*time.Hour))
+	assert.Equal(suite.T(), RolloutPromote, decision)
+
+	decision, message := CheckRolloutRing(ring, DeploymentJobProgress{Total: 10, Succeeded: 8, Failed: 1, TimedOut: 1}, started, time.Now())
+	assert.Equal(suite.T(), RolloutPause, decision, "two failures are over the limit")
+	assert.NotEmpty(suite.T(), message, "pauses should have a message")
+
+	decision, _ = CheckRolloutRing(&ent.RolloutRing{MinSuccessRate: 90, MaxFailures: 5}, DeploymentJobProgress{Total: 10, Succeeded: 8, Failed: 2}, started, time.Now())
+	assert.Equal(suite.T(), RolloutPause, decision, "the ring has finished below the success rate")
+}
+
+func (suite *RolloutsTestSuite) TestEvaluateRollouts() {
+	plan := suite.createPlan()
+
+	agents := []string{}
+	for i := 0; i < 10; i++ {
+		agents = append(agents, "agent"+strconv.Itoa(i))
+	}
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: agents, PlanID: plan.ID}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	due, err := suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 1, len(due), "only the first ring is due")
+	assert.Equal(suite.T(), "agent9", due[0].Edges.Agent.ID)
+
+	err = suite.model.Client.DeploymentJobTarget.UpdateOneID(due[0].ID).SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set target as succeeded")
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 2, len(due), "the first ring is promoted automatically")
+
+	for _, t := range due {
+		err = suite.model.Client.DeploymentJobTarget.UpdateOneID(t.ID).SetStatus(deploymentjobtarget.StatusFailed).Exec(context.Background())
+		assert.NoError(suite.T(), err, "should set target as failed")
+	}
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusPaused, job.Status, "the second ring has too many failures")
+	assert.NotEmpty(suite.T(), job.StatusMessage)
+
+	progress := GetRolloutRingsProgress(job, time.Now())
+	assert.Equal(suite.T(), 3, len(progress))
+	assert.Equal(suite.T(), 2, progress[1].Progress.Failed)
+	assert.Nil(suite.T(), progress[2].Ring, "the last ring has the computers that matched no ring")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 0, len(due), "paused jobs are not sent")
+
+	err = suite.model.PromoteDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should promote the job manually")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 7, len(due))
+
+	err = suite.model.PromoteDeploymentJob(job.ID, suite.commonInfo)
+	assert.Error(suite.T(), err, "there are no more rings")
+
+	err = suite.model.AbortDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should abort the job")
+
+	due, err = suite.model.GetDueDeploymentTargets()
+	assert.NoError(suite.T(), err, "should get due targets")
+	assert.Equal(suite.T(), 0, len(due), "aborted jobs are not sent")
+
+	err = suite.model.ResumeDeploymentJob(job.ID, suite.commonInfo)
+	assert.Error(suite.T(), err, "aborted jobs can't be resumed")
+}
+
+func (suite *RolloutsTestSuite) TestResumeRollout() {
+	plan := suite.createPlan()
+
+	agents := []string{}
+	for i := 0; i < 10; i++ {
+		agents = append(agents, "agent"+strconv.Itoa(i))
+	}
+
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: agents, PlanID: plan.ID}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.Ring(0)).SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set target as succeeded")
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().Where(deploymentjobtarget.Ring(1)).SetStatus(deploymentjobtarget.StatusFailed).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set targets as failed")
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusPaused, job.Status, "the second ring has too many failures")
+
+	err = suite.model.ResumeDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should resume the job")
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusRunning, job.Status, "the failures accepted when the job was resumed don't pause it again")
+	assert.Equal(suite.T(), 1, job.CurrentRing, "the ring must be promoted manually")
+	assert.Equal(suite.T(), 2, job.AcceptedFailures)
+}
+
+func (suite *RolloutsTestSuite) TestWithoutAcceptedFailures() {
+	p := withoutAcceptedFailures(DeploymentJobProgress{Total: 10, Succeeded: 6, Failed: 2, TimedOut: 2}, 3)
+	assert.Equal(suite.T(), DeploymentJobProgress{Total: 7, Succeeded: 6, Failed: 0, TimedOut: 1}, p)
+
+	p = withoutAcceptedFailures(DeploymentJobProgress{Total: 10, Succeeded: 8, Failed: 2}, 0)
+	assert.Equal(suite.T(), DeploymentJobProgress{Total: 10, Succeeded: 8, Failed: 2}, p, "nothing is left out if no failure was accepted")
+}
+
+func (suite *RolloutsTestSuite) TestCompleteDeploymentJob() {
+	job, err := suite.model.CreateDeploymentJob(DeploymentJobRequest{PackageID: "Mozilla.Firefox", Action: "install", Agents: []string{"agent0"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should create deployment job")
+
+	err = suite.model.PauseDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should pause the job")
+
+	err = suite.model.ResumeDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should resume the job")
+
+	err = suite.model.Client.DeploymentJobTarget.Update().SetStatus(deploymentjobtarget.StatusSucceeded).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should set targets as succeeded")
+
+	err = suite.model.EvaluateRollouts()
+	assert.NoError(suite.T(), err, "should evaluate rollouts")
+
+	job, err = suite.model.GetDeploymentJob(job.ID, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get deployment job")
+	assert.Equal(suite.T(), deploymentjob.StatusCompleted, job.Status)
+}
+
+func TestRolloutsTestSuite(t *testing.T) {
+	suite.Run(t, new(RolloutsTestSuite))
+}
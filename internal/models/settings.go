@@ -2,7 +2,9 @@ package models
 
 import (
 	"context"
+	"net/mail"
 	"strconv"
+	"strings"
 
 	scnorion_ent "github.com/scncore/ent"
 	"github.com/scncore/ent/settings"
@@ -543,6 +545,9 @@ func (m *Model) CloneGlobalSettings(tenantID int) error {
 		SetUseBrew(s.UseBrew).
 		SetUseWinget(s.UseWinget).
 		SetUserCertYearsValid(s.UserCertYearsValid).
+		SetDiskUsageThreshold(s.DiskUsageThreshold).
+		SetDiskFreeSpaceThreshold(s.DiskFreeSpaceThreshold).
+		SetDiskAlertEmail(s.DiskAlertEmail).
 		SetTenantID(tenantID)
 
 	if s.Edges.Tag != nil {
@@ -584,6 +589,9 @@ func (m *Model) ApplyGlobalSettings(tenantID int) error {
 		SetUseBrew(s.UseBrew).
 		SetUseWinget(s.UseWinget).
 		SetUserCertYearsValid(s.UserCertYearsValid).
+		SetDiskUsageThreshold(s.DiskUsageThreshold).
+		SetDiskFreeSpaceThreshold(s.DiskFreeSpaceThreshold).
+		SetDiskAlertEmail(s.DiskAlertEmail).
 		SetTenantID(tenantID)
 
 	query = query.ClearTag()
@@ -594,3 +602,66 @@ func (m *Model) ApplyGlobalSettings(tenantID int) error {
 
 	return query.Exec(context.Background())
 }
+
+func (m *Model) GetDefaultDiskThresholds(tenantID string) (int, int, error) {
+	var err error
+	var s *scnorion_ent.Settings
+
+	if tenantID == "-1" {
+		s, err = m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Select(settings.FieldDiskUsageThreshold, settings.FieldDiskFreeSpaceThreshold).Only(context.Background())
+		if err != nil {
+			return 0, 0, err
+		}
+	} else {
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		s, err = m.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(id))).Select(settings.FieldDiskUsageThreshold, settings.FieldDiskFreeSpaceThreshold).Only(context.Background())
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return s.DiskUsageThreshold, s.DiskFreeSpaceThreshold, nil
+}
+
+func (m *Model) UpdateDiskThresholds(settingsId, usageThreshold, freeSpaceThreshold int) error {
+	return m.Client.Settings.UpdateOneID(settingsId).SetDiskUsageThreshold(usageThreshold).SetDiskFreeSpaceThreshold(freeSpaceThreshold).Exec(context.Background())
+}
+
+func (m *Model) GetDiskAlertEmail(tenantID string) (string, error) {
+	var err error
+	var s *scnorion_ent.Settings
+
+	if tenantID == "-1" {
+		s, err = m.Client.Settings.Query().Where(settings.Not(settings.HasTenant())).Select(settings.FieldDiskAlertEmail).Only(context.Background())
+		if err != nil {
+			return "", err
+		}
+	} else {
+		id, err := strconv.Atoi(tenantID)
+		if err != nil {
+			return "", err
+		}
+
+		s, err = m.Client.Settings.Query().Where(settings.HasTenantWith(tenant.ID(id))).Select(settings.FieldDiskAlertEmail).Only(context.Background())
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return s.DiskAlertEmail, nil
+}
+
+// UpdateDiskAlertEmail sets who is warned when volumes exceed the thresholds, an empty email disables the alerts
+func (m *Model) UpdateDiskAlertEmail(settingsId int, email string) error {
+	email = strings.TrimSpace(email)
+	if email != "" {
+		if _, err := mail.ParseAddress(email); err != nil {
+			return err
+		}
+	}
+	return m.Client.Settings.UpdateOneID(settingsId).SetDiskAlertEmail(email).Exec(context.Background())
+}
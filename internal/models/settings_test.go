@@ -216,6 +216,17 @@ func (suite *SettingsTestSuite) TestCreateInitialSettings() {
 	assert.Equal(suite.T(), true, settings.RequestVncPin, "default request vnc pin should be true")
 }
 
+func (suite *SettingsTestSuite) TestUpdateDiskThresholds() {
+	err := suite.model.UpdateDiskThresholds(suite.settingsId, 85, 20)
+	assert.NoError(suite.T(), err, "should update disk thresholds")
+
+	usageThreshold, freeSpaceThreshold, err := suite.model.GetDefaultDiskThresholds("-1")
+	assert.NoError(suite.T(), err, "should get disk thresholds")
+
+	assert.Equal(suite.T(), 85, usageThreshold, "usage threshold should be 85")
+	assert.Equal(suite.T(), 20, freeSpaceThreshold, "free space threshold should be 20")
+}
+
 func TestSettingsTestSuite(t *testing.T) {
 	suite.Run(t, new(SettingsTestSuite))
 }
@@ -0,0 +1,282 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/predicate"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/siterule"
+	"github.com/scncore/ent/tag"
+	"github.com/scncore/ent/tenant"
+)
+
+var SiteRuleTypes = []string{"cidr", "domain", "hostname", "tag"}
+
+type SiteRuleMove struct {
+	AgentID     string
+	Nickname    string
+	CurrentSite string
+	NewSite     string
+	NewSiteID   int
+	RuleID      int
+}
+
+func (m *Model) GetSiteRules(tenantID int) ([]*ent.SiteRule, error) {
+	return m.Client.SiteRule.Query().WithSite().Where(siterule.HasTenantWith(tenant.ID(tenantID))).Order(ent.Asc(siterule.FieldPriority)).All(context.Background())
+}
+
+func (m *Model) AddSiteRule(tenantID int, siteID int, ruleType string, value string) error {
+	value = strings.TrimSpace(value)
+
+	if err := m.validateSiteRule(tenantID, ruleType, value); err != nil {
+		return err
+	}
+
+	exists, err := m.Client.Site.Query().Where(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errors.New("the site doesn't belong to this tenant")
+	}
+
+	// New rules are evaluated last
+	priority := 0
+	last, err := m.Client.SiteRule.Query().Where(siterule.HasTenantWith(tenant.ID(tenantID))).Order(ent.Desc(siterule.FieldPriority)).First(context.Background())
+	if err != nil && !ent.IsNotFound(err) {
+		return err
+	}
+	if last != nil {
+		priority = last.Priority + 1
+	}
+
+	return m.Client.SiteRule.Create().
+		SetType(siterule.Type(ruleType)).
+		SetValue(value).
+		SetPriority(priority).
+		SetTenantID(tenantID).
+		SetSiteID(siteID).
+		Exec(context.Background())
+}
+
+func (m *Model) validateSiteRule(tenantID int, ruleType string, value string) error {
+	if value == "" {
+		return errors.New("the rule value cannot be empty")
+	}
+
+	switch ruleType {
+	case "cidr":
+		if _, _, err := net.ParseCIDR(value); err != nil {
+			return err
+		}
+	case "hostname":
+		if _, err := path.Match(value, ""); err != nil {
+			return err
+		}
+	case "domain":
+	case "tag":
+		tagID, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		exists, err := m.Client.Tag.Query().Where(tag.ID(tagID), tag.HasTenantWith(tenant.ID(tenantID))).Exist(context.Background())
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return errors.New("the tag doesn't belong to this tenant")
+		}
+	default:
+		return errors.New("the rule type is not valid")
+	}
+
+	return nil
+}
+
+func (m *Model) DeleteSiteRule(tenantID int, ruleID int) error {
+	_, err := m.Client.SiteRule.Delete().Where(siterule.ID(ruleID), siterule.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
+	return err
+}
+
+// MoveSiteRule swaps the priority of a rule with the previous or the next one
+func (m *Model) MoveSiteRule(tenantID int, ruleID int, up bool) error {
+	rules, err := m.GetSiteRules(tenantID)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range rules {
+		if r.ID != ruleID {
+			continue
+		}
+
+		j := i + 1
+		if up {
+			j = i - 1
+		}
+		if j < 0 || j >= len(rules) {
+			return nil
+		}
+
+		if err := m.Client.SiteRule.UpdateOneID(rules[i].ID).SetPriority(rules[j].Priority).Exec(context.Background()); err != nil {
+			return err
+		}
+		return m.Client.SiteRule.UpdateOneID(rules[j].ID).SetPriority(rules[i].Priority).Exec(context.Background())
+	}
+
+	return errors.New("the rule doesn't belong to this tenant")
+}
+
+// MatchSiteRule checks if an agent, with its network adapters and tags loaded, matches a rule
+func MatchSiteRule(rule *ent.SiteRule, a *ent.Agent) bool {
+	switch rule.Type {
+	case siterule.TypeCidr:
+		_, network, err := net.ParseCIDR(rule.Value)
+		if err != nil {
+			return false
+		}
+		for _, n := range a.Edges.Networkadapters {
+			for _, address := range ParseAdapterAddresses(n.Addresses, n.Subnet) {
+				if network.Contains(address.IP) {
+					return true
+				}
+			}
+		}
+	case siterule.TypeDomain:
+		domain := strings.ToLower(strings.TrimPrefix(rule.Value, "."))
+		if strings.HasSuffix(strings.ToLower(a.Hostname), "."+domain) {
+			return true
+		}
+		for _, n := range a.Edges.Networkadapters {
+			d := strings.ToLower(n.DNSDomain)
+			if d == domain || strings.HasSuffix(d, "."+domain) {
+				return true
+			}
+		}
+	case siterule.TypeHostname:
+		matched, err := path.Match(strings.ToLower(rule.Value), strings.ToLower(a.Hostname))
+		return err == nil && matched
+	case siterule.TypeTag:
+		for _, t := range a.Edges.Tags {
+			if strconv.Itoa(t.ID) == rule.Value {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// EvaluateSiteRules returns the agents that would be moved to a different site if the rules were applied
+func (m *Model) EvaluateSiteRules(tenantID int) ([]SiteRuleMove, error) {
+	return m.evaluateSiteRules(tenantID)
+}
+
+// evaluateSiteRules checks the rules against the agents of the tenant that match the optional predicates
+func (m *Model) evaluateSiteRules(tenantID int, ps ...predicate.Agent) ([]SiteRuleMove, error) {
+	rules, err := m.GetSiteRules(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	moves := []SiteRuleMove{}
+	if len(rules) == 0 {
+		return moves, nil
+	}
+
+	agents, err := m.Client.Agent.Query().WithSite().WithTags().WithNetworkadapters().
+		Where(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))).
+		Where(ps...).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, a := range agents {
+		if len(a.Edges.Site) != 1 {
+			continue
+		}
+
+		// The first matching rule wins
+		for _, r := range rules {
+			if r.Edges.Site == nil || !MatchSiteRule(r, a) {
+				continue
+			}
+
+			if r.Edges.Site.ID != a.Edges.Site[0].ID {
+				moves = append(moves, SiteRuleMove{
+					AgentID:     a.ID,
+					Nickname:    a.Nickname,
+					CurrentSite: a.Edges.Site[0].Description,
+					NewSite:     r.Edges.Site.Description,
+					NewSiteID:   r.Edges.Site.ID,
+					RuleID:      r.ID,
+				})
+			}
+			break
+		}
+	}
+
+	return moves, nil
+}
+
+func (m *Model) ApplySiteRules(tenantID int) (int, error) {
+	moves, err := m.EvaluateSiteRules(tenantID)
+	if err != nil {
+		return 0, err
+	}
+
+	return m.moveAgentsToSites(tenantID, moves)
+}
+
+func (m *Model) moveAgentsToSites(tenantID int, moves []SiteRuleMove) (int, error) {
+	for _, move := range moves {
+		if err := m.AssociateToTenantAndSite(move.AgentID, strconv.Itoa(tenantID), strconv.Itoa(move.NewSiteID)); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(moves), nil
+}
+
+// ApplySiteRulesToReportedAgents applies the rules of every tenant to the agents that have reported since the given time,
+// so new and existing agents are placed into the right site right after they send a report. A zero time checks every agent
+// and a tenant that fails doesn't stop the rest
+func (m *Model) ApplySiteRulesToReportedAgents(since time.Time) (int, error) {
+	ps := []predicate.Agent{}
+	if !since.IsZero() {
+		ps = append(ps, agent.LastContactGTE(since))
+	}
+
+	tenants, err := m.GetTenants()
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, t := range tenants {
+		moves, err := m.evaluateSiteRules(t.ID, ps...)
+		if err != nil {
+			log.Printf("[ERROR]: could not evaluate the site assignment rules of tenant %d, reason: %v", t.ID, err)
+			continue
+		}
+
+		n, err := m.moveAgentsToSites(t.ID, moves)
+		if err != nil {
+			log.Printf("[ERROR]: could not apply the site assignment rules of tenant %d, reason: %v", t.ID, err)
+			continue
+		}
+		moved += n
+	}
+
+	return moved, nil
+}
@@ -0,0 +1,201 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/ent/site"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SiteRulesTestSuite struct {
+	suite.Suite
+	t        enttest.TestingT
+	model    Model
+	tenantID int
+	siteID   int
+	labID    int
+	officeID int
+	tagID    int
+}
+
+func (suite *SiteRulesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+	suite.siteID = s.ID
+
+	lab, err := client.Site.Create().SetDescription("Lab").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create lab site")
+	suite.labID = lab.ID
+
+	office, err := client.Site.Create().SetDescription("Office").SetTenantID(t.ID).Save(context.Background())
+	assert.NoError(suite.T(), err, "should create office site")
+	suite.officeID = office.ID
+
+	tag, err := client.Tag.Create().SetTag("Servers").SetTenantID(t.ID).SetDescription("Servers").SetColor("#f0f0f0").Save(context.Background())
+	assert.NoError(suite.T(), err, "should create tag")
+	suite.tagID = tag.ID
+
+	for i := range 4 {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	// agent0 is in 192.168.1.0/24, agent1 is in the example.com domain,
+	// agent2 is tagged and agent3 has no matching data
+	adapters := []struct {
+		owner, addresses, subnet, domain string
+	}{
+		{"agent0", "192.168.1.10", "255.255.255.0", ""},
+		{"agent1", "10.1.2.3", "255.0.0.0", "corp.example.com"},
+		{"agent2", "172.16.0.5", "255.255.0.0", ""},
+		{"agent3", "172.16.0.6", "255.255.0.0", ""},
+	}
+	for _, a := range adapters {
+		err := client.NetworkAdapter.Create().
+			SetName("Ethernet").
+			SetMACAddress("aa:bb:cc:dd:ee:ff").
+			SetAddresses(a.addresses).
+			SetSubnet(a.subnet).
+			SetDNSDomain(a.domain).
+			SetSpeed("1 Gbps").
+			SetOwnerID(a.owner).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create network adapter")
+	}
+
+	err = client.Agent.UpdateOneID("agent2").AddTagIDs(tag.ID).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should tag agent")
+}
+
+func (suite *SiteRulesTestSuite) TestAddSiteRule() {
+	err := suite.model.AddSiteRule(suite.tenantID, suite.labID, "cidr", "192.168.1.0/24")
+	assert.NoError(suite.T(), err, "should add cidr rule")
+
+	err = suite.model.AddSiteRule(suite.tenantID, suite.labID, "hostname", "LAB-*")
+	assert.NoError(suite.T(), err, "should add hostname rule")
+
+	err = suite.model.AddSiteRule(suite.tenantID, suite.labID, "cidr", "192.168.1.0")
+	assert.Error(suite.T(), err, "should not add an invalid cidr rule")
+
+	err = suite.model.AddSiteRule(suite.tenantID, suite.labID, "tag", "9999")
+	assert.Error(suite.T(), err, "should not add a rule for an unknown tag")
+
+	err = suite.model.AddSiteRule(suite.tenantID, suite.labID, "unknown", "value")
+	assert.Error(suite.T(), err, "should not add a rule with an invalid type")
+
+	err = suite.model.AddSiteRule(suite.tenantID, 9999, "domain", "example.com")
+	assert.Error(suite.T(), err, "should not add a rule for a site outside the tenant")
+
+	rules, err := suite.model.GetSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get rules")
+	assert.Equal(suite.T(), 2, len(rules), "should get two rules")
+	assert.Equal(suite.T(), "192.168.1.0/24", rules[0].Value, "should get rules by priority")
+	assert.Equal(suite.T(), "LAB-*", rules[1].Value, "should get rules by priority")
+	assert.Equal(suite.T(), suite.labID, rules[0].Edges.Site.ID, "should get rule site")
+}
+
+func (suite *SiteRulesTestSuite) TestMoveAndDeleteSiteRule() {
+	err := suite.model.AddSiteRule(suite.tenantID, suite.labID, "cidr", "192.168.1.0/24")
+	assert.NoError(suite.T(), err, "should add rule")
+	err = suite.model.AddSiteRule(suite.tenantID, suite.officeID, "domain", "example.com")
+	assert.NoError(suite.T(), err, "should add rule")
+
+	rules, err := suite.model.GetSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get rules")
+
+	err = suite.model.MoveSiteRule(suite.tenantID, rules[1].ID, true)
+	assert.NoError(suite.T(), err, "should move rule up")
+
+	moved, err := suite.model.GetSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get rules")
+	assert.Equal(suite.T(), "example.com", moved[0].Value, "should be the first rule")
+
+	err = suite.model.MoveSiteRule(suite.tenantID, moved[0].ID, true)
+	assert.NoError(suite.T(), err, "moving the first rule up should do nothing")
+
+	err = suite.model.DeleteSiteRule(suite.tenantID, moved[0].ID)
+	assert.NoError(suite.T(), err, "should delete rule")
+
+	rules, err = suite.model.GetSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get rules")
+	assert.Equal(suite.T(), 1, len(rules), "should get one rule")
+}
+
+func (suite *SiteRulesTestSuite) TestEvaluateAndApplySiteRules() {
+	moves, err := suite.model.EvaluateSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate rules")
+	assert.Equal(suite.T(), 0, len(moves), "no rules should move no agents")
+
+	assert.NoError(suite.T(), suite.model.AddSiteRule(suite.tenantID, suite.labID, "cidr", "192.168.1.0/24"))
+	assert.NoError(suite.T(), suite.model.AddSiteRule(suite.tenantID, suite.officeID, "domain", "example.com"))
+	assert.NoError(suite.T(), suite.model.AddSiteRule(suite.tenantID, suite.labID, "tag", strconv.Itoa(suite.tagID)))
+	assert.NoError(suite.T(), suite.model.AddSiteRule(suite.tenantID, suite.siteID, "hostname", "AGENT*"))
+
+	moves, err = suite.model.EvaluateSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate rules")
+	assert.Equal(suite.T(), 3, len(moves), "should move three agents")
+
+	targets := map[string]int{}
+	for _, m := range moves {
+		targets[m.AgentID] = m.NewSiteID
+	}
+	assert.Equal(suite.T(), suite.labID, targets["agent0"], "agent0 should match the cidr rule")
+	assert.Equal(suite.T(), suite.officeID, targets["agent1"], "agent1 should match the domain rule")
+	assert.Equal(suite.T(), suite.labID, targets["agent2"], "agent2 should match the tag rule")
+	_, ok := targets["agent3"]
+	assert.False(suite.T(), ok, "agent3 already is in the site of the hostname rule")
+
+	n, err := suite.model.ApplySiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should apply rules")
+	assert.Equal(suite.T(), 3, n, "should move three agents")
+
+	a, err := suite.model.Client.Agent.Query().Where(agent.ID("agent1"), agent.HasSiteWith(site.ID(suite.officeID))).Exist(context.Background())
+	assert.NoError(suite.T(), err, "should query agent")
+	assert.True(suite.T(), a, "agent1 should be in the office site")
+
+	moves, err = suite.model.EvaluateSiteRules(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate rules")
+	assert.Equal(suite.T(), 0, len(moves), "no agents should be moved again")
+}
+
+func (suite *SiteRulesTestSuite) TestApplySiteRulesToReportedAgents() {
+	assert.NoError(suite.T(), suite.model.AddSiteRule(suite.tenantID, suite.officeID, "hostname", "agent*"))
+
+	err := suite.model.Client.Agent.Update().SetLastContact(time.Now().Add(-1 * time.Hour)).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should update last contact")
+	err = suite.model.Client.Agent.UpdateOneID("agent0").SetLastContact(time.Now()).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should update last contact")
+
+	n, err := suite.model.ApplySiteRulesToReportedAgents(time.Now().Add(-1 * time.Minute))
+	assert.NoError(suite.T(), err, "should apply rules")
+	assert.Equal(suite.T(), 1, n, "only the agent that has just reported should be moved")
+
+	n, err = suite.model.ApplySiteRulesToReportedAgents(time.Time{})
+	assert.NoError(suite.T(), err, "should apply rules")
+	assert.Equal(suite.T(), 3, n, "the rest of agents should be moved on the first run")
+}
+
+func TestSiteRulesTestSuite(t *testing.T) {
+	suite.Run(t, new(SiteRulesTestSuite))
+}
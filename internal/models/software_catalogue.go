@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/softwarealias"
+)
+
+var SoftwareAliasKinds = []string{"name", "publisher"}
+
+// Applications are grouped by their normalized identity, falling back to the reported one until they're normalized
+const (
+	appNameSQL      = "COALESCE(NULLIF(normalized_name, ''), name)"
+	appPublisherSQL = "COALESCE(NULLIF(normalized_publisher, ''), publisher)"
+)
+
+var (
+	// Parenthesized qualifiers like (64-bit), (x64 es-ES) or (remove only)
+	softwareQualifierRegexp = regexp.MustCompile(`(?i)\s*\((?:[^()]*\b(?:x64|x86|x86_64|amd64|arm64|64-bit|32-bit|64 bit|32 bit|remove only|[a-z]{2}-[a-z]{2})\b[^()]*)\)`)
+	// Architecture tokens left at the end of the name
+	softwareArchRegexp = regexp.MustCompile(`(?i)[\s\-]+(?:x64|x86|x86_64|amd64|arm64|64-bit|32-bit)$`)
+	// Trailing versions like "7-Zip 22.01" or "Redistributable - 14.38.33130"
+	softwareVersionRegexp = regexp.MustCompile(`(?i)(?:\s+-)?\s+v?\d+(?:\.\d+)+(?:[\-+][0-9a-z.]+)?$`)
+	// Corporate suffixes like "Google LLC" or "Microsoft Corporation"
+	publisherSuffixRegexp = regexp.MustCompile(`(?i)[\s,]+(?:inc\.?|incorporated|llc|l\.l\.c\.|ltd\.?|limited|gmbh|corporation|corp\.?|co\.?|s\.a\.?|s\.l\.?|s\.r\.l\.?|ab|b\.v\.?|ag|se|pty|plc)$`)
+)
+
+// SoftwareNormalizer turns the names and publishers reported by each operating system into a canonical identity,
+// admin overrides are applied before the built-in rules
+type SoftwareNormalizer struct {
+	names      []*ent.SoftwareAlias
+	publishers []*ent.SoftwareAlias
+}
+
+func (m *Model) GetSoftwareAliases() ([]*ent.SoftwareAlias, error) {
+	return m.Client.SoftwareAlias.Query().Order(ent.Asc(softwarealias.FieldKind), ent.Asc(softwarealias.FieldPattern)).All(context.Background())
+}
+
+func (m *Model) AddSoftwareAlias(kind, pattern, canonical string) error {
+	pattern = strings.TrimSpace(pattern)
+	canonical = strings.TrimSpace(canonical)
+
+	if kind != "name" && kind != "publisher" {
+		return errors.New("the alias must apply to the name or the publisher")
+	}
+
+	if pattern == "" || canonical == "" {
+		return errors.New("the pattern and the canonical value are required")
+	}
+
+	if _, err := path.Match(strings.ToLower(pattern), ""); err != nil {
+		return err
+	}
+
+	return m.Client.SoftwareAlias.Create().
+		SetKind(softwarealias.Kind(kind)).
+		SetPattern(pattern).
+		SetCanonical(canonical).
+		Exec(context.Background())
+}
+
+func (m *Model) DeleteSoftwareAlias(aliasID int) error {
+	return m.Client.SoftwareAlias.DeleteOneID(aliasID).Exec(context.Background())
+}
+
+func (m *Model) NewSoftwareNormalizer() (*SoftwareNormalizer, error) {
+	aliases, err := m.GetSoftwareAliases()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSoftwareNormalizer(aliases), nil
+}
+
+func NewSoftwareNormalizer(aliases []*ent.SoftwareAlias) *SoftwareNormalizer {
+	n := SoftwareNormalizer{}
+	for _, a := range aliases {
+		switch a.Kind {
+		case softwarealias.KindName:
+			n.names = append(n.names, a)
+		case softwarealias.KindPublisher:
+			n.publishers = append(n.publishers, a)
+		}
+	}
+	return &n
+}
+
+// Name checks the aliases against the reported name and the one normalized by the built-in rules
+func (n *SoftwareNormalizer) Name(name string) string {
+	normalized := NormalizeSoftwareName(name)
+	for _, a := range n.names {
+		if MatchLicensePattern(a.Pattern, name) || MatchLicensePattern(a.Pattern, normalized) {
+			return a.Canonical
+		}
+	}
+	return normalized
+}
+
+func (n *SoftwareNormalizer) Publisher(publisher string) string {
+	normalized := NormalizeSoftwarePublisher(publisher)
+	for _, a := range n.publishers {
+		if MatchLicensePattern(a.Pattern, publisher) || MatchLicensePattern(a.Pattern, normalized) {
+			return a.Canonical
+		}
+	}
+	return normalized
+}
+
+// NormalizeSoftwareName removes architecture, language and version qualifiers from an application name
+// and turns package ids like org.mozilla.firefox into a readable name
+func NormalizeSoftwareName(name string) string {
+	name = strings.TrimSpace(name)
+
+	if isPackageID(name) {
+		parts := strings.Split(name, ".")
+		name = splitWords(parts[len(parts)-1])
+	}
+
+	for {
+		normalized := softwareQualifierRegexp.ReplaceAllString(name, "")
+		normalized = softwareArchRegexp.ReplaceAllString(normalized, "")
+		normalized = softwareVersionRegexp.ReplaceAllString(normalized, "")
+		normalized = strings.TrimSpace(normalized)
+		if normalized == name || normalized == "" {
+			break
+		}
+		name = normalized
+	}
+
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// NormalizeSoftwarePublisher removes the corporate suffixes from a publisher
+func NormalizeSoftwarePublisher(publisher string) string {
+	publisher = strings.TrimSpace(publisher)
+
+	for {
+		normalized := strings.TrimRight(publisherSuffixRegexp.ReplaceAllString(publisher, ""), " ,.")
+		if normalized == publisher || normalized == "" {
+			break
+		}
+		publisher = normalized
+	}
+
+	return strings.Join(strings.Fields(publisher), " ")
+}
+
+// isPackageID checks if a name is a reverse domain id like the ones used by flatpak
+func isPackageID(name string) bool {
+	if strings.ContainsAny(name, " /\\") || strings.Count(name, ".") < 2 {
+		return false
+	}
+	for _, p := range strings.Split(name, ".") {
+		if p == "" || !unicode.IsLetter(rune(p[0])) {
+			return false
+		}
+	}
+	return true
+}
+
+// splitWords turns identifiers like "GoogleChrome" or "visual-studio-code" into "Google Chrome" or "Visual Studio Code"
+func splitWords(id string) string {
+	words := []string{}
+	current := []rune{}
+	runes := []rune(id)
+	for i, r := range runes {
+		switch {
+		case r == '-' || r == '_':
+			if len(current) > 0 {
+				words = append(words, string(current))
+			}
+			current = []rune{}
+			continue
+		case unicode.IsUpper(r) && len(current) > 0 && i > 0 && unicode.IsLower(runes[i-1]):
+			words = append(words, string(current))
+			current = []rune{}
+		}
+		current = append(current, r)
+	}
+	if len(current) > 0 {
+		words = append(words, string(current))
+	}
+
+	for i, w := range words {
+		r := []rune(w)
+		r[0] = unicode.ToUpper(r[0])
+		words[i] = string(r)
+	}
+	return strings.Join(words, " ")
+}
+
+// NormalizeApps saves the canonical name and publisher of every application so the software
+// list, filters and reports group them by their normalized identity. Apps are updated in batches
+// sharing the same reported name and publisher
+func (m *Model) NormalizeApps() (int, error) {
+	n, err := m.NewSoftwareNormalizer()
+	if err != nil {
+		return 0, err
+	}
+
+	var identities []struct {
+		Name                string `json:"name"`
+		Publisher           string `json:"publisher"`
+		NormalizedName      string `json:"normalized_name"`
+		NormalizedPublisher string `json:"normalized_publisher"`
+	}
+	if err := m.Client.App.Query().GroupBy(app.FieldName, app.FieldPublisher, app.FieldNormalizedName, app.FieldNormalizedPublisher).Scan(context.Background(), &identities); err != nil {
+		return 0, err
+	}
+
+	tx, err := m.Client.Tx(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, i := range identities {
+		name := n.Name(i.Name)
+		publisher := n.Publisher(i.Publisher)
+		if name == i.NormalizedName && publisher == i.NormalizedPublisher {
+			continue
+		}
+
+		count, err := tx.App.Update().
+			Where(app.Name(i.Name), app.Publisher(i.Publisher), app.NormalizedName(i.NormalizedName), app.NormalizedPublisher(i.NormalizedPublisher)).
+			SetNormalizedName(name).
+			SetNormalizedPublisher(publisher).
+			Save(context.Background())
+		if err != nil {
+			return 0, rollback(tx, err)
+		}
+		updated += count
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SoftwareCatalogueTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *SoftwareCatalogueTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	err = client.Agent.Create().
+		SetID("agent1").
+		SetHostname("agent1").
+		SetOs("windows").
+		SetNickname("agent1").
+		SetAgentStatus(agent.AgentStatusEnabled).
+		AddSiteIDs(s.ID).
+		Exec(context.Background())
+	assert.NoError(suite.T(), err, "should create agent")
+
+	apps := []struct{ name, publisher, version string }{
+		{"Google Chrome", "Google LLC", "126.0.6478.127"},
+		{"Google Chrome (64-bit)", "Google Inc.", "126.0.6478.61"},
+		{"com.google.Chrome", "Google", "126.0.6478.55"},
+		{"7-Zip 23.01 (x64)", "Igor Pavlov", "23.01"},
+	}
+	for _, a := range apps {
+		err := client.App.Create().
+			SetName(a.name).
+			SetPublisher(a.publisher).
+			SetVersion(a.version).
+			SetOwnerID("agent1").
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "name", SortOrder: "asc"}
+}
+
+func (suite *SoftwareCatalogueTestSuite) TestNormalizeSoftwareName() {
+	assert.Equal(suite.T(), "Google Chrome", NormalizeSoftwareName("Google Chrome (64-bit)"), "should remove architecture")
+	assert.Equal(suite.T(), "Mozilla Firefox", NormalizeSoftwareName("Mozilla Firefox (x64 es-ES)"), "should remove architecture and language")
+	assert.Equal(suite.T(), "7-Zip", NormalizeSoftwareName("7-Zip 23.01 (x64)"), "should remove version")
+	assert.Equal(suite.T(), "Microsoft Visual C++ 2015-2022 Redistributable", NormalizeSoftwareName("Microsoft Visual C++ 2015-2022 Redistributable (x64) - 14.38.33130"), "should remove version after dash")
+	assert.Equal(suite.T(), "Visual Studio Code", NormalizeSoftwareName("com.visualstudio.visual-studio-code"), "should read package ids")
+	assert.Equal(suite.T(), "Google", NormalizeSoftwarePublisher("Google, Inc."), "should remove corporate suffix")
+	assert.Equal(suite.T(), "Microsoft", NormalizeSoftwarePublisher("Microsoft Corporation"), "should remove corporate suffix")
+}
+
+func (suite *SoftwareCatalogueTestSuite) TestCompareLooseVersions() {
+	assert.Equal(suite.T(), -1, CompareVersions("2.0.0-rc1", "2.0.0"), "pre-releases should come first")
+	assert.Equal(suite.T(), -1, CompareVersions("1.0rc1", "1.0"), "pre-releases without separator should come first")
+	assert.Equal(suite.T(), 0, CompareVersions("1.2.3+build5", "1.2.3"), "should ignore build metadata")
+	assert.Equal(suite.T(), 1, CompareVersions("126.0.6478.127", "126.0.6478.61"), "should not compare as text")
+}
+
+func (suite *SoftwareCatalogueTestSuite) TestNormalizeApps() {
+	err := suite.model.AddSoftwareAlias("product", "*", "Chrome")
+	assert.Error(suite.T(), err, "should not add alias with a wrong kind")
+
+	err = suite.model.AddSoftwareAlias("name", "chrome", "Google Chrome")
+	assert.NoError(suite.T(), err, "should add name alias")
+
+	err = suite.model.AddSoftwareAlias("publisher", "Igor*", "7-Zip")
+	assert.NoError(suite.T(), err, "should add publisher alias")
+
+	updated, err := suite.model.NormalizeApps()
+	assert.NoError(suite.T(), err, "should normalize apps")
+	assert.Equal(suite.T(), 4, updated, "should normalize every app")
+
+	updated, err = suite.model.NormalizeApps()
+	assert.NoError(suite.T(), err, "should normalize apps")
+	assert.Equal(suite.T(), 0, updated, "should not update apps already normalized")
+
+	count, err := suite.model.CountAllApps(filters.ApplicationsFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count apps")
+	assert.Equal(suite.T(), 2, count, "Chrome should be counted once")
+
+	apps, err := suite.model.GetAppsByPage(suite.p, filters.ApplicationsFilter{}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get apps by page")
+	assert.Equal(suite.T(), 2, len(apps), "should group apps by normalized identity")
+	assert.Equal(suite.T(), "7-Zip", apps[0].Name)
+	assert.Equal(suite.T(), "7-Zip", apps[0].Publisher, "should apply publisher alias")
+	assert.Equal(suite.T(), "Google Chrome", apps[1].Name)
+	assert.Equal(suite.T(), "Google", apps[1].Publisher)
+	assert.Equal(suite.T(), 3, apps[1].Count, "should count the three Chrome installations")
+
+	apps, err = suite.model.GetAppsByPage(suite.p, filters.ApplicationsFilter{AppName: "64-bit"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should filter by reported name")
+	assert.Equal(suite.T(), 1, len(apps), "should find Chrome by its reported name")
+
+	suite.p.SortBy = "version"
+	suite.p.SortOrder = "desc"
+	agentApps, err := suite.model.GetAgentAppsByPage("agent1", suite.p, filters.ApplicationsFilter{AppName: "chrome"}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get agent apps")
+	assert.Equal(suite.T(), "126.0.6478.127", agentApps[0].Version, "should sort versions as numbers")
+}
+
+func TestSoftwareCatalogueTestSuite(t *testing.T) {
+	suite.Run(t, new(SoftwareCatalogueTestSuite))
+}
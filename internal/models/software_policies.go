@@ -0,0 +1,438 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/mail"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/policyviolation"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/softwarepolicy"
+	"github.com/scncore/ent/tenant"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+var SoftwarePolicyModes = []string{"deny", "allow"}
+
+// SoftwarePolicyPackages are the packages used to uninstall a denied application on each platform
+type SoftwarePolicyPackages struct {
+	Windows string
+	Linux   string
+	MacOS   string
+}
+
+// PolicyNotification groups the violations that must be notified to an email
+type PolicyNotification struct {
+	Email      string
+	Violations []*ent.PolicyViolation
+}
+
+// PolicyRemediation is the uninstallation requested to a computer to fix a violation
+type PolicyRemediation struct {
+	ViolationID int
+	AgentID     string
+	PackageID   string
+	AppName     string
+}
+
+func (m *Model) GetSoftwarePolicies(tenantID int) ([]*ent.SoftwarePolicy, error) {
+	return m.Client.SoftwarePolicy.Query().Where(softwarepolicy.HasTenantWith(tenant.ID(tenantID))).Order(ent.Asc(softwarepolicy.FieldMode), ent.Asc(softwarepolicy.FieldName)).All(context.Background())
+}
+
+func (m *Model) AddSoftwarePolicy(tenantID int, name, mode, namePattern, publisherPattern, minVersion, maxVersion, notifyEmail string, autoRemediate bool, packages SoftwarePolicyPackages) error {
+	name = strings.TrimSpace(name)
+	namePattern = strings.TrimSpace(namePattern)
+	if name == "" || namePattern == "" {
+		return errors.New("the policy name and the application name pattern are required")
+	}
+
+	if mode != softwarepolicy.ModeDeny.String() && mode != softwarepolicy.ModeAllow.String() {
+		return errors.New("the policy mode is not valid")
+	}
+
+	for _, pattern := range []string{namePattern, publisherPattern} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+
+	minVersion = strings.TrimSpace(minVersion)
+	maxVersion = strings.TrimSpace(maxVersion)
+	if minVersion != "" && maxVersion != "" && CompareVersions(minVersion, maxVersion) > 0 {
+		return errors.New("the minimum version is greater than the maximum version")
+	}
+
+	notifyEmail = strings.TrimSpace(notifyEmail)
+	if notifyEmail != "" {
+		if _, err := mail.ParseAddress(notifyEmail); err != nil {
+			return errors.New("the notification email is not valid")
+		}
+	}
+
+	// Only denied applications can be uninstalled automatically
+	packages.Windows = strings.TrimSpace(packages.Windows)
+	packages.Linux = strings.TrimSpace(packages.Linux)
+	packages.MacOS = strings.TrimSpace(packages.MacOS)
+	if autoRemediate && (mode != softwarepolicy.ModeDeny.String() || (packages.Windows == "" && packages.Linux == "" && packages.MacOS == "")) {
+		return errors.New("auto-remediation requires a deny policy with a package ID")
+	}
+
+	return m.Client.SoftwarePolicy.Create().
+		SetName(name).
+		SetMode(softwarepolicy.Mode(mode)).
+		SetNamePattern(namePattern).
+		SetPublisherPattern(strings.TrimSpace(publisherPattern)).
+		SetMinVersion(minVersion).
+		SetMaxVersion(maxVersion).
+		SetNotifyEmail(notifyEmail).
+		SetAutoRemediate(autoRemediate).
+		SetPackageID(packages.Windows).
+		SetFlatpakPackageID(packages.Linux).
+		SetBrewPackageID(packages.MacOS).
+		SetTenantID(tenantID).
+		Exec(context.Background())
+}
+
+func (m *Model) DeleteSoftwarePolicy(tenantID int, policyID int) error {
+	if _, err := m.Client.PolicyViolation.Delete().Where(policyviolation.HasPolicyWith(softwarepolicy.ID(policyID), softwarepolicy.HasTenantWith(tenant.ID(tenantID)))).Exec(context.Background()); err != nil {
+		return err
+	}
+
+	_, err := m.Client.SoftwarePolicy.Delete().Where(softwarepolicy.ID(policyID), softwarepolicy.HasTenantWith(tenant.ID(tenantID))).Exec(context.Background())
+	return err
+}
+
+// SoftwarePolicyPackageForOS returns the package that uninstalls the application of a policy on the
+// operating system reported by an agent, winget for Windows, brew for macOS and flatpak for Linux
+func SoftwarePolicyPackageForOS(p *ent.SoftwarePolicy, os string) string {
+	switch winget.AgentPlatform(os) {
+	case "windows":
+		return p.PackageID
+	case "macOS":
+		return p.BrewPackageID
+	default:
+		return p.FlatpakPackageID
+	}
+}
+
+// MatchSoftwarePolicy checks if an application matches the name, publisher and version range of a policy
+func MatchSoftwarePolicy(p *ent.SoftwarePolicy, a *ent.App) bool {
+	if !MatchLicensePattern(p.NamePattern, a.Name) || !MatchLicensePattern(p.PublisherPattern, a.Publisher) {
+		return false
+	}
+	if p.MinVersion != "" && CompareVersions(a.Version, p.MinVersion) < 0 {
+		return false
+	}
+	if p.MaxVersion != "" && CompareVersions(a.Version, p.MaxVersion) > 0 {
+		return false
+	}
+	return true
+}
+
+// FindSoftwarePolicyViolation returns the policy that an application breaks. Deny policies are checked first,
+// and if the tenant has allow policies every application that doesn't match one of them is not allowed. The
+// boolean is false if the application complies with the policies
+func FindSoftwarePolicyViolation(policies []*ent.SoftwarePolicy, a *ent.App) (*ent.SoftwarePolicy, bool) {
+	allowOnly := false
+	for _, p := range policies {
+		if p.Mode == softwarepolicy.ModeDeny && MatchSoftwarePolicy(p, a) {
+			return p, true
+		}
+		if p.Mode == softwarepolicy.ModeAllow {
+			allowOnly = true
+		}
+	}
+
+	if !allowOnly {
+		return nil, false
+	}
+
+	for _, p := range policies {
+		if p.Mode == softwarepolicy.ModeAllow && MatchSoftwarePolicy(p, a) {
+			return nil, false
+		}
+	}
+
+	return nil, true
+}
+
+// EvaluateSoftwarePolicies computes the violations of the tenant policies from the agents inventory. Known
+// violations are kept, violations that are gone are removed and the new ones are returned so they can be notified
+func (m *Model) EvaluateSoftwarePolicies(tenantID int) ([]*ent.PolicyViolation, error) {
+	policies, err := m.GetSoftwarePolicies(tenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := m.Client.PolicyViolation.Query().WithPolicy().WithOwner().
+		Where(policyviolation.HasOwnerWith(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	known := map[string]*ent.PolicyViolation{}
+	for _, v := range existing {
+		if v.Edges.Owner == nil {
+			continue
+		}
+		known[policyViolationKey(v.Edges.Owner.ID, v.AppName, v.AppVersion)] = v
+	}
+
+	apps := []*ent.App{}
+	if len(policies) > 0 {
+		apps, err = m.getTenantApps(tenantID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	seen := map[string]bool{}
+	newViolations := []*ent.PolicyViolation{}
+	for _, a := range apps {
+		if a.Edges.Owner == nil {
+			continue
+		}
+
+		policy, violated := FindSoftwarePolicyViolation(policies, a)
+		if !violated {
+			continue
+		}
+
+		key := policyViolationKey(a.Edges.Owner.ID, a.Name, a.Version)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if v, ok := known[key]; ok {
+			if err := m.Client.PolicyViolation.UpdateOneID(v.ID).SetLastSeen(now).Exec(context.Background()); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		query := m.Client.PolicyViolation.Create().
+			SetAppName(a.Name).
+			SetAppPublisher(a.Publisher).
+			SetAppVersion(a.Version).
+			SetFirstSeen(now).
+			SetLastSeen(now).
+			SetOwnerID(a.Edges.Owner.ID)
+		if policy != nil {
+			query = query.SetPolicyID(policy.ID)
+		}
+
+		v, err := query.Save(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		v.Edges.Owner = a.Edges.Owner
+		v.Edges.Policy = policy
+		newViolations = append(newViolations, v)
+	}
+
+	for key, v := range known {
+		if !seen[key] {
+			if err := m.Client.PolicyViolation.DeleteOneID(v.ID).Exec(context.Background()); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return newViolations, nil
+}
+
+// EvaluateAllSoftwarePolicies evaluates the policies of every tenant, a tenant that fails doesn't stop the rest
+func (m *Model) EvaluateAllSoftwarePolicies() error {
+	tenants, err := m.GetTenants()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tenants {
+		if _, err := m.EvaluateSoftwarePolicies(t.ID); err != nil {
+			log.Printf("[ERROR]: could not evaluate software policies for tenant %d, reason: %v", t.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// GetPendingPolicyNotifications groups by email the violations that haven't been notified yet. Violations of a
+// deny policy go to its email and applications that are not in the allow list go to the emails of every allow
+// policy. Violations that nobody has to be notified about are marked as notified
+func (m *Model) GetPendingPolicyNotifications(tenantID int) ([]PolicyNotification, error) {
+	violations, err := m.Client.PolicyViolation.Query().WithPolicy().WithOwner().
+		Where(policyviolation.Notified(false), policyviolation.HasOwnerWith(agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		Order(ent.Asc(policyviolation.FieldID)).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(violations) == 0 {
+		return []PolicyNotification{}, nil
+	}
+
+	allowEmails, err := m.Client.SoftwarePolicy.Query().
+		Where(softwarepolicy.ModeEQ(softwarepolicy.ModeAllow), softwarepolicy.NotifyEmailNEQ(""), softwarepolicy.HasTenantWith(tenant.ID(tenantID))).
+		Unique(true).
+		Select(softwarepolicy.FieldNotifyEmail).
+		Strings(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := []PolicyNotification{}
+	index := map[string]int{}
+	silent := []int{}
+	for _, v := range violations {
+		emails := allowEmails
+		if v.Edges.Policy != nil {
+			emails = []string{}
+			if v.Edges.Policy.NotifyEmail != "" {
+				emails = append(emails, v.Edges.Policy.NotifyEmail)
+			}
+		}
+
+		if len(emails) == 0 || v.Edges.Owner == nil {
+			silent = append(silent, v.ID)
+			continue
+		}
+
+		for _, email := range emails {
+			i, ok := index[email]
+			if !ok {
+				i = len(notifications)
+				index[email] = i
+				notifications = append(notifications, PolicyNotification{Email: email})
+			}
+			notifications[i].Violations = append(notifications[i].Violations, v)
+		}
+	}
+
+	if err := m.SetPolicyViolationsNotified(silent); err != nil {
+		return nil, err
+	}
+
+	return notifications, nil
+}
+
+func (m *Model) SetPolicyViolationsNotified(violationIDs []int) error {
+	if len(violationIDs) == 0 {
+		return nil
+	}
+	return m.Client.PolicyViolation.Update().Where(policyviolation.IDIn(violationIDs...)).SetNotified(true).Exec(context.Background())
+}
+
+// GetPendingPolicyRemediations returns the uninstallations that must be requested for the violations of auto-remediated
+// policies, using the package of the platform of each computer. Nothing is remediated during a change freeze
+func (m *Model) GetPendingPolicyRemediations(tenantID int) ([]PolicyRemediation, error) {
+	freeze, err := m.GetActiveChangeFreeze(tenantID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	remediations := []PolicyRemediation{}
+	if freeze != nil {
+		return remediations, nil
+	}
+
+	violations, err := m.Client.PolicyViolation.Query().WithPolicy().WithOwner().
+		Where(policyviolation.Remediated(false), policyviolation.HasPolicyWith(softwarepolicy.AutoRemediate(true)), policyviolation.HasOwnerWith(agent.AgentStatusEQ(agent.AgentStatusEnabled), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))).
+		All(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	for _, v := range violations {
+		if v.Edges.Policy == nil || v.Edges.Owner == nil {
+			continue
+		}
+
+		packageID := SoftwarePolicyPackageForOS(v.Edges.Policy, v.Edges.Owner.Os)
+		if packageID == "" {
+			continue
+		}
+
+		remediations = append(remediations, PolicyRemediation{ViolationID: v.ID, AgentID: v.Edges.Owner.ID, PackageID: packageID, AppName: v.AppName})
+	}
+
+	return remediations, nil
+}
+
+func policyViolationKey(agentID, name, version string) string {
+	return agentID + "|" + strings.ToLower(name) + "|" + version
+}
+
+func (m *Model) SetPolicyViolationRemediated(violationID int) error {
+	return m.Client.PolicyViolation.UpdateOneID(violationID).SetRemediated(true).Exec(context.Background())
+}
+
+func (m *Model) GetAgentPolicyViolations(agentID string) ([]*ent.PolicyViolation, error) {
+	return m.Client.PolicyViolation.Query().WithPolicy().Where(policyviolation.HasOwnerWith(agent.ID(agentID))).Order(ent.Asc(policyviolation.FieldAppName)).All(context.Background())
+}
+
+func (m *Model) GetPolicyViolationsByPage(p partials.PaginationAndSort, c *partials.CommonInfo) ([]*ent.PolicyViolation, error) {
+	query, err := m.getPolicyViolationsQuery(c)
+	if err != nil {
+		return nil, err
+	}
+
+	switch p.SortBy {
+	case "name":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(policyviolation.FieldAppName))
+		} else {
+			query = query.Order(ent.Desc(policyviolation.FieldAppName))
+		}
+	case "firstSeen":
+		if p.SortOrder == "asc" {
+			query = query.Order(ent.Asc(policyviolation.FieldFirstSeen))
+		} else {
+			query = query.Order(ent.Desc(policyviolation.FieldFirstSeen))
+		}
+	default:
+		query = query.Order(ent.Desc(policyviolation.FieldFirstSeen))
+	}
+
+	if p.PageSize != 0 {
+		query = query.Limit(p.PageSize).Offset((p.CurrentPage - 1) * p.PageSize)
+	}
+
+	return query.WithPolicy().WithOwner().All(context.Background())
+}
+
+func (m *Model) CountPolicyViolations(c *partials.CommonInfo) (int, error) {
+	query, err := m.getPolicyViolationsQuery(c)
+	if err != nil {
+		return 0, err
+	}
+	return query.Count(context.Background())
+}
+
+func (m *Model) getPolicyViolationsQuery(c *partials.CommonInfo) (*ent.PolicyViolationQuery, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	if siteID == -1 {
+		return m.Client.PolicyViolation.Query().Where(policyviolation.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID))))), nil
+	}
+	return m.Client.PolicyViolation.Query().Where(policyviolation.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID))))), nil
+}
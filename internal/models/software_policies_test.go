@@ -0,0 +1,214 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SoftwarePoliciesTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	tenantID   int
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *SoftwarePoliciesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+	suite.tenantID = t.ID
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	apps := []struct{ name, publisher, version string }{
+		{"qBittorrent", "The qBittorrent project", "4.6.0"},
+		{"AnyDesk", "AnyDesk Software GmbH", "7.1.2"},
+		{"AnyDesk", "AnyDesk Software GmbH", "9.0.1"},
+		{"Mozilla Firefox", "Mozilla", "128.0"},
+	}
+	for i, a := range apps {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.App.Create().
+			SetName(a.name).
+			SetPublisher(a.publisher).
+			SetVersion(a.version).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *SoftwarePoliciesTestSuite) TestCompareVersions() {
+	assert.Equal(suite.T(), -1, CompareVersions("1.9", "1.10"), "should compare numbers")
+	assert.Equal(suite.T(), 0, CompareVersions("v2.0", "2.0.0"), "should ignore prefix and trailing zeros")
+	assert.Equal(suite.T(), 1, CompareVersions("10.0.19045", "10.0.19044.100"), "should compare build numbers")
+}
+
+func (suite *SoftwarePoliciesTestSuite) TestAddSoftwarePolicy() {
+	err := suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "block", "*torrent*", "", "", "", "", false, SoftwarePolicyPackages{})
+	assert.Error(suite.T(), err, "should not add a policy with a wrong mode")
+
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "deny", "*torrent*", "", "2.0", "1.0", "", false, SoftwarePolicyPackages{})
+	assert.Error(suite.T(), err, "should not add a policy with a wrong version range")
+
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "allow", "*torrent*", "", "", "", "", true, SoftwarePolicyPackages{Windows: "qBittorrent.qBittorrent"})
+	assert.Error(suite.T(), err, "should not auto-remediate allow policies")
+
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "deny", "*torrent*", "", "", "", "admin@example.com", true, SoftwarePolicyPackages{Windows: "qBittorrent.qBittorrent"})
+	assert.NoError(suite.T(), err, "should add policy")
+
+	policies, err := suite.model.GetSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get policies")
+	assert.Equal(suite.T(), 1, len(policies), "should get one policy")
+}
+
+func (suite *SoftwarePoliciesTestSuite) TestEvaluateSoftwarePolicies() {
+	err := suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "deny", "*torrent*", "", "", "", "", false, SoftwarePolicyPackages{})
+	assert.NoError(suite.T(), err, "should add deny policy")
+
+	// Old AnyDesk versions are not allowed
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "AnyDesk", "deny", "anydesk", "AnyDesk*", "", "8.0", "", false, SoftwarePolicyPackages{})
+	assert.NoError(suite.T(), err, "should add deny policy with version range")
+
+	violations, err := suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+	assert.Equal(suite.T(), 2, len(violations), "should find two violations")
+
+	violations, err = suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+	assert.Equal(suite.T(), 0, len(violations), "should not report known violations again")
+
+	// In allow-only mode everything that is not allowed is a violation
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "Firefox", "allow", "Mozilla Firefox", "", "", "", "", false, SoftwarePolicyPackages{})
+	assert.NoError(suite.T(), err, "should add allow policy")
+
+	violations, err = suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+	assert.Equal(suite.T(), 1, len(violations), "AnyDesk 9 should not be allowed")
+	assert.Nil(suite.T(), violations[0].Edges.Policy, "allow-only violations are not linked to a policy")
+
+	count, err := suite.model.CountPolicyViolations(suite.commonInfo)
+	assert.NoError(suite.T(), err, "should count violations")
+	assert.Equal(suite.T(), 3, count, "should count three violations")
+
+	// Violations are removed when the application is uninstalled
+	_, err = suite.model.Client.App.Delete().Where(app.Name("qBittorrent")).Exec(context.Background())
+	assert.NoError(suite.T(), err, "should delete app")
+
+	_, err = suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+
+	violations, err = suite.model.GetAgentPolicyViolations("agent0")
+	assert.NoError(suite.T(), err, "should get agent violations")
+	assert.Equal(suite.T(), 0, len(violations), "should remove solved violations")
+
+	violations, err = suite.model.GetPolicyViolationsByPage(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get violations by page")
+	assert.Equal(suite.T(), 2, len(violations), "should get two violations")
+}
+
+func (suite *SoftwarePoliciesTestSuite) TestPendingPolicyNotifications() {
+	err := suite.model.AddSoftwarePolicy(suite.tenantID, "Torrent", "deny", "*torrent*", "", "", "", "security@example.com", false, SoftwarePolicyPackages{})
+	assert.NoError(suite.T(), err, "should add deny policy")
+
+	err = suite.model.AddSoftwarePolicy(suite.tenantID, "Firefox", "allow", "Mozilla Firefox", "", "", "", "it@example.com", false, SoftwarePolicyPackages{})
+	assert.NoError(suite.T(), err, "should add allow policy")
+
+	_, err = suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+
+	notifications, err := suite.model.GetPendingPolicyNotifications(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending notifications")
+	assert.Equal(suite.T(), 2, len(notifications), "should notify two emails")
+
+	emails := map[string]int{}
+	for _, n := range notifications {
+		emails[n.Email] = len(n.Violations)
+	}
+	assert.Equal(suite.T(), 1, emails["security@example.com"], "the deny policy email gets the torrent violation")
+	assert.Equal(suite.T(), 2, emails["it@example.com"], "the allow list email gets the AnyDesk violations")
+
+	// Notifications are pending until they're sent
+	notifications, err = suite.model.GetPendingPolicyNotifications(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending notifications")
+	assert.Equal(suite.T(), 2, len(notifications), "unsent notifications should be retried")
+
+	for _, n := range notifications {
+		ids := []int{}
+		for _, v := range n.Violations {
+			ids = append(ids, v.ID)
+		}
+		assert.NoError(suite.T(), suite.model.SetPolicyViolationsNotified(ids), "should mark violations as notified")
+	}
+
+	notifications, err = suite.model.GetPendingPolicyNotifications(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending notifications")
+	assert.Equal(suite.T(), 0, len(notifications), "sent notifications should not be repeated")
+}
+
+func (suite *SoftwarePoliciesTestSuite) TestPendingPolicyRemediations() {
+	err := suite.model.AddSoftwarePolicy(suite.tenantID, "AnyDesk", "deny", "anydesk", "", "", "", "", true, SoftwarePolicyPackages{Windows: "AnyDesk.AnyDesk", Linux: "com.anydesk.Anydesk"})
+	assert.NoError(suite.T(), err, "should add deny policy")
+
+	err = suite.model.Client.Agent.UpdateOneID("agent1").SetOs("ubuntu").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should update agent")
+
+	_, err = suite.model.EvaluateSoftwarePolicies(suite.tenantID)
+	assert.NoError(suite.T(), err, "should evaluate policies")
+
+	remediations, err := suite.model.GetPendingPolicyRemediations(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending remediations")
+	assert.Equal(suite.T(), 2, len(remediations), "should remediate both AnyDesk installations")
+
+	packages := map[string]string{}
+	for _, r := range remediations {
+		packages[r.AgentID] = r.PackageID
+	}
+	assert.Equal(suite.T(), "com.anydesk.Anydesk", packages["agent1"], "the linux computer should use the flatpak package")
+	assert.Equal(suite.T(), "AnyDesk.AnyDesk", packages["agent2"], "the windows computer should use the winget package")
+
+	assert.NoError(suite.T(), suite.model.SetPolicyViolationRemediated(remediations[0].ViolationID), "should mark violation as remediated")
+
+	remediations, err = suite.model.GetPendingPolicyRemediations(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending remediations")
+	assert.Equal(suite.T(), 1, len(remediations), "remediated violations should not be sent again")
+
+	err = suite.model.AddChangeFreeze(suite.tenantID, ChangeFreezeRequest{Name: "Year end", Start: time.Now().Add(-time.Hour), End: time.Now().Add(time.Hour)})
+	assert.NoError(suite.T(), err, "should add change freeze")
+
+	remediations, err = suite.model.GetPendingPolicyRemediations(suite.tenantID)
+	assert.NoError(suite.T(), err, "should get pending remediations")
+	assert.Equal(suite.T(), 0, len(remediations), "nothing is remediated during a change freeze")
+}
+
+func TestSoftwarePoliciesTestSuite(t *testing.T) {
+	suite.Run(t, new(SoftwarePoliciesTestSuite))
+}
@@ -0,0 +1,274 @@
+package models
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/app"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+type VersionCount struct {
+	Version string
+	Count   int
+}
+
+type VersionDrift struct {
+	Name      string
+	Publisher string
+	Versions  []VersionCount
+	Newest    string
+	// CatalogVersions are the versions found in the catalogue sources used by the computers, indexed by source
+	CatalogVersions map[string]string
+	Computers       int
+	Laggards        int
+}
+
+type VersionLaggard struct {
+	AgentID  string
+	Nickname string
+	OS       string
+	Version  string
+}
+
+// Target is the newest version a computer should have, whatever its operating system
+func (d VersionDrift) Target() string {
+	target := d.Newest
+	for _, v := range d.CatalogVersions {
+		if CompareVersions(v, target) > 0 {
+			target = v
+		}
+	}
+	return target
+}
+
+// TargetForOS is the version a computer with that operating system should have, the version
+// published by its catalogue source is used if it's newer than the ones installed
+func (d VersionDrift) TargetForOS(os string) string {
+	if v := d.CatalogVersions[winget.CatalogSourceForOS(os)]; v != "" && CompareVersions(v, d.Newest) > 0 {
+		return v
+	}
+	return d.Newest
+}
+
+// CatalogSummary lists the catalogue versions with their source
+func (d VersionDrift) CatalogSummary() string {
+	sources := []string{}
+	for source := range d.CatalogVersions {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+
+	versions := []string{}
+	for _, source := range sources {
+		versions = append(versions, d.CatalogVersions[source]+" ("+source+")")
+	}
+	return strings.Join(versions, ", ")
+}
+
+func (m *Model) getScopedAppsWithOwner(search string, c *partials.CommonInfo) ([]*ent.App, error) {
+	var query *ent.AppQuery
+
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Info from agents waiting for admission won't be shown
+	if siteID == -1 {
+		query = m.Client.App.Query().Where(app.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query = m.Client.App.Query().Where(app.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+
+	if search != "" {
+		query.Where(app.Or(app.NameContainsFold(search), app.NormalizedNameContainsFold(search)))
+	}
+
+	return query.WithOwner().All(context.Background())
+}
+
+// GetVersionDrift returns the distribution of installed versions for every application,
+// catalog contains the versions found in the software catalogue indexed by lowercase application name and source
+func (m *Model) GetVersionDrift(p partials.PaginationAndSort, search string, catalog map[string]map[string]string, c *partials.CommonInfo) ([]VersionDrift, int, error) {
+	apps, err := m.getScopedAppsWithOwner(search, c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	drift := ComputeVersionDrift(apps, catalog)
+
+	switch p.SortBy {
+	case "name":
+		sort.SliceStable(drift, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return strings.ToLower(drift[i].Name) < strings.ToLower(drift[j].Name)
+			}
+			return strings.ToLower(drift[i].Name) > strings.ToLower(drift[j].Name)
+		})
+	case "computers":
+		sort.SliceStable(drift, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return drift[i].Computers < drift[j].Computers
+			}
+			return drift[i].Computers > drift[j].Computers
+		})
+	case "versions":
+		sort.SliceStable(drift, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return len(drift[i].Versions) < len(drift[j].Versions)
+			}
+			return len(drift[i].Versions) > len(drift[j].Versions)
+		})
+	default:
+		sort.SliceStable(drift, func(i, j int) bool {
+			if p.SortOrder == "asc" {
+				return drift[i].Laggards < drift[j].Laggards
+			}
+			return drift[i].Laggards > drift[j].Laggards
+		})
+	}
+
+	return paginateSlice(drift, p), len(drift), nil
+}
+
+// GetVersionDriftNames returns the names of the applications installed in the fleet so their catalogue versions can be looked up
+func (m *Model) GetVersionDriftNames(search string, c *partials.CommonInfo) ([]string, error) {
+	apps, err := m.getScopedAppsWithOwner(search, c)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	found := map[string]bool{}
+	for _, a := range apps {
+		name := appDisplayName(a)
+		if !found[name] {
+			found[name] = true
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// ComputeVersionDrift groups the applications by their normalized name, a computer with several
+// versions of the same application is counted with the newest one
+func ComputeVersionDrift(apps []*ent.App, catalog map[string]map[string]string) []VersionDrift {
+	type installed struct {
+		publisher string
+		versions  map[string]string
+		os        map[string]string
+	}
+
+	byName := map[string]*installed{}
+	names := []string{}
+	for _, a := range apps {
+		if a.Edges.Owner == nil {
+			continue
+		}
+		name := appDisplayName(a)
+		i, ok := byName[name]
+		if !ok {
+			i = &installed{publisher: appDisplayPublisher(a), versions: map[string]string{}, os: map[string]string{}}
+			byName[name] = i
+			names = append(names, name)
+		}
+		if v, ok := i.versions[a.Edges.Owner.ID]; !ok || CompareVersions(a.Version, v) > 0 {
+			i.versions[a.Edges.Owner.ID] = a.Version
+		}
+		i.os[a.Edges.Owner.ID] = a.Edges.Owner.Os
+	}
+
+	drift := []VersionDrift{}
+	for _, name := range names {
+		i := byName[name]
+		d := VersionDrift{Name: name, Publisher: i.publisher, Computers: len(i.versions), CatalogVersions: map[string]string{}}
+		for _, os := range i.os {
+			source := winget.CatalogSourceForOS(os)
+			if v := catalog[strings.ToLower(name)][source]; v != "" {
+				d.CatalogVersions[source] = v
+			}
+		}
+
+		counts := map[string]int{}
+		for _, v := range i.versions {
+			counts[v]++
+			if d.Newest == "" || CompareVersions(v, d.Newest) > 0 {
+				d.Newest = v
+			}
+		}
+		for v, n := range counts {
+			d.Versions = append(d.Versions, VersionCount{Version: v, Count: n})
+		}
+		sort.SliceStable(d.Versions, func(a, b int) bool {
+			return CompareVersions(d.Versions[a].Version, d.Versions[b].Version) > 0
+		})
+
+		for agentID, v := range i.versions {
+			if CompareVersions(v, d.TargetForOS(i.os[agentID])) < 0 {
+				d.Laggards++
+			}
+		}
+
+		drift = append(drift, d)
+	}
+
+	return drift
+}
+
+// GetVersionLaggards returns the computers with a version of the application older than the target for their operating system
+func (m *Model) GetVersionLaggards(d VersionDrift, c *partials.CommonInfo) ([]VersionLaggard, error) {
+	name := d.Name
+	apps, err := m.getScopedAppsWithOwner(name, c)
+	if err != nil {
+		return nil, err
+	}
+
+	newest := map[string]*ent.App{}
+	for _, a := range apps {
+		if a.Edges.Owner == nil || !strings.EqualFold(appDisplayName(a), name) {
+			continue
+		}
+		if current, ok := newest[a.Edges.Owner.ID]; !ok || CompareVersions(a.Version, current.Version) > 0 {
+			newest[a.Edges.Owner.ID] = a
+		}
+	}
+
+	laggards := []VersionLaggard{}
+	for _, a := range newest {
+		if CompareVersions(a.Version, d.TargetForOS(a.Edges.Owner.Os)) < 0 {
+			laggards = append(laggards, VersionLaggard{AgentID: a.Edges.Owner.ID, Nickname: a.Edges.Owner.Nickname, OS: a.Edges.Owner.Os, Version: a.Version})
+		}
+	}
+
+	sort.SliceStable(laggards, func(i, j int) bool {
+		return laggards[i].Nickname < laggards[j].Nickname
+	})
+
+	return laggards, nil
+}
+
+func appDisplayName(a *ent.App) string {
+	if a.NormalizedName != "" {
+		return a.NormalizedName
+	}
+	return a.Name
+}
+
+func appDisplayPublisher(a *ent.App) string {
+	if a.NormalizedPublisher != "" {
+		return a.NormalizedPublisher
+	}
+	return a.Publisher
+}
@@ -0,0 +1,125 @@
+package models
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	winget "github.com/scncore/scnorion-console/internal/models/winget"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersionDriftTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *VersionDriftTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	for i := 0; i < 4; i++ {
+		err := client.Agent.Create().
+			SetID("agent" + strconv.Itoa(i)).
+			SetHostname("agent" + strconv.Itoa(i)).
+			SetOs("windows").
+			SetNickname("agent" + strconv.Itoa(i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+	}
+
+	apps := []struct{ agent, name, version string }{
+		{"agent0", "Google Chrome", "126.0.6478.127"},
+		{"agent1", "Google Chrome", "126.0.6478.61"},
+		{"agent2", "Google Chrome", "126.0.6478.61"},
+		{"agent3", "Google Chrome", "125.0.1"},
+		{"agent3", "Google Chrome", "126.0.6478.127"},
+		{"agent0", "7-Zip", "23.01"},
+		{"agent1", "7-Zip", "23.01"},
+	}
+	for _, a := range apps {
+		err := client.App.Create().
+			SetName(a.name).
+			SetVersion(a.version).
+			SetOwnerID(a.agent).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5, SortBy: "laggards", SortOrder: "desc"}
+}
+
+func (suite *VersionDriftTestSuite) TestGetVersionDrift() {
+	drift, total, err := suite.model.GetVersionDrift(suite.p, "", nil, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get version drift")
+	assert.Equal(suite.T(), 2, total, "should group apps by name")
+	assert.Equal(suite.T(), "Google Chrome", drift[0].Name, "apps with more laggards should come first")
+	assert.Equal(suite.T(), 4, drift[0].Computers, "should count computers once")
+	assert.Equal(suite.T(), "126.0.6478.127", drift[0].Newest, "should get the newest version")
+	assert.Equal(suite.T(), 2, len(drift[0].Versions), "should use the newest version of each computer")
+	assert.Equal(suite.T(), VersionCount{Version: "126.0.6478.127", Count: 2}, drift[0].Versions[0], "newest versions should come first")
+	assert.Equal(suite.T(), 2, drift[0].Laggards, "should count computers behind the newest version")
+	assert.Equal(suite.T(), 0, drift[1].Laggards, "7-Zip should have no laggards")
+
+	drift, _, err = suite.model.GetVersionDrift(suite.p, "zip", map[string]map[string]string{"7-zip": {"winget": "24.08"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get version drift")
+	assert.Equal(suite.T(), 1, len(drift), "should filter by name")
+	assert.Equal(suite.T(), "24.08", drift[0].Target(), "should use the catalogue version if newer")
+	assert.Equal(suite.T(), 2, drift[0].Laggards, "should count computers behind the catalogue version")
+
+	drift, _, err = suite.model.GetVersionDrift(suite.p, "zip", map[string]map[string]string{"7-zip": {"flatpak": "24.08", "brew": "24.09"}}, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get version drift")
+	assert.Equal(suite.T(), "23.01", drift[0].Target(), "should ignore the sources the computers don't use")
+	assert.Equal(suite.T(), "", drift[0].CatalogSummary(), "should not show versions of other platforms")
+	assert.Equal(suite.T(), 0, drift[0].Laggards, "should compare computers with the source of their platform")
+}
+
+func (suite *VersionDriftTestSuite) TestGetVersionLaggards() {
+	drift, _, err := suite.model.GetVersionDrift(suite.p, "google chrome", nil, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get version drift")
+
+	laggards, err := suite.model.GetVersionLaggards(drift[0], suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get laggards")
+	assert.Equal(suite.T(), 2, len(laggards), "should get computers behind the target")
+	assert.Equal(suite.T(), "agent1", laggards[0].AgentID)
+	assert.Equal(suite.T(), "agent2", laggards[1].AgentID)
+	assert.Equal(suite.T(), "windows", laggards[0].OS)
+
+	err = suite.model.Client.Agent.UpdateOneID("agent0").SetOs("ubuntu").Exec(context.Background())
+	assert.NoError(suite.T(), err, "should update agent")
+
+	drift[0].CatalogVersions = map[string]string{"flatpak": "127.0.1"}
+	laggards, err = suite.model.GetVersionLaggards(drift[0], suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get laggards")
+	assert.Equal(suite.T(), 3, len(laggards), "should use the catalogue version for the agent's platform")
+	assert.Equal(suite.T(), "agent0", laggards[0].AgentID)
+	assert.Equal(suite.T(), "ubuntu", laggards[0].OS)
+}
+
+func (suite *VersionDriftTestSuite) TestCatalogSourceForOS() {
+	assert.Equal(suite.T(), "winget", winget.CatalogSourceForOS("windows"))
+	assert.Equal(suite.T(), "brew", winget.CatalogSourceForOS("macOS"))
+	assert.Equal(suite.T(), "flatpak", winget.CatalogSourceForOS("debian"))
+	assert.Equal(suite.T(), "", winget.CatalogSourceForOS("freebsd"), "should not use flatpak for unknown systems")
+}
+
+func TestVersionDriftTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionDriftTestSuite))
+}
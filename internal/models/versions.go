@@ -0,0 +1,68 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// CompareVersions compares two loosely formatted versions (1.2, v1.2.3, 10.0.19045.1, 2.0.0-beta.1+build5)
+// number by number, returning -1, 0 or 1. Parts that are not numbers are compared as text, build metadata
+// is ignored and, as in semantic versioning, a pre-release comes before its release (2.0.0-rc1 < 2.0.0)
+func CompareVersions(a, b string) int {
+	pa := splitVersion(a)
+	pb := splitVersion(b)
+
+	for i := 0; i < len(pa) || i < len(pb); i++ {
+		x, y := "0", "0"
+		if i < len(pa) {
+			x = pa[i]
+		}
+		if i < len(pb) {
+			y = pb[i]
+		}
+
+		nx, errX := strconv.Atoi(x)
+		ny, errY := strconv.Atoi(y)
+		switch {
+		case i >= len(pa) && errY != nil:
+			return 1
+		case i >= len(pb) && errX != nil:
+			return -1
+		case errX == nil && errY == nil:
+			if nx != ny {
+				if nx < ny {
+					return -1
+				}
+				return 1
+			}
+		default:
+			if c := strings.Compare(strings.ToLower(x), strings.ToLower(y)); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return 0
+}
+
+func splitVersion(version string) []string {
+	version = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(version)), "v")
+	version, _, _ = strings.Cut(version, "+")
+
+	// Numbers and letters are split too so 1.0rc1 is read as 1, 0, rc, 1
+	parts := []string{}
+	for _, field := range strings.FieldsFunc(version, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		start := 0
+		for i := 1; i < len(field); i++ {
+			if unicode.IsDigit(rune(field[i])) != unicode.IsDigit(rune(field[i-1])) {
+				parts = append(parts, field[start:i])
+				start = i
+			}
+		}
+		parts = append(parts, field[start:])
+	}
+	return parts
+}
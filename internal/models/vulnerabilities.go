@@ -0,0 +1,748 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"entgo.io/ent/dialect/sql"
+
+	ent "github.com/scncore/ent"
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/appvulnerability"
+	"github.com/scncore/ent/site"
+	"github.com/scncore/ent/tenant"
+	"github.com/scncore/ent/vulnerability"
+	"github.com/scncore/ent/vulnerableproduct"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+var VulnerabilitySeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "NONE"}
+
+// VulnerabilityFeedEntry is a vulnerability read from a NVD or OSV feed
+type VulnerabilityFeedEntry struct {
+	ID        string
+	Source    string
+	Summary   string
+	Severity  string
+	Score     float64
+	Published *time.Time
+	Products  []VulnerableProductRange
+}
+
+// VulnerableProductRange describes the versions of a product affected by a vulnerability. If Version
+// is set only that version is affected, otherwise the range limits are checked if they're set
+type VulnerableProductRange struct {
+	Vendor         string
+	Product        string
+	Version        string
+	StartIncluding string
+	StartExcluding string
+	EndIncluding   string
+	EndExcluding   string
+}
+
+type VulnerabilityExposure struct {
+	CVE       string
+	Summary   string
+	Severity  string
+	Score     float64
+	Computers int
+}
+
+type ExposedComputer struct {
+	AgentID  string
+	Nickname string
+	OS       string
+	Critical int
+	High     int
+	Medium   int
+	Low      int
+	Total    int
+	MaxScore float64
+}
+
+type nvdFeed struct {
+	Vulnerabilities []nvdVulnerability `json:"vulnerabilities"`
+}
+
+type nvdVulnerability struct {
+	CVE struct {
+		ID           string `json:"id"`
+		Published    string `json:"published"`
+		Descriptions []struct {
+			Lang  string `json:"lang"`
+			Value string `json:"value"`
+		} `json:"descriptions"`
+		Metrics map[string][]struct {
+			BaseSeverity string `json:"baseSeverity"`
+			CVSSData     struct {
+				BaseScore    float64 `json:"baseScore"`
+				BaseSeverity string  `json:"baseSeverity"`
+			} `json:"cvssData"`
+		} `json:"metrics"`
+		Configurations []struct {
+			Nodes []struct {
+				CPEMatch []struct {
+					Vulnerable            bool   `json:"vulnerable"`
+					Criteria              string `json:"criteria"`
+					VersionStartIncluding string `json:"versionStartIncluding"`
+					VersionStartExcluding string `json:"versionStartExcluding"`
+					VersionEndIncluding   string `json:"versionEndIncluding"`
+					VersionEndExcluding   string `json:"versionEndExcluding"`
+				} `json:"cpeMatch"`
+			} `json:"nodes"`
+		} `json:"configurations"`
+	} `json:"cve"`
+}
+
+type osvEntry struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases"`
+	Summary   string   `json:"summary"`
+	Details   string   `json:"details"`
+	Published string   `json:"published"`
+	Affected  []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Events []struct {
+				Introduced   string `json:"introduced"`
+				Fixed        string `json:"fixed"`
+				LastAffected string `json:"last_affected"`
+			} `json:"events"`
+		} `json:"ranges"`
+		Versions []string `json:"versions"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+// ParseVulnerabilityFeed reads a NVD JSON 2.0 feed, an OSV entry or a list of OSV entries. The vulnerabilities
+// of NVD feeds and OSV lists are decoded one by one, so big feeds are never loaded in memory as a whole
+func ParseVulnerabilityFeed(r io.Reader) ([]VulnerabilityFeedEntry, error) {
+	errUnknownFeed := errors.New("the file is not a NVD or OSV vulnerability feed")
+	dec := json.NewDecoder(r)
+
+	t, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []VulnerabilityFeedEntry{}
+	switch t {
+	case json.Delim('['):
+		for dec.More() {
+			entry := osvEntry{}
+			if err := dec.Decode(&entry); err != nil {
+				return nil, err
+			}
+			entries = append(entries, parseOSVEntries([]osvEntry{entry})...)
+		}
+		return entries, nil
+	case json.Delim('{'):
+		isNVD := false
+		fields := map[string]json.RawMessage{}
+		for dec.More() {
+			t, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, _ := t.(string)
+
+			if key != "vulnerabilities" {
+				raw := json.RawMessage{}
+				if err := dec.Decode(&raw); err != nil {
+					return nil, err
+				}
+				fields[key] = raw
+				continue
+			}
+
+			isNVD = true
+			if t, err := dec.Token(); err != nil || t != json.Delim('[') {
+				return nil, errUnknownFeed
+			}
+			for dec.More() {
+				v := nvdVulnerability{}
+				if err := dec.Decode(&v); err != nil {
+					return nil, err
+				}
+				entries = append(entries, parseNVDFeed(nvdFeed{Vulnerabilities: []nvdVulnerability{v}})...)
+			}
+			if _, err := dec.Token(); err != nil {
+				return nil, err
+			}
+		}
+
+		if isNVD {
+			return entries, nil
+		}
+
+		// A single OSV entry is small, so it's decoded once it has been read
+		if _, ok := fields["affected"]; ok {
+			data, err := json.Marshal(fields)
+			if err != nil {
+				return nil, err
+			}
+			entry := osvEntry{}
+			if err := json.Unmarshal(data, &entry); err != nil {
+				return nil, err
+			}
+			return parseOSVEntries([]osvEntry{entry}), nil
+		}
+	}
+
+	return nil, errUnknownFeed
+}
+
+func parseNVDFeed(feed nvdFeed) []VulnerabilityFeedEntry {
+	entries := []VulnerabilityFeedEntry{}
+
+	for _, v := range feed.Vulnerabilities {
+		e := VulnerabilityFeedEntry{ID: v.CVE.ID, Source: "nvd"}
+		for _, d := range v.CVE.Descriptions {
+			if d.Lang == "en" {
+				e.Summary = d.Value
+				break
+			}
+		}
+		if published, err := time.Parse("2006-01-02T15:04:05", strings.Split(v.CVE.Published, ".")[0]); err == nil {
+			e.Published = &published
+		}
+
+		// Prefer the most recent CVSS version
+		for _, key := range []string{"cvssMetricV40", "cvssMetricV31", "cvssMetricV30", "cvssMetricV2"} {
+			metrics, ok := v.CVE.Metrics[key]
+			if !ok || len(metrics) == 0 {
+				continue
+			}
+			e.Score = metrics[0].CVSSData.BaseScore
+			e.Severity = strings.ToUpper(metrics[0].CVSSData.BaseSeverity)
+			if e.Severity == "" {
+				e.Severity = strings.ToUpper(metrics[0].BaseSeverity)
+			}
+			break
+		}
+		// CVSS v2 metrics may come without a severity and entries without metrics have no score
+		if e.Severity == "" {
+			e.Severity = SeverityFromScore(e.Score)
+		}
+
+		for _, c := range v.CVE.Configurations {
+			for _, n := range c.Nodes {
+				for _, m := range n.CPEMatch {
+					if !m.Vulnerable {
+						continue
+					}
+					// cpe:2.3:part:vendor:product:version:...
+					parts := strings.Split(m.Criteria, ":")
+					if len(parts) < 6 || parts[2] != "a" {
+						continue
+					}
+					r := VulnerableProductRange{
+						Vendor:         parts[3],
+						Product:        parts[4],
+						StartIncluding: m.VersionStartIncluding,
+						StartExcluding: m.VersionStartExcluding,
+						EndIncluding:   m.VersionEndIncluding,
+						EndExcluding:   m.VersionEndExcluding,
+					}
+					if parts[5] != "*" && parts[5] != "-" {
+						r.Version = parts[5]
+					}
+					e.Products = append(e.Products, r)
+				}
+			}
+		}
+
+		if len(e.Products) > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+func parseOSVEntries(osv []osvEntry) []VulnerabilityFeedEntry {
+	entries := []VulnerabilityFeedEntry{}
+
+	for _, v := range osv {
+		e := VulnerabilityFeedEntry{ID: v.ID, Source: "osv", Summary: v.Summary, Severity: strings.ToUpper(v.DatabaseSpecific.Severity)}
+		// Use the CVE identifier if the entry has one
+		for _, alias := range v.Aliases {
+			if strings.HasPrefix(alias, "CVE-") {
+				e.ID = alias
+				break
+			}
+		}
+		if e.Summary == "" {
+			e.Summary = v.Details
+		}
+		if e.Severity == "MODERATE" {
+			e.Severity = "MEDIUM"
+		}
+		if e.Severity == "" {
+			e.Severity = "NONE"
+		}
+		if published, err := time.Parse(time.RFC3339, v.Published); err == nil {
+			e.Published = &published
+		}
+
+		for _, a := range v.Affected {
+			for _, version := range a.Versions {
+				e.Products = append(e.Products, VulnerableProductRange{Product: a.Package.Name, Version: version})
+			}
+			for _, r := range a.Ranges {
+				current := VulnerableProductRange{Product: a.Package.Name}
+				for _, ev := range r.Events {
+					switch {
+					case ev.Introduced != "":
+						current.StartIncluding = ev.Introduced
+						if ev.Introduced == "0" {
+							current.StartIncluding = ""
+						}
+					case ev.Fixed != "":
+						current.EndExcluding = ev.Fixed
+						e.Products = append(e.Products, current)
+						current = VulnerableProductRange{Product: a.Package.Name}
+					case ev.LastAffected != "":
+						current.EndIncluding = ev.LastAffected
+						e.Products = append(e.Products, current)
+						current = VulnerableProductRange{Product: a.Package.Name}
+					}
+				}
+				// Without a fix every version since the introduced one is affected
+				if current.StartIncluding != "" {
+					e.Products = append(e.Products, current)
+				}
+			}
+		}
+
+		if len(e.Products) > 0 {
+			entries = append(entries, e)
+		}
+	}
+
+	return entries
+}
+
+// SeverityFromScore maps a CVSS score to its qualitative severity
+func SeverityFromScore(score float64) string {
+	switch {
+	case score >= 9:
+		return "CRITICAL"
+	case score >= 7:
+		return "HIGH"
+	case score >= 4:
+		return "MEDIUM"
+	case score > 0:
+		return "LOW"
+	default:
+		return "NONE"
+	}
+}
+
+// ImportVulnerabilityFeed saves the vulnerabilities of a feed, replacing the products of the ones already known.
+// The feed is saved in a single transaction so a feed that fails is not imported partially
+func (m *Model) ImportVulnerabilityFeed(r io.Reader) (int, error) {
+	entries, err := ParseVulnerabilityFeed(r)
+	if err != nil {
+		return 0, err
+	}
+
+	ctx := context.Background()
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, e := range entries {
+		v, err := tx.Vulnerability.Query().Where(vulnerability.CveID(e.ID)).Only(ctx)
+		if err != nil {
+			if !ent.IsNotFound(err) {
+				return 0, rollback(tx, err)
+			}
+			v, err = tx.Vulnerability.Create().
+				SetCveID(e.ID).
+				SetSource(e.Source).
+				SetSummary(e.Summary).
+				SetSeverity(e.Severity).
+				SetScore(e.Score).
+				SetNillablePublished(e.Published).
+				Save(ctx)
+			if err != nil {
+				return 0, rollback(tx, err)
+			}
+		} else {
+			if err := tx.Vulnerability.UpdateOneID(v.ID).
+				SetSource(e.Source).
+				SetSummary(e.Summary).
+				SetSeverity(e.Severity).
+				SetScore(e.Score).
+				SetNillablePublished(e.Published).
+				Exec(ctx); err != nil {
+				return 0, rollback(tx, err)
+			}
+			if _, err := tx.VulnerableProduct.Delete().Where(vulnerableproduct.HasVulnerabilityWith(vulnerability.ID(v.ID))).Exec(ctx); err != nil {
+				return 0, rollback(tx, err)
+			}
+		}
+
+		builders := []*ent.VulnerableProductCreate{}
+		for _, p := range e.Products {
+			builders = append(builders, tx.VulnerableProduct.Create().
+				SetVendor(NormalizeProductName(p.Vendor)).
+				SetProduct(NormalizeProductName(p.Product)).
+				SetVersion(p.Version).
+				SetVersionStartIncluding(p.StartIncluding).
+				SetVersionStartExcluding(p.StartExcluding).
+				SetVersionEndIncluding(p.EndIncluding).
+				SetVersionEndExcluding(p.EndExcluding).
+				SetVulnerabilityID(v.ID))
+		}
+		if err := tx.VulnerableProduct.CreateBulk(builders...).Exec(ctx); err != nil {
+			return 0, rollback(tx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(entries), nil
+}
+
+// ImportVulnerabilityFeedFile imports a feed file and renames it so it's not imported again
+func (m *Model) ImportVulnerabilityFeedFile(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := m.ImportVulnerabilityFeed(file)
+	file.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.Rename(path, strings.TrimSuffix(path, filepath.Ext(path))+".imported"); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
+
+// NormalizeProductName turns names like "Google Chrome" or "google-chrome" into "google_chrome" as CPE does
+func NormalizeProductName(name string) string {
+	tokens := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '+'
+	})
+	return strings.Join(tokens, "_")
+}
+
+// productCandidates returns the sequences of words of an application name that may be a CPE product name
+func productCandidates(name string) []string {
+	tokens := strings.Split(NormalizeProductName(name), "_")
+	candidates := []string{}
+	for i := range tokens {
+		for j := i + 1; j <= len(tokens) && j-i <= 4; j++ {
+			candidates = append(candidates, strings.Join(tokens[i:j], "_"))
+		}
+	}
+	return candidates
+}
+
+// MatchVulnerableProduct checks if an application is affected by a vulnerable product
+func MatchVulnerableProduct(p *ent.VulnerableProduct, a *ent.App) bool {
+	if p.Vendor != "" {
+		vendor := "_" + p.Vendor + "_"
+		if !strings.Contains("_"+NormalizeProductName(a.Publisher)+"_", vendor) && !strings.Contains("_"+NormalizeProductName(a.Name)+"_", vendor) {
+			return false
+		}
+	}
+
+	if a.Version == "" {
+		return false
+	}
+
+	if p.Version != "" {
+		return CompareVersions(a.Version, p.Version) == 0
+	}
+
+	if p.VersionStartIncluding != "" && CompareVersions(a.Version, p.VersionStartIncluding) < 0 {
+		return false
+	}
+	if p.VersionStartExcluding != "" && CompareVersions(a.Version, p.VersionStartExcluding) <= 0 {
+		return false
+	}
+	if p.VersionEndIncluding != "" && CompareVersions(a.Version, p.VersionEndIncluding) > 0 {
+		return false
+	}
+	if p.VersionEndExcluding != "" && CompareVersions(a.Version, p.VersionEndExcluding) >= 0 {
+		return false
+	}
+
+	// A product without any version information would match every installation
+	return p.VersionStartIncluding != "" || p.VersionStartExcluding != "" || p.VersionEndIncluding != "" || p.VersionEndExcluding != ""
+}
+
+// MatchVulnerabilities matches the installed applications of every agent with the imported vulnerabilities
+func (m *Model) MatchVulnerabilities() (int, error) {
+	products, err := m.Client.VulnerableProduct.Query().WithVulnerability().All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	byProduct := map[string][]*ent.VulnerableProduct{}
+	for _, p := range products {
+		byProduct[p.Product] = append(byProduct[p.Product], p)
+	}
+
+	apps, err := m.Client.App.Query().WithOwner().All(context.Background())
+	if err != nil {
+		return 0, err
+	}
+
+	// The matches are replaced in a single transaction so the vulnerabilities are never shown empty or half matched
+	ctx := context.Background()
+	tx, err := m.Client.Tx(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.AppVulnerability.Delete().Exec(ctx); err != nil {
+		return 0, rollback(tx, err)
+	}
+
+	builders := []*ent.AppVulnerabilityCreate{}
+	for _, a := range apps {
+		if a.Edges.Owner == nil {
+			continue
+		}
+
+		found := map[int]bool{}
+		for _, candidate := range productCandidates(a.Name) {
+			for _, p := range byProduct[candidate] {
+				if p.Edges.Vulnerability == nil || found[p.Edges.Vulnerability.ID] || !MatchVulnerableProduct(p, a) {
+					continue
+				}
+				found[p.Edges.Vulnerability.ID] = true
+				builders = append(builders, tx.AppVulnerability.Create().
+					SetAppName(a.Name).
+					SetAppVersion(a.Version).
+					SetOwnerID(a.Edges.Owner.ID).
+					SetVulnerabilityID(p.Edges.Vulnerability.ID))
+			}
+		}
+	}
+
+	// Save in batches to avoid too many parameters in a single query
+	for start := 0; start < len(builders); start += 500 {
+		end := min(start+500, len(builders))
+		if err := tx.AppVulnerability.CreateBulk(builders[start:end]...).Exec(ctx); err != nil {
+			return 0, rollback(tx, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return len(builders), nil
+}
+
+func (m *Model) GetAppVulnerabilities(c *partials.CommonInfo) ([]*ent.AppVulnerability, error) {
+	siteID, err := strconv.Atoi(c.SiteID)
+	if err != nil {
+		return nil, err
+	}
+	tenantID, err := strconv.Atoi(c.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	query := m.Client.AppVulnerability.Query().WithOwner().WithVulnerability()
+	if siteID == -1 {
+		query = query.Where(appvulnerability.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.HasTenantWith(tenant.ID(tenantID)))))
+	} else {
+		query = query.Where(appvulnerability.HasOwnerWith(agent.AgentStatusNEQ(agent.AgentStatusWaitingForAdmission), agent.HasSiteWith(site.ID(siteID), site.HasTenantWith(tenant.ID(tenantID)))))
+	}
+
+	return query.All(context.Background())
+}
+
+// GetVulnerabilityExposures groups the vulnerable applications by CVE, counting the affected computers
+func (m *Model) GetVulnerabilityExposures(p partials.PaginationAndSort, c *partials.CommonInfo) ([]VulnerabilityExposure, int, error) {
+	matches, err := m.GetAppVulnerabilities(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	exposures := []VulnerabilityExposure{}
+	index := map[string]int{}
+	computers := map[string]map[string]bool{}
+	for _, match := range matches {
+		v := match.Edges.Vulnerability
+		if v == nil || match.Edges.Owner == nil {
+			continue
+		}
+
+		i, ok := index[v.CveID]
+		if !ok {
+			i = len(exposures)
+			index[v.CveID] = i
+			computers[v.CveID] = map[string]bool{}
+			exposures = append(exposures, VulnerabilityExposure{CVE: v.CveID, Summary: v.Summary, Severity: v.Severity, Score: v.Score})
+		}
+		if !computers[v.CveID][match.Edges.Owner.ID] {
+			computers[v.CveID][match.Edges.Owner.ID] = true
+			exposures[i].Computers++
+		}
+	}
+
+	less := func(i, j int) bool {
+		switch p.SortBy {
+		case "cve":
+			return exposures[i].CVE < exposures[j].CVE
+		case "computers":
+			return exposures[i].Computers < exposures[j].Computers
+		default:
+			return exposures[i].Score < exposures[j].Score
+		}
+	}
+	sort.SliceStable(exposures, func(i, j int) bool {
+		if p.SortOrder == "desc" {
+			return less(j, i)
+		}
+		return less(i, j)
+	})
+
+	return paginateSlice(exposures, p), len(exposures), nil
+}
+
+// GetExposedComputers counts the vulnerabilities of each computer by severity, most exposed first
+func (m *Model) GetExposedComputers(p partials.PaginationAndSort, c *partials.CommonInfo) ([]ExposedComputer, int, error) {
+	matches, err := m.GetAppVulnerabilities(c)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	computers := []ExposedComputer{}
+	index := map[string]int{}
+	seen := map[string]bool{}
+	for _, match := range matches {
+		v := match.Edges.Vulnerability
+		owner := match.Edges.Owner
+		if v == nil || owner == nil || seen[owner.ID+v.CveID] {
+			continue
+		}
+		seen[owner.ID+v.CveID] = true
+
+		i, ok := index[owner.ID]
+		if !ok {
+			i = len(computers)
+			index[owner.ID] = i
+			computers = append(computers, ExposedComputer{AgentID: owner.ID, Nickname: owner.Nickname, OS: owner.Os})
+		}
+
+		switch v.Severity {
+		case "CRITICAL":
+			computers[i].Critical++
+		case "HIGH":
+			computers[i].High++
+		case "MEDIUM":
+			computers[i].Medium++
+		default:
+			computers[i].Low++
+		}
+		computers[i].Total++
+		computers[i].MaxScore = max(computers[i].MaxScore, v.Score)
+	}
+
+	// Computers are ranked by their most severe vulnerabilities
+	exposure := func(e ExposedComputer) []int {
+		return []int{e.Critical, e.High, e.Medium, e.Low}
+	}
+	less := func(i, j int) bool {
+		switch p.SortBy {
+		case "nickname":
+			return computers[i].Nickname < computers[j].Nickname
+		case "total":
+			return computers[i].Total < computers[j].Total
+		default:
+			a, b := exposure(computers[i]), exposure(computers[j])
+			for k := range a {
+				if a[k] != b[k] {
+					return a[k] < b[k]
+				}
+			}
+			return false
+		}
+	}
+	sort.SliceStable(computers, func(i, j int) bool {
+		if p.SortOrder == "asc" {
+			return less(i, j)
+		}
+		return less(j, i)
+	})
+
+	return paginateSlice(computers, p), len(computers), nil
+}
+
+func (m *Model) GetVulnerability(cve string) (*ent.Vulnerability, error) {
+	return m.Client.Vulnerability.Query().Where(vulnerability.CveID(cve)).Only(context.Background())
+}
+
+// GetVulnerabilityComputers returns the vulnerable applications affected by a CVE
+func (m *Model) GetVulnerabilityComputers(cve string, c *partials.CommonInfo) ([]*ent.AppVulnerability, error) {
+	matches, err := m.GetAppVulnerabilities(c)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*ent.AppVulnerability{}
+	for _, match := range matches {
+		if match.Edges.Vulnerability != nil && match.Edges.Vulnerability.CveID == cve {
+			result = append(result, match)
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Edges.Owner.Nickname < result[j].Edges.Owner.Nickname
+	})
+
+	return result, nil
+}
+
+// GetAgentVulnerabilities returns the vulnerable applications of a computer, most severe first
+func (m *Model) GetAgentVulnerabilities(agentID string) ([]*ent.AppVulnerability, error) {
+	return m.Client.AppVulnerability.Query().WithVulnerability().
+		Where(appvulnerability.HasOwnerWith(agent.ID(agentID))).
+		Order(appvulnerability.ByVulnerabilityField(vulnerability.FieldScore, sql.OrderDesc())).
+		All(context.Background())
+}
+
+func (m *Model) CountVulnerabilities() (int, error) {
+	return m.Client.Vulnerability.Query().Count(context.Background())
+}
+
+func paginateSlice[T any](items []T, p partials.PaginationAndSort) []T {
+	if p.PageSize == 0 {
+		return items
+	}
+
+	start := min((p.CurrentPage-1)*p.PageSize, len(items))
+	end := min(start+p.PageSize, len(items))
+	return items[start:end]
+}
@@ -0,0 +1,193 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/scncore/ent/agent"
+	"github.com/scncore/ent/enttest"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const nvdTestFeed = `{
+	"vulnerabilities": [
+		{
+			"cve": {
+				"id": "CVE-2024-0001",
+				"published": "2024-01-10T15:15:08.123",
+				"descriptions": [{"lang": "en", "value": "Remote code execution in 7-Zip"}],
+				"metrics": {"cvssMetricV31": [{"cvssData": {"baseScore": 9.8, "baseSeverity": "CRITICAL"}}]},
+				"configurations": [{"nodes": [{"cpeMatch": [{"vulnerable": true, "criteria": "cpe:2.3:a:7-zip:7-zip:*:*:*:*:*:*:*:*", "versionEndExcluding": "23.01"}]}]}]
+			}
+		},
+		{
+			"cve": {
+				"id": "CVE-2024-0002",
+				"published": "2024-02-01T10:00:00.000",
+				"descriptions": [{"lang": "en", "value": "Information disclosure in Firefox"}],
+				"metrics": {"cvssMetricV31": [{"cvssData": {"baseScore": 5.3, "baseSeverity": "MEDIUM"}}]},
+				"configurations": [{"nodes": [{"cpeMatch": [{"vulnerable": true, "criteria": "cpe:2.3:a:mozilla:firefox:127.0:*:*:*:*:*:*:*"}]}]}]
+			}
+		}
+	]
+}`
+
+const osvTestFeed = `[
+	{
+		"id": "GHSA-xxxx-yyyy-zzzz",
+		"aliases": ["CVE-2024-0003"],
+		"summary": "Buffer overflow in curl",
+		"published": "2024-03-01T00:00:00Z",
+		"affected": [{"package": {"name": "curl"}, "ranges": [{"events": [{"introduced": "7.0"}, {"fixed": "8.6.0"}]}]}],
+		"database_specific": {"severity": "HIGH"}
+	}
+]`
+
+type VulnerabilitiesTestSuite struct {
+	suite.Suite
+	t          enttest.TestingT
+	model      Model
+	p          partials.PaginationAndSort
+	commonInfo *partials.CommonInfo
+}
+
+func (suite *VulnerabilitiesTestSuite) SetupTest() {
+	client := enttest.Open(suite.t, "sqlite3", "file:ent?mode=memory&_fk=1")
+	suite.model = Model{Client: client}
+
+	t, err := suite.model.CreateDefaultTenant()
+	assert.NoError(suite.T(), err, "should create default tenant")
+
+	s, err := suite.model.CreateDefaultSite(t)
+	assert.NoError(suite.T(), err, "should create default site")
+
+	suite.commonInfo = &partials.CommonInfo{TenantID: strconv.Itoa(t.ID), SiteID: strconv.Itoa(s.ID)}
+
+	apps := []struct{ name, publisher, version string }{
+		{"7-Zip 22.01 (x64)", "Igor Pavlov", "22.01"},
+		{"7-Zip 23.01 (x64)", "Igor Pavlov", "23.01"},
+		{"Mozilla Firefox (x64 es-ES)", "Mozilla", "127.0"},
+		{"curl", "", "8.5.0"},
+	}
+	for i, a := range apps {
+		err := client.Agent.Create().
+			SetID(fmt.Sprintf("agent%d", i)).
+			SetHostname(fmt.Sprintf("agent%d", i)).
+			SetOs("windows").
+			SetNickname(fmt.Sprintf("agent%d", i)).
+			SetAgentStatus(agent.AgentStatusEnabled).
+			AddSiteIDs(s.ID).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create agent")
+
+		err = client.App.Create().
+			SetName(a.name).
+			SetPublisher(a.publisher).
+			SetVersion(a.version).
+			SetOwnerID(fmt.Sprintf("agent%d", i)).
+			Exec(context.Background())
+		assert.NoError(suite.T(), err, "should create app")
+	}
+
+	suite.p = partials.PaginationAndSort{CurrentPage: 1, PageSize: 5}
+}
+
+func (suite *VulnerabilitiesTestSuite) TestParseVulnerabilityFeed() {
+	entries, err := ParseVulnerabilityFeed(strings.NewReader(nvdTestFeed))
+	assert.NoError(suite.T(), err, "should parse NVD feed")
+	assert.Equal(suite.T(), 2, len(entries), "should get two vulnerabilities")
+	assert.Equal(suite.T(), "CRITICAL", entries[0].Severity, "should get the CVSS severity")
+	assert.Equal(suite.T(), "23.01", entries[0].Products[0].EndExcluding, "should get the version range")
+	assert.Equal(suite.T(), "127.0", entries[1].Products[0].Version, "should get the CPE version")
+
+	entries, err = ParseVulnerabilityFeed(strings.NewReader(osvTestFeed))
+	assert.NoError(suite.T(), err, "should parse OSV feed")
+	assert.Equal(suite.T(), "CVE-2024-0003", entries[0].ID, "should use the CVE alias")
+	assert.Equal(suite.T(), "8.6.0", entries[0].Products[0].EndExcluding, "should get the fixed version")
+
+	_, err = ParseVulnerabilityFeed(strings.NewReader(`{"name": "test"}`))
+	assert.Error(suite.T(), err, "should not parse unknown files")
+}
+
+func (suite *VulnerabilitiesTestSuite) TestMatchVulnerabilities() {
+	n, err := suite.model.ImportVulnerabilityFeed(strings.NewReader(nvdTestFeed))
+	assert.NoError(suite.T(), err, "should import NVD feed")
+	assert.Equal(suite.T(), 2, n, "should import two vulnerabilities")
+
+	_, err = suite.model.ImportVulnerabilityFeed(strings.NewReader(osvTestFeed))
+	assert.NoError(suite.T(), err, "should import OSV feed")
+
+	// Importing a feed again replaces the known vulnerabilities
+	_, err = suite.model.ImportVulnerabilityFeed(strings.NewReader(nvdTestFeed))
+	assert.NoError(suite.T(), err, "should import NVD feed again")
+
+	count, err := suite.model.CountVulnerabilities()
+	assert.NoError(suite.T(), err, "should count vulnerabilities")
+	assert.Equal(suite.T(), 3, count, "should not duplicate vulnerabilities")
+
+	matches, err := suite.model.MatchVulnerabilities()
+	assert.NoError(suite.T(), err, "should match vulnerabilities")
+	assert.Equal(suite.T(), 3, matches, "7-Zip 22.01, Firefox 127.0 and curl 8.5.0 should be vulnerable")
+
+	exposures, total, err := suite.model.GetVulnerabilityExposures(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get exposures")
+	assert.Equal(suite.T(), 3, total, "should get three CVEs")
+	assert.Equal(suite.T(), "CVE-2024-0001", exposures[0].CVE, "should sort by score")
+
+	computers, total, err := suite.model.GetExposedComputers(suite.p, suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get exposed computers")
+	assert.Equal(suite.T(), 3, total, "should get three computers")
+	assert.Equal(suite.T(), "agent0", computers[0].AgentID, "the computer with a critical vulnerability should be the most exposed")
+
+	affected, err := suite.model.GetVulnerabilityComputers("CVE-2024-0002", suite.commonInfo)
+	assert.NoError(suite.T(), err, "should get affected computers")
+	assert.Equal(suite.T(), 1, len(affected), "should get one computer")
+
+	vulnerabilities, err := suite.model.GetAgentVulnerabilities("agent1")
+	assert.NoError(suite.T(), err, "should get agent vulnerabilities")
+	assert.Equal(suite.T(), 0, len(vulnerabilities), "7-Zip 23.01 is not vulnerable")
+}
+
+func (suite *VulnerabilitiesTestSuite) TestSeverityFromScore() {
+	feed := `{"vulnerabilities": [{"cve": {"id": "CVE-2024-0009", "metrics": {"cvssMetricV2": [{"cvssData": {"baseScore": 7.5}}]},
+		"configurations": [{"nodes": [{"cpeMatch": [{"vulnerable": true, "criteria": "cpe:2.3:a:vendor:product:1.0:*:*:*:*:*:*:*"}]}]}]}}]}`
+	entries, err := ParseVulnerabilityFeed(strings.NewReader(feed))
+	assert.NoError(suite.T(), err, "should parse NVD feed")
+	assert.Equal(suite.T(), "HIGH", entries[0].Severity, "the severity should be computed from the score")
+
+	assert.Equal(suite.T(), "CRITICAL", SeverityFromScore(9.8))
+	assert.Equal(suite.T(), "MEDIUM", SeverityFromScore(4))
+	assert.Equal(suite.T(), "NONE", SeverityFromScore(0))
+}
+
+func (suite *VulnerabilitiesTestSuite) TestImportVulnerabilityFeedFile() {
+	path := filepath.Join(suite.T().TempDir(), "feed.json")
+	err := os.WriteFile(path, []byte(nvdTestFeed), 0600)
+	assert.NoError(suite.T(), err, "should write feed")
+
+	n, err := suite.model.ImportVulnerabilityFeedFile(path)
+	assert.NoError(suite.T(), err, "should import feed file")
+	assert.Equal(suite.T(), 2, n, "should import two vulnerabilities")
+
+	_, err = os.Stat(strings.TrimSuffix(path, ".json") + ".imported")
+	assert.NoError(suite.T(), err, "the feed should be renamed once imported")
+
+	// A feed that can't be read is not imported partially
+	_, err = suite.model.ImportVulnerabilityFeed(strings.NewReader(`{"vulnerabilities": [{"cve": {"id": "CVE-2024-0010"}}, {"cve": 1}]}`))
+	assert.Error(suite.T(), err, "should not import a malformed feed")
+
+	count, err := suite.model.CountVulnerabilities()
+	assert.NoError(suite.T(), err, "should count vulnerabilities")
+	assert.Equal(suite.T(), 2, count, "nothing from the malformed feed should be saved")
+}
+
+func TestVulnerabilitiesTestSuite(t *testing.T) {
+	suite.Run(t, new(VulnerabilitiesTestSuite))
+}
@@ -0,0 +1,433 @@
+package models
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	BundleManifestFile  = "manifest.json"
+	BundleSignatureFile = "manifest.sig"
+	bundleInfoFile      = "bundle.json"
+	bundleReplacedFile  = "bundle-replaced.json"
+	previousFolder      = "previous"
+)
+
+var ErrNoPreviousCatalog = errors.New("there is no previous catalogue to roll back to")
+
+// BundleManifest describes an offline catalogue bundle. The manifest is signed with the
+// ed25519 key of the publisher and contains the SHA256 checksum of every file in the bundle
+type BundleManifest struct {
+	Created time.Time         `json:"created"`
+	Channel string            `json:"channel"`
+	Files   map[string]string `json:"files"`
+}
+
+// bundleFiles maps the files a bundle may contain with the folder where the console reads them
+func bundleFiles(folders CatalogFolders) map[string]string {
+	return map[string]string{
+		"winget/index.db":            folders.Winget,
+		"flatpak/flatpak.db":         folders.Flatpak,
+		"brew/brew.db":               folders.Brew,
+		"releases/latest.json":       folders.Releases,
+		"releases/releases.json":     folders.Releases,
+		"releases/latest-agent.json": folders.Releases,
+	}
+}
+
+// ReadBundlePublicKey reads the PEM encoded ed25519 public key used to verify bundle signatures
+func ReadBundlePublicKey(keyPath string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("could not read bundle public key, reason: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("bundle public key is not PEM encoded")
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse bundle public key, reason: %v", err)
+	}
+
+	publicKey, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("bundle public key is not an ed25519 key")
+	}
+
+	return publicKey, nil
+}
+
+// ImportCatalogBundle verifies the signature of the bundle and the checksums of its files and then
+// replaces the catalogue files, keeping the replaced ones so the import can be rolled back
+func ImportCatalogBundle(bundlePath string, publicKey ed25519.PublicKey, folders CatalogFolders) (*BundleManifest, error) {
+	archive, err := zip.OpenReader(bundlePath)
+	if err != nil {
+		return nil, fmt.Errorf("could not open bundle, reason: %v", err)
+	}
+	defer archive.Close()
+
+	entries := map[string]*zip.File{}
+	for _, f := range archive.File {
+		entries[path.Clean(f.Name)] = f
+	}
+
+	manifestData, err := readBundleEntry(entries, BundleManifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := readBundleEntry(entries, BundleSignatureFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// Signatures can be stored raw or base64 encoded
+	if len(signature) != ed25519.SignatureSize {
+		signature, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(signature)))
+		if err != nil {
+			return nil, errors.New("bundle signature is not valid")
+		}
+	}
+
+	if !ed25519.Verify(publicKey, manifestData, signature) {
+		return nil, errors.New("bundle signature could not be verified")
+	}
+
+	manifest := BundleManifest{}
+	if err := json.Unmarshal(manifestData, &manifest); err != nil {
+		return nil, fmt.Errorf("could not decode bundle manifest, reason: %v", err)
+	}
+
+	if len(manifest.Files) == 0 {
+		return nil, errors.New("bundle doesn't contain any file")
+	}
+
+	current, err := GetCatalogBundle(folders)
+	if err != nil {
+		return nil, err
+	}
+	if current != nil && manifest.Created.Before(current.Created) {
+		return nil, fmt.Errorf("bundle is older than the current catalogue created on %s", current.Created.Format(time.RFC3339))
+	}
+
+	targets := bundleFiles(folders)
+
+	// Extract every file next to the one it replaces and check its checksum before replacing anything
+	staged := map[string]string{}
+	defer func() {
+		for _, tmp := range staged {
+			os.Remove(tmp)
+		}
+	}()
+
+	for name, checksum := range manifest.Files {
+		folder, ok := targets[name]
+		if !ok {
+			return nil, fmt.Errorf("bundle contains an unknown file %s", name)
+		}
+
+		f, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("bundle doesn't contain the file %s", name)
+		}
+
+		tmp := filepath.Join(folder, path.Base(name)+".new")
+		dst := filepath.Join(folder, path.Base(name))
+		staged[dst] = tmp
+		if err := extractBundleEntry(f, tmp, checksum); err != nil {
+			return nil, fmt.Errorf("could not extract %s, reason: %v", name, err)
+		}
+
+		// The file modification time tells how old the catalogue is
+		if err := os.Chtimes(tmp, manifest.Created, manifest.Created); err != nil {
+			return nil, err
+		}
+	}
+
+	info := filepath.Join(folders.Common, bundleInfoFile)
+	staged[info] = info + ".new"
+	if err := os.WriteFile(info+".new", manifestData, 0660); err != nil {
+		return nil, err
+	}
+
+	// The files kept by the last import are about to be replaced so it can't be rolled back anymore
+	if err := os.Remove(filepath.Join(folders.Common, bundleReplacedFile)); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	// Either every file is replaced or the catalogue is left as it was
+	replaced := []string{}
+	for dst, tmp := range staged {
+		err := keepPreviousFile(dst)
+		if err == nil {
+			replaced = append(replaced, dst)
+			err = os.Rename(tmp, dst)
+		}
+		if err != nil {
+			for _, f := range replaced {
+				if rerr := restorePreviousFile(f); rerr != nil {
+					log.Printf("[ERROR]: could not restore %s, reason: %v", f, rerr)
+				}
+			}
+			return nil, err
+		}
+	}
+	staged = map[string]string{}
+
+	if err := writeReplacedFiles(folders, replaced); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// RollbackCatalogBundle restores the catalogue files replaced by the last import, the files
+// that are rolled back are kept so rolling back again undoes the rollback
+func RollbackCatalogBundle(folders CatalogFolders) error {
+	files, err := readReplacedFiles(folders)
+	if err != nil {
+		return err
+	}
+
+	if len(files) == 0 {
+		return ErrNoPreviousCatalog
+	}
+
+	for _, f := range files {
+		if err := swapPreviousFile(f); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ImportCatalogBundleFile imports a bundle verified with the public key stored in publicKeyPath
+// and refreshes the common software database with the new catalogue
+func ImportCatalogBundleFile(bundlePath, publicKeyPath string, folders CatalogFolders) (*BundleManifest, error) {
+	key, err := ReadBundlePublicKey(publicKeyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := ImportCatalogBundle(bundlePath, key, folders)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := RefreshCommonSoftwareDB(folders); err != nil {
+		log.Printf("[ERROR]: could not refresh the common software database, reason: %v", err)
+	}
+
+	return manifest, nil
+}
+
+// RollbackCatalog restores the catalogue replaced by the last bundle import and refreshes the common software database
+func RollbackCatalog(folders CatalogFolders) error {
+	if err := RollbackCatalogBundle(folders); err != nil {
+		return err
+	}
+
+	return RefreshCommonSoftwareDB(folders)
+}
+
+// RefreshCommonSoftwareDB replaces the packages of every source in the common software database
+func RefreshCommonSoftwareDB(folders CatalogFolders) error {
+	db, err := OpenCommonDB(folders.Common)
+	if err != nil {
+		return fmt.Errorf("could not open common software database, reason: %v", err)
+	}
+	defer db.Close()
+
+	CreateCommonSoftwareTable(db)
+
+	return RefreshCommonSoftware(db, folders)
+}
+
+// GetCatalogBundle returns the manifest of the imported bundle, if the catalogue comes from a bundle
+func GetCatalogBundle(folders CatalogFolders) (*BundleManifest, error) {
+	data, err := os.ReadFile(filepath.Join(folders.Common, bundleInfoFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	manifest := BundleManifest{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	return &manifest, nil
+}
+
+// HasPreviousCatalog reports if there are catalogue files that can be restored
+func HasPreviousCatalog(folders CatalogFolders) bool {
+	files, err := readReplacedFiles(folders)
+	return err == nil && len(files) > 0
+}
+
+// CatalogUpdated returns when the oldest package database was updated, either downloaded or imported.
+// The zero time is returned if there's no package database yet
+func CatalogUpdated(folders CatalogFolders) time.Time {
+	updated := time.Time{}
+	for _, db := range []string{filepath.Join(folders.Winget, "index.db"), filepath.Join(folders.Flatpak, "flatpak.db"), filepath.Join(folders.Brew, "brew.db")} {
+		info, err := os.Stat(db)
+		if err != nil {
+			continue
+		}
+		if updated.IsZero() || info.ModTime().Before(updated) {
+			updated = info.ModTime()
+		}
+	}
+	return updated
+}
+
+func readBundleEntry(entries map[string]*zip.File, name string) ([]byte, error) {
+	f, ok := entries[name]
+	if !ok {
+		return nil, fmt.Errorf("bundle doesn't contain the file %s", name)
+	}
+
+	r, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func extractBundleEntry(f *zip.File, dst, checksum string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(out, hash), src); err != nil {
+		return err
+	}
+
+	if !strings.EqualFold(hex.EncodeToString(hash.Sum(nil)), checksum) {
+		return errors.New("checksum doesn't match")
+	}
+
+	return out.Close()
+}
+
+// keepPreviousFile moves the file to the previous folder, replacing the file kept by an earlier import.
+// If the file doesn't exist the earlier copy is removed, so restoring it leaves no file
+func keepPreviousFile(f string) error {
+	previous := filepath.Join(filepath.Dir(f), previousFolder, filepath.Base(f))
+
+	if _, err := os.Stat(f); os.IsNotExist(err) {
+		if err := os.Remove(previous); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(previous), 0770); err != nil {
+		return err
+	}
+
+	return os.Rename(f, previous)
+}
+
+// restorePreviousFile puts back the file kept in the previous folder, removing the current one
+func restorePreviousFile(f string) error {
+	previous := filepath.Join(filepath.Dir(f), previousFolder, filepath.Base(f))
+
+	if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(previous, f); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// swapPreviousFile exchanges the file with the one kept in the previous folder, either of them may not exist
+func swapPreviousFile(f string) error {
+	previous := filepath.Join(filepath.Dir(f), previousFolder, filepath.Base(f))
+	tmp := f + ".rollback"
+
+	if err := os.MkdirAll(filepath.Dir(previous), 0770); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f, tmp); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(previous, f); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := os.Rename(tmp, previous); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+// readReplacedFiles returns the files replaced by the last import
+func readReplacedFiles(folders CatalogFolders) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(folders.Common, bundleReplacedFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+
+	files := []string{}
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+func writeReplacedFiles(folders CatalogFolders, files []string) error {
+	data, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+
+	dst := filepath.Join(folders.Common, bundleReplacedFile)
+	if err := os.WriteFile(dst+".new", data, 0660); err != nil {
+		return err
+	}
+
+	return os.Rename(dst+".new", dst)
+}
@@ -0,0 +1,196 @@
+package models
+
+import (
+	"archive/zip"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testBundle struct {
+	created   time.Time
+	files     map[string]string
+	checksums map[string]string
+	key       ed25519.PrivateKey
+}
+
+func newTestFolders(t *testing.T) CatalogFolders {
+	dir := t.TempDir()
+	folders := CatalogFolders{
+		Common:   filepath.Join(dir, "commondb"),
+		Winget:   filepath.Join(dir, "winget"),
+		Flatpak:  filepath.Join(dir, "flatpak"),
+		Brew:     filepath.Join(dir, "brew"),
+		Releases: filepath.Join(dir, "server-releases"),
+	}
+	for _, f := range []string{folders.Common, folders.Winget, folders.Flatpak, folders.Brew, folders.Releases} {
+		assert.NoError(t, os.MkdirAll(f, 0770), "should create catalogue folder")
+	}
+	return folders
+}
+
+func writeTestBundle(t *testing.T, b testBundle) string {
+	manifest := BundleManifest{Created: b.created, Channel: "stable", Files: map[string]string{}}
+	for name, content := range b.files {
+		sum := sha256.Sum256([]byte(content))
+		manifest.Files[name] = hex.EncodeToString(sum[:])
+	}
+	for name, checksum := range b.checksums {
+		manifest.Files[name] = checksum
+	}
+
+	manifestData, err := json.Marshal(manifest)
+	assert.NoError(t, err, "should encode manifest")
+
+	bundlePath := filepath.Join(t.TempDir(), "bundle.zip")
+	out, err := os.Create(bundlePath)
+	assert.NoError(t, err, "should create bundle")
+	defer out.Close()
+
+	w := zip.NewWriter(out)
+	entries := map[string][]byte{
+		BundleManifestFile:  manifestData,
+		BundleSignatureFile: ed25519.Sign(b.key, manifestData),
+	}
+	for name, content := range b.files {
+		entries[name] = []byte(content)
+	}
+	for name, data := range entries {
+		f, err := w.Create(name)
+		assert.NoError(t, err, "should add bundle entry")
+		_, err = f.Write(data)
+		assert.NoError(t, err, "should write bundle entry")
+	}
+	assert.NoError(t, w.Close(), "should close bundle")
+
+	return bundlePath
+}
+
+func readTestFile(t *testing.T, path string) string {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err, "should read %s", path)
+	return string(data)
+}
+
+func TestImportCatalogBundle(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+
+	folders := newTestFolders(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Winget, "index.db"), []byte("winget v1"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Brew, "brew.db"), []byte("brew v1"), 0660))
+
+	created := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	bundle := writeTestBundle(t, testBundle{created: created, files: map[string]string{"winget/index.db": "winget v2"}, key: privateKey})
+
+	manifest, err := ImportCatalogBundle(bundle, publicKey, folders)
+	assert.NoError(t, err, "should import bundle")
+	assert.Equal(t, "stable", manifest.Channel)
+	assert.Equal(t, "winget v2", readTestFile(t, filepath.Join(folders.Winget, "index.db")), "should replace the file")
+	assert.Equal(t, "winget v1", readTestFile(t, filepath.Join(folders.Winget, previousFolder, "index.db")), "should keep the replaced file")
+	assert.True(t, HasPreviousCatalog(folders), "should be able to roll back")
+
+	current, err := GetCatalogBundle(folders)
+	assert.NoError(t, err, "should get bundle information")
+	assert.True(t, created.Equal(current.Created), "should save bundle information")
+}
+
+func TestImportCatalogBundleBadSignature(t *testing.T) {
+	publicKey, _, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+
+	folders := newTestFolders(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Winget, "index.db"), []byte("winget v1"), 0660))
+
+	bundle := writeTestBundle(t, testBundle{created: time.Now(), files: map[string]string{"winget/index.db": "winget v2"}, key: otherKey})
+
+	_, err = ImportCatalogBundle(bundle, publicKey, folders)
+	assert.ErrorContains(t, err, "signature", "should not import a bundle signed with another key")
+	assert.Equal(t, "winget v1", readTestFile(t, filepath.Join(folders.Winget, "index.db")), "should not replace the file")
+	assert.False(t, HasPreviousCatalog(folders))
+}
+
+func TestImportCatalogBundleChecksumMismatch(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+
+	folders := newTestFolders(t)
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Winget, "index.db"), []byte("winget v1"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Brew, "brew.db"), []byte("brew v1"), 0660))
+
+	sum := sha256.Sum256([]byte("something else"))
+	bundle := writeTestBundle(t, testBundle{
+		created:   time.Now(),
+		files:     map[string]string{"winget/index.db": "winget v2", "brew/brew.db": "brew v2"},
+		checksums: map[string]string{"brew/brew.db": hex.EncodeToString(sum[:])},
+		key:       privateKey,
+	})
+
+	_, err = ImportCatalogBundle(bundle, publicKey, folders)
+	assert.ErrorContains(t, err, "checksum", "should not import a file with a wrong checksum")
+	assert.Equal(t, "winget v1", readTestFile(t, filepath.Join(folders.Winget, "index.db")), "should not replace any file")
+	assert.Equal(t, "brew v1", readTestFile(t, filepath.Join(folders.Brew, "brew.db")), "should not replace any file")
+
+	_, err = os.Stat(filepath.Join(folders.Winget, "index.db.new"))
+	assert.True(t, os.IsNotExist(err), "should remove the extracted files")
+}
+
+func TestImportOlderCatalogBundle(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+
+	folders := newTestFolders(t)
+
+	bundle := writeTestBundle(t, testBundle{created: time.Now(), files: map[string]string{"winget/index.db": "winget v2"}, key: privateKey})
+	_, err = ImportCatalogBundle(bundle, publicKey, folders)
+	assert.NoError(t, err, "should import bundle")
+
+	older := writeTestBundle(t, testBundle{created: time.Now().Add(-24 * time.Hour), files: map[string]string{"winget/index.db": "winget v1"}, key: privateKey})
+	_, err = ImportCatalogBundle(older, publicKey, folders)
+	assert.ErrorContains(t, err, "older", "should not import a bundle older than the current catalogue")
+	assert.Equal(t, "winget v2", readTestFile(t, filepath.Join(folders.Winget, "index.db")), "should keep the newer file")
+}
+
+func TestRollbackCatalogBundle(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err, "should generate key")
+
+	folders := newTestFolders(t)
+	assert.ErrorIs(t, RollbackCatalogBundle(folders), ErrNoPreviousCatalog, "should not roll back without an import")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Winget, "index.db"), []byte("winget v1"), 0660))
+	assert.NoError(t, os.WriteFile(filepath.Join(folders.Brew, "brew.db"), []byte("brew v1"), 0660))
+
+	first := writeTestBundle(t, testBundle{created: time.Now().Add(-time.Hour), files: map[string]string{"brew/brew.db": "brew v2"}, key: privateKey})
+	_, err = ImportCatalogBundle(first, publicKey, folders)
+	assert.NoError(t, err, "should import bundle")
+
+	second := writeTestBundle(t, testBundle{created: time.Now(), files: map[string]string{"winget/index.db": "winget v2", "flatpak/flatpak.db": "flatpak v2"}, key: privateKey})
+	_, err = ImportCatalogBundle(second, publicKey, folders)
+	assert.NoError(t, err, "should import bundle")
+
+	assert.NoError(t, RollbackCatalogBundle(folders), "should roll back")
+	assert.Equal(t, "winget v1", readTestFile(t, filepath.Join(folders.Winget, "index.db")), "should restore the replaced file")
+	assert.Equal(t, "brew v2", readTestFile(t, filepath.Join(folders.Brew, "brew.db")), "should not restore files the last import didn't replace")
+
+	_, err = os.Stat(filepath.Join(folders.Flatpak, "flatpak.db"))
+	assert.True(t, os.IsNotExist(err), "should remove the files added by the last import")
+
+	current, err := GetCatalogBundle(folders)
+	assert.NoError(t, err, "should get bundle information")
+	assert.Equal(t, 1, len(current.Files), "should restore the bundle information of the first import")
+
+	assert.NoError(t, RollbackCatalogBundle(folders), "should undo the roll back")
+	assert.Equal(t, "winget v2", readTestFile(t, filepath.Join(folders.Winget, "index.db")))
+	assert.Equal(t, "flatpak v2", readTestFile(t, filepath.Join(folders.Flatpak, "flatpak.db")))
+}
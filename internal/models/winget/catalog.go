@@ -0,0 +1,175 @@
+package models
+
+import (
+	"database/sql"
+	"slices"
+	"strings"
+)
+
+// CatalogFolders are the folders where the common software database and the source databases are stored
+type CatalogFolders struct {
+	Common   string
+	Winget   string
+	Flatpak  string
+	Brew     string
+	Releases string
+}
+
+type CatalogPackage struct {
+	ID      string
+	Name    string
+	Source  string
+	Version string
+}
+
+// catalogQueryBatch is the number of names looked up in a single query, below the SQLite variables limit
+const catalogQueryBatch = 500
+
+// FindCatalogPackages looks for the packages in the common software database whose name matches
+// one of the application names and reads the latest version published by its source, if the source provides it.
+// Packages are indexed by their lowercase name
+func FindCatalogPackages(names []string, folders CatalogFolders) (map[string][]CatalogPackage, error) {
+	packages := map[string][]CatalogPackage{}
+
+	db, err := OpenCommonDB(folders.Common)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	// The same name may be requested with different case or surrounding spaces
+	keys := map[string][]string{}
+	for _, name := range names {
+		trimmed := strings.ToLower(strings.TrimSpace(name))
+		keys[trimmed] = append(keys[trimmed], strings.ToLower(name))
+	}
+
+	lookups := []string{}
+	for name := range keys {
+		lookups = append(lookups, name)
+	}
+
+	for start := 0; start < len(lookups); start += catalogQueryBatch {
+		batch := lookups[start:min(start+catalogQueryBatch, len(lookups))]
+
+		args := make([]any, len(batch))
+		for i, name := range batch {
+			args[i] = name
+		}
+
+		rows, err := db.Query(`SELECT id, name, source FROM apps WHERE name COLLATE NOCASE IN (?`+strings.Repeat(",?", len(batch)-1)+`)`, args...)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var p CatalogPackage
+			if err := rows.Scan(&p.ID, &p.Name, &p.Source); err != nil {
+				rows.Close()
+				return nil, err
+			}
+			for _, key := range keys[strings.ToLower(p.Name)] {
+				packages[key] = append(packages[key], p)
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(packages) > 0 {
+		addCatalogVersions(packages, folders)
+	}
+
+	return packages, nil
+}
+
+// FindCatalogPackagesByID looks for the packages in the common software database, the packages
+// that aren't found are left out of the result. Packages are indexed by their ID
+func FindCatalogPackagesByID(ids []string, folders CatalogFolders) (map[string]CatalogPackage, error) {
+	packages := map[string]CatalogPackage{}
+
+	db, err := OpenCommonDB(folders.Common)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	stmt, err := db.Prepare(`SELECT id, name, source FROM apps WHERE id = ?`)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+
+	for _, id := range ids {
+		var p CatalogPackage
+		err := stmt.QueryRow(strings.TrimSpace(id)).Scan(&p.ID, &p.Name, &p.Source)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		packages[p.ID] = p
+	}
+
+	return packages, nil
+}
+
+// addCatalogVersions leaves the version empty if the source database has no version information
+func addCatalogVersions(packages map[string][]CatalogPackage, folders CatalogFolders) {
+	queries := map[string]*sql.Stmt{}
+
+	if db, err := OpenWingetDB(folders.Winget); err == nil {
+		defer db.Close()
+		if stmt, err := db.Prepare(`SELECT latest_version FROM packages WHERE id = ?`); err == nil {
+			defer stmt.Close()
+			queries["winget"] = stmt
+		}
+	}
+
+	if db, err := OpenFlatpakDB(folders.Flatpak); err == nil {
+		defer db.Close()
+		if stmt, err := db.Prepare(`SELECT version FROM apps WHERE id = ?`); err == nil {
+			defer stmt.Close()
+			queries["flatpak"] = stmt
+		}
+	}
+
+	if db, err := OpenBrewDB(folders.Brew); err == nil {
+		defer db.Close()
+		if stmt, err := db.Prepare(`SELECT version FROM apps WHERE id = ?`); err == nil {
+			defer stmt.Close()
+			queries["brew"] = stmt
+		}
+	}
+
+	for name, list := range packages {
+		for i, p := range list {
+			stmt, ok := queries[p.Source]
+			if !ok {
+				continue
+			}
+			var version sql.NullString
+			if err := stmt.QueryRow(p.ID).Scan(&version); err == nil && version.Valid {
+				packages[name][i].Version = version.String
+			}
+		}
+	}
+}
+
+// CatalogSourceForOS returns the package source used to deploy software to an operating system,
+// it's empty if the catalogue has no source for it
+func CatalogSourceForOS(os string) string {
+	switch {
+	case strings.EqualFold(os, "windows"):
+		return "winget"
+	case strings.EqualFold(os, "macOS"):
+		return "brew"
+	case slices.Contains(LinuxDistributions, strings.ToLower(os)):
+		return "flatpak"
+	default:
+		return ""
+	}
+}
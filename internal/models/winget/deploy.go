@@ -14,15 +14,9 @@ import (
 	"github.com/scncore/scnorion-console/internal/views/partials"
 )
 
+// SearchPackages looks for packages in the common software database, packages are ranked by the
+// full-text index so a match in the name comes before a match in the publisher, tags or description
 func SearchPackages(packageName string, p partials.PaginationAndSort, dbFolder string, f filters.DeployPackageFilter) ([]nats.SoftwarePackage, error) {
-	var rows *sql.Rows
-	var err error
-
-	sources := []string{}
-	for _, s := range f.Sources {
-		sources = append(sources, "'"+s+"'")
-	}
-
 	// Open DB
 	db, err := OpenCommonDB(dbFolder)
 	if err != nil {
@@ -30,42 +24,13 @@ func SearchPackages(packageName string, p partials.PaginationAndSort, dbFolder s
 	}
 	defer db.Close()
 
-	// Query the SQLite database
-	switch p.SortBy {
-	case "name":
-		if p.SortOrder == "asc" {
-			if len(f.Sources) == 0 {
-				rows, err = db.Query(`SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? ORDER BY name ASC LIMIT ? OFFSET ? `, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			} else {
-				q := fmt.Sprintf("SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? AND source IN (%s) ORDER BY name ASC LIMIT ? OFFSET ?", strings.Join(sources, ","))
-				rows, err = db.Query(q, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			}
-		} else {
-			if len(f.Sources) == 0 {
-				rows, err = db.Query(`SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? ORDER BY name DESC LIMIT ? OFFSET ? `, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			} else {
-				q := fmt.Sprintf("SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? AND source IN (%s) ORDER BY name DESC LIMIT ? OFFSET ?", strings.Join(sources, ","))
-				rows, err = db.Query(q, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			}
-		}
-	case "source":
-		if p.SortOrder == "asc" {
-			if len(f.Sources) == 0 {
-				rows, err = db.Query(`SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? ORDER BY source ASC LIMIT ? OFFSET ? `, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			} else {
-				q := fmt.Sprintf("SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? AND source IN (%s) ORDER BY sources ASC LIMIT ? OFFSET ?", strings.Join(sources, ","))
-				rows, err = db.Query(q, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			}
-		} else {
-			if len(f.Sources) == 0 {
-				rows, err = db.Query(`SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? ORDER BY source DESC LIMIT ? OFFSET ? `, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			} else {
-				q := fmt.Sprintf("SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? AND source IN (%s) ORDER BY sources DESC LIMIT ? OFFSET ?", strings.Join(sources, ","))
-				rows, err = db.Query(q, "%"+packageName+"%", p.PageSize, (p.CurrentPage-1)*p.PageSize)
-			}
-		}
-	}
+	indexed := hasCommonSoftwareSearchTable(db) && ftsQuery(packageName) != ""
+	results, args := searchResults(packageName, indexed, f)
+	args = append(args, p.PageSize, (p.CurrentPage-1)*p.PageSize)
 
+	// Query the SQLite database
+	q := fmt.Sprintf("SELECT id, name, source FROM %s ORDER BY %s LIMIT ? OFFSET ?", results, searchOrder(p, indexed))
+	rows, err := db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -86,48 +51,18 @@ func SearchPackages(packageName string, p partials.PaginationAndSort, dbFolder s
 }
 
 func CountPackages(packageName string, indexPath string, f filters.DeployPackageFilter) (int, error) {
-	var err error
-	var rows *sql.Rows
-
 	db, err := OpenCommonDB(indexPath)
 	if err != nil {
 		return 0, err
 	}
 	defer db.Close()
 
-	sources := []string{}
-	for _, s := range f.Sources {
-		sources = append(sources, "'"+s+"'")
-	}
+	indexed := hasCommonSoftwareSearchTable(db) && ftsQuery(packageName) != ""
+	results, args := searchResults(packageName, indexed, f)
 
-	// Query the SQLite database
-	if len(f.Sources) == 0 {
-		rows, err = db.Query(`
-        SELECT DISTINCT id, name, source FROM apps
-		WHERE name LIKE ?
-	`, "%"+packageName+"%")
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		q := fmt.Sprintf("SELECT DISTINCT id, name, source FROM apps WHERE name LIKE ? AND source IN (%s)", strings.Join(sources, ","))
-		rows, err = db.Query(q, "%"+packageName+"%")
-		if err != nil {
-			return 0, err
-		}
-	}
-
-	defer rows.Close()
-
-	// Scan our rows
 	count := 0
-	for rows.Next() {
-		var p nats.SoftwarePackage
-		err := rows.Scan(&p.ID, &p.Name, &p.Source)
-		if err != nil {
-			return 0, err
-		}
-		count++
+	if err := db.QueryRow(fmt.Sprintf("SELECT COUNT(DISTINCT id) FROM %s", results), args...).Scan(&count); err != nil {
+		return 0, err
 	}
 
 	return count, nil
@@ -315,6 +250,8 @@ func CreateCommonSoftwareTable(db *sql.DB) {
 	if err != nil {
 		log.Println("[INFO]: could not create table apps for commondb")
 	}
+
+	createCommonSoftwareSearchTable(db)
 }
 
 func DeleteCommonSoftwareTable(db *sql.DB) error {
@@ -323,29 +260,92 @@ func DeleteCommonSoftwareTable(db *sql.DB) error {
 	if err != nil {
 		return err
 	}
+
+	if hasCommonSoftwareSearchTable(db) {
+		if _, err := db.Exec(`delete from apps_search`); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func InsertCommonSoftware(db *sql.DB, apps []nats.SoftwarePackage, source string) error {
-	tx, err := db.Begin()
-	if err != nil {
+	return InsertCommonSoftwareDetails(db, apps, source, nil)
+}
+
+func DeleteCommonSoftware(db *sql.DB, id string) error {
+	if _, err := db.Exec(`delete from apps where id = ?`, id); err != nil {
 		return err
 	}
-	stmt, err := tx.Prepare("insert into apps(id, name, source) values(?, ?, ?)")
-	if err != nil {
-		return err
+
+	if hasCommonSoftwareSearchTable(db) {
+		if _, err := db.Exec(`delete from apps_search where id = ?`, id); err != nil {
+			return err
+		}
 	}
-	defer stmt.Close()
-	for _, app := range apps {
-		_, err = stmt.Exec(app.ID, app.Name, source)
-		if err != nil {
-			continue
+	return nil
+}
+
+// RefreshCommonSoftware replaces the packages of every catalogue source in the common software
+// database with the packages found in their indexes. Private packages are kept
+func RefreshCommonSoftware(db *sql.DB, folders CatalogFolders) error {
+	for _, s := range CatalogSources(folders) {
+		if err := RefreshCommonSoftwareSource(db, s); err != nil {
+			return err
 		}
 	}
-	err = tx.Commit()
+	return nil
+}
+
+// RefreshCommonSoftwareSource replaces the packages of a source in the common software database,
+// if the source index can't be read the packages that we already have are kept
+func RefreshCommonSoftwareSource(db *sql.DB, s CatalogSource) error {
+	packages, err := s.Packages()
 	if err != nil {
+		log.Printf("[INFO]: could not get %s packages, reason: %v", s.Name(), err)
+		return nil
+	}
+
+	// Details are only used to rank the search results, packages are indexed without them if they can't be read
+	details := map[string]CatalogPackageDetails{}
+	if ds, ok := s.(CatalogDetailsSource); ok {
+		if details, err = ds.PackageDetails(); err != nil {
+			log.Printf("[INFO]: could not get %s package details, reason: %v", s.Name(), err)
+		}
+	}
+
+	if _, err := db.Exec(`delete from apps where source = ?`, s.Name()); err != nil {
 		return err
 	}
 
+	if hasCommonSoftwareSearchTable(db) {
+		if _, err := db.Exec(`delete from apps_search where source = ?`, s.Name()); err != nil {
+			return err
+		}
+	}
+
+	if err := InsertCommonSoftwareDetails(db, packages, s.Name(), details); err != nil {
+		return fmt.Errorf("could not insert %s apps to common software database, reason: %v", s.Name(), err)
+	}
+
 	return nil
 }
+
+func querySourcePackages(db *sql.DB, query string) ([]nats.SoftwarePackage, error) {
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	packages := []nats.SoftwarePackage{}
+	for rows.Next() {
+		var p nats.SoftwarePackage
+		if err := rows.Scan(&p.ID, &p.Name); err != nil {
+			return nil, err
+		}
+		packages = append(packages, p)
+	}
+
+	return packages, nil
+}
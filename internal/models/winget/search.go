@@ -0,0 +1,183 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+
+	"github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+)
+
+// CatalogPackageDetails are indexed with the package name so the deploy search
+// can find packages by their publisher, tags or description
+type CatalogPackageDetails struct {
+	Publisher   string
+	Tags        string
+	Description string
+}
+
+// CatalogDetailsSource is implemented by the sources whose index knows more about a package than its name
+type CatalogDetailsSource interface {
+	// PackageDetails returns the details of the packages in the source index by package id
+	PackageDetails() (map[string]CatalogPackageDetails, error)
+}
+
+// CatalogVersionsSource is implemented by the sources that can install a specific version of a package
+type CatalogVersionsSource interface {
+	// LatestVersion returns the version that is installed if no version is selected
+	LatestVersion(id string) (string, error)
+	// Versions returns every version of the package published by the source
+	Versions(id string) ([]string, error)
+}
+
+// searchRank weights the columns of the search table (id, source, name, publisher, tags, description),
+// a match in the package name or id is worth more than a match in its description
+const searchRank = "bm25(apps_search, 5.0, 0.0, 10.0, 3.0, 2.0, 1.0)"
+
+// createCommonSoftwareSearchTable creates the full-text index of the common software database,
+// sqlite must be built with FTS5 (sqlite_fts5 tag) otherwise searches fall back to the apps table
+func createCommonSoftwareSearchTable(db *sql.DB) {
+	sqlStmt := `create virtual table if not exists apps_search using fts5(id, source unindexed, name, publisher, tags, description, tokenize = 'unicode61 remove_diacritics 2', prefix = '2 3')`
+	if _, err := db.Exec(sqlStmt); err != nil {
+		log.Printf("[INFO]: could not create full-text search table for commondb, reason: %v", err)
+	}
+}
+
+func hasCommonSoftwareSearchTable(db *sql.DB) bool {
+	var name string
+	err := db.QueryRow(`select name from sqlite_master where type = 'table' and name = 'apps_search'`).Scan(&name)
+	return err == nil
+}
+
+// InsertCommonSoftwareDetails adds packages to the common software database and indexes their details
+func InsertCommonSoftwareDetails(db *sql.DB, apps []nats.SoftwarePackage, source string, details map[string]CatalogPackageDetails) error {
+	indexed := hasCommonSoftwareSearchTable(db)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare("insert into apps(id, name, source) values(?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	var searchStmt *sql.Stmt
+	if indexed {
+		searchStmt, err = tx.Prepare("insert into apps_search(id, source, name, publisher, tags, description) values(?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer searchStmt.Close()
+	}
+
+	for _, app := range apps {
+		if _, err := stmt.Exec(app.ID, app.Name, source); err != nil {
+			continue
+		}
+
+		if searchStmt != nil {
+			d := details[app.ID]
+			if _, err := searchStmt.Exec(app.ID, source, app.Name, d.Publisher, d.Tags, d.Description); err != nil {
+				return err
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ftsQuery turns the text typed by the operator into an FTS5 query where every word
+// must match the beginning of a word in the indexed columns
+func ftsQuery(search string) string {
+	terms := []string{}
+	for _, term := range strings.Fields(search) {
+		terms = append(terms, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(terms, " ")
+}
+
+// searchResults returns a subquery with the id, name, source and rank of the packages found and its
+// arguments. With the full-text index the packages whose words start with the search terms come first,
+// ranked by relevance, followed by the ones that only contain the search text in the middle of a word
+func searchResults(packageName string, indexed bool, f filters.DeployPackageFilter) (string, []any) {
+	sources := ""
+	sourceArgs := []any{}
+	if len(f.Sources) > 0 {
+		sources = " AND source IN (?" + strings.Repeat(", ?", len(f.Sources)-1) + ")"
+		for _, s := range f.Sources {
+			sourceArgs = append(sourceArgs, s)
+		}
+	}
+
+	substring := "(name LIKE ? OR id LIKE ?)" + sources
+	args := append([]any{"%" + packageName + "%", "%" + packageName + "%"}, sourceArgs...)
+
+	if !indexed {
+		return "(SELECT id, name, source, 0 AS relevance FROM apps WHERE " + substring + ")", args
+	}
+
+	fts := "apps_search MATCH ?" + sources
+	ftsArgs := append([]any{ftsQuery(packageName)}, sourceArgs...)
+
+	query := "(SELECT id, name, source, " + searchRank + " AS relevance FROM apps_search WHERE " + fts +
+		" UNION ALL SELECT id, name, source, 1e9 AS relevance FROM apps WHERE " + substring +
+		" AND id NOT IN (SELECT id FROM apps_search WHERE " + fts + "))"
+
+	queryArgs := append([]any{}, ftsArgs...)
+	queryArgs = append(queryArgs, args...)
+	queryArgs = append(queryArgs, ftsArgs...)
+	return query, queryArgs
+}
+
+// searchOrder sorts by relevance unless the operator sorts by a column, packages
+// without a relevance rank (no full-text index) are sorted by name
+func searchOrder(p partials.PaginationAndSort, indexed bool) string {
+	order := "ASC"
+	if p.SortOrder == "desc" {
+		order = "DESC"
+	}
+
+	switch p.SortBy {
+	case "name":
+		return "name " + order
+	case "source":
+		return "source " + order + ", name ASC"
+	}
+
+	if indexed {
+		return "relevance, name ASC"
+	}
+	return "name ASC"
+}
+
+// PackageVersions returns the versions that can be selected when a package is deployed.
+// In offline mode or if the versions can't be downloaded only the latest
+// version in the source index is returned
+func PackageVersions(s CatalogSource, id string, offline bool) []string {
+	vs, ok := s.(CatalogVersionsSource)
+	if !ok {
+		return []string{}
+	}
+
+	if !offline {
+		versions, err := vs.Versions(id)
+		if err == nil && len(versions) > 0 {
+			return versions
+		}
+		if err != nil {
+			log.Printf("[ERROR]: could not get %s versions of %s, reason: %v", s.Name(), id, err)
+		}
+	}
+
+	latest, err := vs.LatestVersion(id)
+	if err != nil || latest == "" {
+		return []string{}
+	}
+	return []string{latest}
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/scncore/nats"
+	"github.com/scncore/scnorion-console/internal/views/filters"
+	"github.com/scncore/scnorion-console/internal/views/partials"
+	"github.com/stretchr/testify/assert"
+)
+
+type testVersionsSource struct {
+	versions []string
+	err      error
+}
+
+func (s testVersionsSource) Name() string                   { return "test" }
+func (s testVersionsSource) Platforms() []string            { return []string{"windows"} }
+func (s testVersionsSource) RefreshInterval() time.Duration { return time.Hour }
+func (s testVersionsSource) Refresh() error                 { return nil }
+func (s testVersionsSource) Packages() ([]nats.SoftwarePackage, error) {
+	return []nats.SoftwarePackage{}, nil
+}
+func (s testVersionsSource) Search(name string) ([]nats.SoftwarePackage, error) {
+	return []nats.SoftwarePackage{}, nil
+}
+func (s testVersionsSource) LatestVersion(id string) (string, error) { return "2.0.0", nil }
+func (s testVersionsSource) Versions(id string) ([]string, error)    { return s.versions, s.err }
+
+type testCatalogSourceWithoutVersions struct{}
+
+func (s testCatalogSourceWithoutVersions) Name() string                   { return "test" }
+func (s testCatalogSourceWithoutVersions) Platforms() []string            { return []string{"windows"} }
+func (s testCatalogSourceWithoutVersions) RefreshInterval() time.Duration { return time.Hour }
+func (s testCatalogSourceWithoutVersions) Refresh() error                 { return nil }
+func (s testCatalogSourceWithoutVersions) Packages() ([]nats.SoftwarePackage, error) {
+	return []nats.SoftwarePackage{}, nil
+}
+func (s testCatalogSourceWithoutVersions) Search(name string) ([]nats.SoftwarePackage, error) {
+	return []nats.SoftwarePackage{}, nil
+}
+
+func newTestCommonDB(t *testing.T) string {
+	folder := t.TempDir()
+	db, err := OpenCommonDB(folder)
+	assert.NoError(t, err, "should open common database")
+	defer db.Close()
+
+	CreateCommonSoftwareTable(db)
+
+	err = InsertCommonSoftwareDetails(db, []nats.SoftwarePackage{
+		{ID: "Microsoft.VisualStudioCode", Name: "Visual Studio Code"},
+		{ID: "Notepad++.Notepad++", Name: "Notepad++"},
+		{ID: "Mozilla.Firefox", Name: "Mozilla Firefox"},
+	}, "winget", map[string]CatalogPackageDetails{
+		"Mozilla.Firefox": {Publisher: "Mozilla", Tags: "browser", Description: "Web browser"},
+	})
+	assert.NoError(t, err, "should add winget packages")
+
+	err = InsertCommonSoftwareDetails(db, []nats.SoftwarePackage{
+		{ID: "org.mozilla.firefox", Name: "Firefox"},
+	}, "flatpak", nil)
+	assert.NoError(t, err, "should add flatpak packages")
+
+	return folder
+}
+
+func packageIDs(packages []nats.SoftwarePackage) []string {
+	ids := []string{}
+	for _, p := range packages {
+		ids = append(ids, p.ID)
+	}
+	return ids
+}
+
+func TestSearchPackages(t *testing.T) {
+	folder := newTestCommonDB(t)
+	p := partials.PaginationAndSort{CurrentPage: 1, PageSize: 10}
+
+	packages, err := SearchPackages("visual", p, folder, filters.DeployPackageFilter{})
+	assert.NoError(t, err, "should search packages")
+	assert.Equal(t, []string{"Microsoft.VisualStudioCode"}, packageIDs(packages), "should find packages by the beginning of a word")
+
+	packages, err = SearchPackages("pad", p, folder, filters.DeployPackageFilter{})
+	assert.NoError(t, err, "should search packages")
+	assert.Equal(t, []string{"Notepad++.Notepad++"}, packageIDs(packages), "should find packages by a substring in the middle of a word")
+
+	packages, err = SearchPackages("fox", p, folder, filters.DeployPackageFilter{})
+	assert.NoError(t, err, "should search packages")
+	assert.ElementsMatch(t, []string{"Mozilla.Firefox", "org.mozilla.firefox"}, packageIDs(packages), "should find packages in every source")
+
+	count, err := CountPackages("fox", folder, filters.DeployPackageFilter{})
+	assert.NoError(t, err, "should count packages")
+	assert.Equal(t, len(packages), count, "should count the packages found")
+
+	packages, err = SearchPackages("fox", p, folder, filters.DeployPackageFilter{Sources: []string{"flatpak"}})
+	assert.NoError(t, err, "should search packages")
+	assert.Equal(t, []string{"org.mozilla.firefox"}, packageIDs(packages), "should filter packages by source")
+
+	count, err = CountPackages("fox", folder, filters.DeployPackageFilter{Sources: []string{"flatpak"}})
+	assert.NoError(t, err, "should count packages")
+	assert.Equal(t, 1, count, "should count the packages of the source")
+}
+
+func TestPackageVersions(t *testing.T) {
+	s := testVersionsSource{versions: []string{"2.0.0", "1.0.0"}}
+	assert.Equal(t, []string{"2.0.0", "1.0.0"}, PackageVersions(s, "test.package", false), "should return the versions published by the source")
+	assert.Equal(t, []string{"2.0.0"}, PackageVersions(s, "test.package", true), "should only return the latest version in offline mode")
+
+	s = testVersionsSource{err: errors.New("could not download versions")}
+	assert.Equal(t, []string{"2.0.0"}, PackageVersions(s, "test.package", false), "should fall back to the latest version")
+
+	assert.Equal(t, []string{}, PackageVersions(testCatalogSourceWithoutVersions{}, "test.package", false), "should not return versions if the source can't install them")
+}
@@ -0,0 +1,427 @@
+package models
+
+import (
+	"archive/zip"
+	"database/sql"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/scncore/nats"
+)
+
+// CatalogSource is a package catalogue whose packages are added to the common software database,
+// so they can be found in the deploy search and installed on the agents of its platforms
+type CatalogSource interface {
+	// Name identifies the source in the common software database and in the tenant settings
+	Name() string
+	// Platforms returns the agent platforms (windows, linux or macOS) that can install the packages
+	Platforms() []string
+	// RefreshInterval is how often the source index must be downloaded again
+	RefreshInterval() time.Duration
+	// Refresh downloads the source index
+	Refresh() error
+	// Packages returns every package in the source index
+	Packages() ([]nats.SoftwarePackage, error)
+	// Search looks for packages whose name contains the search string
+	Search(name string) ([]nats.SoftwarePackage, error)
+}
+
+// CatalogRetrySource is implemented by the sources that must be downloaded again sooner
+// than the default retry interval when the download fails
+type CatalogRetrySource interface {
+	RetryInterval() time.Duration
+}
+
+// CatalogSourceFactory creates a source that stores its index in the folders passed
+type CatalogSourceFactory func(folders CatalogFolders) CatalogSource
+
+var (
+	catalogSourcesMu       sync.RWMutex
+	catalogSourceFactories = []CatalogSourceFactory{newWingetSource, newFlatpakSource, newBrewSource}
+)
+
+// LinuxDistributions are the operating systems reported by linux agents
+var LinuxDistributions = []string{"ubuntu", "neon", "debian", "opensuse-leap", "linuxmint", "fedora", "manjaro", "arch", "almalinux", "rocky"}
+
+// RegisterCatalogSource adds a new source, its packages will be added to the common software database
+// and its index refreshed by the console, the source must be enabled in the settings before it can be used
+func RegisterCatalogSource(factory CatalogSourceFactory) {
+	catalogSourcesMu.Lock()
+	defer catalogSourcesMu.Unlock()
+	catalogSourceFactories = append(catalogSourceFactories, factory)
+}
+
+// CatalogSources returns the registered sources, the builtin winget, flatpak and brew sources come first
+func CatalogSources(folders CatalogFolders) []CatalogSource {
+	catalogSourcesMu.RLock()
+	defer catalogSourcesMu.RUnlock()
+
+	sources := []CatalogSource{}
+	for _, factory := range catalogSourceFactories {
+		sources = append(sources, factory(folders))
+	}
+	return sources
+}
+
+// IsRegisteredCatalogSource reports if a source with that name has been registered
+func IsRegisteredCatalogSource(name string) bool {
+	_, ok := GetCatalogSource(name, CatalogFolders{})
+	return ok
+}
+
+func GetCatalogSource(name string, folders CatalogFolders) (CatalogSource, bool) {
+	for _, s := range CatalogSources(folders) {
+		if s.Name() == name {
+			return s, true
+		}
+	}
+	return nil, false
+}
+
+// IsBuiltinCatalogSource reports if the source is enabled with its own general setting
+func IsBuiltinCatalogSource(name string) bool {
+	return name == "winget" || name == "flatpak" || name == "brew"
+}
+
+// AgentPlatform returns the platform of an agent operating system
+func AgentPlatform(os string) string {
+	switch strings.ToLower(os) {
+	case "windows":
+		return "windows"
+	case "macos":
+		return "macOS"
+	default:
+		return "linux"
+	}
+}
+
+// PlatformOSVersions returns the operating systems reported by the agents of a platform
+func PlatformOSVersions(platform string) []string {
+	switch platform {
+	case "windows":
+		return []string{"windows"}
+	case "macOS":
+		return []string{"macOS"}
+	case "linux":
+		return LinuxDistributions
+	}
+	return []string{}
+}
+
+// SupportsOS reports if the packages of the source can be installed on an agent operating system
+func SupportsOS(s CatalogSource, os string) bool {
+	return slices.Contains(s.Platforms(), AgentPlatform(os))
+}
+
+// SourceFolder returns the folder where a source stores its index, sources that are not
+// builtin use a folder named after them next to the common software database folder
+func (f CatalogFolders) SourceFolder(name string) string {
+	switch name {
+	case "winget":
+		return f.Winget
+	case "flatpak":
+		return f.Flatpak
+	case "brew":
+		return f.Brew
+	}
+	return filepath.Join(filepath.Dir(f.Common), name)
+}
+
+type wingetSource struct {
+	folder string
+}
+
+func newWingetSource(folders CatalogFolders) CatalogSource {
+	return &wingetSource{folder: folders.SourceFolder("winget")}
+}
+
+func (s *wingetSource) Name() string                   { return "winget" }
+func (s *wingetSource) Platforms() []string            { return []string{"windows"} }
+func (s *wingetSource) RefreshInterval() time.Duration { return 24 * time.Hour }
+
+func (s *wingetSource) Refresh() error {
+	url := "https://cdn.winget.microsoft.com/cache/source2.msix"
+
+	zipPath := filepath.Join(s.folder, "winget.zip")
+	if err := downloadFile(url, zipPath); err != nil {
+		return err
+	}
+	defer os.Remove(zipPath)
+
+	// Open ZIP reader
+	archive, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.Name == "Public/index.db" {
+			src, err := f.Open()
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+
+			return writeFile(src, filepath.Join(s.folder, "index.db"))
+		}
+	}
+
+	return fmt.Errorf("winget source doesn't contain the index database")
+}
+
+func (s *wingetSource) Packages() ([]nats.SoftwarePackage, error) {
+	return querySourceDB(OpenWingetDB, s.folder, `SELECT DISTINCT id, name FROM packages`)
+}
+
+func (s *wingetSource) Search(name string) ([]nats.SoftwarePackage, error) {
+	return SearchAllPackages(name, s.folder)
+}
+
+// PackageDetails uses the normalized publishers, tags and monikers of the winget index,
+// the index doesn't have descriptions, those are only in the package manifests
+func (s *wingetSource) PackageDetails() (map[string]CatalogPackageDetails, error) {
+	db, err := OpenWingetDB(s.folder)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`
+		SELECT p.id,
+			COALESCE((SELECT group_concat(np.norm_publisher, ' ') FROM norm_publishers2_map m JOIN norm_publishers2 np ON np.rowid = m.norm_publisher WHERE m.package = p.rowid), ''),
+			COALESCE((SELECT group_concat(t.tag, ' ') FROM tags2_map m JOIN tags2 t ON t.rowid = m.tag WHERE m.package = p.rowid), ''),
+			COALESCE(p.moniker, '')
+		FROM packages p`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	details := map[string]CatalogPackageDetails{}
+	for rows.Next() {
+		var id, publisher, tags, moniker string
+		if err := rows.Scan(&id, &publisher, &tags, &moniker); err != nil {
+			return nil, err
+		}
+		details[id] = CatalogPackageDetails{Publisher: publisher, Tags: strings.TrimSpace(moniker + " " + tags)}
+	}
+
+	return details, rows.Err()
+}
+
+func (s *wingetSource) LatestVersion(id string) (string, error) {
+	db, err := OpenWingetDB(s.folder)
+	if err != nil {
+		return "", err
+	}
+	defer db.Close()
+
+	version := ""
+	err = db.QueryRow(`SELECT latest_version FROM packages WHERE id = ?`, id).Scan(&version)
+	return version, err
+}
+
+// Versions downloads the version data of the package from the winget CDN, the index
+// only has the latest version of every package
+func (s *wingetSource) Versions(id string) ([]string, error) {
+	db, err := OpenWingetDB(s.folder)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	hash := ""
+	if err := db.QueryRow(`SELECT lower(hex(hash)) FROM packages WHERE id = ?`, id).Scan(&hash); err != nil {
+		return nil, err
+	}
+
+	if len(hash) < 8 {
+		return nil, fmt.Errorf("winget index has no version data hash for %s", id)
+	}
+
+	return downloadWingetVersions(fmt.Sprintf("https://cdn.winget.microsoft.com/cache/packages/%s/%s/versionData.mszyml", url.PathEscape(id), hash[:8]))
+}
+
+type flatpakSource struct {
+	folder string
+}
+
+func newFlatpakSource(folders CatalogFolders) CatalogSource {
+	return &flatpakSource{folder: folders.SourceFolder("flatpak")}
+}
+
+func (s *flatpakSource) Name() string                   { return "flatpak" }
+func (s *flatpakSource) Platforms() []string            { return []string{"linux"} }
+func (s *flatpakSource) RefreshInterval() time.Duration { return 24 * time.Hour }
+func (s *flatpakSource) RetryInterval() time.Duration   { return 2 * time.Minute }
+
+func (s *flatpakSource) Refresh() error {
+	return downloadFile("https://downloads.scnorion.eu/flatpak/flatpak.db", filepath.Join(s.folder, "flatpak.db"))
+}
+
+func (s *flatpakSource) Packages() ([]nats.SoftwarePackage, error) {
+	return querySourceDB(OpenFlatpakDB, s.folder, `SELECT DISTINCT id, name FROM apps`)
+}
+
+func (s *flatpakSource) Search(name string) ([]nats.SoftwarePackage, error) {
+	return SearchAllFlatpakPackages(name, s.folder)
+}
+
+func (s *flatpakSource) PackageDetails() (map[string]CatalogPackageDetails, error) {
+	return querySourceDetails(OpenFlatpakDB, s.folder)
+}
+
+type brewSource struct {
+	folder string
+}
+
+func newBrewSource(folders CatalogFolders) CatalogSource {
+	return &brewSource{folder: folders.SourceFolder("brew")}
+}
+
+func (s *brewSource) Name() string                   { return "brew" }
+func (s *brewSource) Platforms() []string            { return []string{"macOS"} }
+func (s *brewSource) RefreshInterval() time.Duration { return 24 * time.Hour }
+func (s *brewSource) RetryInterval() time.Duration   { return 2 * time.Minute }
+
+func (s *brewSource) Refresh() error {
+	return downloadFile("https://downloads.scnorion.eu/brew/brew.db", filepath.Join(s.folder, "brew.db"))
+}
+
+func (s *brewSource) Packages() ([]nats.SoftwarePackage, error) {
+	return querySourceDB(OpenBrewDB, s.folder, `SELECT DISTINCT id, name FROM apps`)
+}
+
+func (s *brewSource) Search(name string) ([]nats.SoftwarePackage, error) {
+	formulae, err := SearchAllHomeBrewFormulaePackages(name, s.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	casks, err := SearchAllHomeBrewCasksPackages(name, s.folder)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(formulae, casks...), nil
+}
+
+func (s *brewSource) PackageDetails() (map[string]CatalogPackageDetails, error) {
+	return querySourceDetails(OpenBrewDB, s.folder)
+}
+
+// downloadFile writes the file next to its destination first so a failed download
+// doesn't leave a truncated database behind
+func downloadFile(url, dst string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("could not download %s, status: %s", url, resp.Status)
+	}
+
+	return writeFile(resp.Body, dst)
+}
+
+// writeFile copies the contents to a temporary file that replaces the destination once it's complete
+func writeFile(r io.Reader, dst string) error {
+	tmp := dst + ".download"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func querySourceDB(open func(string) (*sql.DB, error), folder, query string) ([]nats.SoftwarePackage, error) {
+	db, err := open(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	return querySourcePackages(db, query)
+}
+
+// querySourceDetails reads the details of the apps table of the flatpak and brew indexes,
+// the columns are optional so an older index only gives us the package names
+func querySourceDetails(open func(string) (*sql.DB, error), folder string) (map[string]CatalogPackageDetails, error) {
+	db, err := open(folder)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT name FROM pragma_table_info('apps')`)
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{}
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		columns = append(columns, strings.ToLower(c))
+	}
+	rows.Close()
+
+	column := func(candidates ...string) string {
+		for _, c := range candidates {
+			if slices.Contains(columns, c) {
+				return "COALESCE(" + c + ", '')"
+			}
+		}
+		return "''"
+	}
+
+	publisher := column("publisher", "developer_name", "developer", "author")
+	tags := column("tags", "keywords", "categories")
+	description := column("description", "summary", "desc")
+	if publisher == "''" && tags == "''" && description == "''" {
+		return map[string]CatalogPackageDetails{}, nil
+	}
+
+	rows, err = db.Query(fmt.Sprintf(`SELECT id, %s, %s, %s FROM apps`, publisher, tags, description))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	details := map[string]CatalogPackageDetails{}
+	for rows.Next() {
+		var id string
+		var d CatalogPackageDetails
+		if err := rows.Scan(&id, &d.Publisher, &d.Tags, &d.Description); err != nil {
+			return nil, err
+		}
+		details[id] = d
+	}
+
+	return details, rows.Err()
+}
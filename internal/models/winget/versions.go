@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// wingetVersionData is the versionData.mszyml file published for every package in the winget CDN
+type wingetVersionData struct {
+	Versions []struct {
+		Version string `yaml:"v"`
+	} `yaml:"vD"`
+}
+
+func downloadWingetVersions(url string) ([]string, error) {
+	client := http.Client{Timeout: 15 * time.Second}
+
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("could not download %s, status: %s", url, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest, err := decodeMSZIP(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not decompress version data, reason: %v", err)
+	}
+
+	versionData := wingetVersionData{}
+	if err := yaml.Unmarshal(manifest, &versionData); err != nil {
+		return nil, fmt.Errorf("could not read version data, reason: %v", err)
+	}
+
+	versions := []string{}
+	for _, v := range versionData.Versions {
+		if v.Version != "" {
+			versions = append(versions, v.Version)
+		}
+	}
+
+	return versions, nil
+}
+
+// decodeMSZIP decompresses a file compressed with the MSZIP algorithm of the Windows compression API.
+// Every block starts with the CK signature and is deflated using the data of the previous blocks as dictionary
+func decodeMSZIP(data []byte) ([]byte, error) {
+	const maxDictSize = 32 * 1024
+	signature := []byte("CK")
+
+	start := bytes.Index(data, signature)
+	if start < 0 {
+		return nil, fmt.Errorf("no MSZIP block has been found")
+	}
+
+	var out bytes.Buffer
+	for start >= 0 {
+		dict := out.Bytes()
+		if len(dict) > maxDictSize {
+			dict = dict[len(dict)-maxDictSize:]
+		}
+
+		// bytes.Reader is a ByteReader so flate doesn't read past the end of the block
+		block := bytes.NewReader(data[start+len(signature):])
+		r := flate.NewReaderDict(block, bytes.Clone(dict))
+		if _, err := io.Copy(&out, r); err != nil {
+			return nil, err
+		}
+		if err := r.Close(); err != nil {
+			return nil, err
+		}
+
+		end := len(data) - block.Len()
+		next := bytes.Index(data[end:], signature)
+		if next < 0 {
+			break
+		}
+		start = end + next
+	}
+
+	return out.Bytes(), nil
+}
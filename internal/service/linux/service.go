@@ -63,6 +63,18 @@ func main() {
 		log.Fatalf("[FATAL]: could not create commondb temp dir: %v", err)
 	}
 
+	// Create vulnerabilities directory for NVD and OSV feeds
+	w.VulnerabilityFeedFolder = "/tmp/vulnerabilities"
+	if err := w.CreateVulnerabilityFeedDir(); err != nil {
+		log.Fatalf("[FATAL]: could not create vulnerabilities temp dir: %v", err)
+	}
+
+	// Create private packages directory for uploaded installers, it must survive reboots
+	w.PrivatePackagesFolder = "/var/lib/scnorion-console/packages"
+	if err := w.CreatePrivatePackagesDir(); err != nil {
+		log.Fatalf("[FATAL]: could not create private packages dir: %v", err)
+	}
+
 	// Create server releases directory
 	w.ServerReleasesFolder = "/tmp/server-releases"
 	if err := w.CreateServerReleasesDir(); err != nil {
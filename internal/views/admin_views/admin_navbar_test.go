@@ -11,9 +11,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-var globalNavbarTests = []string{"users", "sessions", "smtp", "sessions", "settings", "update-servers", "certificates"}
+var globalNavbarTests = []string{"users", "sessions", "smtp", "sessions", "settings", "update-servers", "certificates", "software-catalogue", "packages", "mirror", "vulnerabilities"}
 
-var tenantNavbarTests = []string{"tags", "metadata", "settings", "update-agents"}
+var tenantNavbarTests = []string{"tags", "site-rules", "maintenance-windows", "change-control", "metadata", "settings", "update-agents"}
 
 func TestTenantConfigNavbarTabs(t *testing.T) {
 	config := partials.CommonInfo{TenantID: "1"}
@@ -0,0 +1,49 @@
+package charts
+
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/go-echarts/go-echarts/v2/charts"
+	"github.com/go-echarts/go-echarts/v2/opts"
+	"github.com/go-echarts/go-echarts/v2/render"
+	"github.com/invopop/ctxi18n/i18n"
+	ent "github.com/scncore/ent"
+)
+
+func DiskFreeSpaceHistory(ctx context.Context, history map[string][]*ent.LogicalDiskSnapshot) render.ChartSnippet {
+	line := charts.NewLine()
+
+	labels := []string{}
+	for label := range history {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	for _, label := range labels {
+		lineData := []opts.LineData{}
+		for _, s := range history[label] {
+			// free space in GB with two decimals
+			freeSpace := math.Round(float64(s.RemainingSpaceInBytes)/(1024*1024*1024)*100) / 100
+			lineData = append(lineData, opts.LineData{Value: []interface{}{s.Created.Format("2006-01-02 15:04"), freeSpace}})
+		}
+		line.AddSeries(label, lineData)
+	}
+
+	labelStyle := opts.TextStyle{Color: "#777"}
+
+	line.SetGlobalOptions(
+		charts.WithTitleOpts(opts.Title{Show: opts.Bool(false)}),
+		charts.WithTooltipOpts(opts.Tooltip{Show: opts.Bool(true), Trigger: "axis"}),
+		charts.WithLegendOpts(opts.Legend{Show: opts.Bool(true), TextStyle: &labelStyle, Type: "scroll"}),
+		charts.WithXAxisOpts(opts.XAxis{Type: "time"}),
+		charts.WithYAxisOpts(opts.YAxis{Name: i18n.T(ctx, "charts.free_space_gb"), Min: 0}),
+		charts.WithInitializationOpts(opts.Initialization{
+			Width:  "900px",
+			Height: "300px",
+		}),
+	)
+
+	return line.RenderSnippet()
+}
@@ -16,6 +16,7 @@ type AgentFilter struct {
 	ComputerManufacturers []string
 	ComputerModels        []string
 	Username              string
+	LoggedOnUser          string
 	ContactFrom           string
 	ContactTo             string
 	WithApplication       string
@@ -122,6 +123,11 @@ type DeployPackageFilter struct {
 	Sources []string
 }
 
+type SubnetFilter struct {
+	CIDR  string
+	Sites []string
+}
+
 func GetPaginationUrl(c echo.Context) string {
 	// If Hx-Replace-Url is set in the header that means that we come from a dialog
 	// and that we force to go to page 1, to avoid going to a non-existent page